@@ -15,6 +15,7 @@ import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
@@ -85,6 +86,8 @@ const (
 	SotrRequest_FIO    SotrRequest_DBField = 2
 	SotrRequest_TABNUM SotrRequest_DBField = 3
 	SotrRequest_IDR    SotrRequest_DBField = 4
+	// employees of the dep by its idr
+	SotrRequest_PARENT SotrRequest_DBField = 5
 )
 
 // Enum value maps for SotrRequest_DBField.
@@ -95,6 +98,7 @@ var (
 		2: "FIO",
 		3: "TABNUM",
 		4: "IDR",
+		5: "PARENT",
 	}
 	SotrRequest_DBField_value = map[string]int32{
 		"NONE":   0,
@@ -102,6 +106,7 @@ var (
 		"FIO":    2,
 		"TABNUM": 3,
 		"IDR":    4,
+		"PARENT": 5,
 	}
 )
 
@@ -415,7 +420,11 @@ type Sotr struct {
 	Children bool                   `protobuf:"varint,11,opt,name=children,proto3" json:"children,omitempty"`
 	ParentId string                 `protobuf:"bytes,12,opt,name=parent_id,json=parentId,proto3" json:"parent_id,omitempty"`
 	// repeated History hist = 13[ json_name = "history" ];
-	Date          *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=date,proto3" json:"date,omitempty"`
+	Date *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=date,proto3" json:"date,omitempty"`
+	// attributes of HR exports merged by the import, they are kept when the dump has no ones
+	HireDate      *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=hire_date,json=hireDate,proto3" json:"hire_date,omitempty"`
+	Position      string                 `protobuf:"bytes,16,opt,name=position,proto3" json:"position,omitempty"`
+	ManagerTabnum string                 `protobuf:"bytes,17,opt,name=manager_tabnum,json=managerTabnum,proto3" json:"manager_tabnum,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -541,6 +550,27 @@ func (x *Sotr) GetDate() *timestamppb.Timestamp {
 	return nil
 }
 
+func (x *Sotr) GetHireDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.HireDate
+	}
+	return nil
+}
+
+func (x *Sotr) GetPosition() string {
+	if x != nil {
+		return x.Position
+	}
+	return ""
+}
+
+func (x *Sotr) GetManagerTabnum() string {
+	if x != nil {
+		return x.ManagerTabnum
+	}
+	return ""
+}
+
 type History struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Date          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
@@ -780,9 +810,12 @@ func (x *HistoryListResponse) GetHistoryList() []*History {
 }
 
 type UpdateSotrRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Sotr          *Sotr                  `protobuf:"bytes,1,opt,name=sotr,proto3" json:"sotr,omitempty"`
-	HistoryList   []*History             `protobuf:"bytes,2,rep,name=history_list,json=historyList,proto3" json:"history_list,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Sotr        *Sotr                  `protobuf:"bytes,1,opt,name=sotr,proto3" json:"sotr,omitempty"`
+	HistoryList []*History             `protobuf:"bytes,2,rep,name=history_list,json=historyList,proto3" json:"history_list,omitempty"`
+	// attributes of HR exports cleared by the update: hire_date, position, manager_tabnum.
+	// Empty attributes of the sotr keep saved values otherwise.
+	Clear         *fieldmaskpb.FieldMask `protobuf:"bytes,3,opt,name=clear,proto3" json:"clear,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -831,85 +864,1006 @@ func (x *UpdateSotrRequest) GetHistoryList() []*History {
 	return nil
 }
 
-var File_stor_proto protoreflect.FileDescriptor
+func (x *UpdateSotrRequest) GetClear() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.Clear
+	}
+	return nil
+}
 
-const file_stor_proto_rawDesc = "" +
-	"\n" +
-	"\n" +
-	"stor.proto\x12\x05kb.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17validate/validate.proto\"o\n" +
-	"\x03Dep\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x10\n" +
-	"\x03idr\x18\x02 \x01(\tR\x03idr\x12\x16\n" +
-	"\x06parent\x18\x03 \x01(\tR\x06parent\x12\x12\n" +
-	"\x04text\x18\x04 \x01(\tR\x04text\x12\x1a\n" +
-	"\bchildren\x18\x05 \x01(\bR\bchildren\".\n" +
-	"\fDepsResponse\x12\x1e\n" +
-	"\x04deps\x18\x01 \x03(\v2\n" +
-	".kb.v1.DepR\x04deps\"y\n" +
-	"\n" +
-	"DepRequest\x12\x10\n" +
-	"\x03str\x18\x01 \x01(\tR\x03str\x12/\n" +
-	"\x05field\x18\x02 \x01(\x0e2\x19.kb.v1.DepRequest.DBFieldR\x05field\"(\n" +
-	"\aDBField\x12\b\n" +
-	"\x04NONE\x10\x00\x12\a\n" +
-	"\x03IDR\x10\x01\x12\n" +
-	"\n" +
-	"\x06PARENT\x10\x04\"\x90\x01\n" +
-	"\vSotrRequest\x12\x10\n" +
-	"\x03str\x18\x01 \x01(\tR\x03str\x120\n" +
-	"\x05field\x18\x02 \x01(\x0e2\x1a.kb.v1.SotrRequest.DBFieldR\x05field\"=\n" +
-	"\aDBField\x12\b\n" +
-	"\x04NONE\x10\x00\x12\n" +
-	"\n" +
-	"\x06MOBILE\x10\x01\x12\a\n" +
-	"\x03FIO\x10\x02\x12\n" +
-	"\n" +
-	"\x06TABNUM\x10\x03\x12\a\n" +
-	"\x03IDR\x10\x04\"&\n" +
-	"\vHistRequest\x12\x17\n" +
-	"\asotr_id\x18\x01 \x01(\tR\x06sotrId\"\xd6\x02\n" +
-	"\x04Sotr\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x10\n" +
-	"\x03idr\x18\x02 \x01(\tR\x03idr\x12\x16\n" +
-	"\x06tabnum\x18\x03 \x01(\tR\x06tabnum\x12\x12\n" +
-	"\x04name\x18\x04 \x01(\tR\x04name\x12\x19\n" +
-	"\bmid_name\x18\x05 \x01(\tR\amidName\x12\x14\n" +
-	"\x05phone\x18\x06 \x03(\tR\x05phone\x12\x16\n" +
-	"\x06mobile\x18\a \x03(\tR\x06mobile\x12 \n" +
-	"\x05email\x18\b \x01(\tB\n" +
-	"\xfaB\ar\x05\xd0\x01\x01`\x01R\x05email\x12\x16\n" +
-	"\x06avatar\x18\t \x01(\tR\x06avatar\x12\x14\n" +
-	"\x05grade\x18\n" +
-	" \x01(\tR\x05grade\x12\x1a\n" +
-	"\bchildren\x18\v \x01(\bR\bchildren\x12\x1b\n" +
-	"\tparent_id\x18\f \x01(\tR\bparentId\x12.\n" +
-	"\x04date\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\x04date\"\x87\x01\n" +
-	"\aHistory\x12.\n" +
-	"\x04date\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x12\x14\n" +
-	"\x05field\x18\x02 \x01(\tR\x05field\x12\x1b\n" +
-	"\told_value\x18\x03 \x01(\tR\boldValue\x12\x19\n" +
-	"\asotr_id\x18\x04 \x01(\x04R\bsotr_uid\"2\n" +
-	"\rSotrsResponse\x12!\n" +
-	"\x05sotrs\x18\x01 \x03(\v2\v.kb.v1.SotrR\x05sotrs\"P\n" +
-	"\x04Item\x12\x1e\n" +
-	"\x03dep\x18\x01 \x01(\v2\n" +
-	".kb.v1.DepH\x00R\x03dep\x12!\n" +
-	"\x04sotr\x18\x02 \x01(\v2\v.kb.v1.SotrH\x00R\x04sotrB\x05\n" +
-	"\x03var\"H\n" +
-	"\x13HistoryListResponse\x121\n" +
-	"\fhistory_list\x18\x01 \x03(\v2\x0e.kb.v1.HistoryR\vhistoryList\"g\n" +
-	"\x11UpdateSotrRequest\x12\x1f\n" +
-	"\x04sotr\x18\x01 \x01(\v2\v.kb.v1.SotrR\x04sotr\x121\n" +
-	"\fhistory_list\x18\x02 \x03(\v2\x0e.kb.v1.HistoryR\vhistoryList2\xc6\x04\n" +
-	"\aStorAPI\x12[\n" +
-	"\tGetDepsBy\x12\x11.kb.v1.DepRequest\x1a\x13.kb.v1.DepsResponse\"&\x82\xd3\xe4\x93\x02 \x12\x1e/api/stor/v1/dep/{field}/{str}\x12c\n" +
-	"\n" +
-	"GetSotrsBy\x12\x12.kb.v1.SotrRequest\x1a\x14.kb.v1.SotrsResponse\"+\x82\xd3\xe4\x93\x02%\x12#/api/stor/v1/employee/{field}/{str}\x12V\n" +
-	"\x05Flush\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\"\x1d\x82\xd3\xe4\x93\x02\x17:\x01*\"\x12/api/stor/v1/flush\x12a\n" +
-	"\x04Save\x12\v.kb.v1.Item\x1a\x16.google.protobuf.Empty\"4\x82\xd3\xe4\x93\x02.:\x01*Z\x16:\x01*\x1a\x11/api/stor/v1/save\"\x11/api/stor/v1/save\x12X\n" +
-	"\x06Update\x12\x18.kb.v1.UpdateSotrRequest\x1a\x16.google.protobuf.Empty\"\x1c\x82\xd3\xe4\x93\x02\x16:\x01*2\x11/api/stor/v1/save\x12d\n" +
+type AvatarRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Tabnum string                 `protobuf:"bytes,1,opt,name=tabnum,proto3" json:"tabnum,omitempty"`
+	// etag of the image known by the client, the image is not returned if it's not changed
+	IfNoneMatch string `protobuf:"bytes,2,opt,name=if_none_match,json=ifNoneMatch,proto3" json:"if_none_match,omitempty"`
+	// size of the thumbnail in px, the original image if it's 0
+	Size uint32 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	// format of the thumbnail: jpeg (by default) or webp
+	Format        string `protobuf:"bytes,4,opt,name=format,proto3" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AvatarRequest) Reset() {
+	*x = AvatarRequest{}
+	mi := &file_stor_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AvatarRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AvatarRequest) ProtoMessage() {}
+
+func (x *AvatarRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AvatarRequest.ProtoReflect.Descriptor instead.
+func (*AvatarRequest) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AvatarRequest) GetTabnum() string {
+	if x != nil {
+		return x.Tabnum
+	}
+	return ""
+}
+
+func (x *AvatarRequest) GetIfNoneMatch() string {
+	if x != nil {
+		return x.IfNoneMatch
+	}
+	return ""
+}
+
+func (x *AvatarRequest) GetSize() uint32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *AvatarRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+// Avatar is the image of the employee stored by hash of the content
+type Avatar struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tabnum        string                 `protobuf:"bytes,1,opt,name=tabnum,proto3" json:"tabnum,omitempty"`
+	Hash          string                 `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	ContentType   string                 `protobuf:"bytes,4,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	FirstSeen     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"`
+	LastSeen      *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Avatar) Reset() {
+	*x = Avatar{}
+	mi := &file_stor_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Avatar) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Avatar) ProtoMessage() {}
+
+func (x *Avatar) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Avatar.ProtoReflect.Descriptor instead.
+func (*Avatar) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Avatar) GetTabnum() string {
+	if x != nil {
+		return x.Tabnum
+	}
+	return ""
+}
+
+func (x *Avatar) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *Avatar) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Avatar) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Avatar) GetFirstSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return nil
+}
+
+func (x *Avatar) GetLastSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastSeen
+	}
+	return nil
+}
+
+type AvatarResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Avatar      *Avatar                `protobuf:"bytes,1,opt,name=avatar,proto3" json:"avatar,omitempty"`
+	Data        []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	NotModified bool                   `protobuf:"varint,3,opt,name=not_modified,json=notModified,proto3" json:"not_modified,omitempty"`
+	// etag and content type of the returned image, the original or the thumbnail
+	Etag          string `protobuf:"bytes,4,opt,name=etag,proto3" json:"etag,omitempty"`
+	ContentType   string `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AvatarResponse) Reset() {
+	*x = AvatarResponse{}
+	mi := &file_stor_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AvatarResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AvatarResponse) ProtoMessage() {}
+
+func (x *AvatarResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AvatarResponse.ProtoReflect.Descriptor instead.
+func (*AvatarResponse) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AvatarResponse) GetAvatar() *Avatar {
+	if x != nil {
+		return x.Avatar
+	}
+	return nil
+}
+
+func (x *AvatarResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *AvatarResponse) GetNotModified() bool {
+	if x != nil {
+		return x.NotModified
+	}
+	return false
+}
+
+func (x *AvatarResponse) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+func (x *AvatarResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+// News is the news of the intranet
+type News struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// id of the news in the source
+	Idn    string `protobuf:"bytes,2,opt,name=idn,proto3" json:"idn,omitempty"`
+	Title  string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Author string `protobuf:"bytes,4,opt,name=author,proto3" json:"author,omitempty"`
+	// tabnum of the author if the author is found among employees
+	AuthorTabnum  string                 `protobuf:"bytes,5,opt,name=author_tabnum,json=authorTabnum,proto3" json:"author_tabnum,omitempty"`
+	Date          *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=date,proto3" json:"date,omitempty"`
+	Body          string                 `protobuf:"bytes,7,opt,name=body,proto3" json:"body,omitempty"`
+	Url           string                 `protobuf:"bytes,8,opt,name=url,proto3" json:"url,omitempty"`
+	Comments      []*Comment             `protobuf:"bytes,9,rep,name=comments,proto3" json:"comments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *News) Reset() {
+	*x = News{}
+	mi := &file_stor_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *News) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*News) ProtoMessage() {}
+
+func (x *News) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use News.ProtoReflect.Descriptor instead.
+func (*News) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *News) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *News) GetIdn() string {
+	if x != nil {
+		return x.Idn
+	}
+	return ""
+}
+
+func (x *News) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *News) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *News) GetAuthorTabnum() string {
+	if x != nil {
+		return x.AuthorTabnum
+	}
+	return ""
+}
+
+func (x *News) GetDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Date
+	}
+	return nil
+}
+
+func (x *News) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *News) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *News) GetComments() []*Comment {
+	if x != nil {
+		return x.Comments
+	}
+	return nil
+}
+
+// Comment is the comment of the news, replies refer to the parent comment
+type Comment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id of the comment in the source
+	Idc       string `protobuf:"bytes,1,opt,name=idc,proto3" json:"idc,omitempty"`
+	ParentIdc string `protobuf:"bytes,2,opt,name=parent_idc,json=parentIdc,proto3" json:"parent_idc,omitempty"`
+	Author    string `protobuf:"bytes,3,opt,name=author,proto3" json:"author,omitempty"`
+	// tabnum of the author if the author is found among employees
+	AuthorTabnum  string                 `protobuf:"bytes,4,opt,name=author_tabnum,json=authorTabnum,proto3" json:"author_tabnum,omitempty"`
+	Date          *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=date,proto3" json:"date,omitempty"`
+	Text          string                 `protobuf:"bytes,6,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Comment) Reset() {
+	*x = Comment{}
+	mi := &file_stor_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Comment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Comment) ProtoMessage() {}
+
+func (x *Comment) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Comment.ProtoReflect.Descriptor instead.
+func (*Comment) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Comment) GetIdc() string {
+	if x != nil {
+		return x.Idc
+	}
+	return ""
+}
+
+func (x *Comment) GetParentIdc() string {
+	if x != nil {
+		return x.ParentIdc
+	}
+	return ""
+}
+
+func (x *Comment) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Comment) GetAuthorTabnum() string {
+	if x != nil {
+		return x.AuthorTabnum
+	}
+	return ""
+}
+
+func (x *Comment) GetDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Date
+	}
+	return nil
+}
+
+func (x *Comment) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type ListNewsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// news of the author only
+	AuthorTabnum string                 `protobuf:"bytes,1,opt,name=author_tabnum,json=authorTabnum,proto3" json:"author_tabnum,omitempty"`
+	Since        *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=since,proto3" json:"since,omitempty"`
+	Until        *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=until,proto3" json:"until,omitempty"`
+	// substring of the title
+	Query         string `protobuf:"bytes,4,opt,name=query,proto3" json:"query,omitempty"`
+	Limit         uint32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        uint32 `protobuf:"varint,6,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListNewsRequest) Reset() {
+	*x = ListNewsRequest{}
+	mi := &file_stor_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListNewsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListNewsRequest) ProtoMessage() {}
+
+func (x *ListNewsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListNewsRequest.ProtoReflect.Descriptor instead.
+func (*ListNewsRequest) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ListNewsRequest) GetAuthorTabnum() string {
+	if x != nil {
+		return x.AuthorTabnum
+	}
+	return ""
+}
+
+func (x *ListNewsRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *ListNewsRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *ListNewsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ListNewsRequest) GetLimit() uint32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListNewsRequest) GetOffset() uint32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type NewsListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	News          []*News                `protobuf:"bytes,1,rep,name=news,proto3" json:"news,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NewsListResponse) Reset() {
+	*x = NewsListResponse{}
+	mi := &file_stor_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewsListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewsListResponse) ProtoMessage() {}
+
+func (x *NewsListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewsListResponse.ProtoReflect.Descriptor instead.
+func (*NewsListResponse) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *NewsListResponse) GetNews() []*News {
+	if x != nil {
+		return x.News
+	}
+	return nil
+}
+
+type NewsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Idn           string                 `protobuf:"bytes,1,opt,name=idn,proto3" json:"idn,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NewsRequest) Reset() {
+	*x = NewsRequest{}
+	mi := &file_stor_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewsRequest) ProtoMessage() {}
+
+func (x *NewsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewsRequest.ProtoReflect.Descriptor instead.
+func (*NewsRequest) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *NewsRequest) GetIdn() string {
+	if x != nil {
+		return x.Idn
+	}
+	return ""
+}
+
+type ReportsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Tabnum string                 `protobuf:"bytes,1,opt,name=tabnum,proto3" json:"tabnum,omitempty"`
+	// reports of all levels, direct reports only by default
+	Recursive     bool `protobuf:"varint,2,opt,name=recursive,proto3" json:"recursive,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportsRequest) Reset() {
+	*x = ReportsRequest{}
+	mi := &file_stor_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportsRequest) ProtoMessage() {}
+
+func (x *ReportsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportsRequest.ProtoReflect.Descriptor instead.
+func (*ReportsRequest) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ReportsRequest) GetTabnum() string {
+	if x != nil {
+		return x.Tabnum
+	}
+	return ""
+}
+
+func (x *ReportsRequest) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+type ManagerChainRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tabnum        string                 `protobuf:"bytes,1,opt,name=tabnum,proto3" json:"tabnum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ManagerChainRequest) Reset() {
+	*x = ManagerChainRequest{}
+	mi := &file_stor_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ManagerChainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ManagerChainRequest) ProtoMessage() {}
+
+func (x *ManagerChainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ManagerChainRequest.ProtoReflect.Descriptor instead.
+func (*ManagerChainRequest) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ManagerChainRequest) GetTabnum() string {
+	if x != nil {
+		return x.Tabnum
+	}
+	return ""
+}
+
+type VersionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SotrIds       []uint64               `protobuf:"varint,1,rep,packed,name=sotr_ids,json=sotrIds,proto3" json:"sotr_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VersionsRequest) Reset() {
+	*x = VersionsRequest{}
+	mi := &file_stor_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VersionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionsRequest) ProtoMessage() {}
+
+func (x *VersionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionsRequest.ProtoReflect.Descriptor instead.
+func (*VersionsRequest) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *VersionsRequest) GetSotrIds() []uint64 {
+	if x != nil {
+		return x.SotrIds
+	}
+	return nil
+}
+
+type VersionsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the time of the latest change by id of the employee, employees without history are absent
+	Versions      map[uint64]*timestamppb.Timestamp `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VersionsResponse) Reset() {
+	*x = VersionsResponse{}
+	mi := &file_stor_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VersionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionsResponse) ProtoMessage() {}
+
+func (x *VersionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionsResponse.ProtoReflect.Descriptor instead.
+func (*VersionsResponse) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *VersionsResponse) GetVersions() map[uint64]*timestamppb.Timestamp {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+type GenerationResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Generation    int64                  `protobuf:"varint,1,opt,name=generation,proto3" json:"generation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GenerationResponse) Reset() {
+	*x = GenerationResponse{}
+	mi := &file_stor_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GenerationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GenerationResponse) ProtoMessage() {}
+
+func (x *GenerationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stor_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GenerationResponse.ProtoReflect.Descriptor instead.
+func (*GenerationResponse) Descriptor() ([]byte, []int) {
+	return file_stor_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *GenerationResponse) GetGeneration() int64 {
+	if x != nil {
+		return x.Generation
+	}
+	return 0
+}
+
+var File_stor_proto protoreflect.FileDescriptor
+
+const file_stor_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"stor.proto\x12\x05kb.v1\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1cgoogle/api/annotations.proto\x1a google/protobuf/field_mask.proto\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x17validate/validate.proto\"o\n" +
+	"\x03Dep\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x10\n" +
+	"\x03idr\x18\x02 \x01(\tR\x03idr\x12\x16\n" +
+	"\x06parent\x18\x03 \x01(\tR\x06parent\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12\x1a\n" +
+	"\bchildren\x18\x05 \x01(\bR\bchildren\".\n" +
+	"\fDepsResponse\x12\x1e\n" +
+	"\x04deps\x18\x01 \x03(\v2\n" +
+	".kb.v1.DepR\x04deps\"y\n" +
+	"\n" +
+	"DepRequest\x12\x10\n" +
+	"\x03str\x18\x01 \x01(\tR\x03str\x12/\n" +
+	"\x05field\x18\x02 \x01(\x0e2\x19.kb.v1.DepRequest.DBFieldR\x05field\"(\n" +
+	"\aDBField\x12\b\n" +
+	"\x04NONE\x10\x00\x12\a\n" +
+	"\x03IDR\x10\x01\x12\n" +
+	"\n" +
+	"\x06PARENT\x10\x04\"\x9c\x01\n" +
+	"\vSotrRequest\x12\x10\n" +
+	"\x03str\x18\x01 \x01(\tR\x03str\x120\n" +
+	"\x05field\x18\x02 \x01(\x0e2\x1a.kb.v1.SotrRequest.DBFieldR\x05field\"I\n" +
+	"\aDBField\x12\b\n" +
+	"\x04NONE\x10\x00\x12\n" +
+	"\n" +
+	"\x06MOBILE\x10\x01\x12\a\n" +
+	"\x03FIO\x10\x02\x12\n" +
+	"\n" +
+	"\x06TABNUM\x10\x03\x12\a\n" +
+	"\x03IDR\x10\x04\x12\n" +
+	"\n" +
+	"\x06PARENT\x10\x05\"&\n" +
+	"\vHistRequest\x12\x17\n" +
+	"\asotr_id\x18\x01 \x01(\tR\x06sotrId\"\xd2\x03\n" +
+	"\x04Sotr\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x10\n" +
+	"\x03idr\x18\x02 \x01(\tR\x03idr\x12\x16\n" +
+	"\x06tabnum\x18\x03 \x01(\tR\x06tabnum\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x19\n" +
+	"\bmid_name\x18\x05 \x01(\tR\amidName\x12\x14\n" +
+	"\x05phone\x18\x06 \x03(\tR\x05phone\x12\x16\n" +
+	"\x06mobile\x18\a \x03(\tR\x06mobile\x12 \n" +
+	"\x05email\x18\b \x01(\tB\n" +
+	"\xfaB\ar\x05\xd0\x01\x01`\x01R\x05email\x12\x16\n" +
+	"\x06avatar\x18\t \x01(\tR\x06avatar\x12\x14\n" +
+	"\x05grade\x18\n" +
+	" \x01(\tR\x05grade\x12\x1a\n" +
+	"\bchildren\x18\v \x01(\bR\bchildren\x12\x1b\n" +
+	"\tparent_id\x18\f \x01(\tR\bparentId\x12.\n" +
+	"\x04date\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x127\n" +
+	"\thire_date\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\bhireDate\x12\x1a\n" +
+	"\bposition\x18\x10 \x01(\tR\bposition\x12%\n" +
+	"\x0emanager_tabnum\x18\x11 \x01(\tR\rmanagerTabnum\"\x87\x01\n" +
+	"\aHistory\x12.\n" +
+	"\x04date\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12\x1b\n" +
+	"\told_value\x18\x03 \x01(\tR\boldValue\x12\x19\n" +
+	"\asotr_id\x18\x04 \x01(\x04R\bsotr_uid\"2\n" +
+	"\rSotrsResponse\x12!\n" +
+	"\x05sotrs\x18\x01 \x03(\v2\v.kb.v1.SotrR\x05sotrs\"P\n" +
+	"\x04Item\x12\x1e\n" +
+	"\x03dep\x18\x01 \x01(\v2\n" +
+	".kb.v1.DepH\x00R\x03dep\x12!\n" +
+	"\x04sotr\x18\x02 \x01(\v2\v.kb.v1.SotrH\x00R\x04sotrB\x05\n" +
+	"\x03var\"H\n" +
+	"\x13HistoryListResponse\x121\n" +
+	"\fhistory_list\x18\x01 \x03(\v2\x0e.kb.v1.HistoryR\vhistoryList\"\x99\x01\n" +
+	"\x11UpdateSotrRequest\x12\x1f\n" +
+	"\x04sotr\x18\x01 \x01(\v2\v.kb.v1.SotrR\x04sotr\x121\n" +
+	"\fhistory_list\x18\x02 \x03(\v2\x0e.kb.v1.HistoryR\vhistoryList\x120\n" +
+	"\x05clear\x18\x03 \x01(\v2\x1a.google.protobuf.FieldMaskR\x05clear\"\x9f\x01\n" +
+	"\rAvatarRequest\x12\x1f\n" +
+	"\x06tabnum\x18\x01 \x01(\tB\a\xfaB\x04r\x02\x10\x01R\x06tabnum\x12\"\n" +
+	"\rif_none_match\x18\x02 \x01(\tR\vifNoneMatch\x12\x1c\n" +
+	"\x04size\x18\x03 \x01(\rB\b\xfaB\x05*\x03\x18\x80 R\x04size\x12+\n" +
+	"\x06format\x18\x04 \x01(\tB\x13\xfaB\x10r\x0eR\x00R\x04jpegR\x04webpR\x06format\"\xdf\x01\n" +
+	"\x06Avatar\x12\x16\n" +
+	"\x06tabnum\x18\x01 \x01(\tR\x06tabnum\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\tR\x04hash\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12!\n" +
+	"\fcontent_type\x18\x04 \x01(\tR\vcontentType\x129\n" +
+	"\n" +
+	"first_seen\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tfirstSeen\x127\n" +
+	"\tlast_seen\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\blastSeen\"\xa5\x01\n" +
+	"\x0eAvatarResponse\x12%\n" +
+	"\x06avatar\x18\x01 \x01(\v2\r.kb.v1.AvatarR\x06avatar\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12!\n" +
+	"\fnot_modified\x18\x03 \x01(\bR\vnotModified\x12\x12\n" +
+	"\x04etag\x18\x04 \x01(\tR\x04etag\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\"\xfd\x01\n" +
+	"\x04News\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x10\n" +
+	"\x03idn\x18\x02 \x01(\tR\x03idn\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12\x16\n" +
+	"\x06author\x18\x04 \x01(\tR\x06author\x12#\n" +
+	"\rauthor_tabnum\x18\x05 \x01(\tR\fauthorTabnum\x12.\n" +
+	"\x04date\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x12\x12\n" +
+	"\x04body\x18\a \x01(\tR\x04body\x12\x10\n" +
+	"\x03url\x18\b \x01(\tR\x03url\x12*\n" +
+	"\bcomments\x18\t \x03(\v2\x0e.kb.v1.CommentR\bcomments\"\xbb\x01\n" +
+	"\aComment\x12\x10\n" +
+	"\x03idc\x18\x01 \x01(\tR\x03idc\x12\x1d\n" +
+	"\n" +
+	"parent_idc\x18\x02 \x01(\tR\tparentIdc\x12\x16\n" +
+	"\x06author\x18\x03 \x01(\tR\x06author\x12#\n" +
+	"\rauthor_tabnum\x18\x04 \x01(\tR\fauthorTabnum\x12.\n" +
+	"\x04date\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x12\x12\n" +
+	"\x04text\x18\x06 \x01(\tR\x04text\"\xe8\x01\n" +
+	"\x0fListNewsRequest\x12#\n" +
+	"\rauthor_tabnum\x18\x01 \x01(\tR\fauthorTabnum\x120\n" +
+	"\x05since\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x14\n" +
+	"\x05query\x18\x04 \x01(\tR\x05query\x12\x1e\n" +
+	"\x05limit\x18\x05 \x01(\rB\b\xfaB\x05*\x03\x18\xe8\aR\x05limit\x12\x16\n" +
+	"\x06offset\x18\x06 \x01(\rR\x06offset\"3\n" +
+	"\x10NewsListResponse\x12\x1f\n" +
+	"\x04news\x18\x01 \x03(\v2\v.kb.v1.NewsR\x04news\"(\n" +
+	"\vNewsRequest\x12\x19\n" +
+	"\x03idn\x18\x01 \x01(\tB\a\xfaB\x04r\x02\x10\x01R\x03idn\"O\n" +
+	"\x0eReportsRequest\x12\x1f\n" +
+	"\x06tabnum\x18\x01 \x01(\tB\a\xfaB\x04r\x02\x10\x01R\x06tabnum\x12\x1c\n" +
+	"\trecursive\x18\x02 \x01(\bR\trecursive\"6\n" +
+	"\x13ManagerChainRequest\x12\x1f\n" +
+	"\x06tabnum\x18\x01 \x01(\tB\a\xfaB\x04r\x02\x10\x01R\x06tabnum\",\n" +
+	"\x0fVersionsRequest\x12\x19\n" +
+	"\bsotr_ids\x18\x01 \x03(\x04R\asotrIds\"\xae\x01\n" +
+	"\x10VersionsResponse\x12A\n" +
+	"\bversions\x18\x01 \x03(\v2%.kb.v1.VersionsResponse.VersionsEntryR\bversions\x1aW\n" +
+	"\rVersionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x04R\x03key\x120\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05value:\x028\x01\"4\n" +
+	"\x12GenerationResponse\x12\x1e\n" +
+	"\n" +
+	"generation\x18\x01 \x01(\x03R\n" +
+	"generation2\xb4\t\n" +
+	"\aStorAPI\x12[\n" +
+	"\tGetDepsBy\x12\x11.kb.v1.DepRequest\x1a\x13.kb.v1.DepsResponse\"&\x82\xd3\xe4\x93\x02 \x12\x1e/api/stor/v1/dep/{field}/{str}\x12c\n" +
+	"\n" +
+	"GetSotrsBy\x12\x12.kb.v1.SotrRequest\x1a\x14.kb.v1.SotrsResponse\"+\x82\xd3\xe4\x93\x02%\x12#/api/stor/v1/employee/{field}/{str}\x12V\n" +
+	"\x05Flush\x12\x16.google.protobuf.Empty\x1a\x16.google.protobuf.Empty\"\x1d\x82\xd3\xe4\x93\x02\x17:\x01*\"\x12/api/stor/v1/flush\x12a\n" +
+	"\x04Save\x12\v.kb.v1.Item\x1a\x16.google.protobuf.Empty\"4\x82\xd3\xe4\x93\x02.:\x01*Z\x16:\x01*\x1a\x11/api/stor/v1/save\"\x11/api/stor/v1/save\x124\n" +
+	"\tSaveItems\x12\v.kb.v1.Item\x1a\x16.google.protobuf.Empty\"\x00(\x01\x12X\n" +
+	"\x06Update\x12\x18.kb.v1.UpdateSotrRequest\x1a\x16.google.protobuf.Empty\"\x1c\x82\xd3\xe4\x93\x02\x16:\x01*2\x11/api/stor/v1/save\x12d\n" +
+	"\n" +
+	"GetHistory\x12\x12.kb.v1.HistRequest\x1a\x1a.kb.v1.HistoryListResponse\"&\x82\xd3\xe4\x93\x02 \x12\x1e/api/stor/v1/history/{sotr_id}\x12:\n" +
+	"\tGetAvatar\x12\x14.kb.v1.AvatarRequest\x1a\x15.kb.v1.AvatarResponse\"\x00\x12V\n" +
+	"\bListNews\x12\x16.kb.v1.ListNewsRequest\x1a\x17.kb.v1.NewsListResponse\"\x19\x82\xd3\xe4\x93\x02\x13\x12\x11/api/stor/v1/news\x12K\n" +
+	"\aGetNews\x12\x12.kb.v1.NewsRequest\x1a\v.kb.v1.News\"\x1f\x82\xd3\xe4\x93\x02\x19\x12\x17/api/stor/v1/news/{idn}\x12`\n" +
 	"\n" +
-	"GetHistory\x12\x12.kb.v1.HistRequest\x1a\x1a.kb.v1.HistoryListResponse\"&\x82\xd3\xe4\x93\x02 \x12\x1e/api/stor/v1/history/{sotr_id}B-Z+github.com/mioxin/kbempgo/api/kbemp/v1;kbv1b\x06proto3"
+	"GetReports\x12\x15.kb.v1.ReportsRequest\x1a\x14.kb.v1.SotrsResponse\"%\x82\xd3\xe4\x93\x02\x1f\x12\x1d/api/stor/v1/reports/{tabnum}\x12k\n" +
+	"\x0fGetManagerChain\x12\x1a.kb.v1.ManagerChainRequest\x1a\x14.kb.v1.SotrsResponse\"&\x82\xd3\xe4\x93\x02 \x12\x1e/api/stor/v1/managers/{tabnum}\x12@\n" +
+	"\vGetVersions\x12\x16.kb.v1.VersionsRequest\x1a\x17.kb.v1.VersionsResponse\"\x00\x12D\n" +
+	"\rGetGeneration\x12\x16.google.protobuf.Empty\x1a\x19.kb.v1.GenerationResponse\"\x00B-Z+github.com/mioxin/kbempgo/api/kbemp/v1;kbv1b\x06proto3"
 
 var (
 	file_stor_proto_rawDescOnce sync.Once
@@ -924,7 +1878,7 @@ func file_stor_proto_rawDescGZIP() []byte {
 }
 
 var file_stor_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_stor_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_stor_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_stor_proto_goTypes = []any{
 	(DepRequest_DBField)(0),       // 0: kb.v1.DepRequest.DBField
 	(SotrRequest_DBField)(0),      // 1: kb.v1.SotrRequest.DBField
@@ -939,38 +1893,82 @@ var file_stor_proto_goTypes = []any{
 	(*Item)(nil),                  // 10: kb.v1.Item
 	(*HistoryListResponse)(nil),   // 11: kb.v1.HistoryListResponse
 	(*UpdateSotrRequest)(nil),     // 12: kb.v1.UpdateSotrRequest
-	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
-	(*emptypb.Empty)(nil),         // 14: google.protobuf.Empty
+	(*AvatarRequest)(nil),         // 13: kb.v1.AvatarRequest
+	(*Avatar)(nil),                // 14: kb.v1.Avatar
+	(*AvatarResponse)(nil),        // 15: kb.v1.AvatarResponse
+	(*News)(nil),                  // 16: kb.v1.News
+	(*Comment)(nil),               // 17: kb.v1.Comment
+	(*ListNewsRequest)(nil),       // 18: kb.v1.ListNewsRequest
+	(*NewsListResponse)(nil),      // 19: kb.v1.NewsListResponse
+	(*NewsRequest)(nil),           // 20: kb.v1.NewsRequest
+	(*ReportsRequest)(nil),        // 21: kb.v1.ReportsRequest
+	(*ManagerChainRequest)(nil),   // 22: kb.v1.ManagerChainRequest
+	(*VersionsRequest)(nil),       // 23: kb.v1.VersionsRequest
+	(*VersionsResponse)(nil),      // 24: kb.v1.VersionsResponse
+	(*GenerationResponse)(nil),    // 25: kb.v1.GenerationResponse
+	nil,                           // 26: kb.v1.VersionsResponse.VersionsEntry
+	(*timestamppb.Timestamp)(nil), // 27: google.protobuf.Timestamp
+	(*fieldmaskpb.FieldMask)(nil), // 28: google.protobuf.FieldMask
+	(*emptypb.Empty)(nil),         // 29: google.protobuf.Empty
 }
 var file_stor_proto_depIdxs = []int32{
 	2,  // 0: kb.v1.DepsResponse.deps:type_name -> kb.v1.Dep
 	0,  // 1: kb.v1.DepRequest.field:type_name -> kb.v1.DepRequest.DBField
 	1,  // 2: kb.v1.SotrRequest.field:type_name -> kb.v1.SotrRequest.DBField
-	13, // 3: kb.v1.Sotr.date:type_name -> google.protobuf.Timestamp
-	13, // 4: kb.v1.History.date:type_name -> google.protobuf.Timestamp
-	7,  // 5: kb.v1.SotrsResponse.sotrs:type_name -> kb.v1.Sotr
-	2,  // 6: kb.v1.Item.dep:type_name -> kb.v1.Dep
-	7,  // 7: kb.v1.Item.sotr:type_name -> kb.v1.Sotr
-	8,  // 8: kb.v1.HistoryListResponse.history_list:type_name -> kb.v1.History
-	7,  // 9: kb.v1.UpdateSotrRequest.sotr:type_name -> kb.v1.Sotr
-	8,  // 10: kb.v1.UpdateSotrRequest.history_list:type_name -> kb.v1.History
-	4,  // 11: kb.v1.StorAPI.GetDepsBy:input_type -> kb.v1.DepRequest
-	5,  // 12: kb.v1.StorAPI.GetSotrsBy:input_type -> kb.v1.SotrRequest
-	14, // 13: kb.v1.StorAPI.Flush:input_type -> google.protobuf.Empty
-	10, // 14: kb.v1.StorAPI.Save:input_type -> kb.v1.Item
-	12, // 15: kb.v1.StorAPI.Update:input_type -> kb.v1.UpdateSotrRequest
-	6,  // 16: kb.v1.StorAPI.GetHistory:input_type -> kb.v1.HistRequest
-	3,  // 17: kb.v1.StorAPI.GetDepsBy:output_type -> kb.v1.DepsResponse
-	9,  // 18: kb.v1.StorAPI.GetSotrsBy:output_type -> kb.v1.SotrsResponse
-	14, // 19: kb.v1.StorAPI.Flush:output_type -> google.protobuf.Empty
-	14, // 20: kb.v1.StorAPI.Save:output_type -> google.protobuf.Empty
-	14, // 21: kb.v1.StorAPI.Update:output_type -> google.protobuf.Empty
-	11, // 22: kb.v1.StorAPI.GetHistory:output_type -> kb.v1.HistoryListResponse
-	17, // [17:23] is the sub-list for method output_type
-	11, // [11:17] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	27, // 3: kb.v1.Sotr.date:type_name -> google.protobuf.Timestamp
+	27, // 4: kb.v1.Sotr.hire_date:type_name -> google.protobuf.Timestamp
+	27, // 5: kb.v1.History.date:type_name -> google.protobuf.Timestamp
+	7,  // 6: kb.v1.SotrsResponse.sotrs:type_name -> kb.v1.Sotr
+	2,  // 7: kb.v1.Item.dep:type_name -> kb.v1.Dep
+	7,  // 8: kb.v1.Item.sotr:type_name -> kb.v1.Sotr
+	8,  // 9: kb.v1.HistoryListResponse.history_list:type_name -> kb.v1.History
+	7,  // 10: kb.v1.UpdateSotrRequest.sotr:type_name -> kb.v1.Sotr
+	8,  // 11: kb.v1.UpdateSotrRequest.history_list:type_name -> kb.v1.History
+	28, // 12: kb.v1.UpdateSotrRequest.clear:type_name -> google.protobuf.FieldMask
+	27, // 13: kb.v1.Avatar.first_seen:type_name -> google.protobuf.Timestamp
+	27, // 14: kb.v1.Avatar.last_seen:type_name -> google.protobuf.Timestamp
+	14, // 15: kb.v1.AvatarResponse.avatar:type_name -> kb.v1.Avatar
+	27, // 16: kb.v1.News.date:type_name -> google.protobuf.Timestamp
+	17, // 17: kb.v1.News.comments:type_name -> kb.v1.Comment
+	27, // 18: kb.v1.Comment.date:type_name -> google.protobuf.Timestamp
+	27, // 19: kb.v1.ListNewsRequest.since:type_name -> google.protobuf.Timestamp
+	27, // 20: kb.v1.ListNewsRequest.until:type_name -> google.protobuf.Timestamp
+	16, // 21: kb.v1.NewsListResponse.news:type_name -> kb.v1.News
+	26, // 22: kb.v1.VersionsResponse.versions:type_name -> kb.v1.VersionsResponse.VersionsEntry
+	27, // 23: kb.v1.VersionsResponse.VersionsEntry.value:type_name -> google.protobuf.Timestamp
+	4,  // 24: kb.v1.StorAPI.GetDepsBy:input_type -> kb.v1.DepRequest
+	5,  // 25: kb.v1.StorAPI.GetSotrsBy:input_type -> kb.v1.SotrRequest
+	29, // 26: kb.v1.StorAPI.Flush:input_type -> google.protobuf.Empty
+	10, // 27: kb.v1.StorAPI.Save:input_type -> kb.v1.Item
+	10, // 28: kb.v1.StorAPI.SaveItems:input_type -> kb.v1.Item
+	12, // 29: kb.v1.StorAPI.Update:input_type -> kb.v1.UpdateSotrRequest
+	6,  // 30: kb.v1.StorAPI.GetHistory:input_type -> kb.v1.HistRequest
+	13, // 31: kb.v1.StorAPI.GetAvatar:input_type -> kb.v1.AvatarRequest
+	18, // 32: kb.v1.StorAPI.ListNews:input_type -> kb.v1.ListNewsRequest
+	20, // 33: kb.v1.StorAPI.GetNews:input_type -> kb.v1.NewsRequest
+	21, // 34: kb.v1.StorAPI.GetReports:input_type -> kb.v1.ReportsRequest
+	22, // 35: kb.v1.StorAPI.GetManagerChain:input_type -> kb.v1.ManagerChainRequest
+	23, // 36: kb.v1.StorAPI.GetVersions:input_type -> kb.v1.VersionsRequest
+	29, // 37: kb.v1.StorAPI.GetGeneration:input_type -> google.protobuf.Empty
+	3,  // 38: kb.v1.StorAPI.GetDepsBy:output_type -> kb.v1.DepsResponse
+	9,  // 39: kb.v1.StorAPI.GetSotrsBy:output_type -> kb.v1.SotrsResponse
+	29, // 40: kb.v1.StorAPI.Flush:output_type -> google.protobuf.Empty
+	29, // 41: kb.v1.StorAPI.Save:output_type -> google.protobuf.Empty
+	29, // 42: kb.v1.StorAPI.SaveItems:output_type -> google.protobuf.Empty
+	29, // 43: kb.v1.StorAPI.Update:output_type -> google.protobuf.Empty
+	11, // 44: kb.v1.StorAPI.GetHistory:output_type -> kb.v1.HistoryListResponse
+	15, // 45: kb.v1.StorAPI.GetAvatar:output_type -> kb.v1.AvatarResponse
+	19, // 46: kb.v1.StorAPI.ListNews:output_type -> kb.v1.NewsListResponse
+	16, // 47: kb.v1.StorAPI.GetNews:output_type -> kb.v1.News
+	9,  // 48: kb.v1.StorAPI.GetReports:output_type -> kb.v1.SotrsResponse
+	9,  // 49: kb.v1.StorAPI.GetManagerChain:output_type -> kb.v1.SotrsResponse
+	24, // 50: kb.v1.StorAPI.GetVersions:output_type -> kb.v1.VersionsResponse
+	25, // 51: kb.v1.StorAPI.GetGeneration:output_type -> kb.v1.GenerationResponse
+	38, // [38:52] is the sub-list for method output_type
+	24, // [24:38] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
 }
 
 func init() { file_stor_proto_init() }
@@ -988,7 +1986,7 @@ func file_stor_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_stor_proto_rawDesc), len(file_stor_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   11,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -667,6 +667,39 @@ func (m *Sotr) validate(all bool) error {
 		}
 	}
 
+	if all {
+		switch v := interface{}(m.GetHireDate()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, SotrValidationError{
+					field:  "HireDate",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, SotrValidationError{
+					field:  "HireDate",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetHireDate()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return SotrValidationError{
+				field:  "HireDate",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Position
+
+	// no validation rules for ManagerTabnum
+
 	if len(errors) > 0 {
 		return SotrMultiError(errors)
 	}
@@ -1467,6 +1500,35 @@ func (m *UpdateSotrRequest) validate(all bool) error {
 
 	}
 
+	if all {
+		switch v := interface{}(m.GetClear()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, UpdateSotrRequestValidationError{
+					field:  "Clear",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, UpdateSotrRequestValidationError{
+					field:  "Clear",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetClear()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return UpdateSotrRequestValidationError{
+				field:  "Clear",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
 	if len(errors) > 0 {
 		return UpdateSotrRequestMultiError(errors)
 	}
@@ -1546,3 +1608,1752 @@ var _ interface {
 	Cause() error
 	ErrorName() string
 } = UpdateSotrRequestValidationError{}
+
+// Validate checks the field values on AvatarRequest with the rules defined in
+// the proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *AvatarRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on AvatarRequest with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in AvatarRequestMultiError, or
+// nil if none found.
+func (m *AvatarRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *AvatarRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if utf8.RuneCountInString(m.GetTabnum()) < 1 {
+		err := AvatarRequestValidationError{
+			field:  "Tabnum",
+			reason: "value length must be at least 1 runes",
+		}
+		if !all {
+			return err
+		}
+		errors = append(errors, err)
+	}
+
+	// no validation rules for IfNoneMatch
+
+	if m.GetSize() > 4096 {
+		err := AvatarRequestValidationError{
+			field:  "Size",
+			reason: "value must be less than or equal to 4096",
+		}
+		if !all {
+			return err
+		}
+		errors = append(errors, err)
+	}
+
+	if _, ok := _AvatarRequest_Format_InLookup[m.GetFormat()]; !ok {
+		err := AvatarRequestValidationError{
+			field:  "Format",
+			reason: "value must be in list [ jpeg webp]",
+		}
+		if !all {
+			return err
+		}
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return AvatarRequestMultiError(errors)
+	}
+
+	return nil
+}
+
+// AvatarRequestMultiError is an error wrapping multiple validation errors
+// returned by AvatarRequest.ValidateAll() if the designated constraints
+// aren't met.
+type AvatarRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m AvatarRequestMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m AvatarRequestMultiError) AllErrors() []error { return m }
+
+// AvatarRequestValidationError is the validation error returned by
+// AvatarRequest.Validate if the designated constraints aren't met.
+type AvatarRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e AvatarRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e AvatarRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e AvatarRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e AvatarRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e AvatarRequestValidationError) ErrorName() string { return "AvatarRequestValidationError" }
+
+// Error satisfies the builtin error interface
+func (e AvatarRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sAvatarRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = AvatarRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = AvatarRequestValidationError{}
+
+var _AvatarRequest_Format_InLookup = map[string]struct{}{
+	"":     {},
+	"jpeg": {},
+	"webp": {},
+}
+
+// Validate checks the field values on Avatar with the rules defined in the
+// proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *Avatar) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on Avatar with the rules defined in the
+// proto definition for this message. If any rules are violated, the result is
+// a list of violation errors wrapped in AvatarMultiError, or nil if none found.
+func (m *Avatar) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *Avatar) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Tabnum
+
+	// no validation rules for Hash
+
+	// no validation rules for Size
+
+	// no validation rules for ContentType
+
+	if all {
+		switch v := interface{}(m.GetFirstSeen()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, AvatarValidationError{
+					field:  "FirstSeen",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, AvatarValidationError{
+					field:  "FirstSeen",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetFirstSeen()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return AvatarValidationError{
+				field:  "FirstSeen",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if all {
+		switch v := interface{}(m.GetLastSeen()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, AvatarValidationError{
+					field:  "LastSeen",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, AvatarValidationError{
+					field:  "LastSeen",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetLastSeen()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return AvatarValidationError{
+				field:  "LastSeen",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return AvatarMultiError(errors)
+	}
+
+	return nil
+}
+
+// AvatarMultiError is an error wrapping multiple validation errors returned by
+// Avatar.ValidateAll() if the designated constraints aren't met.
+type AvatarMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m AvatarMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m AvatarMultiError) AllErrors() []error { return m }
+
+// AvatarValidationError is the validation error returned by Avatar.Validate if
+// the designated constraints aren't met.
+type AvatarValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e AvatarValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e AvatarValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e AvatarValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e AvatarValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e AvatarValidationError) ErrorName() string { return "AvatarValidationError" }
+
+// Error satisfies the builtin error interface
+func (e AvatarValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sAvatar.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = AvatarValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = AvatarValidationError{}
+
+// Validate checks the field values on AvatarResponse with the rules defined in
+// the proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *AvatarResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on AvatarResponse with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in AvatarResponseMultiError,
+// or nil if none found.
+func (m *AvatarResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *AvatarResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if all {
+		switch v := interface{}(m.GetAvatar()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, AvatarResponseValidationError{
+					field:  "Avatar",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, AvatarResponseValidationError{
+					field:  "Avatar",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetAvatar()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return AvatarResponseValidationError{
+				field:  "Avatar",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Data
+
+	// no validation rules for NotModified
+
+	// no validation rules for Etag
+
+	// no validation rules for ContentType
+
+	if len(errors) > 0 {
+		return AvatarResponseMultiError(errors)
+	}
+
+	return nil
+}
+
+// AvatarResponseMultiError is an error wrapping multiple validation errors
+// returned by AvatarResponse.ValidateAll() if the designated constraints
+// aren't met.
+type AvatarResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m AvatarResponseMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m AvatarResponseMultiError) AllErrors() []error { return m }
+
+// AvatarResponseValidationError is the validation error returned by
+// AvatarResponse.Validate if the designated constraints aren't met.
+type AvatarResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e AvatarResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e AvatarResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e AvatarResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e AvatarResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e AvatarResponseValidationError) ErrorName() string { return "AvatarResponseValidationError" }
+
+// Error satisfies the builtin error interface
+func (e AvatarResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sAvatarResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = AvatarResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = AvatarResponseValidationError{}
+
+// Validate checks the field values on News with the rules defined in the proto
+// definition for this message. If any rules are violated, the first error
+// encountered is returned, or nil if there are no violations.
+func (m *News) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on News with the rules defined in the
+// proto definition for this message. If any rules are violated, the result is
+// a list of violation errors wrapped in NewsMultiError, or nil if none found.
+func (m *News) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *News) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Id
+
+	// no validation rules for Idn
+
+	// no validation rules for Title
+
+	// no validation rules for Author
+
+	// no validation rules for AuthorTabnum
+
+	if all {
+		switch v := interface{}(m.GetDate()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, NewsValidationError{
+					field:  "Date",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, NewsValidationError{
+					field:  "Date",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetDate()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return NewsValidationError{
+				field:  "Date",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Body
+
+	// no validation rules for Url
+
+	for idx, item := range m.GetComments() {
+		_, _ = idx, item
+
+		if all {
+			switch v := interface{}(item).(type) {
+			case interface{ ValidateAll() error }:
+				if err := v.ValidateAll(); err != nil {
+					errors = append(errors, NewsValidationError{
+						field:  fmt.Sprintf("Comments[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			case interface{ Validate() error }:
+				if err := v.Validate(); err != nil {
+					errors = append(errors, NewsValidationError{
+						field:  fmt.Sprintf("Comments[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			}
+		} else if v, ok := interface{}(item).(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return NewsValidationError{
+					field:  fmt.Sprintf("Comments[%v]", idx),
+					reason: "embedded message failed validation",
+					cause:  err,
+				}
+			}
+		}
+
+	}
+
+	if len(errors) > 0 {
+		return NewsMultiError(errors)
+	}
+
+	return nil
+}
+
+// NewsMultiError is an error wrapping multiple validation errors returned by
+// News.ValidateAll() if the designated constraints aren't met.
+type NewsMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m NewsMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m NewsMultiError) AllErrors() []error { return m }
+
+// NewsValidationError is the validation error returned by News.Validate if the
+// designated constraints aren't met.
+type NewsValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e NewsValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e NewsValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e NewsValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e NewsValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e NewsValidationError) ErrorName() string { return "NewsValidationError" }
+
+// Error satisfies the builtin error interface
+func (e NewsValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sNews.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = NewsValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = NewsValidationError{}
+
+// Validate checks the field values on Comment with the rules defined in the
+// proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *Comment) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on Comment with the rules defined in the
+// proto definition for this message. If any rules are violated, the result is
+// a list of violation errors wrapped in CommentMultiError, or nil if none found.
+func (m *Comment) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *Comment) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Idc
+
+	// no validation rules for ParentIdc
+
+	// no validation rules for Author
+
+	// no validation rules for AuthorTabnum
+
+	if all {
+		switch v := interface{}(m.GetDate()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, CommentValidationError{
+					field:  "Date",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, CommentValidationError{
+					field:  "Date",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetDate()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return CommentValidationError{
+				field:  "Date",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Text
+
+	if len(errors) > 0 {
+		return CommentMultiError(errors)
+	}
+
+	return nil
+}
+
+// CommentMultiError is an error wrapping multiple validation errors returned
+// by Comment.ValidateAll() if the designated constraints aren't met.
+type CommentMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m CommentMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m CommentMultiError) AllErrors() []error { return m }
+
+// CommentValidationError is the validation error returned by Comment.Validate
+// if the designated constraints aren't met.
+type CommentValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e CommentValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e CommentValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e CommentValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e CommentValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e CommentValidationError) ErrorName() string { return "CommentValidationError" }
+
+// Error satisfies the builtin error interface
+func (e CommentValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sComment.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = CommentValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = CommentValidationError{}
+
+// Validate checks the field values on ListNewsRequest with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// first error encountered is returned, or nil if there are no violations.
+func (m *ListNewsRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ListNewsRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ListNewsRequestMultiError, or nil if none found.
+func (m *ListNewsRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ListNewsRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for AuthorTabnum
+
+	if all {
+		switch v := interface{}(m.GetSince()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, ListNewsRequestValidationError{
+					field:  "Since",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, ListNewsRequestValidationError{
+					field:  "Since",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetSince()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return ListNewsRequestValidationError{
+				field:  "Since",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	if all {
+		switch v := interface{}(m.GetUntil()).(type) {
+		case interface{ ValidateAll() error }:
+			if err := v.ValidateAll(); err != nil {
+				errors = append(errors, ListNewsRequestValidationError{
+					field:  "Until",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		case interface{ Validate() error }:
+			if err := v.Validate(); err != nil {
+				errors = append(errors, ListNewsRequestValidationError{
+					field:  "Until",
+					reason: "embedded message failed validation",
+					cause:  err,
+				})
+			}
+		}
+	} else if v, ok := interface{}(m.GetUntil()).(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return ListNewsRequestValidationError{
+				field:  "Until",
+				reason: "embedded message failed validation",
+				cause:  err,
+			}
+		}
+	}
+
+	// no validation rules for Query
+
+	if m.GetLimit() > 1000 {
+		err := ListNewsRequestValidationError{
+			field:  "Limit",
+			reason: "value must be less than or equal to 1000",
+		}
+		if !all {
+			return err
+		}
+		errors = append(errors, err)
+	}
+
+	// no validation rules for Offset
+
+	if len(errors) > 0 {
+		return ListNewsRequestMultiError(errors)
+	}
+
+	return nil
+}
+
+// ListNewsRequestMultiError is an error wrapping multiple validation errors
+// returned by ListNewsRequest.ValidateAll() if the designated constraints
+// aren't met.
+type ListNewsRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ListNewsRequestMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ListNewsRequestMultiError) AllErrors() []error { return m }
+
+// ListNewsRequestValidationError is the validation error returned by
+// ListNewsRequest.Validate if the designated constraints aren't met.
+type ListNewsRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ListNewsRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ListNewsRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ListNewsRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ListNewsRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ListNewsRequestValidationError) ErrorName() string { return "ListNewsRequestValidationError" }
+
+// Error satisfies the builtin error interface
+func (e ListNewsRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sListNewsRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ListNewsRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ListNewsRequestValidationError{}
+
+// Validate checks the field values on NewsListResponse with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// first error encountered is returned, or nil if there are no violations.
+func (m *NewsListResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on NewsListResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// NewsListResponseMultiError, or nil if none found.
+func (m *NewsListResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *NewsListResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	for idx, item := range m.GetNews() {
+		_, _ = idx, item
+
+		if all {
+			switch v := interface{}(item).(type) {
+			case interface{ ValidateAll() error }:
+				if err := v.ValidateAll(); err != nil {
+					errors = append(errors, NewsListResponseValidationError{
+						field:  fmt.Sprintf("News[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			case interface{ Validate() error }:
+				if err := v.Validate(); err != nil {
+					errors = append(errors, NewsListResponseValidationError{
+						field:  fmt.Sprintf("News[%v]", idx),
+						reason: "embedded message failed validation",
+						cause:  err,
+					})
+				}
+			}
+		} else if v, ok := interface{}(item).(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return NewsListResponseValidationError{
+					field:  fmt.Sprintf("News[%v]", idx),
+					reason: "embedded message failed validation",
+					cause:  err,
+				}
+			}
+		}
+
+	}
+
+	if len(errors) > 0 {
+		return NewsListResponseMultiError(errors)
+	}
+
+	return nil
+}
+
+// NewsListResponseMultiError is an error wrapping multiple validation errors
+// returned by NewsListResponse.ValidateAll() if the designated constraints
+// aren't met.
+type NewsListResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m NewsListResponseMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m NewsListResponseMultiError) AllErrors() []error { return m }
+
+// NewsListResponseValidationError is the validation error returned by
+// NewsListResponse.Validate if the designated constraints aren't met.
+type NewsListResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e NewsListResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e NewsListResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e NewsListResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e NewsListResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e NewsListResponseValidationError) ErrorName() string { return "NewsListResponseValidationError" }
+
+// Error satisfies the builtin error interface
+func (e NewsListResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sNewsListResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = NewsListResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = NewsListResponseValidationError{}
+
+// Validate checks the field values on NewsRequest with the rules defined in
+// the proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *NewsRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on NewsRequest with the rules defined in
+// the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in NewsRequestMultiError, or
+// nil if none found.
+func (m *NewsRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *NewsRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if utf8.RuneCountInString(m.GetIdn()) < 1 {
+		err := NewsRequestValidationError{
+			field:  "Idn",
+			reason: "value length must be at least 1 runes",
+		}
+		if !all {
+			return err
+		}
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return NewsRequestMultiError(errors)
+	}
+
+	return nil
+}
+
+// NewsRequestMultiError is an error wrapping multiple validation errors
+// returned by NewsRequest.ValidateAll() if the designated constraints aren't met.
+type NewsRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m NewsRequestMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m NewsRequestMultiError) AllErrors() []error { return m }
+
+// NewsRequestValidationError is the validation error returned by
+// NewsRequest.Validate if the designated constraints aren't met.
+type NewsRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e NewsRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e NewsRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e NewsRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e NewsRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e NewsRequestValidationError) ErrorName() string { return "NewsRequestValidationError" }
+
+// Error satisfies the builtin error interface
+func (e NewsRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sNewsRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = NewsRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = NewsRequestValidationError{}
+
+// Validate checks the field values on ReportsRequest with the rules defined in
+// the proto definition for this message. If any rules are violated, the first
+// error encountered is returned, or nil if there are no violations.
+func (m *ReportsRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ReportsRequest with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// result is a list of violation errors wrapped in ReportsRequestMultiError,
+// or nil if none found.
+func (m *ReportsRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ReportsRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if utf8.RuneCountInString(m.GetTabnum()) < 1 {
+		err := ReportsRequestValidationError{
+			field:  "Tabnum",
+			reason: "value length must be at least 1 runes",
+		}
+		if !all {
+			return err
+		}
+		errors = append(errors, err)
+	}
+
+	// no validation rules for Recursive
+
+	if len(errors) > 0 {
+		return ReportsRequestMultiError(errors)
+	}
+
+	return nil
+}
+
+// ReportsRequestMultiError is an error wrapping multiple validation errors
+// returned by ReportsRequest.ValidateAll() if the designated constraints
+// aren't met.
+type ReportsRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ReportsRequestMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ReportsRequestMultiError) AllErrors() []error { return m }
+
+// ReportsRequestValidationError is the validation error returned by
+// ReportsRequest.Validate if the designated constraints aren't met.
+type ReportsRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ReportsRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ReportsRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ReportsRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ReportsRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ReportsRequestValidationError) ErrorName() string { return "ReportsRequestValidationError" }
+
+// Error satisfies the builtin error interface
+func (e ReportsRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sReportsRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ReportsRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ReportsRequestValidationError{}
+
+// Validate checks the field values on ManagerChainRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *ManagerChainRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on ManagerChainRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// ManagerChainRequestMultiError, or nil if none found.
+func (m *ManagerChainRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *ManagerChainRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if utf8.RuneCountInString(m.GetTabnum()) < 1 {
+		err := ManagerChainRequestValidationError{
+			field:  "Tabnum",
+			reason: "value length must be at least 1 runes",
+		}
+		if !all {
+			return err
+		}
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return ManagerChainRequestMultiError(errors)
+	}
+
+	return nil
+}
+
+// ManagerChainRequestMultiError is an error wrapping multiple validation
+// errors returned by ManagerChainRequest.ValidateAll() if the designated
+// constraints aren't met.
+type ManagerChainRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m ManagerChainRequestMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m ManagerChainRequestMultiError) AllErrors() []error { return m }
+
+// ManagerChainRequestValidationError is the validation error returned by
+// ManagerChainRequest.Validate if the designated constraints aren't met.
+type ManagerChainRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e ManagerChainRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e ManagerChainRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e ManagerChainRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e ManagerChainRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e ManagerChainRequestValidationError) ErrorName() string {
+	return "ManagerChainRequestValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e ManagerChainRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sManagerChainRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = ManagerChainRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = ManagerChainRequestValidationError{}
+
+// Validate checks the field values on VersionsRequest with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// first error encountered is returned, or nil if there are no violations.
+func (m *VersionsRequest) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on VersionsRequest with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// VersionsRequestMultiError, or nil if none found.
+func (m *VersionsRequest) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *VersionsRequest) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	if len(errors) > 0 {
+		return VersionsRequestMultiError(errors)
+	}
+
+	return nil
+}
+
+// VersionsRequestMultiError is an error wrapping multiple validation errors
+// returned by VersionsRequest.ValidateAll() if the designated constraints
+// aren't met.
+type VersionsRequestMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m VersionsRequestMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m VersionsRequestMultiError) AllErrors() []error { return m }
+
+// VersionsRequestValidationError is the validation error returned by
+// VersionsRequest.Validate if the designated constraints aren't met.
+type VersionsRequestValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e VersionsRequestValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e VersionsRequestValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e VersionsRequestValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e VersionsRequestValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e VersionsRequestValidationError) ErrorName() string { return "VersionsRequestValidationError" }
+
+// Error satisfies the builtin error interface
+func (e VersionsRequestValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sVersionsRequest.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = VersionsRequestValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = VersionsRequestValidationError{}
+
+// Validate checks the field values on VersionsResponse with the rules defined
+// in the proto definition for this message. If any rules are violated, the
+// first error encountered is returned, or nil if there are no violations.
+func (m *VersionsResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on VersionsResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// VersionsResponseMultiError, or nil if none found.
+func (m *VersionsResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *VersionsResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	{
+		sorted_keys := make([]uint64, len(m.GetVersions()))
+		i := 0
+		for key := range m.GetVersions() {
+			sorted_keys[i] = key
+			i++
+		}
+		sort.Slice(sorted_keys, func(i, j int) bool { return sorted_keys[i] < sorted_keys[j] })
+		for _, key := range sorted_keys {
+			val := m.GetVersions()[key]
+			_ = val
+
+			// no validation rules for Versions[key]
+
+			if all {
+				switch v := interface{}(val).(type) {
+				case interface{ ValidateAll() error }:
+					if err := v.ValidateAll(); err != nil {
+						errors = append(errors, VersionsResponseValidationError{
+							field:  fmt.Sprintf("Versions[%v]", key),
+							reason: "embedded message failed validation",
+							cause:  err,
+						})
+					}
+				case interface{ Validate() error }:
+					if err := v.Validate(); err != nil {
+						errors = append(errors, VersionsResponseValidationError{
+							field:  fmt.Sprintf("Versions[%v]", key),
+							reason: "embedded message failed validation",
+							cause:  err,
+						})
+					}
+				}
+			} else if v, ok := interface{}(val).(interface{ Validate() error }); ok {
+				if err := v.Validate(); err != nil {
+					return VersionsResponseValidationError{
+						field:  fmt.Sprintf("Versions[%v]", key),
+						reason: "embedded message failed validation",
+						cause:  err,
+					}
+				}
+			}
+
+		}
+	}
+
+	if len(errors) > 0 {
+		return VersionsResponseMultiError(errors)
+	}
+
+	return nil
+}
+
+// VersionsResponseMultiError is an error wrapping multiple validation errors
+// returned by VersionsResponse.ValidateAll() if the designated constraints
+// aren't met.
+type VersionsResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m VersionsResponseMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m VersionsResponseMultiError) AllErrors() []error { return m }
+
+// VersionsResponseValidationError is the validation error returned by
+// VersionsResponse.Validate if the designated constraints aren't met.
+type VersionsResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e VersionsResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e VersionsResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e VersionsResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e VersionsResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e VersionsResponseValidationError) ErrorName() string { return "VersionsResponseValidationError" }
+
+// Error satisfies the builtin error interface
+func (e VersionsResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sVersionsResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = VersionsResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = VersionsResponseValidationError{}
+
+// Validate checks the field values on GenerationResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the first error encountered is returned, or nil if there are no violations.
+func (m *GenerationResponse) Validate() error {
+	return m.validate(false)
+}
+
+// ValidateAll checks the field values on GenerationResponse with the rules
+// defined in the proto definition for this message. If any rules are
+// violated, the result is a list of violation errors wrapped in
+// GenerationResponseMultiError, or nil if none found.
+func (m *GenerationResponse) ValidateAll() error {
+	return m.validate(true)
+}
+
+func (m *GenerationResponse) validate(all bool) error {
+	if m == nil {
+		return nil
+	}
+
+	var errors []error
+
+	// no validation rules for Generation
+
+	if len(errors) > 0 {
+		return GenerationResponseMultiError(errors)
+	}
+
+	return nil
+}
+
+// GenerationResponseMultiError is an error wrapping multiple validation errors
+// returned by GenerationResponse.ValidateAll() if the designated constraints
+// aren't met.
+type GenerationResponseMultiError []error
+
+// Error returns a concatenation of all the error messages it wraps.
+func (m GenerationResponseMultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AllErrors returns a list of validation violation errors.
+func (m GenerationResponseMultiError) AllErrors() []error { return m }
+
+// GenerationResponseValidationError is the validation error returned by
+// GenerationResponse.Validate if the designated constraints aren't met.
+type GenerationResponseValidationError struct {
+	field  string
+	reason string
+	cause  error
+	key    bool
+}
+
+// Field function returns field value.
+func (e GenerationResponseValidationError) Field() string { return e.field }
+
+// Reason function returns reason value.
+func (e GenerationResponseValidationError) Reason() string { return e.reason }
+
+// Cause function returns cause value.
+func (e GenerationResponseValidationError) Cause() error { return e.cause }
+
+// Key function returns key value.
+func (e GenerationResponseValidationError) Key() bool { return e.key }
+
+// ErrorName returns error name.
+func (e GenerationResponseValidationError) ErrorName() string {
+	return "GenerationResponseValidationError"
+}
+
+// Error satisfies the builtin error interface
+func (e GenerationResponseValidationError) Error() string {
+	cause := ""
+	if e.cause != nil {
+		cause = fmt.Sprintf(" | caused by: %v", e.cause)
+	}
+
+	key := ""
+	if e.key {
+		key = "key for "
+	}
+
+	return fmt.Sprintf(
+		"invalid %sGenerationResponse.%s: %s%s",
+		key,
+		e.field,
+		e.reason,
+		cause)
+}
+
+var _ error = GenerationResponseValidationError{}
+
+var _ interface {
+	Field() string
+	Reason() string
+	Key() bool
+	Cause() error
+	ErrorName() string
+} = GenerationResponseValidationError{}
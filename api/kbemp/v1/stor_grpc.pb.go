@@ -23,12 +23,20 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	StorAPI_GetDepsBy_FullMethodName  = "/kb.v1.StorAPI/GetDepsBy"
-	StorAPI_GetSotrsBy_FullMethodName = "/kb.v1.StorAPI/GetSotrsBy"
-	StorAPI_Flush_FullMethodName      = "/kb.v1.StorAPI/Flush"
-	StorAPI_Save_FullMethodName       = "/kb.v1.StorAPI/Save"
-	StorAPI_Update_FullMethodName     = "/kb.v1.StorAPI/Update"
-	StorAPI_GetHistory_FullMethodName = "/kb.v1.StorAPI/GetHistory"
+	StorAPI_GetDepsBy_FullMethodName       = "/kb.v1.StorAPI/GetDepsBy"
+	StorAPI_GetSotrsBy_FullMethodName      = "/kb.v1.StorAPI/GetSotrsBy"
+	StorAPI_Flush_FullMethodName           = "/kb.v1.StorAPI/Flush"
+	StorAPI_Save_FullMethodName            = "/kb.v1.StorAPI/Save"
+	StorAPI_SaveItems_FullMethodName       = "/kb.v1.StorAPI/SaveItems"
+	StorAPI_Update_FullMethodName          = "/kb.v1.StorAPI/Update"
+	StorAPI_GetHistory_FullMethodName      = "/kb.v1.StorAPI/GetHistory"
+	StorAPI_GetAvatar_FullMethodName       = "/kb.v1.StorAPI/GetAvatar"
+	StorAPI_ListNews_FullMethodName        = "/kb.v1.StorAPI/ListNews"
+	StorAPI_GetNews_FullMethodName         = "/kb.v1.StorAPI/GetNews"
+	StorAPI_GetReports_FullMethodName      = "/kb.v1.StorAPI/GetReports"
+	StorAPI_GetManagerChain_FullMethodName = "/kb.v1.StorAPI/GetManagerChain"
+	StorAPI_GetVersions_FullMethodName     = "/kb.v1.StorAPI/GetVersions"
+	StorAPI_GetGeneration_FullMethodName   = "/kb.v1.StorAPI/GetGeneration"
 )
 
 // StorAPIClient is the client API for StorAPI service.
@@ -47,11 +55,31 @@ type StorAPIClient interface {
 	// Save updates Dep data
 	// buf:lint:ignore RPC_REQUEST_RESPONSE_UNIQUE
 	Save(ctx context.Context, in *Item, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// SaveItems saves the stream of deps and employees of the distributed crawl
+	// buf:lint:ignore RPC_REQUEST_RESPONSE_UNIQUE
+	SaveItems(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Item, emptypb.Empty], error)
 	// Update sotr if it exists by tabnum field
 	// nolint:RPC_REQUEST_RESPONSE_UNIQUE
 	Update(ctx context.Context, in *UpdateSotrRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// Get sotr history
 	GetHistory(ctx context.Context, in *HistRequest, opts ...grpc.CallOption) (*HistoryListResponse, error)
+	// GetAvatar returns the current avatar image of the employee or its thumbnail.
+	// REST route /api/stor/v1/avatar/{tabnum}?size=96&format=webp is served by the gateway with ETag.
+	GetAvatar(ctx context.Context, in *AvatarRequest, opts ...grpc.CallOption) (*AvatarResponse, error)
+	// ListNews returns news of the intranet without comments, the newest first
+	ListNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*NewsListResponse, error)
+	// GetNews returns the news with comments by id of the source
+	GetNews(ctx context.Context, in *NewsRequest, opts ...grpc.CallOption) (*News, error)
+	// GetReports returns employees whose manager is the employee, reports of reports too if recursive.
+	// The manager is manager_tabnum of the employee or the head of the dep inferred by the grade.
+	GetReports(ctx context.Context, in *ReportsRequest, opts ...grpc.CallOption) (*SotrsResponse, error)
+	// GetManagerChain returns managers of the employee from the direct manager to the top one
+	GetManagerChain(ctx context.Context, in *ManagerChainRequest, opts ...grpc.CallOption) (*SotrsResponse, error)
+	// GetVersions returns times of the latest changes in histories of employees by their ids
+	GetVersions(ctx context.Context, in *VersionsRequest, opts ...grpc.CallOption) (*VersionsResponse, error)
+	// GetGeneration returns the generation of the storage, it's changed by every Update and Flush
+	// nolint:RPC_REQUEST_RESPONSE_UNIQUE
+	GetGeneration(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GenerationResponse, error)
 }
 
 type storAPIClient struct {
@@ -102,6 +130,19 @@ func (c *storAPIClient) Save(ctx context.Context, in *Item, opts ...grpc.CallOpt
 	return out, nil
 }
 
+func (c *storAPIClient) SaveItems(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Item, emptypb.Empty], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StorAPI_ServiceDesc.Streams[0], StorAPI_SaveItems_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Item, emptypb.Empty]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorAPI_SaveItemsClient = grpc.ClientStreamingClient[Item, emptypb.Empty]
+
 func (c *storAPIClient) Update(ctx context.Context, in *UpdateSotrRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -122,6 +163,76 @@ func (c *storAPIClient) GetHistory(ctx context.Context, in *HistRequest, opts ..
 	return out, nil
 }
 
+func (c *storAPIClient) GetAvatar(ctx context.Context, in *AvatarRequest, opts ...grpc.CallOption) (*AvatarResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AvatarResponse)
+	err := c.cc.Invoke(ctx, StorAPI_GetAvatar_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storAPIClient) ListNews(ctx context.Context, in *ListNewsRequest, opts ...grpc.CallOption) (*NewsListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NewsListResponse)
+	err := c.cc.Invoke(ctx, StorAPI_ListNews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storAPIClient) GetNews(ctx context.Context, in *NewsRequest, opts ...grpc.CallOption) (*News, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(News)
+	err := c.cc.Invoke(ctx, StorAPI_GetNews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storAPIClient) GetReports(ctx context.Context, in *ReportsRequest, opts ...grpc.CallOption) (*SotrsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SotrsResponse)
+	err := c.cc.Invoke(ctx, StorAPI_GetReports_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storAPIClient) GetManagerChain(ctx context.Context, in *ManagerChainRequest, opts ...grpc.CallOption) (*SotrsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SotrsResponse)
+	err := c.cc.Invoke(ctx, StorAPI_GetManagerChain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storAPIClient) GetVersions(ctx context.Context, in *VersionsRequest, opts ...grpc.CallOption) (*VersionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VersionsResponse)
+	err := c.cc.Invoke(ctx, StorAPI_GetVersions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storAPIClient) GetGeneration(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GenerationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenerationResponse)
+	err := c.cc.Invoke(ctx, StorAPI_GetGeneration_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StorAPIServer is the server API for StorAPI service.
 // All implementations must embed UnimplementedStorAPIServer
 // for forward compatibility.
@@ -138,11 +249,31 @@ type StorAPIServer interface {
 	// Save updates Dep data
 	// buf:lint:ignore RPC_REQUEST_RESPONSE_UNIQUE
 	Save(context.Context, *Item) (*emptypb.Empty, error)
+	// SaveItems saves the stream of deps and employees of the distributed crawl
+	// buf:lint:ignore RPC_REQUEST_RESPONSE_UNIQUE
+	SaveItems(grpc.ClientStreamingServer[Item, emptypb.Empty]) error
 	// Update sotr if it exists by tabnum field
 	// nolint:RPC_REQUEST_RESPONSE_UNIQUE
 	Update(context.Context, *UpdateSotrRequest) (*emptypb.Empty, error)
 	// Get sotr history
 	GetHistory(context.Context, *HistRequest) (*HistoryListResponse, error)
+	// GetAvatar returns the current avatar image of the employee or its thumbnail.
+	// REST route /api/stor/v1/avatar/{tabnum}?size=96&format=webp is served by the gateway with ETag.
+	GetAvatar(context.Context, *AvatarRequest) (*AvatarResponse, error)
+	// ListNews returns news of the intranet without comments, the newest first
+	ListNews(context.Context, *ListNewsRequest) (*NewsListResponse, error)
+	// GetNews returns the news with comments by id of the source
+	GetNews(context.Context, *NewsRequest) (*News, error)
+	// GetReports returns employees whose manager is the employee, reports of reports too if recursive.
+	// The manager is manager_tabnum of the employee or the head of the dep inferred by the grade.
+	GetReports(context.Context, *ReportsRequest) (*SotrsResponse, error)
+	// GetManagerChain returns managers of the employee from the direct manager to the top one
+	GetManagerChain(context.Context, *ManagerChainRequest) (*SotrsResponse, error)
+	// GetVersions returns times of the latest changes in histories of employees by their ids
+	GetVersions(context.Context, *VersionsRequest) (*VersionsResponse, error)
+	// GetGeneration returns the generation of the storage, it's changed by every Update and Flush
+	// nolint:RPC_REQUEST_RESPONSE_UNIQUE
+	GetGeneration(context.Context, *emptypb.Empty) (*GenerationResponse, error)
 	mustEmbedUnimplementedStorAPIServer()
 }
 
@@ -165,12 +296,36 @@ func (UnimplementedStorAPIServer) Flush(context.Context, *emptypb.Empty) (*empty
 func (UnimplementedStorAPIServer) Save(context.Context, *Item) (*emptypb.Empty, error) {
 	return nil, status.Error(codes.Unimplemented, "method Save not implemented")
 }
+func (UnimplementedStorAPIServer) SaveItems(grpc.ClientStreamingServer[Item, emptypb.Empty]) error {
+	return status.Error(codes.Unimplemented, "method SaveItems not implemented")
+}
 func (UnimplementedStorAPIServer) Update(context.Context, *UpdateSotrRequest) (*emptypb.Empty, error) {
 	return nil, status.Error(codes.Unimplemented, "method Update not implemented")
 }
 func (UnimplementedStorAPIServer) GetHistory(context.Context, *HistRequest) (*HistoryListResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetHistory not implemented")
 }
+func (UnimplementedStorAPIServer) GetAvatar(context.Context, *AvatarRequest) (*AvatarResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetAvatar not implemented")
+}
+func (UnimplementedStorAPIServer) ListNews(context.Context, *ListNewsRequest) (*NewsListResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListNews not implemented")
+}
+func (UnimplementedStorAPIServer) GetNews(context.Context, *NewsRequest) (*News, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetNews not implemented")
+}
+func (UnimplementedStorAPIServer) GetReports(context.Context, *ReportsRequest) (*SotrsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetReports not implemented")
+}
+func (UnimplementedStorAPIServer) GetManagerChain(context.Context, *ManagerChainRequest) (*SotrsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetManagerChain not implemented")
+}
+func (UnimplementedStorAPIServer) GetVersions(context.Context, *VersionsRequest) (*VersionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetVersions not implemented")
+}
+func (UnimplementedStorAPIServer) GetGeneration(context.Context, *emptypb.Empty) (*GenerationResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetGeneration not implemented")
+}
 func (UnimplementedStorAPIServer) mustEmbedUnimplementedStorAPIServer() {}
 func (UnimplementedStorAPIServer) testEmbeddedByValue()                 {}
 
@@ -264,6 +419,13 @@ func _StorAPI_Save_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorAPI_SaveItems_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StorAPIServer).SaveItems(&grpc.GenericServerStream[Item, emptypb.Empty]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StorAPI_SaveItemsServer = grpc.ClientStreamingServer[Item, emptypb.Empty]
+
 func _StorAPI_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateSotrRequest)
 	if err := dec(in); err != nil {
@@ -300,6 +462,132 @@ func _StorAPI_GetHistory_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StorAPI_GetAvatar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AvatarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorAPIServer).GetAvatar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorAPI_GetAvatar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorAPIServer).GetAvatar(ctx, req.(*AvatarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorAPI_ListNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorAPIServer).ListNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorAPI_ListNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorAPIServer).ListNews(ctx, req.(*ListNewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorAPI_GetNews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorAPIServer).GetNews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorAPI_GetNews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorAPIServer).GetNews(ctx, req.(*NewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorAPI_GetReports_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorAPIServer).GetReports(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorAPI_GetReports_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorAPIServer).GetReports(ctx, req.(*ReportsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorAPI_GetManagerChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ManagerChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorAPIServer).GetManagerChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorAPI_GetManagerChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorAPIServer).GetManagerChain(ctx, req.(*ManagerChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorAPI_GetVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorAPIServer).GetVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorAPI_GetVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorAPIServer).GetVersions(ctx, req.(*VersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorAPI_GetGeneration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorAPIServer).GetGeneration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorAPI_GetGeneration_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorAPIServer).GetGeneration(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StorAPI_ServiceDesc is the grpc.ServiceDesc for StorAPI service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -331,7 +619,41 @@ var StorAPI_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetHistory",
 			Handler:    _StorAPI_GetHistory_Handler,
 		},
+		{
+			MethodName: "GetAvatar",
+			Handler:    _StorAPI_GetAvatar_Handler,
+		},
+		{
+			MethodName: "ListNews",
+			Handler:    _StorAPI_ListNews_Handler,
+		},
+		{
+			MethodName: "GetNews",
+			Handler:    _StorAPI_GetNews_Handler,
+		},
+		{
+			MethodName: "GetReports",
+			Handler:    _StorAPI_GetReports_Handler,
+		},
+		{
+			MethodName: "GetManagerChain",
+			Handler:    _StorAPI_GetManagerChain_Handler,
+		},
+		{
+			MethodName: "GetVersions",
+			Handler:    _StorAPI_GetVersions_Handler,
+		},
+		{
+			MethodName: "GetGeneration",
+			Handler:    _StorAPI_GetGeneration_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SaveItems",
+			Handler:       _StorAPI_SaveItems_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "stor.proto",
 }
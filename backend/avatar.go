@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// avatarPath is the REST route of avatars, the tabnum is the last element of the path
+const avatarPath = "/api/stor/v1/avatar/"
+
+// avatarHandler serves images of avatars and thumbnails (?size=96&format=webp) by GetAvatar with ETag of the image
+func avatarHandler(cli kbv1.StorAPIClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		tabnum := strings.TrimPrefix(r.URL.Path, avatarPath)
+		if tabnum == "" || strings.Contains(tabnum, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		q := &kbv1.AvatarRequest{
+			Tabnum:      tabnum,
+			IfNoneMatch: strings.Trim(strings.TrimPrefix(r.Header.Get("If-None-Match"), "W/"), `"`),
+			Format:      r.URL.Query().Get("format"),
+		}
+		if sz := r.URL.Query().Get("size"); sz != "" {
+			size, err := strconv.ParseUint(sz, 10, 32)
+			if err != nil {
+				http.Error(w, "invalid size "+sz, http.StatusBadRequest)
+				return
+			}
+			q.Size = uint32(size)
+		}
+
+		resp, err := cli.GetAvatar(r.Context(), q)
+		if err != nil {
+			switch status.Code(err) {
+			case codes.NotFound:
+				http.NotFound(w, r)
+			case codes.InvalidArgument:
+				http.Error(w, status.Convert(err).Message(), http.StatusBadRequest)
+			default:
+				http.Error(w, status.Convert(err).Message(), http.StatusBadGateway)
+			}
+			return
+		}
+
+		a := resp.GetAvatar()
+		w.Header().Set("ETag", strconv.Quote(resp.Etag))
+		w.Header().Set("Cache-Control", "no-cache")
+		if a.FirstSeen != nil {
+			w.Header().Set("Last-Modified", a.FirstSeen.AsTime().UTC().Format(http.TimeFormat))
+		}
+
+		if resp.NotModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if resp.ContentType != "" {
+			w.Header().Set("Content-Type", resp.ContentType)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp.Data)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write(resp.Data)
+	})
+}
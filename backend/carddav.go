@@ -0,0 +1,663 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/export"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// carddavPath is the root of the read-only CardDAV server (RFC 6352) of the directory:
+//
+//	/carddav/                    the principal and the home set of address books
+//	/carddav/<idr>/              the address book of the top-level dep with employees of its subtree
+//	/carddav/<idr>/<tabnum>.vcf  the vCard 3.0 of the employee
+const carddavPath = "/carddav/"
+
+// wellKnownCardDAV is the path of the service discovery of clients (RFC 6764)
+const wellKnownCardDAV = "/.well-known/carddav"
+
+// carddavAllow is methods of the read-only server
+const carddavAllow = "OPTIONS, GET, HEAD, PROPFIND, REPORT"
+
+const vcardContentType = "text/vcard; charset=utf-8"
+
+// bookTTL is the time of loaded address books in the generation of the storage.
+// Storages changed by other processes without the shared cache don't change the generation of the backend.
+const bookTTL = 5 * time.Minute
+
+// carddav serves address books of top-level deps by the gRPC client of the gateway.
+// The version of the card is the time of the latest change in the history of the employee,
+// the sync-token of the address book is made by versions of its cards.
+// Loaded address books are kept until the generation of the storage is changed.
+type carddav struct {
+	cli kbv1.StorAPIClient
+	lg  *slog.Logger
+
+	mu sync.Mutex
+	// generation of the storage of loaded books
+	gen int64
+	// loaded books by idrs of deps
+	loaded map[string]*addressBook
+	// loadMu serializes loads, so concurrent requests of the book load it once
+	loadMu sync.Mutex
+}
+
+// carddavHandler serves the read-only CardDAV of the directory
+func carddavHandler(cli kbv1.StorAPIClient, lg *slog.Logger) http.Handler {
+	return &carddav{cli: cli, lg: lg, loaded: make(map[string]*addressBook)}
+}
+
+func (c *carddav) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.lg.Debug("CardDAV", "method", r.Method, "path", r.URL.Path, "depth", r.Header.Get("Depth"))
+
+	var err error
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", carddavAllow)
+		w.Header().Set("DAV", "1, 3, addressbook")
+	case http.MethodGet, http.MethodHead:
+		err = c.get(w, r)
+	case "PROPFIND":
+		err = c.propfind(w, r)
+	case "REPORT":
+		err = c.report(w, r)
+	default:
+		w.Header().Set("Allow", carddavAllow)
+		http.Error(w, "address books are read-only", http.StatusMethodNotAllowed)
+	}
+	if err == nil {
+		return
+	}
+
+	var he *httpError
+	switch {
+	case errors.As(err, &he):
+		http.Error(w, he.msg, he.code)
+	case status.Code(err) == codes.NotFound:
+		http.Error(w, status.Convert(err).Message(), http.StatusNotFound)
+	case status.Code(err) == codes.InvalidArgument:
+		http.Error(w, status.Convert(err).Message(), http.StatusBadRequest)
+	default:
+		c.lg.Error("CardDAV", "method", r.Method, "path", r.URL.Path, "err", err)
+		http.Error(w, status.Convert(err).Message(), http.StatusBadGateway)
+	}
+}
+
+// httpError is responded with its status code
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func notFound(format string, a ...any) error {
+	return &httpError{code: http.StatusNotFound, msg: fmt.Sprintf(format, a...)}
+}
+
+// get serves the vCard of the employee
+func (c *carddav) get(w http.ResponseWriter, r *http.Request) error {
+	t, ok := parseTarget(r.URL.Path)
+	if !ok {
+		return notFound("%s not found", r.URL.Path)
+	}
+	if t.tabnum == "" {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, REPORT")
+		return &httpError{code: http.StatusMethodNotAllowed, msg: "collections are read by PROPFIND and REPORT"}
+	}
+
+	cd, err := c.card(r.Context(), t)
+	if err != nil {
+		return err
+	}
+	data, err := c.vcard(r.Context(), cd)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", vcardContentType)
+	w.Header().Set("ETag", cd.etag())
+	http.ServeContent(w, r, "", cd.version, bytes.NewReader(data))
+	return nil
+}
+
+// propfind responds properties of the resource and of its members by Depth: 1, Depth: infinity is Depth: 1
+func (c *carddav) propfind(w http.ResponseWriter, r *http.Request) error {
+	t, ok := parseTarget(r.URL.Path)
+	if !ok {
+		return notFound("%s not found", r.URL.Path)
+	}
+	req, err := readDAVRequest(r)
+	if err != nil {
+		return err
+	}
+	names := req.propNames()
+	members := r.Header.Get("Depth") != "0"
+
+	ctx := r.Context()
+	ms := &multistatus{}
+	switch {
+	case t.book == "":
+		if err = c.props(ctx, ms, resource{href: carddavPath}, names); err != nil {
+			return err
+		}
+		if !members {
+			break
+		}
+		books, err := c.books(ctx)
+		if err != nil {
+			return err
+		}
+		for _, b := range books {
+			if err = c.props(ctx, ms, resource{href: bookHref(b.dep.Idr), book: b}, names); err != nil {
+				return err
+			}
+		}
+
+	case t.tabnum == "":
+		b, err := c.book(ctx, t.book)
+		if err != nil {
+			return err
+		}
+		if err = c.props(ctx, ms, resource{href: bookHref(b.dep.Idr), book: b}, names); err != nil {
+			return err
+		}
+		if !members {
+			break
+		}
+		if b, err = c.loadedBook(ctx, t.book); err != nil {
+			return err
+		}
+		for _, cd := range b.cards {
+			if err = c.props(ctx, ms, b.resource(cd), names); err != nil {
+				return err
+			}
+		}
+
+	default:
+		cd, err := c.card(ctx, t)
+		if err != nil {
+			return err
+		}
+		if err = c.props(ctx, ms, resource{href: cardHref(t.book, t.tabnum), card: cd}, names); err != nil {
+			return err
+		}
+	}
+
+	ms.send(w)
+	return nil
+}
+
+// report responds cards of addressbook-multiget, addressbook-query and sync-collection of the address book
+func (c *carddav) report(w http.ResponseWriter, r *http.Request) error {
+	t, ok := parseTarget(r.URL.Path)
+	if !ok {
+		return notFound("%s not found", r.URL.Path)
+	}
+	if t.book == "" || t.tabnum != "" {
+		return &httpError{code: http.StatusForbidden, msg: "reports are supported by address books"}
+	}
+	req, err := readDAVRequest(r)
+	if err != nil {
+		return err
+	}
+
+	ctx := r.Context()
+	b, err := c.loadedBook(ctx, t.book)
+	if err != nil {
+		return err
+	}
+
+	names := req.propNames()
+	ms := &multistatus{}
+	switch req.XMLName {
+	case cardName("addressbook-multiget"):
+		for _, href := range req.Hrefs {
+			cd := b.cardByHref(href)
+			if cd == nil {
+				ms.status(href, http.StatusNotFound)
+				continue
+			}
+			if err = c.props(ctx, ms, b.resource(cd), names); err != nil {
+				return err
+			}
+		}
+
+	case cardName("addressbook-query"):
+		for _, cd := range b.cards {
+			if !req.Filter.match(cd.row) {
+				continue
+			}
+			if err = c.props(ctx, ms, b.resource(cd), names); err != nil {
+				return err
+			}
+		}
+
+	case davName("sync-collection"):
+		cards, ok := b.changed(req.SyncToken)
+		if !ok {
+			// the client synchronizes the address book again without the token
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, xmlHeader+`<D:error xmlns:D="DAV:"><D:valid-sync-token/></D:error>`)
+			return nil
+		}
+		for _, cd := range cards {
+			if err = c.props(ctx, ms, b.resource(cd), names); err != nil {
+				return err
+			}
+		}
+		ms.syncToken = b.token.String()
+
+	default:
+		return &httpError{code: http.StatusBadRequest, msg: "unsupported report " + req.XMLName.Local}
+	}
+
+	ms.send(w)
+	return nil
+}
+
+// target is the resource of the path: the root, the address book or the card
+type target struct {
+	book, tabnum string
+}
+
+func parseTarget(p string) (t target, ok bool) {
+	rest, ok := strings.CutPrefix(p, carddavPath)
+	if !ok {
+		return t, false
+	}
+
+	book, file, _ := strings.Cut(rest, "/")
+	if book == "" {
+		return t, file == ""
+	}
+	t.book = book
+	if file == "" {
+		return t, true
+	}
+
+	t.tabnum, ok = strings.CutSuffix(file, ".vcf")
+	return t, ok && t.tabnum != "" && !strings.Contains(t.tabnum, "/")
+}
+
+func bookHref(idr string) string {
+	return carddavPath + url.PathEscape(idr) + "/"
+}
+
+func cardHref(idr, tabnum string) string {
+	return bookHref(idr) + url.PathEscape(tabnum) + ".vcf"
+}
+
+// addressBook is the top-level dep with employees of its subtree.
+// The loaded book is shared by requests and isn't changed.
+type addressBook struct {
+	dep *kbv1.Dep
+	// cards sorted by tabnums, nil until loaded
+	cards  []*card
+	token  syncToken
+	loaded time.Time
+}
+
+// card is the employee of the address book
+type card struct {
+	row *export.Row
+	// version is the time of the latest change of the employee
+	version time.Time
+}
+
+func (cd *card) etag() string {
+	return strconv.Quote(strconv.FormatInt(cd.version.UnixNano(), 36))
+}
+
+func (b *addressBook) resource(cd *card) resource {
+	return resource{href: cardHref(b.dep.Idr, cd.row.Sotr.Tabnum), card: cd}
+}
+
+func (b *addressBook) card(tabnum string) *card {
+	i, ok := slices.BinarySearchFunc(b.cards, tabnum, func(cd *card, tabnum string) int {
+		return strings.Compare(cd.row.Sotr.Tabnum, tabnum)
+	})
+	if !ok {
+		return nil
+	}
+	return b.cards[i]
+}
+
+// cardByHref returns the card of the book by the path or the URL
+func (b *addressBook) cardByHref(href string) *card {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil
+	}
+	t, ok := parseTarget(u.Path)
+	if !ok || t.book != b.dep.Idr || t.tabnum == "" {
+		return nil
+	}
+	return b.card(t.tabnum)
+}
+
+// changed returns cards changed after the sync token, all cards are returned by the empty token.
+// The token is invalid if cards of it are removed or moved, so the client synchronizes all cards again.
+func (b *addressBook) changed(token string) ([]*card, bool) {
+	if token == "" {
+		return b.cards, true
+	}
+	t, ok := parseSyncToken(token)
+	if !ok {
+		return nil, false
+	}
+
+	var changed, kept []*card
+	for _, cd := range b.cards {
+		if cd.version.After(t.version) {
+			changed = append(changed, cd)
+		}
+		// employees added after the token are not in its hash
+		if !cd.row.Sotr.Date.AsTime().After(t.version) {
+			kept = append(kept, cd)
+		}
+	}
+	if tabnumsHash(kept) != t.hash {
+		return nil, false
+	}
+	return changed, true
+}
+
+// syncToken is the latest version of cards of the address book and the hash of their tabnums
+type syncToken struct {
+	version time.Time
+	hash    uint64
+}
+
+const syncTokenPrefix = "urn:kbemp:sync:"
+
+func (t syncToken) String() string {
+	return syncTokenPrefix + strconv.FormatInt(t.version.UnixNano(), 10) + "-" + strconv.FormatUint(t.hash, 16)
+}
+
+func parseSyncToken(s string) (t syncToken, ok bool) {
+	s, ok = strings.CutPrefix(s, syncTokenPrefix)
+	if !ok {
+		return t, false
+	}
+	v, h, _ := strings.Cut(s, "-")
+
+	nano, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return t, false
+	}
+	if t.hash, err = strconv.ParseUint(h, 16, 64); err != nil {
+		return t, false
+	}
+	t.version = time.Unix(0, nano)
+	return t, true
+}
+
+// tabnumsHash returns the hash of tabnums of sorted cards
+func tabnumsHash(cards []*card) uint64 {
+	h := fnv.New64a()
+	for _, cd := range cards {
+		h.Write([]byte(cd.row.Sotr.Tabnum))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// books returns address books of top-level deps, parents of them are not deps
+func (c *carddav) books(ctx context.Context) ([]*addressBook, error) {
+	deps, err := storSource{c.cli}.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+	if err != nil {
+		return nil, err
+	}
+
+	idrs := make(map[string]struct{}, len(deps))
+	for _, d := range deps {
+		idrs[d.Idr] = struct{}{}
+	}
+
+	var books []*addressBook
+	for _, d := range deps {
+		if _, ok := idrs[d.Parent]; ok {
+			continue
+		}
+		if slices.ContainsFunc(books, func(b *addressBook) bool { return b.dep.Idr == d.Idr }) {
+			continue
+		}
+		books = append(books, &addressBook{dep: d})
+	}
+	slices.SortFunc(books, func(a, b *addressBook) int { return strings.Compare(a.dep.Idr, b.dep.Idr) })
+	return books, nil
+}
+
+// book returns the address book of the top-level dep
+func (c *carddav) book(ctx context.Context, idr string) (*addressBook, error) {
+	src := storSource{c.cli}
+	deps, err := src.GetDepsBy(ctx, &kbv1.DepRequest{Str: idr, Field: kbv1.DepRequest_IDR})
+	if err != nil {
+		return nil, err
+	}
+	if len(deps) == 0 {
+		return nil, notFound("address book %s not found", idr)
+	}
+
+	parents, err := src.GetDepsBy(ctx, &kbv1.DepRequest{Str: deps[0].Parent, Field: kbv1.DepRequest_IDR})
+	if err != nil {
+		return nil, err
+	}
+	if len(parents) > 0 {
+		return nil, notFound("address book %s not found, %s is not top-level section", idr, idr)
+	}
+	return &addressBook{dep: deps[0]}, nil
+}
+
+// loadedBook returns the address book with cards loaded in the current generation of the storage
+func (c *carddav) loadedBook(ctx context.Context, idr string) (*addressBook, error) {
+	gen, err := c.generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if b := c.cached(gen, idr); b != nil {
+		return b, nil
+	}
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	// the book is loaded by the concurrent request
+	if b := c.cached(gen, idr); b != nil {
+		return b, nil
+	}
+
+	b, err := c.book(ctx, idr)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.load(ctx, b); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		c.loaded[idr] = b
+	}
+	c.mu.Unlock()
+	return b, nil
+}
+
+// cached returns the loaded book of the generation or nil, books of other generations are dropped
+func (c *carddav) cached(gen int64, idr string) *addressBook {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		c.gen = gen
+		clear(c.loaded)
+	}
+	b := c.loaded[idr]
+	if b == nil || time.Since(b.loaded) > bookTTL {
+		return nil
+	}
+	return b
+}
+
+// generation returns the generation of the storage, it's changed by every change of employees
+func (c *carddav) generation(ctx context.Context) (int64, error) {
+	resp, err := c.cli.GetGeneration(ctx, &emptypb.Empty{})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Generation, nil
+}
+
+// load reads employees of the address book with versions of them by one query of versions
+func (c *carddav) load(ctx context.Context, b *addressBook) error {
+	var rows rowsWriter
+	if _, err := export.Export(ctx, storSource{c.cli}, []string{b.dep.Idr}, &rows); err != nil {
+		return err
+	}
+
+	ids := make([]uint64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.Sotr.Id)
+	}
+	versions, err := c.cli.GetVersions(ctx, &kbv1.VersionsRequest{SotrIds: ids})
+	if err != nil {
+		return err
+	}
+
+	b.cards = make([]*card, 0, len(rows))
+	for _, row := range rows {
+		if row.Sotr.Tabnum == "" {
+			continue
+		}
+
+		cd := newCard(row, versions.Versions[row.Sotr.Id])
+		b.cards = append(b.cards, cd)
+		if cd.version.After(b.token.version) {
+			b.token.version = cd.version
+		}
+	}
+
+	slices.SortFunc(b.cards, func(a, b *card) int { return strings.Compare(a.row.Sotr.Tabnum, b.row.Sotr.Tabnum) })
+	b.token.hash = tabnumsHash(b.cards)
+	b.loaded = time.Now()
+	return nil
+}
+
+// newCard returns the card of the employee, the version is the date of the employee
+// if the latest change of the history is absent or older
+func newCard(row *export.Row, latest *timestamppb.Timestamp) *card {
+	version := row.Sotr.Date.AsTime()
+	if latest != nil && latest.AsTime().After(version) {
+		version = latest.AsTime()
+	}
+	return &card{row: row, version: version}
+}
+
+// card returns the card of the path from the loaded address book,
+// otherwise by the employee with its history without loading the book
+func (c *carddav) card(ctx context.Context, t target) (*card, error) {
+	gen, err := c.generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if b := c.cached(gen, t.book); b != nil {
+		if cd := b.card(t.tabnum); cd != nil {
+			return cd, nil
+		}
+		return nil, notFound("card %s not found in address book %s", t.tabnum, t.book)
+	}
+
+	src := storSource{c.cli}
+	sotrs, err := src.GetSotrsBy(ctx, &kbv1.SotrRequest{Str: t.tabnum, Field: kbv1.SotrRequest_TABNUM})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sotrs {
+		top, path, err := c.ancestors(ctx, s.ParentId)
+		if err != nil {
+			return nil, err
+		}
+		if top != t.book {
+			continue
+		}
+
+		hist, err := c.cli.GetHistory(ctx, &kbv1.HistRequest{SotrId: strconv.FormatUint(s.Id, 10)})
+		if err != nil {
+			return nil, err
+		}
+		var latest *timestamppb.Timestamp
+		for _, h := range hist.GetHistoryList() {
+			if latest == nil || h.Date.AsTime().After(latest.AsTime()) {
+				latest = h.Date
+			}
+		}
+		return newCard(&export.Row{Sotr: s, Dep: s.ParentId, Path: path}, latest), nil
+	}
+	return nil, notFound("card %s not found in address book %s", t.tabnum, t.book)
+}
+
+// ancestors returns the idr of the top-level dep of the dep and names of deps from the top-level one
+func (c *carddav) ancestors(ctx context.Context, idr string) (top string, path []string, err error) {
+	src := storSource{c.cli}
+	seen := map[string]struct{}{}
+
+	for idr != "" {
+		if _, ok := seen[idr]; ok {
+			return "", nil, fmt.Errorf("cycle of deps at %s", idr)
+		}
+		seen[idr] = struct{}{}
+
+		deps, err := src.GetDepsBy(ctx, &kbv1.DepRequest{Str: idr, Field: kbv1.DepRequest_IDR})
+		if err != nil {
+			return "", nil, err
+		}
+		if len(deps) == 0 {
+			break
+		}
+		top = deps[0].Idr
+		path = append(path, deps[0].Text)
+		idr = deps[0].Parent
+	}
+
+	slices.Reverse(path)
+	return top, path, nil
+}
+
+// vcard returns the vCard of the card with the thumbnail of the avatar
+func (c *carddav) vcard(ctx context.Context, cd *card) ([]byte, error) {
+	var b bytes.Buffer
+	err := export.WriteVCard(&b, cd.row, storPhotos{ctx: ctx, cli: c.cli}, export.VCard3)
+	return b.Bytes(), err
+}
+
+// rowsWriter keeps exported rows
+type rowsWriter []*export.Row
+
+func (w *rowsWriter) Write(r *export.Row) error {
+	*w = append(*w, r)
+	return nil
+}
+
+func (w *rowsWriter) Close() error {
+	return nil
+}
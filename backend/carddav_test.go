@@ -0,0 +1,304 @@
+package backend
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/mem"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// storClient calls the storage like the gRPC client of the gateway, requests of histories are counted
+type storClient struct {
+	kbv1.StorAPIClient
+	ps        *PStor
+	histories *atomic.Int32
+}
+
+func (c storClient) GetDepsBy(ctx context.Context, q *kbv1.DepRequest, _ ...grpc.CallOption) (*kbv1.DepsResponse, error) {
+	return c.ps.GetDepsBy(ctx, q)
+}
+
+func (c storClient) GetSotrsBy(ctx context.Context, q *kbv1.SotrRequest, _ ...grpc.CallOption) (*kbv1.SotrsResponse, error) {
+	return c.ps.GetSotrsBy(ctx, q)
+}
+
+func (c storClient) GetHistory(ctx context.Context, q *kbv1.HistRequest, _ ...grpc.CallOption) (*kbv1.HistoryListResponse, error) {
+	c.histories.Add(1)
+	return c.ps.GetHistory(ctx, q)
+}
+
+func (c storClient) GetVersions(ctx context.Context, q *kbv1.VersionsRequest, _ ...grpc.CallOption) (*kbv1.VersionsResponse, error) {
+	c.histories.Add(1)
+	return c.ps.GetVersions(ctx, q)
+}
+
+func (c storClient) GetGeneration(ctx context.Context, q *emptypb.Empty, _ ...grpc.CallOption) (*kbv1.GenerationResponse, error) {
+	return c.ps.GetGeneration(ctx, q)
+}
+
+func (c storClient) GetAvatar(ctx context.Context, q *kbv1.AvatarRequest, _ ...grpc.CallOption) (*kbv1.AvatarResponse, error) {
+	return c.ps.GetAvatar(ctx, q)
+}
+
+// newStore returns the memory storage of fixtures
+func newStore(t *testing.T) *mem.MemStore {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := mem.New("", slog.Default())
+	require.NoError(t, err)
+
+	deps := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(t, "dep.json", deps)
+	for _, d := range deps.Deps {
+		_, err = store.Save(ctx, d)
+		require.NoError(t, err)
+	}
+	// the mem store is flushed after deps and after employees
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	sotrs := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(t, "sotr.json", sotrs)
+	for _, s := range sotrs.Sotrs {
+		_, err = store.Save(ctx, s)
+		require.NoError(t, err)
+	}
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	return store
+}
+
+func newCardDAV(t *testing.T) (*httptest.Server, storClient) {
+	t.Helper()
+
+	cli := storClient{ps: &PStor{stor: newStore(t)}, histories: new(atomic.Int32)}
+	srv := httptest.NewServer(carddavHandler(cli, slog.Default()))
+	t.Cleanup(srv.Close)
+	return srv, cli
+}
+
+// davMultistatus is the 207 response, properties are matched in any namespace
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Status    string `xml:"status"`
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					AddressBook *struct{} `xml:"addressbook"`
+				} `xml:"resourcetype"`
+				DisplayName string `xml:"displayname"`
+				ETag        string `xml:"getetag"`
+				SyncToken   string `xml:"sync-token"`
+				AddressData string `xml:"address-data"`
+				HomeSet     string `xml:"addressbook-home-set>href"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+	SyncToken string `xml:"sync-token"`
+}
+
+func (m *davMultistatus) hrefs() (hrefs []string) {
+	for _, r := range m.Responses {
+		hrefs = append(hrefs, r.Href)
+	}
+	return
+}
+
+func davDo(t *testing.T, srv *httptest.Server, method, path, depth, body string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(b)
+}
+
+func davMulti(t *testing.T, srv *httptest.Server, method, path, depth, body string) *davMultistatus {
+	t.Helper()
+
+	resp, b := davDo(t, srv, method, path, depth, body)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode, b)
+
+	ms := &davMultistatus{}
+	require.NoError(t, xml.Unmarshal([]byte(b), ms), b)
+	return ms
+}
+
+const (
+	book     = "/carddav/razd1.27.2935/"
+	syncBody = `<D:sync-collection xmlns:D="DAV:"><D:sync-token>%s</D:sync-token><D:sync-level>1</D:sync-level>` +
+		`<D:prop><D:getetag/></D:prop></D:sync-collection>`
+)
+
+func TestCardDAVPropfind(t *testing.T) {
+	srv, _ := newCardDAV(t)
+
+	ms := davMulti(t, srv, "PROPFIND", "/carddav/", "1", `<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+  <D:prop><D:resourcetype/><D:displayname/><C:addressbook-home-set/><D:sync-token/><D:unknown/></D:prop>
+</D:propfind>`)
+	require.Equal(t, []string{"/carddav/", book}, ms.hrefs())
+	assert.Equal(t, "/carddav/", ms.Responses[0].Propstats[0].Prop.HomeSet)
+
+	b := ms.Responses[1]
+	require.Len(t, b.Propstats, 2)
+	assert.Equal(t, "HTTP/1.1 200 OK", b.Propstats[0].Status)
+	assert.NotNil(t, b.Propstats[0].Prop.ResourceType.AddressBook)
+	assert.Equal(t, "Департамент финансовых институтов", b.Propstats[0].Prop.DisplayName)
+	assert.True(t, strings.HasPrefix(b.Propstats[0].Prop.SyncToken, syncTokenPrefix))
+	// the unknown property is not found
+	assert.Equal(t, "HTTP/1.1 404 Not Found", b.Propstats[1].Status)
+
+	// cards of the subtree of the book
+	ms = davMulti(t, srv, "PROPFIND", book, "1", "")
+	assert.Equal(t, []string{book,
+		book + "1122.vcf", book + "1600.vcf", book + "25301.vcf", book + "2681.vcf",
+		book + "52957.vcf", book + "60609.vcf", book + "63665.vcf",
+	}, ms.hrefs())
+
+	// deps under the top-level one are not address books
+	resp, _ := davDo(t, srv, "PROPFIND", "/carddav/razd1.27.2935.37/", "0", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = davDo(t, srv, "PROPFIND", book+"1.vcf", "0", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestCardDAVGet(t *testing.T) {
+	srv, _ := newCardDAV(t)
+
+	resp, b := davDo(t, srv, http.MethodGet, book+"60609.vcf", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, b)
+	assert.Equal(t, vcardContentType, resp.Header.Get("Content-Type"))
+	assert.Contains(t, b, "VERSION:3.0\r\n")
+	// long lines are folded
+	assert.Contains(t, strings.ReplaceAll(b, "\r\n ", ""), "ORG:Департамент финансовых институтов;Управление финансовых институтов;Отдел корреспондентских отношений\r\n")
+
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	req, err := http.NewRequest(http.MethodGet, srv.URL+book+"60609.vcf", nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = srv.Client().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	// address books are read-only
+	resp, _ = davDo(t, srv, http.MethodPut, book+"60609.vcf", "", "BEGIN:VCARD\r\nEND:VCARD\r\n")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	resp, _ = davDo(t, srv, http.MethodOptions, book, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("DAV"), "addressbook")
+}
+
+func TestCardDAVReport(t *testing.T) {
+	srv, _ := newCardDAV(t)
+
+	ms := davMulti(t, srv, "REPORT", book, "1", `<C:addressbook-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+  <D:prop><D:getetag/><C:address-data/></D:prop>
+  <D:href>`+book+`1600.vcf</D:href>
+  <D:href>`+book+`404.vcf</D:href>
+</C:addressbook-multiget>`)
+	require.Equal(t, []string{book + "1600.vcf", book + "404.vcf"}, ms.hrefs())
+	assert.NotEmpty(t, ms.Responses[0].Propstats[0].Prop.ETag)
+	assert.Contains(t, ms.Responses[0].Propstats[0].Prop.AddressData, "UID:urn:kbemp:tabnum:1600\r\n")
+	assert.Equal(t, "HTTP/1.1 404 Not Found", ms.Responses[1].Status)
+
+	ms = davMulti(t, srv, "REPORT", book, "1", `<C:addressbook-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:carddav">
+  <D:prop><D:getetag/></D:prop>
+  <C:filter test="anyof">
+    <C:prop-filter name="FN"><C:text-match match-type="starts-with">пал</C:text-match></C:prop-filter>
+    <C:prop-filter name="UID"><C:text-match match-type="ends-with">:1122</C:text-match></C:prop-filter>
+  </C:filter>
+</C:addressbook-query>`)
+	assert.Equal(t, []string{book + "1122.vcf", book + "2681.vcf"}, ms.hrefs())
+}
+
+func TestCardDAVSync(t *testing.T) {
+	srv, cli := newCardDAV(t)
+	ctx := context.Background()
+
+	ms := davMulti(t, srv, "REPORT", book, "", strings.Replace(syncBody, "%s", "", 1))
+	assert.Len(t, ms.Responses, 7)
+	token := ms.SyncToken
+	require.NotEmpty(t, token)
+
+	ms = davMulti(t, srv, "REPORT", book, "", strings.Replace(syncBody, "%s", token, 1))
+	assert.Empty(t, ms.Responses)
+	assert.Equal(t, token, ms.SyncToken)
+
+	// changed and added employees are synchronized by the token
+	time.Sleep(time.Millisecond)
+	sotrs, err := cli.ps.GetSotrsBy(ctx, &kbv1.SotrRequest{Str: "1600", Field: kbv1.SotrRequest_TABNUM})
+	require.NoError(t, err)
+	require.Len(t, sotrs.Sotrs, 1)
+	sotrs.Sotrs[0].Grade = "Советник"
+	_, err = cli.ps.Update(ctx, &kbv1.UpdateSotrRequest{Sotr: sotrs.Sotrs[0]})
+	require.NoError(t, err)
+	_, err = cli.ps.Update(ctx, &kbv1.UpdateSotrRequest{Sotr: &kbv1.Sotr{Tabnum: "777", Name: "Новый Сотрудник", ParentId: "razd1.27.2935.69"}})
+	require.NoError(t, err)
+
+	ms = davMulti(t, srv, "REPORT", book, "", strings.Replace(syncBody, "%s", token, 1))
+	assert.Equal(t, []string{book + "1600.vcf", book + "777.vcf"}, ms.hrefs())
+	assert.NotEqual(t, token, ms.SyncToken)
+
+	// the token of other cards is invalid
+	v, _, _ := strings.Cut(token, "-")
+	resp, b := davDo(t, srv, "REPORT", book, "", strings.Replace(syncBody, "%s", v+"-1", 1))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, b, "valid-sync-token")
+}
+
+func TestCardDAVLoad(t *testing.T) {
+	srv, cli := newCardDAV(t)
+	ctx := context.Background()
+
+	// the card is served by the employee and its history without loading the book
+	resp, b := davDo(t, srv, http.MethodGet, book+"60609.vcf", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, b)
+	assert.Equal(t, int32(1), cli.histories.Load())
+	resp, _ = davDo(t, srv, http.MethodGet, book+"404.vcf", "", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// versions of cards are read once for the book, the loaded book is kept in the generation
+	for range 2 {
+		ms := davMulti(t, srv, "PROPFIND", book, "1", "")
+		assert.Len(t, ms.Responses, 8)
+	}
+	assert.Equal(t, int32(2), cli.histories.Load())
+	resp, b = davDo(t, srv, http.MethodGet, book+"1600.vcf", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode, b)
+	assert.Equal(t, int32(2), cli.histories.Load())
+
+	// the book is loaded again after the change
+	_, err := cli.ps.Flush(ctx, nil)
+	require.NoError(t, err)
+	davMulti(t, srv, "REPORT", book, "", strings.Replace(syncBody, "%s", "", 1))
+	assert.Equal(t, int32(3), cli.histories.Load())
+}
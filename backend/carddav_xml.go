@@ -0,0 +1,349 @@
+package backend
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mioxin/kbempgo/internal/export"
+)
+
+// XML of WebDAV (RFC 4918) and CardDAV requests and responses
+
+const (
+	nsDAV     = "DAV:"
+	nsCardDAV = "urn:ietf:params:xml:ns:carddav"
+	// getctag of Apple clients
+	nsCalendarServer = "http://calendarserver.org/ns/"
+)
+
+const xmlHeader = `<?xml version="1.0" encoding="utf-8"?>` + "\n"
+
+// prefixes of namespaces declared by responses
+var davPrefixes = map[string]string{nsDAV: "D", nsCardDAV: "C", nsCalendarServer: "CS"}
+
+// maxDAVRequest is the max size of the request body
+const maxDAVRequest = 1 << 20
+
+func davName(local string) xml.Name {
+	return xml.Name{Space: nsDAV, Local: local}
+}
+
+func cardName(local string) xml.Name {
+	return xml.Name{Space: nsCardDAV, Local: local}
+}
+
+// allProps are responded by allprop and by the empty PROPFIND
+var allProps = []xml.Name{
+	davName("resourcetype"), davName("displayname"), davName("current-user-principal"),
+	davName("getetag"), davName("getcontenttype"), davName("getlastmodified"),
+	davName("sync-token"), {Space: nsCalendarServer, Local: "getctag"},
+}
+
+// davRequest is the body of PROPFIND and REPORT
+type davRequest struct {
+	XMLName   xml.Name
+	AllProp   *struct{}  `xml:"DAV: allprop"`
+	Prop      *davProp   `xml:"DAV: prop"`
+	Hrefs     []string   `xml:"DAV: href"`
+	SyncToken string     `xml:"DAV: sync-token"`
+	Filter    cardFilter `xml:"urn:ietf:params:xml:ns:carddav filter"`
+}
+
+type davProp struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func readDAVRequest(r *http.Request) (*davRequest, error) {
+	req := &davRequest{}
+	err := xml.NewDecoder(io.LimitReader(r.Body, maxDAVRequest)).Decode(req)
+	if errors.Is(err, io.EOF) {
+		// the empty body of PROPFIND is allprop
+		return req, nil
+	}
+	if err != nil {
+		return nil, &httpError{code: http.StatusBadRequest, msg: "invalid body: " + err.Error()}
+	}
+	return req, nil
+}
+
+// propNames returns requested properties, nil is all properties
+func (q *davRequest) propNames() []xml.Name {
+	if q.Prop == nil || q.AllProp != nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(q.Prop.Names))
+	for _, n := range q.Prop.Names {
+		names = append(names, n.XMLName)
+	}
+	return names
+}
+
+// resource is the root if the book and the card are not set
+type resource struct {
+	href string
+	book *addressBook
+	card *card
+}
+
+// props adds the response of properties of the resource, all known properties are added by nil names
+func (c *carddav) props(ctx context.Context, ms *multistatus, res resource, names []xml.Name) error {
+	all := names == nil
+	if all {
+		names = allProps
+	}
+
+	var found []string
+	var missing []xml.Name
+	for _, name := range names {
+		value, ok, err := c.prop(ctx, res, name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case ok:
+			found = append(found, element(name, value))
+		case !all:
+			missing = append(missing, name)
+		}
+	}
+
+	ms.propstat(res.href, found, missing)
+	return nil
+}
+
+// prop returns XML of the value of the property, ok is false if the resource has no property
+func (c *carddav) prop(ctx context.Context, res resource, name xml.Name) (value string, ok bool, err error) {
+	root, b, cd := res.book == nil && res.card == nil, res.book, res.card
+	home := "<D:href>" + carddavPath + "</D:href>"
+
+	switch name {
+	case davName("resourcetype"):
+		switch {
+		case root:
+			return "<D:collection/><D:principal/>", true, nil
+		case b != nil:
+			return "<D:collection/><C:addressbook/>", true, nil
+		}
+		return "", true, nil
+	case davName("displayname"):
+		switch {
+		case root:
+			return "kbemp", true, nil
+		case b != nil:
+			return xmlEscape(b.dep.Text), true, nil
+		}
+		return xmlEscape(export.Fio(cd.row.Sotr)), true, nil
+	case davName("current-user-principal"):
+		return home, true, nil
+	case davName("principal-URL"), cardName("addressbook-home-set"):
+		return home, root, nil
+	case davName("current-user-privilege-set"):
+		return "<D:privilege><D:read/></D:privilege>", true, nil
+	case davName("supported-report-set"):
+		if b == nil {
+			return "", false, nil
+		}
+		for _, report := range []string{"<C:addressbook-multiget/>", "<C:addressbook-query/>", "<D:sync-collection/>"} {
+			value += "<D:supported-report><D:report>" + report + "</D:report></D:supported-report>"
+		}
+		return value, true, nil
+	case cardName("supported-address-data"):
+		return `<C:address-data-type content-type="text/vcard" version="` + export.VCard3 + `"/>`, b != nil, nil
+	case davName("sync-token"), xml.Name{Space: nsCalendarServer, Local: "getctag"}:
+		if b == nil {
+			return "", false, nil
+		}
+		if b, err = c.loadedBook(ctx, b.dep.Idr); err != nil {
+			return "", false, err
+		}
+		return xmlEscape(b.token.String()), true, nil
+	case davName("getetag"):
+		if cd == nil {
+			return "", false, nil
+		}
+		return xmlEscape(cd.etag()), true, nil
+	case davName("getlastmodified"):
+		if cd == nil {
+			return "", false, nil
+		}
+		return cd.version.UTC().Format(http.TimeFormat), true, nil
+	case davName("getcontenttype"):
+		return vcardContentType, cd != nil, nil
+	case cardName("address-data"):
+		if cd == nil {
+			return "", false, nil
+		}
+		data, err := c.vcard(ctx, cd)
+		if err != nil {
+			return "", false, err
+		}
+		return xmlEscape(string(data)), true, nil
+	}
+	return "", false, nil
+}
+
+// element returns XML of the element, the namespace unknown by the response is declared by the element
+func element(name xml.Name, inner string) string {
+	tag := davPrefixes[name.Space] + ":" + name.Local
+	open := tag
+	if _, ok := davPrefixes[name.Space]; !ok {
+		tag = "X:" + name.Local
+		open = tag + ` xmlns:X="` + xmlEscape(name.Space) + `"`
+	}
+
+	if inner == "" {
+		return "<" + open + "/>"
+	}
+	return "<" + open + ">" + inner + "</" + tag + ">"
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// multistatus is the body of the 207 response
+type multistatus struct {
+	b strings.Builder
+	// syncToken is the new token of sync-collection
+	syncToken string
+}
+
+// propstat adds the response of found and missing properties
+func (m *multistatus) propstat(href string, found []string, missing []xml.Name) {
+	m.b.WriteString("<D:response><D:href>" + xmlEscape(href) + "</D:href>")
+	if len(found) > 0 {
+		m.b.WriteString("<D:propstat><D:prop>" + strings.Join(found, "") + "</D:prop>")
+		m.b.WriteString("<D:status>" + statusLine(http.StatusOK) + "</D:status></D:propstat>")
+	}
+	if len(missing) > 0 {
+		m.b.WriteString("<D:propstat><D:prop>")
+		for _, name := range missing {
+			m.b.WriteString(element(name, ""))
+		}
+		m.b.WriteString("</D:prop><D:status>" + statusLine(http.StatusNotFound) + "</D:status></D:propstat>")
+	}
+	m.b.WriteString("</D:response>")
+}
+
+// status adds the response of the status of the resource
+func (m *multistatus) status(href string, code int) {
+	m.b.WriteString("<D:response><D:href>" + xmlEscape(href) + "</D:href><D:status>" + statusLine(code) + "</D:status></D:response>")
+}
+
+func (m *multistatus) send(w http.ResponseWriter) {
+	if m.syncToken != "" {
+		m.b.WriteString("<D:sync-token>" + xmlEscape(m.syncToken) + "</D:sync-token>")
+	}
+
+	var ns []string
+	for space, prefix := range davPrefixes {
+		ns = append(ns, " xmlns:"+prefix+`="`+space+`"`)
+	}
+	slices.Sort(ns)
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xmlHeader+"<D:multistatus"+strings.Join(ns, "")+">"+m.b.String()+"</D:multistatus>")
+}
+
+func statusLine(code int) string {
+	return "HTTP/1.1 " + strconv.Itoa(code) + " " + http.StatusText(code)
+}
+
+// cardFilter is the filter of addressbook-query (RFC 6352 10.5), values are matched case-insensitively
+type cardFilter struct {
+	Test  string       `xml:"test,attr"`
+	Props []propFilter `xml:"urn:ietf:params:xml:ns:carddav prop-filter"`
+}
+
+type propFilter struct {
+	Name         string      `xml:"name,attr"`
+	Test         string      `xml:"test,attr"`
+	IsNotDefined *struct{}   `xml:"urn:ietf:params:xml:ns:carddav is-not-defined"`
+	TextMatches  []textMatch `xml:"urn:ietf:params:xml:ns:carddav text-match"`
+}
+
+type textMatch struct {
+	Text      string `xml:",chardata"`
+	MatchType string `xml:"match-type,attr"`
+	Negate    string `xml:"negate-condition,attr"`
+}
+
+// match reports whether the employee matches the filter, the empty filter matches all employees
+func (f *cardFilter) match(r *export.Row) bool {
+	if len(f.Props) == 0 {
+		return true
+	}
+	return matchTest(f.Test, len(f.Props), func(i int) bool { return f.Props[i].match(r) })
+}
+
+func (p *propFilter) match(r *export.Row) bool {
+	values := cardValues(r, p.Name)
+	switch {
+	case p.IsNotDefined != nil:
+		return len(values) == 0
+	case len(p.TextMatches) == 0:
+		return len(values) > 0
+	}
+	return matchTest(p.Test, len(p.TextMatches), func(i int) bool { return p.TextMatches[i].match(values) })
+}
+
+func (t *textMatch) match(values []string) bool {
+	text := strings.ToLower(t.Text)
+	ok := slices.ContainsFunc(values, func(v string) bool {
+		v = strings.ToLower(v)
+		switch t.MatchType {
+		case "equals":
+			return v == text
+		case "starts-with":
+			return strings.HasPrefix(v, text)
+		case "ends-with":
+			return strings.HasSuffix(v, text)
+		}
+		return strings.Contains(v, text)
+	})
+	return ok != (t.Negate == "yes")
+}
+
+// matchTest reports whether all (allof) or any (anyof by default) of n conditions are matched
+func matchTest(test string, n int, match func(i int) bool) bool {
+	all := test == "allof"
+	for i := range n {
+		if match(i) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// cardValues returns values of the vCard property of the employee
+func cardValues(r *export.Row, name string) (values []string) {
+	s := r.Sotr
+	switch strings.ToUpper(name) {
+	case "FN":
+		values = []string{export.Fio(s)}
+	case "N":
+		values = []string{s.Name, s.MidName}
+	case "UID":
+		values = []string{"urn:kbemp:tabnum:" + s.Tabnum}
+	case "ORG":
+		values = slices.Clone(r.Path)
+	case "TITLE":
+		values = []string{s.Grade}
+	case "TEL":
+		values = append(slices.Clone(s.Phone), s.Mobile...)
+	case "EMAIL":
+		values = []string{s.Email}
+	}
+	return slices.DeleteFunc(values, func(v string) bool { return v == "" })
+}
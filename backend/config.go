@@ -2,10 +2,15 @@ package backend
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/mioxin/kbempgo/internal/avatar"
+	"github.com/mioxin/kbempgo/internal/ldapsrv"
+	"github.com/mioxin/kbempgo/internal/orgchart"
 	gsrv "github.com/mioxin/kbempgo/pkg/grpc_server"
 	"github.com/mioxin/kbempgo/pkg/otel"
 	"github.com/mioxin/kbempgo/pkg/prometheus"
+	"github.com/mioxin/kbempgo/pkg/redis"
 )
 
 // Config of slicd
@@ -15,6 +20,16 @@ type Config struct {
 	Prometheus prometheus.ClientConfig `embed:"" json:"prometheus" prefix:"prometheus-"`
 	// Log        slog.Logger             `embed:"" yaml:",inline"`
 	Otel otel.OtelConfig `embed:"" json:"otel" prefix:"otel-" help:"OpenTelemetry config"`
+	// cache of storage queries is enabled if redis addresses are set
+	Redis    redis.ClientConfig `embed:"" json:"redis" prefix:"redis-"`
+	CacheTTL time.Duration      `json:"cache-ttl" name:"cache-ttl" default:"5m" help:"TTL of cached storage queries in Redis"`
+	// directory of the avatar store filled by the dump
+	Avatars string             `json:"avatars" name:"avatars" help:"Directory of avatar images of employees served by GetAvatar"`
+	Thumbs  avatar.ThumbConfig `embed:"" json:"avatar-thumb" prefix:"avatar-thumb-"`
+	// read-only LDAP server of the directory is enabled if the listen address is set
+	LDAP ldapsrv.Config `embed:"" json:"ldap" prefix:"ldap-"`
+	// inference of managers of GetReports and GetManagerChain
+	OrgChart orgchart.Config `embed:"" json:"org" prefix:"org-"`
 }
 
 func (config *Config) AfterApply() error {
@@ -0,0 +1,146 @@
+package backend
+
+import (
+	"context"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/avatar"
+	"github.com/mioxin/kbempgo/internal/export"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exportPath is the REST route of the export of employees
+const exportPath = "/api/stor/v1/export"
+
+// storSource reads deps and employees of the export by the gRPC client of the gateway
+type storSource struct {
+	cli kbv1.StorAPIClient
+}
+
+func (s storSource) GetDepsBy(ctx context.Context, q *kbv1.DepRequest) ([]*kbv1.Dep, error) {
+	resp, err := s.cli.GetDepsBy(ctx, q)
+	return resp.GetDeps(), err
+}
+
+func (s storSource) GetSotrsBy(ctx context.Context, q *kbv1.SotrRequest) ([]*kbv1.Sotr, error) {
+	resp, err := s.cli.GetSotrsBy(ctx, q)
+	return resp.GetSotrs(), err
+}
+
+// photoSize is the size of thumbnails of avatars embedded to vCard and LDIF
+const photoSize = 96
+
+// storPhotos reads thumbnails of avatars embedded to vCard and LDIF by GetAvatar
+type storPhotos struct {
+	ctx context.Context
+	cli kbv1.StorAPIClient
+}
+
+func (p storPhotos) Photo(tabnum string) ([]byte, string, error) {
+	resp, err := p.cli.GetAvatar(p.ctx, &kbv1.AvatarRequest{Tabnum: tabnum, Size: photoSize, Format: avatar.FormatJPEG})
+	switch status.Code(err) {
+	case codes.OK:
+		return resp.Data, resp.ContentType, nil
+	case codes.NotFound, codes.Unavailable:
+		// the employee has no avatar or the avatar store is not configured
+		return nil, "", nil
+	}
+	return nil, "", err
+}
+
+// exportHandler streams employees of branches or employees by tabnums as the file:
+// ?branch=razd1&branch=razd2&format=xlsx&columns=fio,email
+// ?tabnum=1600&format=vcard
+// ?branch=razd1&format=vcard&zip=true, ?branch=razd1&format=ldif&base_dn=dc=example,dc=com&photo=false
+func exportHandler(cli kbv1.StorAPIClient, lg *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		branches, tabnums := q["branch"], q["tabnum"]
+		if len(branches) == 0 && len(tabnums) == 0 {
+			http.Error(w, "branch or tabnum is required", http.StatusBadRequest)
+			return
+		}
+
+		cfg := &export.Config{
+			Format:  q.Get("format"),
+			Columns: strings.Split(q.Get("columns"), ","),
+			Zip:     q.Get("zip") == "true",
+			BaseDN:  q.Get("base_dn"),
+			Photo:   q.Get("photo") != "false",
+			Photos:  storPhotos{ctx: r.Context(), cli: cli},
+		}
+		if cfg.Format == "" {
+			cfg.Format = export.FormatCSV
+		}
+		if q.Get("columns") == "" {
+			cfg.Columns = export.DefaultColumns
+		}
+		if cfg.BaseDN == "" {
+			cfg.BaseDN = "dc=kbemp"
+		}
+
+		// the status is sent with the first written byte, errors before it are responded as is
+		cw := &countWriter{ResponseWriter: w}
+		ew, err := export.NewWriter(cw, cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		name := "employees-" + strings.Join(branches, "_")
+		if len(branches) == 0 {
+			name = "employee-" + strings.Join(tabnums, "_")
+		}
+		w.Header().Set("Content-Type", cfg.ContentType())
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment",
+			map[string]string{"filename": name + "." + cfg.Ext()}))
+
+		var n int
+		if len(branches) > 0 {
+			n, err = export.Export(r.Context(), storSource{cli}, branches, ew)
+		} else {
+			n, err = export.ExportSotrs(r.Context(), storSource{cli}, tabnums, ew)
+		}
+		if err == nil {
+			err = ew.Close()
+		}
+		if err == nil {
+			lg.Info("Export", "branches", branches, "tabnums", tabnums, "format", cfg.Format, "sotrs", n)
+			return
+		}
+
+		lg.Error("Export", "branches", branches, "tabnums", tabnums, "format", cfg.Format, "sotrs", n, "err", err)
+		if cw.n > 0 {
+			return
+		}
+		w.Header().Del("Content-Disposition")
+		code := http.StatusBadGateway
+		if status.Code(err) == codes.InvalidArgument {
+			code = http.StatusBadRequest
+		}
+		http.Error(w, status.Convert(err).Message(), code)
+	})
+}
+
+// countWriter counts bytes written to the response
+type countWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -2,12 +2,23 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
 
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/avatar"
+	"github.com/mioxin/kbempgo/internal/orgchart"
 	"github.com/mioxin/kbempgo/internal/storage"
+	"github.com/mioxin/kbempgo/internal/storage/cache"
+	"github.com/mioxin/kbempgo/pkg/redis"
 	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -15,8 +26,17 @@ import (
 type PStor struct {
 	kbv1.UnimplementedStorAPIServer
 	stor    storage.Store
+	avatars *avatar.Store
+	org     orgchart.Config
 	lg      *slog.Logger
 	dbmetrx prometheus.Collector
+	// generation of the storage without the shared one, it's changed by Update and Flush of the backend
+	gen atomic.Int64
+
+	// reporting lines built for the generation chartGen of the storage
+	chartMu  sync.Mutex
+	chartGen int64
+	orgChart *orgchart.Chart
 }
 
 // Creating persistent storage
@@ -32,11 +52,44 @@ func NewPStor(cfg *CLI) (*PStor, error) {
 		return nil, err
 	}
 
-	return &PStor{
+	if len(cfg.Redis.Addrs) > 0 {
+		rdb, err := redis.NewUniversalClient(&cfg.Redis, &redis.ClientOptions{Lg: lg})
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("create cache of storage: %w", err)
+		}
+
+		lg.Info("Cache of storage queries enabled", "addrs", cfg.Redis.Addrs, "ttl", cfg.CacheTTL)
+		s = cache.New(s, rdb, cfg.CacheTTL, cfg.Log)
+	}
+
+	// storage metrics are served by /metrics of the gateway from the default registry
+	dbmetrx := s.PromCollector()
+	if dbmetrx != nil {
+		if err = prometheus.Register(dbmetrx); err != nil {
+			lg.Error("Failed to register storage metrics", "error", err)
+		}
+	}
+
+	ps := &PStor{
 		stor:    s,
+		org:     cfg.OrgChart,
 		lg:      lg,
-		dbmetrx: s.PromCollector(),
-	}, nil
+		dbmetrx: dbmetrx,
+	}
+
+	if cfg.Avatars != "" {
+		if err = cfg.Thumbs.Validate(); err != nil {
+			s.Close()
+			return nil, err
+		}
+		if ps.avatars, err = avatar.NewStore(cfg.Avatars, cfg.Log); err != nil {
+			s.Close()
+			return nil, err
+		}
+		ps.avatars.Thumbs = cfg.Thumbs
+	}
+	return ps, nil
 }
 
 // gRPC implementation
@@ -77,14 +130,201 @@ func (ps *PStor) Save(ctx context.Context, query *kbv1.Item) (empty *emptypb.Emp
 	return
 }
 
+// SaveItems saves the stream of items until the client closes it
+func (ps *PStor) SaveItems(stream kbv1.StorAPI_SaveItemsServer) error {
+	n := 0
+	for {
+		item, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			ps.lg.Debug("Saved stream of items", "num", n)
+			return stream.SendAndClose(&emptypb.Empty{})
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err = ps.Save(stream.Context(), item); err != nil {
+			return err
+		}
+		n++
+	}
+}
+
 func (ps *PStor) Flush(ctx context.Context, em *emptypb.Empty) (*emptypb.Empty, error) {
+	defer ps.gen.Add(1)
 	return ps.stor.Flush(ctx, em)
 }
 
+// Update updates the employee by tabnum
+func (ps *PStor) Update(ctx context.Context, q *kbv1.UpdateSotrRequest) (*emptypb.Empty, error) {
+	defer ps.gen.Add(1)
+	return ps.stor.Update(ctx, q)
+}
+
+// Generation returns the generation of the storage. The generation of the cache is shared by backends,
+// otherwise changes of the backend are counted.
+func (ps *PStor) Generation(ctx context.Context) (int64, error) {
+	if g, ok := ps.stor.(storage.Generations); ok {
+		return g.Generation(ctx)
+	}
+	return ps.gen.Load(), nil
+}
+
+// GetGeneration returns the generation of the storage
+func (ps *PStor) GetGeneration(ctx context.Context, _ *emptypb.Empty) (*kbv1.GenerationResponse, error) {
+	gen, err := ps.Generation(ctx)
+	return &kbv1.GenerationResponse{Generation: gen}, err
+}
+
 func (ps *PStor) Close() error {
 	return ps.stor.Close()
 }
 
 func (ps *PStor) GetHistory(ctx context.Context, geq *kbv1.HistRequest) (lhist *kbv1.HistoryListResponse, err error) {
+	return ps.stor.GetHistory(ctx, geq)
+}
+
+// GetVersions returns times of the latest changes in histories of employees
+func (ps *PStor) GetVersions(ctx context.Context, q *kbv1.VersionsRequest) (*kbv1.VersionsResponse, error) {
+	return ps.stor.GetVersions(ctx, q)
+}
+
+// GetAvatar returns the current avatar of the employee from the avatar store.
+// Metadata is taken from the storage if it keeps avatars, otherwise from the avatar store.
+func (ps *PStor) GetAvatar(ctx context.Context, q *kbv1.AvatarRequest) (resp *kbv1.AvatarResponse, err error) {
+	if ps.avatars == nil {
+		return nil, status.Error(codes.Unavailable, "avatar store is not configured")
+	}
+
+	var a *kbv1.Avatar
+	if as, ok := ps.stor.(storage.Avatars); ok {
+		if a, err = as.GetAvatar(ctx, q.Tabnum); err != nil {
+			return nil, err
+		}
+	}
+	if a == nil {
+		if m, ok := ps.avatars.Meta(q.Tabnum); ok {
+			a = m.Avatar()
+		}
+	}
+	if a == nil {
+		return nil, status.Errorf(codes.NotFound, "avatar of %s not found", q.Tabnum)
+	}
+
+	if q.Size == 0 {
+		resp = &kbv1.AvatarResponse{Avatar: a, Etag: a.Hash, ContentType: a.ContentType}
+		if q.IfNoneMatch == resp.Etag {
+			resp.NotModified = true
+			return
+		}
+
+		resp.Data, err = os.ReadFile(ps.avatars.Path(a.Hash))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "image of avatar %s not found", a.Hash)
+		}
+		return
+	}
+
+	return ps.getThumb(a, int(q.Size), q.Format, q.IfNoneMatch)
+}
+
+// getThumb returns the thumbnail of the avatar. Missing thumbnails of configured sizes are made on demand.
+func (ps *PStor) getThumb(a *kbv1.Avatar, size int, format, ifNoneMatch string) (resp *kbv1.AvatarResponse, err error) {
+	if format == "" {
+		format = avatar.FormatJPEG
+	}
+
+	resp = &kbv1.AvatarResponse{
+		Avatar:      a,
+		Etag:        fmt.Sprintf("%s-%d-%s", a.Hash, size, format),
+		ContentType: avatar.ContentType(format),
+	}
+	if ifNoneMatch == resp.Etag {
+		resp.NotModified = true
+		return
+	}
+
+	path := ps.avatars.ThumbPath(a.Hash, size, format)
+	resp.Data, err = os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && ps.avatars.Thumbs.HasThumb(size, format) {
+		if err = ps.avatars.Thumbnails(a.Hash); err != nil {
+			ps.lg.Warn("Make thumbnails of avatar", "hash", a.Hash, "err", err)
+		}
+		resp.Data, err = os.ReadFile(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, status.Errorf(codes.NotFound, "thumbnail %d %s of avatar %s not found", size, format, a.Hash)
+	}
+	return
+}
+
+// ListNews returns news without comments, the newest first.
+func (ps *PStor) ListNews(ctx context.Context, q *kbv1.ListNewsRequest) (*kbv1.NewsListResponse, error) {
+	ns, ok := ps.stor.(storage.News)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "storage doesn't keep news")
+	}
+
+	news, err := ns.ListNews(ctx, q)
+	return &kbv1.NewsListResponse{News: news}, err
+}
+
+// GetNews returns the news with comments.
+func (ps *PStor) GetNews(ctx context.Context, q *kbv1.NewsRequest) (n *kbv1.News, err error) {
+	ns, ok := ps.stor.(storage.News)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "storage doesn't keep news")
+	}
+
+	if n, err = ns.GetNews(ctx, q.Idn); err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, status.Errorf(codes.NotFound, "news %s not found", q.Idn)
+	}
 	return
 }
+
+// GetReports returns employees managed by the employee
+func (ps *PStor) GetReports(ctx context.Context, q *kbv1.ReportsRequest) (*kbv1.SotrsResponse, error) {
+	c, err := ps.chart(ctx, q.Tabnum)
+	if err != nil {
+		return nil, err
+	}
+	return &kbv1.SotrsResponse{Sotrs: c.Reports(q.Tabnum, q.Recursive)}, nil
+}
+
+// GetManagerChain returns managers of the employee from the direct manager to the top one
+func (ps *PStor) GetManagerChain(ctx context.Context, q *kbv1.ManagerChainRequest) (*kbv1.SotrsResponse, error) {
+	c, err := ps.chart(ctx, q.Tabnum)
+	if err != nil {
+		return nil, err
+	}
+	return &kbv1.SotrsResponse{Sotrs: c.ManagerChain(q.Tabnum)}, nil
+}
+
+// chart returns reporting lines of the storage, the employee must exist.
+// Lines are loaded again when the generation of the storage is changed by Flush or Update.
+func (ps *PStor) chart(ctx context.Context, tabnum string) (*orgchart.Chart, error) {
+	gen, err := ps.Generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ps.chartMu.Lock()
+	defer ps.chartMu.Unlock()
+
+	if ps.orgChart == nil || ps.chartGen != gen {
+		c, err := orgchart.Load(ctx, ps.stor, ps.org)
+		if err != nil {
+			return nil, err
+		}
+		ps.orgChart, ps.chartGen = c, gen
+	}
+
+	c := ps.orgChart
+	if _, ok := c.Sotr(tabnum); !ok {
+		return nil, status.Errorf(codes.NotFound, "employee %s not found", tabnum)
+	}
+	return c, nil
+}
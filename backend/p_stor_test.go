@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/orgchart"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func tabnums(sotrs []*kbv1.Sotr) (tn []string) {
+	for _, s := range sotrs {
+		tn = append(tn, s.Tabnum)
+	}
+	return
+}
+
+func TestReportingLines(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	ps := &PStor{stor: store, org: orgchart.Config{HeadGrades: []string{"начальник", "директор"}}}
+
+	resp, err := ps.GetManagerChain(ctx, &kbv1.ManagerChainRequest{Tabnum: "60609"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"52957", "63665", "1600"}, tabnums(resp.Sotrs))
+
+	resp, err = ps.GetReports(ctx, &kbv1.ReportsRequest{Tabnum: "63665"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"52957"}, tabnums(resp.Sotrs))
+	resp, err = ps.GetReports(ctx, &kbv1.ReportsRequest{Tabnum: "63665", Recursive: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"52957", "60609"}, tabnums(resp.Sotrs))
+
+	// reporting lines are loaded once for the generation of the storage
+	chart := ps.orgChart
+	_, err = ps.GetManagerChain(ctx, &kbv1.ManagerChainRequest{Tabnum: "60609"})
+	require.NoError(t, err)
+	assert.Same(t, chart, ps.orgChart)
+
+	// the manager set by Update replaces the inferred one
+	sotrs, err := store.GetSotrsBy(ctx, &kbv1.SotrRequest{Str: "60609", Field: kbv1.SotrRequest_TABNUM})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+	sotrs[0].ManagerTabnum = "63665"
+	_, err = ps.Update(ctx, &kbv1.UpdateSotrRequest{Sotr: sotrs[0]})
+	require.NoError(t, err)
+
+	resp, err = ps.GetManagerChain(ctx, &kbv1.ManagerChainRequest{Tabnum: "60609"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"63665", "1600"}, tabnums(resp.Sotrs))
+	resp, err = ps.GetReports(ctx, &kbv1.ReportsRequest{Tabnum: "63665"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"52957", "60609"}, tabnums(resp.Sotrs))
+
+	// the cleared manager is inferred again
+	sotrs[0].ManagerTabnum = ""
+	_, err = ps.Update(ctx, &kbv1.UpdateSotrRequest{
+		Sotr:  sotrs[0],
+		Clear: &fieldmaskpb.FieldMask{Paths: []string{"manager_tabnum"}},
+	})
+	require.NoError(t, err)
+
+	resp, err = ps.GetManagerChain(ctx, &kbv1.ManagerChainRequest{Tabnum: "60609"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"52957", "63665", "1600"}, tabnums(resp.Sotrs))
+
+	_, err = ps.GetReports(ctx, &kbv1.ReportsRequest{Tabnum: "404"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -3,11 +3,14 @@ package backend
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/ldapsrv"
+	"github.com/mioxin/kbempgo/internal/storage"
 	gsrv "github.com/mioxin/kbempgo/pkg/grpc_server"
 )
 
@@ -90,6 +93,14 @@ func start(e *CLI, store *PStor) error {
 		return err
 	}
 
+	// images are served as is, not as JSON of the gateway
+	gw.Mux().Handle(avatarPath, avatarHandler(kbv1.NewStorAPIClient(gw.Conn)))
+	// files of the export are streamed as is
+	gw.Mux().Handle(exportPath, exportHandler(kbv1.NewStorAPIClient(gw.Conn), e.Log.With("srv", "export")))
+	// read-only address books of top-level sections
+	gw.Mux().Handle(carddavPath, carddavHandler(kbv1.NewStorAPIClient(gw.Conn), e.Log.With("srv", "carddav")))
+	gw.Mux().Handle(wellKnownCardDAV, http.RedirectHandler(carddavPath, http.StatusMovedPermanently))
+
 	go func() {
 		err := gw.Serve()
 		if err != nil {
@@ -97,6 +108,28 @@ func start(e *CLI, store *PStor) error {
 		}
 	}()
 
+	// start LDAP server
+	if e.LDAP.Listen != "" {
+		lsrv, err := ldapsrv.New(e.LDAP, ldapSource{Store: store.stor, ps: store}, e.Log.With("srv", "LDAP"))
+		if err != nil {
+			e.Log.Error("LDAP server failed to construct", "error", err)
+			return err
+		}
+
+		defer func() {
+			e.Log.Info("Stopping LDAP service...")
+			if err := lsrv.Stop(); err != nil {
+				e.Log.Error("Failed to stop LDAP server", "error", err)
+			}
+		}()
+
+		go func() {
+			if err := lsrv.Run(); err != nil {
+				e.Log.Error("Failed to serve LDAP", "error", err)
+			}
+		}()
+	}
+
 	// XXX TODO: control readiness
 	gw.IsReady.Store(true)
 
@@ -107,3 +140,13 @@ func start(e *CLI, store *PStor) error {
 
 	return nil
 }
+
+// ldapSource is the storage of the LDAP server with the generation of the backend
+type ldapSource struct {
+	storage.Store
+	ps *PStor
+}
+
+func (s ldapSource) Generation(ctx context.Context) (int64, error) {
+	return s.ps.Generation(ctx)
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/coordinator"
+	"github.com/mioxin/kbempgo/internal/dump"
+	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/pkg/grpc_client"
+	"github.com/mioxin/kbempgo/pkg/redis"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// runShared dumps employees with other processes of the crawl shared by the coordinator.
+// Items are streamed to the backend, the last process leaving the completed crawl flushes the backend.
+func (e *dumpCommand) runShared(ctx context.Context, cli *CLI) (err error) {
+	if e.Quality.MaxChangeRatio > 0 || e.Quality.Report != "" {
+		e.Lg.Warn("MAIN Quality guard is disabled for the shared crawl, every process gets a part of items")
+	}
+
+	rcfg, err := redis.ParseURL(e.Coordinator)
+	if err != nil {
+		return fmt.Errorf("coordinator url: %w", err)
+	}
+	rdb, err := redis.NewUniversalClient(rcfg, &redis.ClientOptions{Lg: e.Lg})
+	if err != nil {
+		return fmt.Errorf("connect to coordinator: %w", err)
+	}
+	defer rdb.Close()
+
+	cliCfg := e.Grpc.ClientConfig()
+	if cliCfg.Address == "" {
+		return fmt.Errorf("gRPC endpoint of backend is not configured")
+	}
+	conn, err := grpc_client.NewConnection(ctx, cliCfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	gcli := kbv1.NewStorAPIClient(conn)
+
+	stream, err := gcli.SaveItems(ctx)
+	if err != nil {
+		return fmt.Errorf("stream items to backend: %w", err)
+	}
+
+	coord := coordinator.New(rdb, e.Coord, e.Lg)
+	if err = coord.Start(ctx); err != nil {
+		return err
+	}
+	defer coord.Close()
+
+	sotrCounter, depsCounter := 0, 0
+	itemsCh, crawl := dump.StartDump(ctx, &dump.Config{Config: cli.Config,
+		Workers:         e.Workers,
+		Limit:           e.Limit,
+		RootRazd:        e.RootRazd,
+		Branches:        e.Branches,
+		Depth:           e.Depth,
+		PriorityRazds:   e.PriorityRazds,
+		OpTimeout:       cli.OpTimeout,
+		WaitDataTimeout: cli.WaitDataTimeout,
+		Debug:           cli.Debug,
+		Progress:        e.Progress,
+		Frontier:        coord,
+		Lg:              cli.Log.With("cmd", "dump", "process", coord.ID()),
+	})
+
+	// items are read until the channel is closed, so workers are not blocked after the stream is broken
+	for item := range itemsCh {
+		if item.GetChildren() {
+			depsCounter++
+		} else {
+			sotrCounter++
+		}
+		if err != nil {
+			continue
+		}
+		if err = stream.Send(kbvItem(item)); err != nil {
+			e.Lg.Error("MAIN Send item to backend", "err", err)
+		}
+	}
+	e.Lg.Info("MAIN Collected.", "SotrResponse", sotrCounter, "DepsResponse", depsCounter)
+
+	if _, e := stream.CloseAndRecv(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		return fmt.Errorf("stream items to backend: %w", err)
+	}
+
+	if !crawl.Completed() {
+		return fmt.Errorf("crawl %s is not completed, pending tasks %d", e.Coord.Crawl, coord.Pending())
+	}
+
+	// items are saved after the timeout of operation too
+	ctx = context.WithoutCancel(ctx)
+
+	last, err := coord.Leave(ctx)
+	if err != nil || !last {
+		return
+	}
+
+	e.Lg.Info("MAIN The last process of the crawl flushes the backend")
+	for range 2 {
+		if _, err = gcli.Flush(ctx, &emptypb.Empty{}); err != nil {
+			return fmt.Errorf("flush backend: %w", err)
+		}
+	}
+	return coord.Expire(ctx)
+}
+
+func kbvItem(item models.Item) *kbv1.Item {
+	switch it := item.(type) {
+	case *kbv1.Dep:
+		return &kbv1.Item{Var: &kbv1.Item_Dep{Dep: it}}
+	case *kbv1.Sotr:
+		return &kbv1.Item{Var: &kbv1.Item_Sotr{Sotr: it}}
+	}
+	return &kbv1.Item{}
+}
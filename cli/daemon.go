@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"syscall"
+
+	"github.com/mioxin/kbempgo/internal/daemon"
+)
+
+type daemonCommand struct {
+	Daemon daemon.Config `embed:"" prefix:"daemon-"`
+	// Dump is the config of dump jobs, branches and depth of the job replace ones of the config.
+	// The backend of sync jobs is configured by its grpc flags.
+	Dump dumpCommand `embed:""`
+
+	Lg *slog.Logger `kong:"-"`
+}
+
+func (e *daemonCommand) Run(cli *CLI) error {
+	e.Lg = cli.Log.With("cmd", "daemon")
+
+	lock, err := daemon.NewLocker(e.Daemon.Lock, cli.StorageURL)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	d, err := daemon.New(e.Daemon, lock, func(ctx context.Context, job *daemon.Job) error {
+		return e.run(ctx, cli, job)
+	}, e.Lg)
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	e.Lg.Info("MAIN Daemon started", "jobs", len(e.Daemon.Jobs))
+	d.Start(ctx)
+	e.Lg.Info("MAIN Daemon stopped")
+	return nil
+}
+
+// run runs the command of the job. Runs don't overlap, so they share the CLI.
+func (e *daemonCommand) run(ctx context.Context, cli *CLI, job *daemon.Job) error {
+	timeout := job.Timeout
+	if timeout == 0 {
+		timeout = cli.OpTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch job.Cmd {
+	case daemon.CmdDump:
+		dump := e.Dump
+		if len(job.Branches) > 0 {
+			dump.Branches = job.Branches
+		}
+		if job.Depth > 0 {
+			dump.Depth = job.Depth
+		}
+		return dump.run(ctx, cli)
+
+	case daemon.CmdSync:
+		sync := &syncCommand{
+			Workers:    e.Dump.Workers,
+			Limit:      e.Dump.Limit,
+			RootRazd:   e.Dump.RootRazd,
+			FileSource: job.FileSource,
+			Grpc:       e.Dump.Grpc,
+		}
+		return sync.run(ctx, cli)
+	}
+	return fmt.Errorf("unknown cmd %s", job.Cmd)
+}
@@ -2,11 +2,17 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/coordinator"
 	"github.com/mioxin/kbempgo/internal/dump"
+	"github.com/mioxin/kbempgo/internal/quality"
 	"github.com/mioxin/kbempgo/internal/storage"
+	gsrv "github.com/mioxin/kbempgo/pkg/grpc_server"
 )
 
 const ()
@@ -22,8 +28,19 @@ type dumpCommand struct {
 	Workers  int    `name:"workers" short:"w" default:"5" env:"KB_WORKERS" help:"Number of workers. Every worker run 3 goroutines."`
 	Limit    int    `name:"limit" short:"l" default:"0" env:"KB_LIMIT" help:"Limit of data for get. If =0 then no limit."`
 	RootRazd string `name:"rootr" env:"KB_ROOT_RAZD" help:"Name of root section"`
+	// Branches are refreshed instead of the whole tree
+	Branches []string `name:"branch" help:"Idrs of sections refreshed with their subtrees instead of the whole tree from root section"`
+	Depth    int      `name:"depth" default:"0" help:"Levels of sections crawled below the root section or branches. If =0 then no limit."`
+	// PriorityRazds are refreshed first
+	PriorityRazds []string `name:"priority-razd" env:"KB_PRIORITY_RAZD" help:"Idrs of sections crawled first with their subtrees, in order of priority"`
+
+	Progress dump.ProgressConfig `embed:"" prefix:"progress-"`
+	Quality  quality.Config      `embed:"" prefix:"quality-"`
+	// Coordinator shares the crawl by dump processes, items are sent to the backend
+	Coordinator string             `name:"coordinator" env:"KB_COORDINATOR" help:"Redis URL of the coordinator of the crawl shared by dump processes, e.g. redis://localhost:6379/0. Items are sent to the backend by gRPC instead of the storage."`
+	Coord       coordinator.Config `embed:"" prefix:"coordinator-"`
+	Grpc        gsrv.ServerConfig  `embed:"" json:"grpc" prefix:"grpc-"`
 	// FileSource string `name:"file_source" default:"" help:"Path includes dep.json and sotr.json for insert data from ones into storage"`
-	// Grpc       gsrv.ServerConfig `embed:"" json:"grpc" prefix:"grpc-"`
 
 	// grpcClient  *grpc.ClientConn `kong:"-"`
 	Lg *slog.Logger `kong:"-"`
@@ -32,16 +49,32 @@ type dumpCommand struct {
 }
 
 func (e *dumpCommand) Run(cli *CLI) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cli.OpTimeout)
+	defer cancel()
+
+	return e.run(ctx, cli)
+}
+
+// run dumps employees until the context is done, it's used by the daemon too
+func (e *dumpCommand) run(ctx context.Context, cli *CLI) error {
 	var err error
 
 	if e.Workers <= 0 {
 		return fmt.Errorf("number of workers should be > 0")
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), cli.OpTimeout)
-	defer cancel()
+	if e.Depth < 0 {
+		return fmt.Errorf("depth should be >= 0")
+	}
 
 	e.Lg = cli.Log.With("cmd", "employ")
+
+	if e.Coordinator != "" {
+		// avatars would be saved to local directories of processes and never linked by the backend
+		if cli.Avatars != "" {
+			return fmt.Errorf("avatars (--scrape-avatars) are not supported by the shared crawl (--coordinator)")
+		}
+		return e.runShared(ctx, cli)
+	}
 	// ********************
 	// dump data from url
 	// ********************
@@ -61,15 +94,29 @@ func (e *dumpCommand) Run(cli *CLI) error {
 		}
 	}()
 
+	// items are checked by the guard before saving
+	var guard *quality.Guard
+	if e.Quality.MaxChangeRatio > 0 || e.Quality.Report != "" {
+		prev, err := cli.Store.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE})
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("get employees of the last run: %w", err)
+		}
+		guard = quality.NewGuard(e.Quality, prev, e.Lg)
+	}
+
 	sotrCounter := 0
 	depsCounter := 0
-	itemsCh := dump.StartDump(ctx, cancel, &dump.Config{Config: cli.Config,
+	itemsCh, crawl := dump.StartDump(ctx, &dump.Config{Config: cli.Config,
 		Workers:         e.Workers,
 		Limit:           e.Limit,
 		RootRazd:        e.RootRazd,
+		Branches:        e.Branches,
+		Depth:           e.Depth,
+		PriorityRazds:   e.PriorityRazds,
 		OpTimeout:       cli.OpTimeout,
 		WaitDataTimeout: cli.WaitDataTimeout,
 		Debug:           cli.Debug,
+		Progress:        e.Progress,
 		Lg:              cli.Log.With("cmd", "dump"),
 	})
 LOOP:
@@ -86,6 +133,10 @@ LOOP:
 			} else {
 				sotrCounter++
 			}
+			if guard != nil {
+				guard.Add(item)
+				continue
+			}
 			_, err := cli.Store.Save(ctx, item)
 			if err != nil {
 				e.Lg.Error("save item", "error", err)
@@ -98,5 +149,63 @@ LOOP:
 	}
 
 	e.Lg.Info("MAIN Collected.", "SotrResponse", sotrCounter, "DepsResponse", depsCounter)
-	return err
+
+	if guard != nil {
+		if err = e.check(ctx, cli.Store, guard, crawl); err != nil {
+			return err
+		}
+	}
+
+	return e.flush(ctx, cli.Store, crawl)
+}
+
+// check saves items collected by the guard if the data quality is good
+func (e *dumpCommand) check(ctx context.Context, st storage.Store, guard *quality.Guard, crawl *dump.Crawl) (err error) {
+	var scope []string
+	// employees are removed only if the crawl is completed
+	if crawl.Completed() {
+		scope = crawl.Razds()
+	}
+
+	if _, err = guard.Check(scope); err != nil {
+		return fmt.Errorf("dump is not saved: %w", err)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	for _, item := range guard.Items() {
+		if _, err := st.Save(ctx, item); err != nil {
+			e.Lg.Error("save item", "error", err)
+		}
+	}
+	return nil
+}
+
+// flush syncs the storage with saved items. Employees absent in crawled sections are removed
+// only if the crawl is completed, employees outside of crawled branches are kept.
+func (e *dumpCommand) flush(ctx context.Context, st storage.Store, crawl *dump.Crawl) (err error) {
+	// items are saved after the timeout of operation too
+	ctx = context.WithoutCancel(ctx)
+
+	if sc, ok := st.(storage.Scoper); ok && crawl.Completed() {
+		razds := crawl.Razds()
+		e.Lg.Info("MAIN Remove employees absent in crawled sections", "sections", len(razds))
+		sc.SetScope(razds)
+	}
+
+	// deps and sotrs are saved in mixed order, so both flushes are done at the end
+	for range 2 {
+		if _, err = st.Flush(ctx, nil); err != nil {
+			return fmt.Errorf("flush storage: %w", err)
+		}
+	}
+
+	// avatars are linked to employees saved by the flush
+	if as, ok := st.(storage.Avatars); ok {
+		if avatars := crawl.Avatars(); len(avatars) > 0 {
+			if err = as.SaveAvatars(ctx, avatars); err != nil {
+				return fmt.Errorf("save avatars: %w", err)
+			}
+		}
+	}
+	return
 }
@@ -1 +1,19 @@
 package cli
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/mioxin/kbempgo/internal/config"
+	"github.com/mioxin/kbempgo/internal/worker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpSharedAvatars(t *testing.T) {
+	cli := &CLI{Config: worker.Config{Avatars: t.TempDir()}, Globals: config.Globals{Log: slog.Default()}}
+	e := &dumpCommand{Workers: 1, Coordinator: "redis://localhost:6379/0"}
+
+	err := e.run(context.Background(), cli)
+	assert.ErrorContains(t, err, "--coordinator")
+}
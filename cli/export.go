@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/mioxin/kbempgo/internal/avatar"
+	"github.com/mioxin/kbempgo/internal/export"
+	"github.com/mioxin/kbempgo/internal/storage"
+)
+
+type exportCommand struct {
+	Export   export.Config `embed:""`
+	RootRazd string        `name:"rootr" env:"KB_ROOT_RAZD" help:"Name of root section"`
+	Branches []string      `name:"branch" help:"Idrs of sections exported with their subtrees instead of the whole tree from root section"`
+	Tabnums  []string      `name:"tabnum" help:"Tabnums of employees exported instead of sections"`
+	Output   string        `name:"output" help:"File of the export, employees.<ext> by default"`
+	// avatars are read from the avatar store of the dump (--scrape-avatars)
+	PhotoSize int `name:"photo-size" default:"96" help:"Size of JPEG thumbnails of avatars embedded to vCard and LDIF, 0 embeds original images"`
+
+	Lg *slog.Logger `kong:"-"`
+}
+
+func (e *exportCommand) Run(cli *CLI) (err error) {
+	branches := e.Branches
+	if len(branches) == 0 && len(e.Tabnums) == 0 {
+		if e.RootRazd == "" {
+			return fmt.Errorf("root section, branches or tabnums of export are not set")
+		}
+		branches = []string{e.RootRazd}
+	}
+	if e.Output == "" {
+		e.Output = "employees." + e.Export.Ext()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cli.OpTimeout)
+	defer cancel()
+
+	e.Lg = cli.Log.With("cmd", "export")
+
+	// open storage
+	cli.Store, err = storage.NewStore(cli.StorageURL, e.Lg)
+	if err != nil {
+		return fmt.Errorf("create storage %w", err)
+	}
+
+	defer func() {
+		cli.Log.Info("MAIN Close storage")
+		if err := cli.Store.Close(); err != nil {
+			cli.Log.Error("MAIN close storage", "err", err)
+		}
+	}()
+
+	if cli.Avatars != "" {
+		avatars, err := avatar.NewStore(cli.Avatars, e.Lg)
+		if err != nil {
+			return err
+		}
+		avatars.Thumbs = cli.Thumbs
+		e.Export.Photos = export.AvatarPhotos(avatars, e.PhotoSize)
+	}
+
+	f, err := os.Create(e.Output)
+	if err != nil {
+		return fmt.Errorf("create file of export: %w", err)
+	}
+	defer func() {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	w, err := export.NewWriter(f, &e.Export)
+	if err != nil {
+		return err
+	}
+
+	var n int
+	if len(branches) > 0 {
+		n, err = export.Export(ctx, cli.Store, branches, w)
+	} else {
+		n, err = export.ExportSotrs(ctx, cli.Store, e.Tabnums, w)
+	}
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	e.Lg.Info("MAIN Exported", "sotrs", n, "file", e.Output)
+	return nil
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/mioxin/kbempgo/internal/hrimport"
+	"github.com/mioxin/kbempgo/internal/storage"
+)
+
+type importCommand struct {
+	File    string `name:"file" required:"" help:"HR export of employees, CSV or XLSX"`
+	Mapping string `name:"mapping" help:"YAML mapping of columns of the file, columns are named tabnum, hire_date, position and manager_tabnum by default"`
+	Apply   bool   `name:"apply" help:"Save changes to the storage, only the diff is written without it"`
+
+	Lg *slog.Logger `kong:"-"`
+}
+
+func (e *importCommand) Run(cli *CLI) (err error) {
+	e.Lg = cli.Log.With("cmd", "import")
+
+	format, err := hrimport.FormatOf(e.File)
+	if err != nil {
+		return err
+	}
+	m, err := hrimport.LoadMapping(e.Mapping)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(e.File)
+	if err != nil {
+		return fmt.Errorf("open file of import: %w", err)
+	}
+	defer f.Close()
+
+	records, err := hrimport.Read(f, format, m)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", e.File, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cli.OpTimeout)
+	defer cancel()
+
+	// open storage
+	cli.Store, err = storage.NewStore(cli.StorageURL, e.Lg)
+	if err != nil {
+		return fmt.Errorf("create storage %w", err)
+	}
+
+	defer func() {
+		cli.Log.Info("MAIN Close storage")
+		if err := cli.Store.Close(); err != nil {
+			cli.Log.Error("MAIN close storage", "err", err)
+		}
+	}()
+
+	plan, err := hrimport.NewPlan(ctx, cli.Store, records, m)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	if err = plan.WriteDiff(os.Stdout); err != nil {
+		return err
+	}
+
+	if !e.Apply {
+		e.Lg.Info("MAIN Dry run, use --apply to save changes", "changed", len(plan.Changes))
+		return nil
+	}
+
+	n, err := plan.Apply(ctx, cli.Store)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	e.Lg.Info("MAIN Imported", "sotrs", n, "file", e.File)
+	return nil
+}
@@ -18,9 +18,12 @@ type CLI struct {
 	worker.Config
 	config.Globals
 
-	DumpEmployes dumpCommand `cmd:"" aliases:"dump" help:"Get a full dump of employes to the storage from web sources"`
-	SyncEmployes syncCommand `cmd:"" aliases:"sync" help:"Update employes data in backend service from a local storage or web sources"`
-	News         newsCommand `cmd:"" aliases:"news" help:"Get news and comments from web sources"`
+	DumpEmployes dumpCommand   `cmd:"" aliases:"dump" help:"Get a full dump of employes to the storage from web sources"`
+	SyncEmployes syncCommand   `cmd:"" aliases:"sync" help:"Update employes data in backend service from a local storage or web sources"`
+	News         newsCommand   `cmd:"" aliases:"news" help:"Get news and comments from web sources"`
+	Daemon       daemonCommand `cmd:"" aliases:"daemon" help:"Run dump and sync of employes by schedules"`
+	Export       exportCommand `cmd:"" aliases:"export" help:"Export employes of the storage to CSV, XLSX, JSON or NDJSON"`
+	Import       importCommand `cmd:"" aliases:"import" help:"Merge hire dates, positions and managers of HR exports (CSV, XLSX) to employes of the storage"`
 }
 
 // Main CLI func
@@ -1,7 +1,78 @@
 package cli
 
-type newsCommand struct{}
+import (
+	"context"
+	"fmt"
+	"log/slog"
 
-func (e *newsCommand) Run() {
+	"github.com/mioxin/kbempgo/internal/news"
+	"github.com/mioxin/kbempgo/internal/storage"
+)
 
+type newsCommand struct {
+	Workers int    `name:"workers" short:"w" default:"3" env:"KB_WORKERS" help:"Number of workers getting pages of news."`
+	Pages   int    `name:"pages" default:"1" help:"Number of pages of the news list from the first one."`
+	Profile string `name:"profile" env:"KB_NEWS_PROFILE" default:"news" help:"Profile of news pages: name of built-in profile (news) or path to YAML file"`
+
+	Lg *slog.Logger `kong:"-"`
+}
+
+func (e *newsCommand) Run(cli *CLI) error {
+	var err error
+
+	if e.Workers <= 0 {
+		return fmt.Errorf("number of workers should be > 0")
+	}
+	if e.Pages <= 0 {
+		return fmt.Errorf("number of pages should be > 0")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cli.OpTimeout)
+	defer cancel()
+
+	e.Lg = cli.Log.With("cmd", "news")
+
+	// open storage
+	cli.Store, err = storage.NewStore(cli.StorageURL, e.Lg)
+	if err != nil {
+		return fmt.Errorf("create storage %w", err)
+	}
+
+	defer func() {
+		cli.Log.Info("MAIN Close storage")
+		if err := cli.Store.Close(); err != nil {
+			cli.Log.Error("MAIN close storage", "err", err)
+		}
+	}()
+
+	ns, ok := cli.Store.(storage.News)
+	if !ok {
+		return fmt.Errorf("storage %T doesn't keep news", cli.Store)
+	}
+
+	list, err := news.Scrape(ctx, &news.Config{
+		Config:  cli.Config,
+		Workers: e.Workers,
+		Pages:   e.Pages,
+		Profile: e.Profile,
+		Debug:   cli.Debug,
+		Lg:      e.Lg,
+	})
+	if err != nil {
+		return fmt.Errorf("scrape news: %w", err)
+	}
+
+	linker := news.NewLinker(cli.Store, e.Lg)
+	linked, authors := 0, 0
+	for _, n := range list {
+		l, a := linker.Link(ctx, n)
+		linked += l
+		authors += a
+	}
+	e.Lg.Info("MAIN Authors linked to employees", "linked", linked, "authors", authors)
+
+	if err = ns.SaveNews(context.WithoutCancel(ctx), list); err != nil {
+		return fmt.Errorf("save news: %w", err)
+	}
+	return nil
 }
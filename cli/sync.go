@@ -34,12 +34,17 @@ type syncCommand struct {
 }
 
 func (e *syncCommand) Run(cli *CLI) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cli.OpTimeout)
+	defer cancel()
+
+	return e.run(ctx, cli)
+}
+
+// run syncs employees until the context is done, it's used by the daemon too
+func (e *syncCommand) run(ctx context.Context, cli *CLI) error {
 	var err error
 
 	e.Lg = cli.Log.With("cmd", "employ")
-
-	ctx, cancel := context.WithTimeout(context.Background(), cli.OpTimeout)
-	defer cancel()
 	// ****************************************
 	// if FileSource setted then load data from file source to storage by gRPC
 	// ****************************************
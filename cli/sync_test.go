@@ -48,6 +48,30 @@ func (c *Gcli) Update(ctx context.Context, in *kbv1.UpdateSotrRequest, opts ...g
 func (c *Gcli) GetHistory(ctx context.Context, in *kbv1.HistRequest, opts ...grpc.CallOption) (*kbv1.HistoryListResponse, error) {
 	return nil, nil
 }
+func (c *Gcli) GetAvatar(ctx context.Context, in *kbv1.AvatarRequest, opts ...grpc.CallOption) (*kbv1.AvatarResponse, error) {
+	return nil, nil
+}
+func (c *Gcli) SaveItems(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[kbv1.Item, emptypb.Empty], error) {
+	return nil, nil
+}
+func (c *Gcli) ListNews(ctx context.Context, in *kbv1.ListNewsRequest, opts ...grpc.CallOption) (*kbv1.NewsListResponse, error) {
+	return nil, nil
+}
+func (c *Gcli) GetNews(ctx context.Context, in *kbv1.NewsRequest, opts ...grpc.CallOption) (*kbv1.News, error) {
+	return nil, nil
+}
+func (c *Gcli) GetReports(ctx context.Context, in *kbv1.ReportsRequest, opts ...grpc.CallOption) (*kbv1.SotrsResponse, error) {
+	return nil, nil
+}
+func (c *Gcli) GetManagerChain(ctx context.Context, in *kbv1.ManagerChainRequest, opts ...grpc.CallOption) (*kbv1.SotrsResponse, error) {
+	return nil, nil
+}
+func (c *Gcli) GetVersions(ctx context.Context, in *kbv1.VersionsRequest, opts ...grpc.CallOption) (*kbv1.VersionsResponse, error) {
+	return nil, nil
+}
+func (c *Gcli) GetGeneration(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*kbv1.GenerationResponse, error) {
+	return nil, nil
+}
 
 type Gcli struct{}
 
@@ -0,0 +1,241 @@
+// Package avatar stores images of employees by hash of the content.
+// The same image is stored once for all employees and versions, the current avatar
+// of the employee is referenced by the meta file of the tabnum.
+package avatar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	objectsDir = "objects"
+	metaDir    = "meta"
+)
+
+// Meta is the current avatar of the employee with validators of the source response
+type Meta struct {
+	Tabnum       string    `json:"tabnum"`
+	Hash         string    `json:"hash"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// Store keeps images in objects/<2 chars of hash>/<hash>, thumbnails next to them and meta of employees in meta/<tabnum>.json.
+// One Store can be shared by the pool of workers.
+type Store struct {
+	dir string
+	lg  *slog.Logger
+	// Thumbs are sizes and formats of thumbnails made by Thumbnails
+	Thumbs ThumbConfig
+
+	mu sync.Mutex
+	// avatars seen by the current run by tabnum
+	seen map[string]*kbv1.Avatar
+}
+
+// NewStore creates the store in the directory
+func NewStore(dir string, lg *slog.Logger) (s *Store, err error) {
+	for _, d := range []string{objectsDir, metaDir} {
+		if err = os.MkdirAll(filepath.Join(dir, d), 0750); err != nil {
+			return nil, fmt.Errorf("create avatar store: %w", err)
+		}
+	}
+
+	return &Store{
+		dir:  dir,
+		lg:   lg.With("avatars", dir),
+		seen: make(map[string]*kbv1.Avatar),
+	}, nil
+}
+
+// Put writes the image to the store and returns hash and size of it.
+// The image is not written again if the store has the same content.
+func (s *Store) Put(r io.Reader) (hash string, size int64, err error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, objectsDir), "put-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("put avatar: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("put avatar: %w", err)
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+
+	path := s.Path(hash)
+	if _, err = os.Stat(path); err == nil {
+		s.lg.Debug("Avatar: the same image exists", "hash", hash)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return "", 0, fmt.Errorf("put avatar: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, fmt.Errorf("put avatar: %w", err)
+	}
+	return
+}
+
+// Import puts the image file as the current avatar of the employee without validators of the source
+func (s *Store) Import(tabnum, path string) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("import avatar: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("import avatar: %w", err)
+	}
+
+	hash, size, err := s.Put(f)
+	if err != nil {
+		return
+	}
+
+	return s.writeMeta(&Meta{
+		Tabnum:      tabnum,
+		Hash:        hash,
+		Size:        size,
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+		FirstSeen:   fi.ModTime(),
+		LastSeen:    fi.ModTime(),
+	})
+}
+
+// IsStoreDir reports whether the directory is a part of the store layout
+func IsStoreDir(name string) bool {
+	return name == objectsDir || name == metaDir
+}
+
+// Path returns the file of the image by hash
+func (s *Store) Path(hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(s.dir, objectsDir, hash)
+	}
+	return filepath.Join(s.dir, objectsDir, hash[:2], hash)
+}
+
+// Open opens the image by hash
+func (s *Store) Open(hash string) (*os.File, error) {
+	return os.Open(s.Path(hash))
+}
+
+// Meta returns the current avatar of the employee
+func (s *Store) Meta(tabnum string) (m *Meta, ok bool) {
+	b, err := os.ReadFile(s.metaPath(tabnum))
+	if err != nil {
+		return nil, false
+	}
+
+	m = &Meta{}
+	if err = json.Unmarshal(b, m); err != nil {
+		s.lg.Warn("Avatar: invalid meta", "tabnum", tabnum, "err", err)
+		return nil, false
+	}
+	return m, true
+}
+
+// Seen records the avatar of the employee seen by the run. The avatar is new if the hash is changed.
+func (s *Store) Seen(m *Meta, now time.Time) error {
+	if old, ok := s.Meta(m.Tabnum); ok && old.Hash == m.Hash {
+		m.FirstSeen = old.FirstSeen
+	} else {
+		m.FirstSeen = now
+	}
+	m.LastSeen = now
+
+	if err := s.writeMeta(m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.seen[m.Tabnum] = m.Avatar()
+	s.mu.Unlock()
+	return nil
+}
+
+// Avatars returns avatars seen by the run sorted by tabnum
+func (s *Store) Avatars() (avatars []*kbv1.Avatar) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.seen {
+		avatars = append(avatars, proto.Clone(a).(*kbv1.Avatar))
+	}
+	sort.Slice(avatars, func(i, j int) bool { return avatars[i].Tabnum < avatars[j].Tabnum })
+	return
+}
+
+// Avatar converts the meta to the API message
+func (m *Meta) Avatar() *kbv1.Avatar {
+	return &kbv1.Avatar{
+		Tabnum:      m.Tabnum,
+		Hash:        m.Hash,
+		Size:        m.Size,
+		ContentType: m.ContentType,
+		FirstSeen:   timestamppb.New(m.FirstSeen),
+		LastSeen:    timestamppb.New(m.LastSeen),
+	}
+}
+
+func (s *Store) metaPath(tabnum string) string {
+	return filepath.Join(s.dir, metaDir, filepath.Base(tabnum)+".json")
+}
+
+// writeMeta writes the meta to a temporary file and renames one, so readers never see a partial meta
+func (s *Store) writeMeta(m *Meta) (err error) {
+	if m.Tabnum == "" {
+		return errors.New("write avatar meta: tabnum is empty")
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("write avatar meta: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, metaDir), "meta-*")
+	if err != nil {
+		return fmt.Errorf("write avatar meta: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write avatar meta: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("write avatar meta: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), s.metaPath(m.Tabnum)); err != nil {
+		return fmt.Errorf("write avatar meta: %w", err)
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package avatar
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutDedup(t *testing.T) {
+	st, err := NewStore(t.TempDir(), slog.Default())
+	require.NoError(t, err)
+
+	h1, size, err := st.Put(strings.NewReader("image"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, size)
+	assert.FileExists(t, st.Path(h1))
+
+	h2, _, err := st.Put(strings.NewReader("image"))
+	require.NoError(t, err)
+	assert.Equal(t, h1, h2)
+
+	h3, _, err := st.Put(strings.NewReader("other"))
+	require.NoError(t, err)
+	assert.NotEqual(t, h1, h3)
+
+	objects, err := filepath.Glob(filepath.Join(st.dir, objectsDir, "*", "*"))
+	require.NoError(t, err)
+	assert.Len(t, objects, 2)
+}
+
+func TestSeen(t *testing.T) {
+	st, err := NewStore(t.TempDir(), slog.Default())
+	require.NoError(t, err)
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, st.Seen(&Meta{Tabnum: "1", Hash: "aaa"}, day))
+	require.NoError(t, st.Seen(&Meta{Tabnum: "1", Hash: "aaa"}, day.AddDate(0, 0, 1)))
+
+	m, ok := st.Meta("1")
+	require.True(t, ok)
+	assert.Equal(t, day, m.FirstSeen.UTC())
+	assert.Equal(t, day.AddDate(0, 0, 1), m.LastSeen.UTC())
+
+	// the changed image is seen first time
+	require.NoError(t, st.Seen(&Meta{Tabnum: "1", Hash: "bbb"}, day.AddDate(0, 0, 2)))
+	m, ok = st.Meta("1")
+	require.True(t, ok)
+	assert.Equal(t, day.AddDate(0, 0, 2), m.FirstSeen.UTC())
+
+	avatars := st.Avatars()
+	require.Len(t, avatars, 1)
+	assert.Equal(t, "bbb", avatars[0].Hash)
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	st, err := NewStore(dir, slog.Default())
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "avatar", "1.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, []byte("image"), 0644))
+
+	require.NoError(t, st.Import("1", path))
+
+	m, ok := st.Meta("1")
+	require.True(t, ok)
+	assert.EqualValues(t, 5, m.Size)
+	assert.Equal(t, "image/jpeg", m.ContentType)
+
+	b, err := os.ReadFile(st.Path(m.Hash))
+	require.NoError(t, err)
+	assert.Equal(t, "image", string(b))
+
+	_, ok = st.Meta("2")
+	assert.False(t, ok)
+	assert.True(t, IsStoreDir("objects"))
+	assert.False(t, IsStoreDir("avatar"))
+}
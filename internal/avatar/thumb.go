@@ -0,0 +1,174 @@
+package avatar
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // decoders of source images
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Formats of thumbnails
+const (
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+)
+
+// ThumbConfig configures thumbnails of avatars
+type ThumbConfig struct {
+	Sizes   []int    `name:"sizes" default:"48,96,256" help:"Sizes of thumbnails of avatars in px by the longest side. If empty then thumbnails are not made."`
+	Formats []string `name:"formats" default:"jpeg,webp" help:"Formats of thumbnails: jpeg, webp"`
+	Quality int      `name:"quality" default:"85" help:"Quality of JPEG thumbnails, 1-100"`
+}
+
+// Validate checks sizes and formats of thumbnails
+func (c *ThumbConfig) Validate() error {
+	for _, size := range c.Sizes {
+		if size <= 0 || size > 4096 {
+			return fmt.Errorf("invalid size of thumbnails %d", size)
+		}
+	}
+	for _, f := range c.Formats {
+		if f != FormatJPEG && f != FormatWebP {
+			return fmt.Errorf("invalid format of thumbnails %q", f)
+		}
+	}
+	if c.Quality < 0 || c.Quality > 100 {
+		return fmt.Errorf("invalid quality of thumbnails %d", c.Quality)
+	}
+	return nil
+}
+
+// HasThumb reports whether the size is one of configured sizes of thumbnails
+func (c *ThumbConfig) HasThumb(size int, format string) bool {
+	return slices.Contains(c.Sizes, size) && slices.Contains(c.Formats, format)
+}
+
+// ContentType returns the MIME type of the thumbnail format
+func ContentType(format string) string {
+	if format == FormatWebP {
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+// ThumbPath returns the file of the thumbnail, it's next to the original image
+func (s *Store) ThumbPath(hash string, size int, format string) string {
+	ext := ".jpg"
+	if format == FormatWebP {
+		ext = ".webp"
+	}
+	return s.Path(hash) + "_" + strconv.Itoa(size) + ext
+}
+
+// Thumbnails makes missing thumbnails of the image by the config of the store.
+// Thumbnails are normalised: the image is scaled to fit the size and is put on the white background.
+func (s *Store) Thumbnails(hash string) (err error) {
+	type variant struct {
+		size   int
+		format string
+	}
+
+	var missing []variant
+	for _, size := range s.Thumbs.Sizes {
+		for _, f := range s.Thumbs.Formats {
+			if _, e := os.Stat(s.ThumbPath(hash, size, f)); e != nil {
+				missing = append(missing, variant{size, f})
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	src, err := s.decode(hash)
+	if err != nil {
+		return fmt.Errorf("thumbnails of %s: %w", hash, err)
+	}
+
+	scaled := make(map[int]image.Image, len(s.Thumbs.Sizes))
+	for _, v := range missing {
+		img, ok := scaled[v.size]
+		if !ok {
+			img = scale(src, v.size)
+			scaled[v.size] = img
+		}
+
+		if err = s.writeThumb(s.ThumbPath(hash, v.size, v.format), img, v.format); err != nil {
+			return fmt.Errorf("thumbnails of %s: %w", hash, err)
+		}
+	}
+
+	s.lg.Debug("Avatar: thumbnails are made", "hash", hash, "num", len(missing))
+	return
+}
+
+func (s *Store) decode(hash string) (image.Image, error) {
+	f, err := s.Open(hash)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(bufio.NewReader(f))
+	return img, err
+}
+
+// scale fits the image to the size by the longest side, small images are not enlarged
+func scale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
+
+// writeThumb encodes the image to a temporary file and renames one, so readers never see a partial image
+func (s *Store) writeThumb(path string, img image.Image, format string) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "thumb-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = s.encode(tmp, img, format); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *Store) encode(w io.Writer, img image.Image, format string) error {
+	if format == FormatWebP {
+		return nativewebp.Encode(w, img, nil)
+	}
+
+	q := s.Thumbs.Quality
+	if q == 0 {
+		q = jpeg.DefaultQuality
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: q})
+}
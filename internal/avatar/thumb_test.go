@@ -0,0 +1,82 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+func TestThumbnails(t *testing.T) {
+	st, err := NewStore(t.TempDir(), slog.Default())
+	require.NoError(t, err)
+	st.Thumbs = ThumbConfig{Sizes: []int{48, 96, 400}, Formats: []string{FormatJPEG, FormatWebP}}
+	require.NoError(t, st.Thumbs.Validate())
+
+	src := image.NewNRGBA(image.Rect(0, 0, 300, 150))
+	for x := range 300 {
+		for y := range 150 {
+			src.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	b := &bytes.Buffer{}
+	require.NoError(t, png.Encode(b, src))
+
+	hash, _, err := st.Put(b)
+	require.NoError(t, err)
+	require.NoError(t, st.Thumbnails(hash))
+
+	for size, want := range map[int]image.Point{48: {48, 24}, 96: {96, 48}, 400: {300, 150}} {
+		f, err := os.Open(st.ThumbPath(hash, size, FormatJPEG))
+		require.NoError(t, err)
+		img, err := jpeg.Decode(f)
+		f.Close()
+		require.NoError(t, err)
+		assert.Equal(t, want, img.Bounds().Size(), "jpeg %d", size)
+
+		f, err = os.Open(st.ThumbPath(hash, size, FormatWebP))
+		require.NoError(t, err)
+		img, err = webp.Decode(f)
+		f.Close()
+		require.NoError(t, err)
+		assert.Equal(t, want, img.Bounds().Size(), "webp %d", size)
+	}
+
+	// thumbnails are not made again
+	fi, err := os.Stat(st.ThumbPath(hash, 96, FormatJPEG))
+	require.NoError(t, err)
+	require.NoError(t, st.Thumbnails(hash))
+	fi2, err := os.Stat(st.ThumbPath(hash, 96, FormatJPEG))
+	require.NoError(t, err)
+	assert.Equal(t, fi.ModTime(), fi2.ModTime())
+
+	assert.True(t, st.Thumbs.HasThumb(96, FormatWebP))
+	assert.False(t, st.Thumbs.HasThumb(100, FormatJPEG))
+}
+
+func TestThumbnailsInvalidImage(t *testing.T) {
+	st, err := NewStore(t.TempDir(), slog.Default())
+	require.NoError(t, err)
+	st.Thumbs = ThumbConfig{Sizes: []int{48}, Formats: []string{FormatJPEG}}
+
+	hash, _, err := st.Put(strings.NewReader("not an image"))
+	require.NoError(t, err)
+	assert.Error(t, st.Thumbnails(hash))
+	assert.NoFileExists(t, st.ThumbPath(hash, 48, FormatJPEG))
+}
+
+func TestThumbConfigValidate(t *testing.T) {
+	assert.NoError(t, (&ThumbConfig{}).Validate())
+	assert.Error(t, (&ThumbConfig{Sizes: []int{0}}).Validate())
+	assert.Error(t, (&ThumbConfig{Formats: []string{"png"}}).Validate())
+	assert.Error(t, (&ThumbConfig{Quality: 101}).Validate())
+}
@@ -17,7 +17,7 @@ type Globals struct {
 	OpTimeout       time.Duration   `name:"op-timeout" default:"1600s" help:"timeout for Main getting"`
 	WaitDataTimeout time.Duration   `name:"wait-timeout" default:"6s" help:"timeout for waiting data in dispatcher of worker"`
 	Debug           int             `name:"debug" short:"d" type:"counter" help:"Enable debug"`
-	DbUrl           string          `name:"db" env:"KB_DB_URL" help:"DB connection string"`
+	DbUrl           string          `name:"db" env:"KB_DB_URL" help:"DB connection string: postgres://, mysql://, file://<dir>, mem://[<dir>]"`
 	LogOutput       string          `name:"log-output" short:"o" default:"" help:"output file for logs, default: stdOut"`
 	JsonLog         bool            `name:"json" help:"set JSON format for logs"`
 
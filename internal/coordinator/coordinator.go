@@ -0,0 +1,218 @@
+// Package coordinator shares the crawl frontier of the dump by several processes through Redis.
+// Queues of razds and avatars, visited data and the number of outstanding tasks live in Redis.
+// Tasks popped by a process are leased, tasks of the crashed process are queued again when their leases expire.
+package coordinator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mioxin/kbempgo/internal/worker"
+	"github.com/mioxin/kbempgo/pkg/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type Config struct {
+	Crawl string        `name:"crawl" default:"dump" help:"Id of the crawl shared by dump processes"`
+	Lease time.Duration `name:"lease" default:"1m" help:"Lease of the task by the process, the task of the crashed process is queued again when its lease expires"`
+	Keep  time.Duration `name:"keep" default:"10m" help:"Time of keeping the state of the completed crawl, the crawl with the same id is started again after it"`
+	Poll  time.Duration `name:"poll" default:"500ms" help:"Interval of polling of empty queues and of the end of the crawl"`
+}
+
+// KEYS: instances, flushed, pending; ARGV: id, now
+var leaveScript = goredis.NewScript(`
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+if redis.call('ZCARD', KEYS[1]) > 0 then return 0 end
+if tonumber(redis.call('GET', KEYS[3]) or '0') ~= 0 then return 0 end
+if redis.call('SET', KEYS[2], ARGV[1], 'NX') then return 1 end
+return 0`)
+
+// Coordinator is dump.Frontier shared by dump processes
+type Coordinator struct {
+	rdb redis.UniversalClient
+	cfg Config
+	lg  *slog.Logger
+	// id of the process
+	id string
+
+	razds, avatars *Queue
+
+	done     chan struct{}
+	doneOnce sync.Once
+	stop     context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// New creates the coordinator of the crawl, it's joined to the crawl by Start
+func New(rdb redis.UniversalClient, cfg Config, lg *slog.Logger) *Coordinator {
+	host, _ := os.Hostname()
+
+	c := &Coordinator{
+		rdb:  rdb,
+		cfg:  cfg,
+		lg:   lg.With("crawl", cfg.Crawl),
+		id:   host + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		done: make(chan struct{}),
+	}
+	c.razds = newQueue(c, "razds")
+	c.avatars = newQueue(c, "avatars")
+	return c
+}
+
+// key of the crawl, the hash tag keeps keys of the crawl in the same slot of the cluster
+func (c *Coordinator) key(k string) string {
+	return "kbemp:crawl:{" + c.cfg.Crawl + "}:" + k
+}
+
+// ID returns id of the process in the crawl
+func (c *Coordinator) ID() string {
+	return c.id
+}
+
+func (c *Coordinator) Razds() worker.Queue {
+	return c.razds
+}
+
+func (c *Coordinator) Avatars() worker.Queue {
+	return c.avatars
+}
+
+// Pending returns number of outstanding tasks of all processes
+func (c *Coordinator) Pending() int64 {
+	n, err := c.rdb.Get(context.Background(), c.key("pending")).Int64()
+	if err != nil && err != redis.Nil {
+		c.lg.Error("Coordinator: pending tasks", "err", err)
+	}
+	return n
+}
+
+// Done returns a channel closed when all tasks of the crawl are finished by all processes
+func (c *Coordinator) Done() <-chan struct{} {
+	return c.done
+}
+
+// Start joins the process to the crawl. Leases of the process are renewed and expired leases
+// of all processes are queued again until Close.
+func (c *Coordinator) Start(ctx context.Context) error {
+	if c.cfg.Lease <= 0 || c.cfg.Poll <= 0 {
+		return fmt.Errorf("lease and poll interval of coordinator should be > 0")
+	}
+
+	if err := c.heartbeat(ctx); err != nil {
+		return fmt.Errorf("join crawl %s: %w", c.cfg.Crawl, err)
+	}
+	c.lg.Info("Coordinator: joined crawl", "id", c.id)
+
+	ctx, c.stop = context.WithCancel(context.WithoutCancel(ctx))
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.run(ctx)
+	}()
+	return nil
+}
+
+// Close stops renewing of leases. Tasks leased by the process are queued again by other processes
+// when the leases expire.
+func (c *Coordinator) Close() {
+	if c.stop != nil {
+		c.stop()
+		c.wg.Wait()
+	}
+}
+
+// Leave removes the process from the crawl after its items are saved. It returns true for the last process
+// leaving the completed crawl, so the storage is flushed once after all items are saved.
+func (c *Coordinator) Leave(ctx context.Context) (last bool, err error) {
+	n, err := leaveScript.Run(ctx, c.rdb,
+		[]string{c.key("instances"), c.key("flushed"), c.key("pending")},
+		c.id, time.Now().UnixMilli()).Int()
+	if err != nil {
+		return false, fmt.Errorf("leave crawl %s: %w", c.cfg.Crawl, err)
+	}
+	return n == 1, nil
+}
+
+// Expire sets the time of keeping of the state of the completed crawl
+func (c *Coordinator) Expire(ctx context.Context) error {
+	keys := append(c.razds.keys(), c.avatars.keys()...)
+	keys = append(keys, c.key("pending"), c.key("seq"), c.key("seeded"), c.key("instances"), c.key("flushed"))
+
+	_, err := c.rdb.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		for _, k := range keys {
+			p.Expire(ctx, k, c.cfg.Keep)
+		}
+		return nil
+	})
+	return err
+}
+
+// run checks the end of the crawl and maintains leases
+func (c *Coordinator) run(ctx context.Context) {
+	tick := time.NewTicker(c.cfg.Poll)
+	defer tick.Stop()
+
+	maintained := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+
+		c.checkDone(ctx)
+
+		if time.Since(maintained) < c.cfg.Lease/3 {
+			continue
+		}
+		maintained = time.Now()
+
+		if err := c.heartbeat(ctx); err != nil {
+			c.lg.Error("Coordinator: heartbeat", "err", err)
+		}
+		for _, q := range []*Queue{c.razds, c.avatars} {
+			if err := q.renew(ctx); err != nil {
+				c.lg.Error("Coordinator: renew leases", "queue", q.name, "err", err)
+			}
+			if n, err := q.reap(ctx); err != nil {
+				c.lg.Error("Coordinator: queue expired tasks", "queue", q.name, "err", err)
+			} else if n > 0 {
+				c.lg.Warn("Coordinator: tasks of expired leases are queued again", "queue", q.name, "num", n)
+			}
+		}
+	}
+}
+
+// heartbeat keeps the process alive in the crawl
+func (c *Coordinator) heartbeat(ctx context.Context) error {
+	deadline := time.Now().Add(c.cfg.Lease).UnixMilli()
+	return c.rdb.ZAdd(ctx, c.key("instances"), goredis.Z{Score: float64(deadline), Member: c.id}).Err()
+}
+
+// checkDone closes Done if the crawl is started and no tasks are outstanding
+func (c *Coordinator) checkDone(ctx context.Context) {
+	var seeded *goredis.IntCmd
+	var pending *goredis.StringCmd
+	_, err := c.rdb.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		seeded = p.Exists(ctx, c.key("seeded"))
+		pending = p.Get(ctx, c.key("pending"))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		c.lg.Error("Coordinator: check end of crawl", "err", err)
+		return
+	}
+
+	if n, _ := pending.Int64(); seeded.Val() == 1 && n == 0 {
+		c.doneOnce.Do(func() {
+			c.lg.Info("Coordinator: all tasks of crawl finished")
+			close(c.done)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package coordinator
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mioxin/kbempgo/internal/worker"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() Config {
+	return Config{Crawl: "test", Lease: time.Minute, Keep: time.Minute, Poll: 10 * time.Millisecond}
+}
+
+func newCoordinators(t *testing.T, cfg Config, n int) ([]*Coordinator, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	cs := make([]*Coordinator, n)
+	for i := range cs {
+		cs[i] = New(rdb, cfg, slog.Default())
+		require.NoError(t, cs[i].Start(context.Background()))
+		t.Cleanup(cs[i].Close)
+	}
+	return cs, mr
+}
+
+func TestPushVisited(t *testing.T) {
+	cs, _ := newCoordinators(t, testConfig(), 2)
+
+	assert.True(t, cs[0].Razds().Push(worker.Task{Data: "razd1"}))
+	assert.False(t, cs[1].Razds().Push(worker.Task{Data: "razd1"}), "task is visited by another process")
+	assert.True(t, cs[1].Avatars().Push(worker.Task{Data: "razd1"}), "queues don't share visited data")
+
+	assert.Equal(t, 1, cs[1].Razds().Len())
+	assert.EqualValues(t, 2, cs[0].Pending())
+}
+
+func TestPopPriority(t *testing.T) {
+	cs, _ := newCoordinators(t, testConfig(), 1)
+	q := cs[0].Razds()
+
+	q.SetPriority("razd3", 5)
+	q.Push(worker.Task{Data: "razd1"})
+	q.Push(worker.Task{Data: "razd2", Priority: 1})
+	q.Push(worker.Task{Data: "razd3"})
+	q.Push(worker.Task{Data: "razd4"})
+
+	var got []string
+	for range 4 {
+		task, ok := q.Pop()
+		require.True(t, ok)
+		got = append(got, task.Data)
+	}
+	assert.Equal(t, []string{"razd3", "razd2", "razd1", "razd4"}, got)
+}
+
+func TestFinishDone(t *testing.T) {
+	cs, _ := newCoordinators(t, testConfig(), 2)
+
+	cs[0].Razds().Push(worker.Task{Data: "root"})
+	task, ok := cs[1].Razds().Pop()
+	require.True(t, ok)
+
+	cs[1].Razds().Push(worker.Task{Data: "razd1", Depth: 1})
+	cs[1].Razds().Finish(task)
+	assert.EqualValues(t, 1, cs[0].Pending())
+
+	// the task of another process isn't finished
+	child, ok := cs[0].Razds().Pop()
+	require.True(t, ok)
+	cs[1].Razds().Finish(child)
+	assert.EqualValues(t, 1, cs[0].Pending())
+
+	select {
+	case <-cs[0].Done():
+		t.Fatal("crawl is done before all tasks are finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cs[0].Razds().Finish(child)
+	for _, c := range cs {
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+			t.Fatal("crawl isn't done after all tasks are finished")
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	cs, _ := newCoordinators(t, testConfig(), 1)
+	q := cs[0].Razds()
+
+	q.Push(worker.Task{Data: "razd1"})
+	task, ok := q.Pop()
+	require.True(t, ok)
+
+	task.Num++
+	q.Retry(task)
+	assert.EqualValues(t, 1, cs[0].Pending(), "retried task is outstanding")
+
+	task, ok = q.Pop()
+	require.True(t, ok)
+	assert.Equal(t, 1, task.Num)
+}
+
+func TestReapExpiredLease(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lease = 50 * time.Millisecond
+	cs, _ := newCoordinators(t, cfg, 2)
+	// the crashed process doesn't renew its leases
+	cs[0].Close()
+
+	cs[0].Razds().Push(worker.Task{Data: "razd1"})
+	_, ok := cs[0].Razds().Pop()
+	require.True(t, ok)
+	assert.Equal(t, 0, cs[1].Razds().Len())
+
+	require.Eventually(t, func() bool { return cs[1].Razds().Len() == 1 }, time.Second, 10*time.Millisecond)
+
+	task, ok := cs[1].Razds().Pop()
+	require.True(t, ok)
+	assert.Equal(t, "razd1", task.Data)
+	assert.Equal(t, 1, task.Num, "expired lease is the next try of task")
+
+	// the lease of the crashed process is lost
+	cs[0].Razds().Finish(task)
+	assert.EqualValues(t, 1, cs[1].Pending())
+	cs[1].Razds().Finish(task)
+	assert.EqualValues(t, 0, cs[1].Pending())
+}
+
+func TestRenewLease(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lease = 60 * time.Millisecond
+	cs, _ := newCoordinators(t, cfg, 2)
+
+	cs[0].Razds().Push(worker.Task{Data: "razd1"})
+	_, ok := cs[0].Razds().Pop()
+	require.True(t, ok)
+
+	time.Sleep(4 * cfg.Lease)
+	assert.Equal(t, 0, cs[1].Razds().Len(), "lease of alive process is renewed")
+}
+
+func TestLeave(t *testing.T) {
+	ctx := context.Background()
+	cs, mr := newCoordinators(t, testConfig(), 2)
+
+	cs[0].Razds().Push(worker.Task{Data: "root"})
+	task, _ := cs[0].Razds().Pop()
+	cs[0].Razds().Finish(task)
+
+	last, err := cs[0].Leave(ctx)
+	require.NoError(t, err)
+	assert.False(t, last, "another process is in crawl")
+
+	last, err = cs[1].Leave(ctx)
+	require.NoError(t, err)
+	assert.True(t, last)
+
+	last, err = cs[1].Leave(ctx)
+	require.NoError(t, err)
+	assert.False(t, last, "crawl is flushed once")
+
+	require.NoError(t, cs[1].Expire(ctx))
+	assert.Equal(t, time.Minute, mr.TTL(cs[1].key("flushed")))
+	assert.Equal(t, time.Minute, mr.TTL(cs[1].razds.key("visited")))
+}
+
+func TestLeaveNotCompleted(t *testing.T) {
+	cs, _ := newCoordinators(t, testConfig(), 1)
+
+	cs[0].Razds().Push(worker.Task{Data: "root"})
+
+	last, err := cs[0].Leave(context.Background())
+	require.NoError(t, err)
+	assert.False(t, last, "crawl has outstanding tasks")
+}
+
+func TestPopClosed(t *testing.T) {
+	cs, _ := newCoordinators(t, testConfig(), 1)
+	q := cs[0].Razds()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		q.Close()
+	}()
+
+	_, ok := q.Pop()
+	assert.False(t, ok)
+}
@@ -0,0 +1,272 @@
+package coordinator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mioxin/kbempgo/internal/worker"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// Tasks are ordered by score -priority*1e12+seq: the higher priority and the earlier queued task is the first.
+var (
+	// KEYS: visited, tasks, pending, seq, seeded; ARGV: data, priority, task
+	pushScript = goredis.NewScript(`
+if redis.call('SADD', KEYS[1], ARGV[1]) == 0 then return 0 end
+redis.call('INCR', KEYS[3])
+redis.call('SET', KEYS[5], 1)
+local seq = redis.call('INCR', KEYS[4])
+redis.call('ZADD', KEYS[2], -tonumber(ARGV[2]) * 1e12 + seq, ARGV[3])
+return 1`)
+
+	// KEYS: tasks, leases, leased, owners; ARGV: deadline, owner
+	popScript = goredis.NewScript(`
+local t = redis.call('ZPOPMIN', KEYS[1])
+if #t == 0 then return false end
+local data = cjson.decode(t[1]).Data
+redis.call('ZADD', KEYS[2], ARGV[1], data)
+redis.call('HSET', KEYS[3], data, t[1])
+redis.call('HSET', KEYS[4], data, ARGV[2])
+return t[1]`)
+
+	// KEYS: leases, leased, owners, pending; ARGV: data, owner
+	finishScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[3], ARGV[1]) ~= ARGV[2] then return 0 end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('DECR', KEYS[4])
+return 1`)
+
+	// KEYS: leases, leased, owners, tasks, seq; ARGV: data, owner, priority, task
+	retryScript = goredis.NewScript(`
+if redis.call('HGET', KEYS[3], ARGV[1]) ~= ARGV[2] then return 0 end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+local seq = redis.call('INCR', KEYS[5])
+redis.call('ZADD', KEYS[4], -tonumber(ARGV[3]) * 1e12 + seq, ARGV[4])
+return 1`)
+
+	// KEYS: leases, owners; ARGV: deadline, owner, data...
+	renewScript = goredis.NewScript(`
+local n = 0
+for i = 3, #ARGV do
+  if redis.call('HGET', KEYS[2], ARGV[i]) == ARGV[2] then
+    redis.call('ZADD', KEYS[1], 'XX', ARGV[1], ARGV[i])
+    n = n + 1
+  end
+end
+return n`)
+
+	// tasks of expired leases are queued again with the next try
+	// KEYS: leases, leased, owners, tasks, seq; ARGV: now
+	reapScript = goredis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+for _, data in ipairs(expired) do
+  local js = redis.call('HGET', KEYS[2], data)
+  redis.call('ZREM', KEYS[1], data)
+  redis.call('HDEL', KEYS[2], data)
+  redis.call('HDEL', KEYS[3], data)
+  if js then
+    local t = cjson.decode(js)
+    t.Num = (t.Num or 0) + 1
+    local seq = redis.call('INCR', KEYS[5])
+    redis.call('ZADD', KEYS[4], -(t.Priority or 0) * 1e12 + seq, cjson.encode(t))
+  end
+end
+return #expired`)
+)
+
+// Queue is worker.Queue in Redis shared by dump processes.
+// The popped task is leased by the process until it's finished or queued again.
+// The lease is renewed while the process is alive, the task of the expired lease is queued again.
+type Queue struct {
+	c    *Coordinator
+	name string
+
+	mt sync.Mutex
+	// leases held by the process
+	held       map[string]struct{}
+	priorities map[string]int
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ worker.Queue = (*Queue)(nil)
+
+func newQueue(c *Coordinator, name string) *Queue {
+	return &Queue{
+		c:          c,
+		name:       name,
+		held:       make(map[string]struct{}),
+		priorities: make(map[string]int),
+		closed:     make(chan struct{}),
+	}
+}
+
+func (q *Queue) key(k string) string {
+	return q.c.key(q.name + ":" + k)
+}
+
+func (q *Queue) SetPriority(data string, priority int) {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	q.priorities[data] = priority
+}
+
+func (q *Queue) priority(t *worker.Task) {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	if p, ok := q.priorities[t.Data]; ok && p > t.Priority {
+		t.Priority = p
+	}
+}
+
+func (q *Queue) Push(t worker.Task) bool {
+	q.priority(&t)
+	b, _ := json.Marshal(t)
+
+	n, err := pushScript.Run(context.Background(), q.c.rdb,
+		[]string{q.key("visited"), q.key("tasks"), q.c.key("pending"), q.c.key("seq"), q.c.key("seeded")},
+		t.Data, t.Priority, b).Int()
+	if err != nil {
+		q.c.lg.Error("Coordinator: push task", "queue", q.name, "data", t.Data, "err", err)
+		return false
+	}
+	return n == 1
+}
+
+func (q *Queue) Retry(t worker.Task) {
+	q.priority(&t)
+	b, _ := json.Marshal(t)
+
+	q.release(t.Data)
+	err := retryScript.Run(context.Background(), q.c.rdb,
+		[]string{q.key("leases"), q.key("leased"), q.key("owners"), q.key("tasks"), q.c.key("seq")},
+		t.Data, q.c.id, t.Priority, b).Err()
+	if err != nil {
+		// the task is queued again when its lease is expired
+		q.c.lg.Error("Coordinator: retry task", "queue", q.name, "data", t.Data, "err", err)
+	}
+}
+
+// Pop polls the queue until a task is leased or the queue is closed
+func (q *Queue) Pop() (t worker.Task, ok bool) {
+	for {
+		select {
+		case <-q.closed:
+			return
+		default:
+		}
+
+		deadline := time.Now().Add(q.c.cfg.Lease).UnixMilli()
+		s, err := popScript.Run(context.Background(), q.c.rdb,
+			[]string{q.key("tasks"), q.key("leases"), q.key("leased"), q.key("owners")},
+			deadline, q.c.id).Text()
+
+		switch {
+		case err == nil:
+			if e := json.Unmarshal([]byte(s), &t); e != nil {
+				q.c.lg.Error("Coordinator: invalid task", "queue", q.name, "task", s, "err", e)
+				continue
+			}
+			q.mt.Lock()
+			q.held[t.Data] = struct{}{}
+			q.mt.Unlock()
+			return t, true
+
+		case !errors.Is(err, goredis.Nil):
+			q.c.lg.Error("Coordinator: pop task", "queue", q.name, "err", err)
+		}
+
+		select {
+		case <-q.closed:
+			return
+		case <-time.After(q.c.cfg.Poll):
+		}
+	}
+}
+
+func (q *Queue) Finish(t worker.Task) {
+	q.release(t.Data)
+	n, err := finishScript.Run(context.Background(), q.c.rdb,
+		[]string{q.key("leases"), q.key("leased"), q.key("owners"), q.c.key("pending")},
+		t.Data, q.c.id).Int()
+	if err != nil {
+		q.c.lg.Error("Coordinator: finish task", "queue", q.name, "data", t.Data, "err", err)
+	} else if n == 0 {
+		q.c.lg.Warn("Coordinator: lease of task was expired, the task is taken by another process", "queue", q.name, "data", t.Data)
+	}
+}
+
+func (q *Queue) release(data string) {
+	q.mt.Lock()
+	defer q.mt.Unlock()
+
+	delete(q.held, data)
+}
+
+func (q *Queue) Close() {
+	q.closeOnce.Do(func() { close(q.closed) })
+}
+
+func (q *Queue) Complete(data string) {
+	if err := q.c.rdb.SAdd(context.Background(), q.key("completed"), data).Err(); err != nil {
+		q.c.lg.Error("Coordinator: complete task", "queue", q.name, "data", data, "err", err)
+	}
+}
+
+// Completed returns data of tasks completed by all processes
+func (q *Queue) Completed() []string {
+	completed, err := q.c.rdb.SMembers(context.Background(), q.key("completed")).Result()
+	if err != nil {
+		q.c.lg.Error("Coordinator: completed tasks", "queue", q.name, "err", err)
+	}
+	return completed
+}
+
+func (q *Queue) Len() int {
+	n, err := q.c.rdb.ZCard(context.Background(), q.key("tasks")).Result()
+	if err != nil {
+		q.c.lg.Error("Coordinator: length of queue", "queue", q.name, "err", err)
+	}
+	return int(n)
+}
+
+// renew extends leases held by the process
+func (q *Queue) renew(ctx context.Context) error {
+	q.mt.Lock()
+	args := make([]any, 0, len(q.held)+2)
+	args = append(args, time.Now().Add(q.c.cfg.Lease).UnixMilli(), q.c.id)
+	for data := range q.held {
+		args = append(args, data)
+	}
+	q.mt.Unlock()
+
+	if len(args) == 2 {
+		return nil
+	}
+	return renewScript.Run(ctx, q.c.rdb, []string{q.key("leases"), q.key("owners")}, args...).Err()
+}
+
+// reap queues tasks of expired leases again
+func (q *Queue) reap(ctx context.Context) (int, error) {
+	return reapScript.Run(ctx, q.c.rdb,
+		[]string{q.key("leases"), q.key("leased"), q.key("owners"), q.key("tasks"), q.c.key("seq")},
+		time.Now().UnixMilli()).Int()
+}
+
+func (q *Queue) keys() []string {
+	var keys []string
+	for _, k := range []string{"visited", "tasks", "leases", "leased", "owners", "completed"} {
+		keys = append(keys, q.key(k))
+	}
+	return keys
+}
@@ -0,0 +1,297 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Config struct {
+	Jobs       Jobs   `name:"jobs" env:"KB_DAEMON_JOBS" help:"Jobs of the daemon: list in the config file or YAML of a job, e.g. '{name: tree, schedule: \"0 2 * * *\", cmd: dump, branch: [razd1]}'"`
+	Listen     string `name:"listen" default:":9102" help:"Listen address of /healthz and /metrics endpoints, empty disables them"`
+	Lock       string `name:"lock" env:"KB_DAEMON_LOCK" help:"Lock of runs: path of the lock file or postgres:// URL of the advisory lock. By default it's the advisory lock of Postgres storage or the lock file in the temp dir."`
+	StatusFile string `name:"status-file" help:"JSON file keeping the status of last runs of jobs between restarts"`
+}
+
+// Results of runs
+const (
+	ResultOK      = "ok"
+	ResultError   = "error"
+	ResultSkipped = "skipped"
+)
+
+// Status of the job
+type Status struct {
+	Job      string    `json:"job"`
+	Cmd      string    `json:"cmd"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	Next     time.Time `json:"next,omitzero"`
+	// last run
+	Started     time.Time     `json:"started,omitzero"`
+	Finished    time.Time     `json:"finished,omitzero"`
+	Duration    time.Duration `json:"duration,omitempty"`
+	Result      string        `json:"result,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	LastSuccess time.Time     `json:"last_success,omitzero"`
+	Runs        int           `json:"runs"`
+	Failures    int           `json:"failures"`
+	Skipped     int           `json:"skipped"`
+}
+
+// RunFunc runs the command of the job
+type RunFunc func(ctx context.Context, job *Job) error
+
+// Daemon runs jobs by their schedules
+type Daemon struct {
+	cfg  Config
+	run  RunFunc
+	lock Locker
+	lg   *slog.Logger
+
+	mt     sync.Mutex
+	status map[string]*Status
+
+	reg     *prometheus.Registry
+	runs    *prometheus.CounterVec
+	seconds *prometheus.HistogramVec
+	success *prometheus.GaugeVec
+	running *prometheus.GaugeVec
+}
+
+// New creates the daemon of validated jobs. The status of last runs is loaded from the status file.
+func New(cfg Config, lock Locker, run RunFunc, lg *slog.Logger) (d *Daemon, err error) {
+	if err = cfg.Jobs.Validate(); err != nil {
+		return
+	}
+
+	d = &Daemon{
+		cfg:    cfg,
+		run:    run,
+		lock:   lock,
+		lg:     lg.With("daemon", "scheduler"),
+		status: make(map[string]*Status, len(cfg.Jobs)),
+		reg:    prometheus.NewRegistry(),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kbemp_daemon_runs_total",
+			Help: "Number of runs of jobs by result",
+		}, []string{"job", "result"}),
+		seconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kbemp_daemon_run_duration_seconds",
+			Help:    "Duration of runs of jobs",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"job"}),
+		success: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kbemp_daemon_last_success_timestamp_seconds",
+			Help: "Time of the last successful run of the job",
+		}, []string{"job"}),
+		running: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kbemp_daemon_running",
+			Help: "1 if the job is running",
+		}, []string{"job"}),
+	}
+	d.reg.MustRegister(d.runs, d.seconds, d.success, d.running,
+		collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	if err = d.loadStatus(); err != nil {
+		return nil, err
+	}
+	for _, j := range cfg.Jobs {
+		st, ok := d.status[j.Name]
+		if !ok {
+			st = &Status{Job: j.Name}
+			d.status[j.Name] = st
+		}
+		st.Cmd, st.Schedule, st.Running = j.Cmd, j.Schedule, false
+		if !st.LastSuccess.IsZero() {
+			d.success.WithLabelValues(j.Name).Set(float64(st.LastSuccess.Unix()))
+		}
+	}
+	return
+}
+
+// Start runs jobs by schedules and serves endpoints until the context is done.
+// The running job is canceled by the context.
+func (d *Daemon) Start(ctx context.Context) error {
+	var srv *http.Server
+	if d.cfg.Listen != "" {
+		srv = &http.Server{Addr: d.cfg.Listen, Handler: d.Handler(), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			d.lg.Info("Serve /healthz and /metrics", "addr", d.cfg.Listen)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				d.lg.Error("Serve endpoints of daemon", "err", err)
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for i := range d.cfg.Jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.loop(ctx, &d.cfg.Jobs[i])
+		}()
+	}
+	wg.Wait()
+
+	if srv != nil {
+		ctxSh, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctxSh)
+	}
+	return ctx.Err()
+}
+
+// loop runs the job by its schedule. Times missed by the long run are skipped.
+func (d *Daemon) loop(ctx context.Context, job *Job) {
+	for {
+		next := job.Next(time.Now())
+		d.update(job.Name, func(st *Status) { st.Next = next })
+		d.lg.Info("Next run", "job", job.Name, "at", next)
+
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		d.RunJob(ctx, job)
+	}
+}
+
+// RunJob runs the job if the lock is free. The run is skipped if the lock is held by another run.
+func (d *Daemon) RunJob(ctx context.Context, job *Job) (err error) {
+	lg := d.lg.With("job", job.Name, "cmd", job.Cmd)
+
+	unlock, err := d.lock.TryLock(ctx)
+	if err != nil {
+		lg.Warn("Run is skipped", "err", err)
+		d.runs.WithLabelValues(job.Name, ResultSkipped).Inc()
+		d.update(job.Name, func(st *Status) { st.Skipped++ })
+		return
+	}
+	defer unlock()
+
+	start := time.Now()
+	lg.Info("Run started", "branches", job.Branches)
+	d.running.WithLabelValues(job.Name).Set(1)
+	d.update(job.Name, func(st *Status) { st.Running = true; st.Started = start })
+
+	err = d.run(ctx, job)
+
+	end := time.Now()
+	d.running.WithLabelValues(job.Name).Set(0)
+	d.seconds.WithLabelValues(job.Name).Observe(end.Sub(start).Seconds())
+
+	result := ResultOK
+	if err != nil {
+		result = ResultError
+		lg.Error("Run failed", "duration", end.Sub(start), "err", err)
+	} else {
+		lg.Info("Run finished", "duration", end.Sub(start))
+		d.success.WithLabelValues(job.Name).Set(float64(end.Unix()))
+	}
+	d.runs.WithLabelValues(job.Name, result).Inc()
+
+	d.update(job.Name, func(st *Status) {
+		st.Running = false
+		st.Finished = end
+		st.Duration = end.Sub(start)
+		st.Result = result
+		st.Error = ""
+		st.Runs++
+		if err != nil {
+			st.Error = err.Error()
+			st.Failures++
+		} else {
+			st.LastSuccess = end
+		}
+	})
+	d.saveStatus()
+	return
+}
+
+// Status returns statuses of jobs in order of the config
+func (d *Daemon) Status() []Status {
+	d.mt.Lock()
+	defer d.mt.Unlock()
+
+	sts := make([]Status, 0, len(d.cfg.Jobs))
+	for _, j := range d.cfg.Jobs {
+		sts = append(sts, *d.status[j.Name])
+	}
+	return sts
+}
+
+// Handler serves /healthz with statuses of jobs and /metrics of the daemon
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(d.reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Status string   `json:"status"`
+			Jobs   []Status `json:"jobs"`
+		}{"ok", d.Status()})
+	})
+	return mux
+}
+
+func (d *Daemon) update(name string, f func(st *Status)) {
+	d.mt.Lock()
+	defer d.mt.Unlock()
+	f(d.status[name])
+}
+
+func (d *Daemon) loadStatus() error {
+	if d.cfg.StatusFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(d.cfg.StatusFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read status: %w", err)
+	}
+
+	var sts []*Status
+	if err = json.Unmarshal(b, &sts); err != nil {
+		return fmt.Errorf("read status %s: %w", d.cfg.StatusFile, err)
+	}
+	for _, st := range sts {
+		d.status[st.Job] = st
+	}
+	return nil
+}
+
+// saveStatus writes the status file atomically, errors are only logged
+func (d *Daemon) saveStatus() {
+	if d.cfg.StatusFile == "" {
+		return
+	}
+
+	b, err := json.MarshalIndent(d.Status(), "", "  ")
+	if err == nil {
+		tmp := d.cfg.StatusFile + ".tmp"
+		if err = os.WriteFile(tmp, b, 0644); err == nil {
+			err = os.Rename(tmp, d.cfg.StatusFile)
+		}
+	}
+	if err != nil {
+		d.lg.Error("Save status", "file", d.cfg.StatusFile, "err", err)
+	}
+}
@@ -0,0 +1,181 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/mioxin/kbempgo/pkg/kongyaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCLI struct {
+	Daemon Config `embed:"" prefix:"daemon-"`
+}
+
+func parse(t *testing.T, args ...string) (*testCLI, error) {
+	t.Helper()
+
+	fd, err := os.Open("testdata/daemon.yaml")
+	require.NoError(t, err)
+	defer fd.Close()
+
+	ld, err := kongyaml.Loader(fd)
+	require.NoError(t, err)
+
+	cli := &testCLI{}
+	parser, err := kong.New(cli, kong.Resolvers(ld))
+	require.NoError(t, err)
+
+	_, err = parser.Parse(args)
+	return cli, err
+}
+
+func TestJobsConfig(t *testing.T) {
+	cli, err := parse(t)
+	require.NoError(t, err)
+	require.NoError(t, cli.Daemon.Jobs.Validate())
+
+	jobs := cli.Daemon.Jobs
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "tree", jobs[0].Name)
+	assert.Empty(t, jobs[0].Branches)
+	assert.Equal(t, []string{"razd1.27", "razd1.28"}, jobs[1].Branches)
+	assert.Equal(t, 2, jobs[1].Depth)
+	assert.Equal(t, 20*time.Minute, jobs[1].Timeout)
+	assert.Empty(t, cli.Daemon.Listen)
+
+	at := time.Date(2025, 3, 10, 2, 30, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2025, 3, 11, 2, 0, 0, 0, time.Local), jobs[0].Next(at))
+	assert.Equal(t, time.Date(2025, 3, 10, 3, 0, 0, 0, time.Local), jobs[1].Next(at))
+}
+
+func TestJobsFlags(t *testing.T) {
+	// jobs of flags replace jobs of the config file
+	cli, err := parse(t,
+		"--daemon-jobs", `{name: a, schedule: "*/15 * * * *", cmd: dump}`,
+		"--daemon-jobs", `[{name: b, schedule: "@daily", cmd: sync, file_source: /tmp/kb}]`,
+	)
+	require.NoError(t, err)
+	require.NoError(t, cli.Daemon.Jobs.Validate())
+	require.Len(t, cli.Daemon.Jobs, 2)
+	assert.Equal(t, "a", cli.Daemon.Jobs[0].Name)
+	assert.Equal(t, "/tmp/kb", cli.Daemon.Jobs[1].FileSource)
+}
+
+func TestJobsValidate(t *testing.T) {
+	cases := []struct {
+		Name string
+		Jobs Jobs
+		Err  string
+	}{
+		{"empty", Jobs{}, "no jobs"},
+		{"no name", Jobs{{Schedule: "@daily", Cmd: CmdDump}}, "name of job is empty"},
+		{"unknown cmd", Jobs{{Name: "a", Schedule: "@daily", Cmd: "news"}}, "unknown cmd"},
+		{"sync without source", Jobs{{Name: "a", Schedule: "@daily", Cmd: CmdSync}}, "file_source"},
+		{"bad schedule", Jobs{{Name: "a", Schedule: "0 25 * * *", Cmd: CmdDump}}, "schedule"},
+		{"duplicate", Jobs{{Name: "a", Schedule: "@daily", Cmd: CmdDump}, {Name: "a", Schedule: "@hourly", Cmd: CmdDump}}, "duplicated"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Jobs.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.Err)
+		})
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kb.lock")
+	l1, err := NewLocker(path, "mem://")
+	require.NoError(t, err)
+	l2, err := NewLocker(path, "mem://")
+	require.NoError(t, err)
+
+	unlock, err := l1.TryLock(context.Background())
+	require.NoError(t, err)
+
+	_, err = l2.TryLock(context.Background())
+	assert.ErrorIs(t, err, ErrLocked)
+
+	unlock()
+	unlock, err = l2.TryLock(context.Background())
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestRunJob(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := NewLocker(filepath.Join(dir, "kb.lock"), "")
+	require.NoError(t, err)
+
+	cfg := Config{
+		Jobs: Jobs{
+			{Name: "tree", Schedule: "0 2 * * *", Cmd: CmdDump},
+			{Name: "management", Schedule: "@hourly", Cmd: CmdDump, Branches: []string{"razd1.27"}},
+		},
+		StatusFile: filepath.Join(dir, "status.json"),
+	}
+
+	var d *Daemon
+	overlapped := 0
+	d, err = New(cfg, lock, func(ctx context.Context, job *Job) error {
+		if job.Name == "management" {
+			return errors.New("crawl failed")
+		}
+		// the branch job can't run while the tree is dumped
+		if e := d.RunJob(ctx, &d.cfg.Jobs[1]); errors.Is(e, ErrLocked) {
+			overlapped++
+		}
+		return nil
+	}, slog.Default())
+	require.NoError(t, err)
+
+	require.NoError(t, d.RunJob(context.Background(), &d.cfg.Jobs[0]))
+	assert.Equal(t, 1, overlapped)
+	require.Error(t, d.RunJob(context.Background(), &d.cfg.Jobs[1]))
+
+	sts := d.Status()
+	assert.Equal(t, ResultOK, sts[0].Result)
+	assert.Equal(t, 1, sts[0].Runs)
+	assert.False(t, sts[0].LastSuccess.IsZero())
+	assert.Equal(t, ResultError, sts[1].Result)
+	assert.Equal(t, "crawl failed", sts[1].Error)
+	assert.Equal(t, 1, sts[1].Failures)
+	assert.Equal(t, 1, sts[1].Skipped)
+
+	// the status is kept between restarts
+	d2, err := New(cfg, lock, nil, slog.Default())
+	require.NoError(t, err)
+	sts2 := d2.Status()
+	assert.Equal(t, sts[0].Runs, sts2[0].Runs)
+	assert.True(t, sts[0].LastSuccess.Equal(sts2[0].LastSuccess))
+	assert.Equal(t, "crawl failed", sts2[1].Error)
+
+	// endpoints
+	rec := httptest.NewRecorder()
+	d.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, 200, rec.Code)
+	var health struct {
+		Status string
+		Jobs   []Status
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health))
+	assert.Equal(t, "ok", health.Status)
+	assert.Len(t, health.Jobs, 2)
+
+	rec = httptest.NewRecorder()
+	d.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), `kbemp_daemon_runs_total{job="management",result="skipped"} 1`)
+	assert.Contains(t, rec.Body.String(), `kbemp_daemon_runs_total{job="tree",result="ok"} 1`)
+	assert.Contains(t, rec.Body.String(), "kbemp_daemon_last_success_timestamp_seconds")
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+)
+
+func flock(_ *os.File) error {
+	return fmt.Errorf("lock file is not supported, use the advisory lock of postgres")
+}
+
+func funlock(_ *os.File) {}
@@ -0,0 +1,123 @@
+// Package daemon runs dump and sync of employees by cron-like schedules.
+// Runs don't overlap: every run takes the lock shared by daemons of the same storage.
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/goccy/go-yaml"
+	"github.com/robfig/cron/v3"
+)
+
+// Commands run by jobs
+const (
+	CmdDump = "dump"
+	CmdSync = "sync"
+)
+
+// Job is a command run by the schedule
+type Job struct {
+	Name string `yaml:"name"`
+	// Schedule is a cron expression with 5 fields (minute hour day month weekday)
+	// or a descriptor: @hourly, @daily, @every 30m
+	Schedule string `yaml:"schedule"`
+	Cmd      string `yaml:"cmd"`
+	// Branches are refreshed by the dump instead of the whole tree
+	Branches []string `yaml:"branch"`
+	// Depth of the crawl below the root section or branches, 0 is the default of the dump
+	Depth int `yaml:"depth"`
+	// FileSource is the dir with dep.json and sotr.json loaded by the sync
+	FileSource string `yaml:"file_source"`
+	// Timeout of the run, the op timeout is used by default
+	Timeout time.Duration `yaml:"timeout"`
+
+	sched cron.Schedule
+}
+
+// Validate checks the job and parses its schedule
+func (j *Job) Validate() (err error) {
+	if j.Name == "" {
+		return errors.New("name of job is empty")
+	}
+
+	switch j.Cmd {
+	case CmdDump:
+	case CmdSync:
+		if j.FileSource == "" {
+			return fmt.Errorf("job %s: file_source of sync is empty", j.Name)
+		}
+	default:
+		return fmt.Errorf("job %s: unknown cmd %q, expected %s or %s", j.Name, j.Cmd, CmdDump, CmdSync)
+	}
+
+	if j.Depth < 0 || j.Timeout < 0 {
+		return fmt.Errorf("job %s: depth and timeout should be >= 0", j.Name)
+	}
+
+	if j.sched, err = cron.ParseStandard(j.Schedule); err != nil {
+		return fmt.Errorf("job %s: schedule %q: %w", j.Name, j.Schedule, err)
+	}
+	return nil
+}
+
+// Next returns the time of the next run after t
+func (j *Job) Next(t time.Time) time.Time {
+	return j.sched.Next(t)
+}
+
+// Jobs are jobs of the daemon. They are a list in the config file
+// or YAML of a job or of the list in flags and the environment.
+type Jobs []Job
+
+// Decode implements kong.MapperValue, every flag adds its jobs
+func (js *Jobs) Decode(ctx *kong.DecodeContext) (err error) {
+	t, err := ctx.Scan.PopValue("jobs")
+	if err != nil {
+		return
+	}
+
+	var b []byte
+	switch v := t.Value.(type) {
+	case string:
+		b = []byte(v)
+	default:
+		// the list of the config file
+		if b, err = yaml.Marshal(v); err != nil {
+			return fmt.Errorf("jobs: %w", err)
+		}
+	}
+
+	var jobs []Job
+	if err = yaml.Unmarshal(b, &jobs); err != nil {
+		var job Job
+		if e := yaml.Unmarshal(b, &job); e != nil {
+			return fmt.Errorf("jobs: %w", err)
+		}
+		jobs = []Job{job}
+	}
+
+	*js = append(*js, jobs...)
+	return nil
+}
+
+// Validate checks jobs, names of jobs should be unique
+func (js Jobs) Validate() error {
+	if len(js) == 0 {
+		return errors.New("no jobs are configured")
+	}
+
+	names := make(map[string]bool, len(js))
+	for i := range js {
+		if err := js[i].Validate(); err != nil {
+			return err
+		}
+		if names[js[i].Name] {
+			return fmt.Errorf("job %s is duplicated", js[i].Name)
+		}
+		names[js[i].Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// ErrLocked is returned by TryLock if the lock is held by another run
+var ErrLocked = errors.New("lock is held by another run")
+
+// Locker prevents overlapping runs of daemons
+type Locker interface {
+	// TryLock takes the lock without waiting, it returns ErrLocked if the lock is held
+	TryLock(ctx context.Context) (unlock func(), err error)
+	Close() error
+}
+
+// DefaultLockName is the name of the advisory lock and the lock file
+const DefaultLockName = "kbemp-daemon"
+
+// NewLocker returns the Postgres advisory lock for postgres:// URL
+// or the lock file for the path. If lock is empty then the advisory lock of the storage
+// is used for Postgres storage, otherwise the lock file in the temp dir.
+func NewLocker(lock, storageURL string) (Locker, error) {
+	if lock == "" {
+		lock = filepath.Join(os.TempDir(), DefaultLockName+".lock")
+		if isPostgres(storageURL) {
+			lock = storageURL
+		}
+	}
+
+	if isPostgres(lock) {
+		db, err := sql.Open("pgx", lock)
+		if err != nil {
+			return nil, fmt.Errorf("open advisory lock: %w", err)
+		}
+		return &pgLock{db: db, key: lockKey(DefaultLockName)}, nil
+	}
+	return &fileLock{path: lock}, nil
+}
+
+func isPostgres(url string) bool {
+	return strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://")
+}
+
+// lockKey is the key of the advisory lock by its name
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// pgLock is the session advisory lock, it's held by the connection until unlock
+// and released by Postgres if the daemon is killed.
+type pgLock struct {
+	db  *sql.DB
+	key int64
+}
+
+func (l *pgLock) TryLock(ctx context.Context) (unlock func(), err error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("advisory lock: %w", err)
+	}
+
+	var ok bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		if err != nil {
+			return nil, fmt.Errorf("advisory lock: %w", err)
+		}
+		return nil, ErrLocked
+	}
+
+	return func() {
+		// the lock is released by closing of the session anyway
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key)
+		conn.Close()
+	}, nil
+}
+
+func (l *pgLock) Close() error {
+	return l.db.Close()
+}
+
+// fileLock is the exclusive lock of the file, it's released by the system if the daemon is killed
+type fileLock struct {
+	path string
+}
+
+func (l *fileLock) TryLock(_ context.Context) (unlock func(), err error) {
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("lock file: %w", err)
+	}
+
+	if err = flock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// pid of the holder is for humans
+	f.Truncate(0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+
+	return func() {
+		funlock(f)
+		f.Close()
+	}, nil
+}
+
+func (l *fileLock) Close() error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package datasource
 
 import (
+	"context"
 	"fmt"
 	"slices"
 	"strconv"
@@ -34,10 +35,18 @@ type Sotr struct {
 	Children  bool     `json:"children"`
 	ParentIdr string   `gorm:"size:255" json:"parentIdr"`
 
+	// attributes of HR exports set by the import
+	HireDate *time.Time `json:"hireDate,omitempty"`
+	Position string     `gorm:"size:255" json:"position"`
+	// ManagerTabnum is the optional link to the manager set by Update or the import,
+	// managers of other employees are inferred by grades of heads of deps
+	ManagerTabnum string `gorm:"size:16;index" json:"managerTabnum"`
+
 	DepID *uint `json:"-"`
 	Dep   Dep   `json:"-"`
 
 	History []History `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+	Avatars []Avatar  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
 
 	// Deleted bool `gorm:"default:false" json:"-"`
 }
@@ -53,7 +62,9 @@ func (d *Sotr) BeforeSave(tx *gorm.DB) (err error) {
 	}
 
 	if len(oldSotrsResponse) > 0 {
-		hist := d.Diff(oldSotrsResponse[len(oldSotrsResponse)-1])
+		old := oldSotrsResponse[len(oldSotrsResponse)-1]
+		d.KeepImported(old, Cleared(tx.Statement.Context))
+		hist := d.Diff(old)
 		d.History = hist
 	}
 	d.Phone = nil
@@ -87,8 +98,8 @@ func (s Sotr) Diff(oldSotr Sotr) []History {
 		h = append(h, History{Field: "name", OldValue: oldSotr.Name})
 	}
 
-	if *s.Email != *oldSotr.Email {
-		h = append(h, History{Field: "email", OldValue: *oldSotr.Email})
+	if strVal(s.Email) != strVal(oldSotr.Email) {
+		h = append(h, History{Field: "email", OldValue: strVal(oldSotr.Email)})
 	}
 	if s.Avatar != oldSotr.Avatar {
 		h = append(h, History{Field: "avatar", OldValue: oldSotr.Avatar})
@@ -99,8 +110,87 @@ func (s Sotr) Diff(oldSotr Sotr) []History {
 	if s.ParentIdr != oldSotr.ParentIdr {
 		h = append(h, History{Field: "parent_idr", OldValue: oldSotr.ParentIdr})
 	}
+	if dateVal(s.HireDate) != dateVal(oldSotr.HireDate) {
+		h = append(h, History{Field: FieldHireDate, OldValue: dateVal(oldSotr.HireDate)})
+	}
+	if s.Position != oldSotr.Position {
+		h = append(h, History{Field: FieldPosition, OldValue: oldSotr.Position})
+	}
+	if s.ManagerTabnum != oldSotr.ManagerTabnum {
+		h = append(h, History{Field: FieldManagerTabnum, OldValue: oldSotr.ManagerTabnum})
+	}
 	return h
 }
+
+// KeepImported sets empty attributes of HR exports from the old sotr except cleared ones.
+// The dump doesn't scrape them, so they are changed by the import only.
+func (s *Sotr) KeepImported(oldSotr Sotr, cleared []string) {
+	if s.HireDate == nil && !slices.Contains(cleared, FieldHireDate) {
+		s.HireDate = oldSotr.HireDate
+	}
+	if s.Position == "" && !slices.Contains(cleared, FieldPosition) {
+		s.Position = oldSotr.Position
+	}
+	if s.ManagerTabnum == "" && !slices.Contains(cleared, FieldManagerTabnum) {
+		s.ManagerTabnum = oldSotr.ManagerTabnum
+	}
+}
+
+// Attributes of HR exports cleared by UpdateSotrRequest
+const (
+	FieldHireDate      = "hire_date"
+	FieldPosition      = "position"
+	FieldManagerTabnum = "manager_tabnum"
+)
+
+// CheckCleared returns the error if the field can't be cleared
+func CheckCleared(fields []string) error {
+	for _, f := range fields {
+		if f != FieldHireDate && f != FieldPosition && f != FieldManagerTabnum {
+			return fmt.Errorf("field %q can't be cleared, only attributes of HR exports are cleared", f)
+		}
+	}
+	return nil
+}
+
+type clearedKey struct{}
+
+// WithCleared returns the context of saves clearing the attributes of HR exports instead of keeping saved values
+func WithCleared(ctx context.Context, fields []string) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, clearedKey{}, fields)
+}
+
+// Cleared returns attributes of HR exports cleared by saves of the context
+func Cleared(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(clearedKey{}).([]string)
+	return fields
+}
+
+// DateLayout is the layout of dates of histories
+const DateLayout = "2006-01-02"
+
+// dateVal returns the date of nullable time field or empty string
+func dateVal(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(DateLayout)
+}
+
+// strVal returns value of nullable string field or empty string
+func strVal(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 func (d Sotr) GetChildren() bool {
 	return d.Children
 }
@@ -134,6 +224,11 @@ func (d Sotr) Conv2Kbv() *kbv1.Item {
 	sotr.Children = d.Children
 	sotr.ParentId = d.ParentIdr
 	sotr.Date = timestamppb.New(d.CreatedAt)
+	if d.HireDate != nil {
+		sotr.HireDate = timestamppb.New(*d.HireDate)
+	}
+	sotr.Position = d.Position
+	sotr.ManagerTabnum = d.ManagerTabnum
 
 	return &kbv1.Item{
 		Var: &kbv1.Item_Sotr{
@@ -200,6 +295,32 @@ func (p History) SqlInsertValueFormat() string {
 	return fmt.Sprintf("(%d, '%s', '%s')", *p.SotrID, p.Field, p.OldValue)
 }
 
+// Avatar is the image of the employee in the content-addressed avatar store.
+// The same hash of the employee is one row, the current avatar has the latest LastSeen.
+type Avatar struct {
+	ID          uint      `gorm:"primaryKey"`
+	Tabnum      string    `gorm:"size:16;uniqueIndex:idx_avatar_tabnum_hash" json:"tabnum"`
+	Hash        string    `gorm:"size:64;index;uniqueIndex:idx_avatar_tabnum_hash" json:"hash"`
+	Size        int64     `json:"size"`
+	ContentType string    `gorm:"size:64" json:"content_type"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `gorm:"index" json:"last_seen"`
+
+	SotrID        *uint `json:"sotr_id,omitempty"`
+	SotrDeletedID *uint `json:"sotr_deleted_id,omitempty"`
+}
+
+func (a Avatar) Conv2Kbv() *kbv1.Avatar {
+	return &kbv1.Avatar{
+		Tabnum:      a.Tabnum,
+		Hash:        a.Hash,
+		Size:        a.Size,
+		ContentType: a.ContentType,
+		FirstSeen:   timestamppb.New(a.FirstSeen),
+		LastSeen:    timestamppb.New(a.LastSeen),
+	}
+}
+
 type Phone struct {
 	ID    uint   `gorm:"primaryKey"`
 	Phone string `gorm:"size:16;index;uniqueIndex:idx_phone_sotrid;uniqueIndex:idx_phone_sotrdelid" json:"phone"`
@@ -243,3 +364,64 @@ func (m *Mobile) String() string {
 func (m Mobile) SqlInsertValueFormat() string {
 	return fmt.Sprintf("(%d, '%d')", *m.SotrID, m.Mobile)
 }
+
+// News is the news of the intranet. The author is linked to the employee by tabnum,
+// it's kept after the employee is removed.
+type News struct {
+	gorm.Model
+	Idn          string    `gorm:"size:64;uniqueIndex" json:"idn"`
+	Title        string    `gorm:"size:512" json:"title"`
+	Author       string    `gorm:"size:255" json:"author"`
+	AuthorTabnum string    `gorm:"size:16;index" json:"author_tabnum"`
+	Date         time.Time `gorm:"index" json:"date"`
+	Body         string    `gorm:"type:text" json:"body"`
+	Url          string    `gorm:"size:512" json:"url"`
+
+	Comments []Comment `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+func (n News) Conv2Kbv() *kbv1.News {
+	news := &kbv1.News{
+		Id:           uint64(n.ID),
+		Idn:          n.Idn,
+		Title:        n.Title,
+		Author:       n.Author,
+		AuthorTabnum: n.AuthorTabnum,
+		Body:         n.Body,
+		Url:          n.Url,
+	}
+	if !n.Date.IsZero() {
+		news.Date = timestamppb.New(n.Date)
+	}
+	for _, c := range n.Comments {
+		news.Comments = append(news.Comments, c.Conv2Kbv())
+	}
+	return news
+}
+
+// Comment is the comment of the news, the author is linked to the employee by tabnum
+type Comment struct {
+	ID           uint      `gorm:"primaryKey"`
+	Idc          string    `gorm:"size:64;uniqueIndex:idx_comment_news_idc" json:"idc"`
+	ParentIdc    string    `gorm:"size:64" json:"parent_idc"`
+	Author       string    `gorm:"size:255" json:"author"`
+	AuthorTabnum string    `gorm:"size:16;index" json:"author_tabnum"`
+	Date         time.Time `json:"date"`
+	Text         string    `gorm:"type:text" json:"text"`
+
+	NewsID uint `gorm:"uniqueIndex:idx_comment_news_idc" json:"news_id"`
+}
+
+func (c Comment) Conv2Kbv() *kbv1.Comment {
+	cm := &kbv1.Comment{
+		Idc:          c.Idc,
+		ParentIdc:    c.ParentIdc,
+		Author:       c.Author,
+		AuthorTabnum: c.AuthorTabnum,
+		Text:         c.Text,
+	}
+	if !c.Date.IsZero() {
+		cm.Date = timestamppb.New(c.Date)
+	}
+	return cm
+}
@@ -9,12 +9,35 @@ import (
 
 type Config struct {
 	worker.Config
-	Workers         int
-	Limit           int
-	RootRazd        string
+	Workers  int
+	Limit    int
+	RootRazd string
+	// razds crawled instead of RootRazd with their subtrees
+	Branches []string
+	// levels of razds crawled below the start ones, 0 is unlimited
+	Depth int
+	// razds crawled first with their subtrees in order of priority
+	PriorityRazds   []string
 	OpTimeout       time.Duration
 	WaitDataTimeout time.Duration
 	Debug           int
+	Progress        ProgressConfig
+	// Frontier is shared by dump processes of the distributed crawl, the local one is used if it's nil
+	Frontier Frontier
 
 	Lg *slog.Logger
 }
+
+// Frontier is the crawl frontier shared by dump processes
+type Frontier interface {
+	worker.Outstanding
+	Razds() worker.Queue
+	Avatars() worker.Queue
+}
+
+// ProgressConfig configures reporting of the dump progress
+type ProgressConfig struct {
+	Interval time.Duration `name:"interval" default:"5s" help:"Interval of progress reporting, 0 disables the progress line"`
+	Listen   string        `name:"listen" help:"Listen address of /metrics endpoint with metrics of the dump, e.g. :9101"`
+	PushURL  string        `name:"push-url" help:"URL of Prometheus Pushgateway for metrics of the dump"`
+}
@@ -0,0 +1,313 @@
+package dump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mioxin/kbempgo/internal/worker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Progress of the dump. It's reported periodically to the terminal or the log
+// and exported as Prometheus metrics of the CLI.
+type Progress struct {
+	// fetched deps and employees
+	Deps, Sotrs atomic.Int32
+	// counters of requests shared by the pool of workers
+	Stats *worker.Stats
+
+	cfg   ProgressConfig
+	limit int
+	pool  []*worker.Worker
+	start time.Time
+	reg   *prometheus.Registry
+
+	mt sync.Mutex
+	// previous reported snapshot for request rate
+	last Snapshot
+
+	out io.Writer
+	tty bool
+	lg  *slog.Logger
+}
+
+// Snapshot is a state of the dump progress
+type Snapshot struct {
+	Elapsed     time.Duration
+	Deps, Sotrs int32
+	Requests    int64
+	Errors      int64
+	Retries     int64
+	Cached      int64
+	Avatars     int64
+	AvatarBytes int64
+	// length of queues of razds and avatars
+	QueueDep, QueueAvatar int
+	// requests per second since the previous snapshot
+	Rate float64
+	// estimated time to the end, it is negative if unknown
+	ETA time.Duration
+}
+
+var (
+	dumpDesc = func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("kbemp", "dump", name), help, nil, nil)
+	}
+
+	depsDesc        = dumpDesc("deps_total", "Number of fetched departments")
+	sotrsDesc       = dumpDesc("sotrs_total", "Number of fetched employees")
+	requestsDesc    = dumpDesc("requests_total", "Number of http requests")
+	errorsDesc      = dumpDesc("errors_total", "Number of failed requests")
+	retriesDesc     = dumpDesc("retries_total", "Number of retried requests")
+	cachedDesc      = dumpDesc("cached_total", "Number of not modified responses and not changed employees got from the cache")
+	avatarsDesc     = dumpDesc("avatars_total", "Number of downloaded avatars")
+	avatarBytesDesc = dumpDesc("avatar_bytes_total", "Size of downloaded avatars")
+	etaDesc         = dumpDesc("eta_seconds", "Estimated time to the end of the dump")
+	queueDesc       = prometheus.NewDesc("kbemp_dump_queue_length", "Length of the queue of tasks", []string{"queue"}, nil)
+)
+
+// NewProgress creates progress of the pool of workers. Workers share the counters of the progress.
+func NewProgress(cfg ProgressConfig, limit int, pool []*worker.Worker, lg *slog.Logger) *Progress {
+	p := &Progress{
+		Stats: &worker.Stats{},
+		cfg:   cfg,
+		limit: limit,
+		pool:  pool,
+		start: time.Now(),
+		reg:   prometheus.NewRegistry(),
+		out:   os.Stderr,
+		lg:    lg.With("dump", "progress"),
+	}
+
+	if fi, err := os.Stderr.Stat(); err == nil {
+		p.tty = fi.Mode()&os.ModeCharDevice != 0
+	}
+
+	for _, w := range pool {
+		w.Stats = p.Stats
+	}
+
+	p.reg.MustRegister(p)
+	return p
+}
+
+// Run reports the progress every interval until ctx is done, then reports the final state.
+func (p *Progress) Run(ctx context.Context) {
+	if p.cfg.Listen != "" {
+		srv := p.serve()
+		defer srv.Close()
+	}
+
+	if p.cfg.Interval <= 0 {
+		<-ctx.Done()
+		p.push()
+		return
+	}
+
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s := p.Snapshot()
+
+			p.mt.Lock()
+			if s.Requests == p.last.Requests && s.Elapsed > p.cfg.Interval {
+				p.lg.Warn("Dump: no requests since last report", "interval", p.cfg.Interval)
+			}
+			p.last = s
+			p.mt.Unlock()
+
+			p.report(s, false)
+			p.push()
+
+		case <-ctx.Done():
+			p.report(p.Snapshot(), true)
+			p.push()
+			return
+		}
+	}
+}
+
+// Snapshot returns the current state of the progress
+func (p *Progress) Snapshot() (s Snapshot) {
+	s = Snapshot{
+		Elapsed:     time.Since(p.start),
+		Deps:        p.Deps.Load(),
+		Sotrs:       p.Sotrs.Load(),
+		Requests:    p.Stats.Requests.Load(),
+		Errors:      p.Stats.Errors.Load(),
+		Retries:     p.Stats.Retries.Load(),
+		Cached:      p.Stats.Cached.Load(),
+		Avatars:     p.Stats.Avatars.Load(),
+		AvatarBytes: p.Stats.AvatarBytes.Load(),
+		ETA:         -1,
+	}
+
+	s.QueueDep, s.QueueAvatar = p.queues()
+
+	p.mt.Lock()
+	last := p.last
+	p.mt.Unlock()
+
+	if d := (s.Elapsed - last.Elapsed).Seconds(); d > 0 {
+		s.Rate = float64(s.Requests-last.Requests) / d
+	}
+
+	s.ETA = p.eta(s)
+	return
+}
+
+// queues returns lengths of razd and avatar queues, the queues can be shared by workers
+func (p *Progress) queues() (razds, avatars int) {
+	seen := make(map[worker.Queue]struct{}, 2*len(p.pool))
+
+	for _, w := range p.pool {
+		for _, f := range []worker.Queue{w.Razds, w.Avatars} {
+			if _, ok := seen[f]; ok {
+				continue
+			}
+			seen[f] = struct{}{}
+
+			if f == w.Razds {
+				razds += f.Len()
+			} else {
+				avatars += f.Len()
+			}
+		}
+	}
+	return
+}
+
+// eta estimates time to the end by the limit of items or by the queue of deps
+func (p *Progress) eta(s Snapshot) time.Duration {
+	if p.limit > 0 {
+		done := int(s.Deps + s.Sotrs)
+		if done == 0 {
+			return -1
+		}
+		return time.Duration(float64(s.Elapsed) * float64(max(p.limit-done, 0)) / float64(done))
+	}
+
+	// every fetched dep is queued to get its children
+	processed := int(s.Deps) - s.QueueDep
+	if processed <= 0 {
+		return -1
+	}
+	return time.Duration(float64(s.Elapsed) * float64(s.QueueDep) / float64(processed))
+}
+
+func (p *Progress) report(s Snapshot, final bool) {
+	if !p.tty {
+		p.lg.Info("Dump progress", "elapsed", s.Elapsed.Round(time.Second), "deps", s.Deps, "sotrs", s.Sotrs,
+			"queue_dep", s.QueueDep, "queue_avatar", s.QueueAvatar, "req_rate", fmt.Sprintf("%.1f", s.Rate),
+			"errors", s.Errors, "retries", s.Retries, "cached", s.Cached, "avatars", s.Avatars, "avatar_bytes", s.AvatarBytes,
+			"eta", s.ETA.Round(time.Second))
+		return
+	}
+
+	end := ""
+	if final {
+		end = "\n"
+	}
+	// rewrite the line in the terminal
+	fmt.Fprintf(p.out, "\r\033[K%s%s", s, end)
+}
+
+func (s Snapshot) String() string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "dump %s: deps %d, sotrs %d | queue razd %d, avatar %d | %.1f req/s, errors %d, retries %d, cached %d | avatars %d (%s)",
+		s.Elapsed.Round(time.Second), s.Deps, s.Sotrs, s.QueueDep, s.QueueAvatar,
+		s.Rate, s.Errors, s.Retries, s.Cached, s.Avatars, formatBytes(s.AvatarBytes))
+
+	if s.ETA >= 0 {
+		fmt.Fprintf(b, " | ETA %s", s.ETA.Round(time.Second))
+	}
+	return b.String()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// serve starts /metrics endpoint with metrics of the dump
+func (p *Progress) serve() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(p.reg, promhttp.HandlerOpts{}))
+
+	srv := &http.Server{Addr: p.cfg.Listen, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		p.lg.Info("Serve metrics of dump", "addr", p.cfg.Listen)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			p.lg.Error("Serve metrics of dump", "err", err)
+		}
+	}()
+	return srv
+}
+
+// push sends metrics to Pushgateway if it's configured
+func (p *Progress) push() {
+	if p.cfg.PushURL == "" {
+		return
+	}
+
+	if err := push.New(p.cfg.PushURL, "kbemp_dump").Gatherer(p.reg).Push(); err != nil {
+		p.lg.Error("Push metrics of dump", "url", p.cfg.PushURL, "err", err)
+	}
+}
+
+func (p *Progress) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{depsDesc, sotrsDesc, requestsDesc, errorsDesc, retriesDesc, cachedDesc,
+		avatarsDesc, avatarBytesDesc, etaDesc, queueDesc} {
+		ch <- d
+	}
+}
+
+func (p *Progress) Collect(ch chan<- prometheus.Metric) {
+	s := p.Snapshot()
+
+	counters := map[*prometheus.Desc]float64{
+		depsDesc:        float64(s.Deps),
+		sotrsDesc:       float64(s.Sotrs),
+		requestsDesc:    float64(s.Requests),
+		errorsDesc:      float64(s.Errors),
+		retriesDesc:     float64(s.Retries),
+		cachedDesc:      float64(s.Cached),
+		avatarsDesc:     float64(s.Avatars),
+		avatarBytesDesc: float64(s.AvatarBytes),
+	}
+	for d, v := range counters {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+
+	if s.ETA >= 0 {
+		ch <- prometheus.MustNewConstMetric(etaDesc, prometheus.GaugeValue, s.ETA.Seconds())
+	}
+
+	ch <- prometheus.MustNewConstMetric(queueDesc, prometheus.GaugeValue, float64(s.QueueDep), "razd")
+	ch <- prometheus.MustNewConstMetric(queueDesc, prometheus.GaugeValue, float64(s.QueueAvatar), "avatar")
+}
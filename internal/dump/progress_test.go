@@ -0,0 +1,108 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mioxin/kbempgo/internal/worker"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProgress(limit int) (*Progress, []*worker.Worker) {
+	tasks := worker.NewTracker()
+	razds, avatars := worker.NewFrontier(tasks), worker.NewFrontier(tasks)
+
+	pool := []*worker.Worker{
+		worker.NewWorker(&worker.Config{}, "get-0", 0, slog.Default()),
+		worker.NewWorker(&worker.Config{}, "get-1", 0, slog.Default()),
+	}
+	for _, w := range pool {
+		w.Razds, w.Avatars = razds, avatars
+	}
+	return NewProgress(ProgressConfig{Interval: 10 * time.Millisecond}, limit, pool, slog.Default()), pool
+}
+
+func TestProgressSnapshot(t *testing.T) {
+	p, pool := newTestProgress(0)
+	p.start = time.Now().Add(-10 * time.Second)
+
+	assert.Same(t, p.Stats, pool[1].Stats, "workers should share stats of progress")
+
+	p.Deps.Store(4)
+	p.Sotrs.Store(30)
+	pool[0].Stats.Requests.Add(50)
+	pool[1].Stats.AvatarBytes.Add(3 << 20)
+	pool[0].Razds.Push(*worker.NewTask("razd1"))
+	pool[1].Razds.Push(*worker.NewTask("razd2"))
+	pool[1].Avatars.Push(*worker.NewTask("/avatar/1.jpg"))
+
+	s := p.Snapshot()
+	assert.Equal(t, 2, s.QueueDep)
+	assert.Equal(t, 1, s.QueueAvatar)
+	assert.EqualValues(t, 50, s.Requests)
+	assert.InDelta(t, 5.0, s.Rate, 0.1)
+	// 2 deps of 4 are processed in 10s
+	assert.InDelta(t, 10*time.Second, s.ETA, float64(100*time.Millisecond))
+
+	line := s.String()
+	assert.Contains(t, line, "deps 4, sotrs 30")
+	assert.Contains(t, line, "queue razd 2, avatar 1")
+	assert.Contains(t, line, "(3.0 MiB)")
+	assert.Contains(t, line, "ETA 10s")
+}
+
+func TestProgressETALimit(t *testing.T) {
+	p, _ := newTestProgress(100)
+	p.start = time.Now().Add(-10 * time.Second)
+
+	assert.Negative(t, p.Snapshot().ETA, "nothing fetched")
+
+	p.Sotrs.Store(25)
+	assert.InDelta(t, 30*time.Second, p.Snapshot().ETA, float64(100*time.Millisecond))
+}
+
+func TestProgressRun(t *testing.T) {
+	p, _ := newTestProgress(0)
+	out := &bytes.Buffer{}
+	p.out, p.tty = out, true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+	defer cancel()
+
+	p.Deps.Store(1)
+	p.Run(ctx)
+
+	lines := strings.Split(out.String(), "\r\033[K")
+	require.Greater(t, len(lines), 2)
+	assert.True(t, strings.HasSuffix(out.String(), "\n"), "final report ends the line")
+	assert.Contains(t, lines[len(lines)-1], "deps 1, sotrs 0")
+}
+
+func TestProgressCollector(t *testing.T) {
+	p, pool := newTestProgress(0)
+	p.Deps.Store(2)
+	pool[0].Stats.Errors.Add(3)
+	pool[1].Avatars.Push(*worker.NewTask("/avatar/1.jpg"))
+
+	expected := `
+# HELP kbemp_dump_deps_total Number of fetched departments
+# TYPE kbemp_dump_deps_total counter
+kbemp_dump_deps_total 2
+# HELP kbemp_dump_errors_total Number of failed requests
+# TYPE kbemp_dump_errors_total counter
+kbemp_dump_errors_total 3
+# HELP kbemp_dump_queue_length Length of the queue of tasks
+# TYPE kbemp_dump_queue_length gauge
+kbemp_dump_queue_length{queue="avatar"} 1
+kbemp_dump_queue_length{queue="razd"} 0
+`
+	err := testutil.GatherAndCompare(p.reg, strings.NewReader(expected),
+		"kbemp_dump_deps_total", "kbemp_dump_errors_total", "kbemp_dump_queue_length")
+	require.NoError(t, err)
+}
@@ -8,120 +8,265 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync/atomic"
-	"time"
 
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/avatar"
+	httpclient "github.com/mioxin/kbempgo/internal/http_client"
 	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/parser"
 	"github.com/mioxin/kbempgo/internal/utils"
 	"github.com/mioxin/kbempgo/internal/worker"
 	"golang.org/x/sync/errgroup"
 )
 
-var (
-	DepsCounter atomic.Int32
-	SotrCounter atomic.Int32
-)
+// Crawl is the state of the started dump
+type Crawl struct {
+	tasks   worker.Outstanding
+	razds   worker.Queue
+	avatars *avatar.Store
+}
+
+// Completed reports whether all tasks of the crawl are finished.
+// It is valid after the channel of items is closed.
+func (c *Crawl) Completed() bool {
+	select {
+	case <-c.tasks.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// Razds returns idrs of razds whose children are retrieved
+func (c *Crawl) Razds() []string {
+	return c.razds.Completed()
+}
+
+// Avatars returns avatars of employees downloaded or revalidated by the crawl
+func (c *Crawl) Avatars() []*kbv1.Avatar {
+	if c.avatars == nil {
+		return nil
+	}
+	return c.avatars.Avatars()
+}
 
-// StartDump starts the dump process.
-// The returned channel will be closed by StartDump after all workers complete.
-// Call cancel() to stop all workers (via context cancellation).
-func StartDump(ctx context.Context, cancel context.CancelFunc, cfg *Config) <-chan models.Item {
+// StartDump starts the dump process from cfg.Branches or cfg.RootRazd.
+// The returned channel will be closed by StartDump when the last task of the crawl is finished
+// and all retrieved items are sent to the channel.
+// Cancel ctx to stop all workers.
+func StartDump(ctx context.Context, cfg *Config) (<-chan models.Item, *Crawl) {
 	// output channel for retrieved items
 	outCh := make(chan (models.Item), 1000)
 
+	if cfg.DispPollInterval != 0 {
+		cfg.Lg.Warn("Flag --disp-pollinterval is deprecated and ignored, workers take tasks from the shared frontier")
+	}
+
+	// limits of requests, outstanding tasks and queues of ones are common for all workers
+	limiters := worker.NewLimiters(&cfg.Config)
+	var (
+		tasks          worker.Outstanding
+		razds, avatars worker.Queue
+	)
+	if cfg.Frontier != nil {
+		tasks, razds, avatars = cfg.Frontier, cfg.Frontier.Razds(), cfg.Frontier.Avatars()
+	} else {
+		tracker := worker.NewTracker()
+		tracker.Lg = cfg.Lg
+		tasks, razds, avatars = tracker, worker.NewFrontier(tracker), worker.NewFrontier(tracker)
+	}
+	crawl := &Crawl{tasks: tasks, razds: razds}
+
 	// *****************************
 	// init request workers
 
-	// fileCollection is map of existing avatar files info for define new avatars
-	fileCollection, err := getFileCollection(cfg.Avatars, cfg.Lg)
+	var err error
+
+	// avatars are downloaded if the directory is set
+	if cfg.Avatars != "" {
+		if crawl.avatars, err = openAvatars(cfg.Avatars, cfg.Thumbs, cfg.Lg); err != nil {
+			cfg.Lg.Error("Dump is not started", "err", err)
+			close(outCh)
+			return outCh, crawl
+		}
+	}
+
+	// fixtures are shared by workers for replaying responses in the recorded order
+	if cfg.Fixtures == nil {
+		if cfg.Fixtures, err = httpclient.OpenFixtures(cfg.Fixture); err != nil {
+			cfg.Lg.Error("Dump is not started", "err", err)
+			close(outCh)
+			return outCh, crawl
+		}
+	}
+
+	var cache *worker.HTTPCache
+	if cfg.CacheDir != "" {
+		if cache, err = worker.NewHTTPCache(cfg.CacheDir, cfg.CacheMaxAge, cfg.Lg); err != nil {
+			cfg.Lg.Error("Dump is not started", "err", err)
+			close(outCh)
+			return outCh, crawl
+		}
+	}
+
+	prs, err := parser.Load(cfg.Parser.Profile)
 	if err != nil {
-		return outCh
+		cfg.Lg.Error("Dump is not started", "err", err)
+		close(outCh)
+		return outCh, crawl
+	}
+	cfg.Lg.Info("Parser profile", "profile", prs.Profile())
+
+	// filled fields are compared with the previous run after the crawl
+	var coverage *parser.Coverage
+	if cfg.Parser.Validate != "" {
+		coverage = parser.NewCoverage(prs.Profile())
+	}
+
+	for i, idr := range cfg.PriorityRazds {
+		// the first razd has the highest priority
+		razds.SetPriority(idr, len(cfg.PriorityRazds)-i)
 	}
 
 	pool := make([]*worker.Worker, cfg.Workers)
 	for i := range cfg.Workers {
 		pool[i] = worker.NewWorker(&cfg.Config, fmt.Sprintf("get-%d", i), cfg.Debug, cfg.Lg)
+		pool[i].Limiters = limiters
+		pool[i].Razds = razds
+		pool[i].Avatars = avatars
+		pool[i].MaxDepth = cfg.Depth
+		pool[i].Cache = cache
+		pool[i].AvatarStore = crawl.avatars
+		pool[i].Parser = prs
+		pool[i].Coverage = coverage
 	}
 
+	progress := NewProgress(cfg.Progress, cfg.Limit, pool, cfg.Lg)
+	ctxProgress, stopProgress := context.WithCancel(context.Background())
+	progressDone := make(chan struct{})
+
+	go func() {
+		defer close(progressDone)
+		progress.Run(ctxProgress)
+	}()
+
 	// start request workers
 	go func() {
 
 		ctxw, cancelw := context.WithCancel(ctx)
+		defer cancelw()
 		eg, ctxEg := errgroup.WithContext(ctxw)
 
-		razdCh := make(chan worker.Task)
-		avatarCh := make(chan worker.Task)
-
 		defer close(outCh)
-		defer close(razdCh)
-		defer close(avatarCh)
+		defer func() {
+			// report the final state
+			stopProgress()
+			<-progressDone
+		}()
 
 		for _, w := range pool {
-			eg.Go(func() error { return w.GetRazd(ctxEg, razdCh, outCh, int32(cfg.Limit), &(DepsCounter), &(SotrCounter)) })
 			eg.Go(func() error {
-				return w.GetAvatar(ctxEg, avatarCh, int32(cfg.Limit), &(DepsCounter), &(SotrCounter), fileCollection)
+				return w.GetRazd(ctxEg, outCh, int32(cfg.Limit), &progress.Deps, &progress.Sotrs)
+			})
+			eg.Go(func() error {
+				return w.GetAvatar(ctxEg, int32(cfg.Limit), &progress.Deps, &progress.Sotrs)
 			})
-
-			// start dispatcher workers for razd and avatar tasks
-			// it should terminate by canceling main context when all workers done and queues for razd&avatar is empty
-			go func() {
-				w.Dispatcher(ctx, w.Name, w.QueueDep, razdCh) //, w.IsData)
-			}()
-
-			go func() {
-				w.Dispatcher(ctx, "avatar", w.QueueAvatar, avatarCh) //, w.IsDataA)
-			}()
 		}
 
-		// Stop workers
+		// Stop workers when the last task is finished
 		go func() {
-			isQueueEmpty := func() (ok bool) {
-				ok = true
-				for _, w := range pool {
-					if w.QueueAvatar.Len() > 0 || w.QueueDep.Len() > 0 {
-						ok = false
-						break
-					}
-				}
-				return
-			}
-			// waiting for worker's retrieve data start
-			time.Sleep(5 * time.Second)
-			timer := time.NewTicker(cfg.HttpReqTimeout)
-
-			for {
-				select {
-				case <-ctx.Done():
-					cfg.Lg.Info("Main context cancel done!")
-					return
-
-				case <-timer.C:
-					if isQueueEmpty() {
-						cfg.Lg.Info("Queues for razd&avatar is empty too long time: Timer cancel")
-						cancelw()
-						return
-					}
-				}
+			select {
+			case <-tasks.Done():
+				cfg.Lg.Info("All tasks of crawl finished")
+				cancelw()
+			case <-ctxEg.Done():
 			}
+
+			// wake up workers waiting for tasks
+			razds.Close()
+			avatars.Close()
 		}()
 
-		// Start root section
-		razdCh <- *worker.NewTask(cfg.RootRazd)
+		// Start root section or branches
+		branches := cfg.Branches
+		if len(branches) == 0 {
+			branches = []string{cfg.RootRazd}
+		}
+		for _, idr := range branches {
+			razds.Push(*worker.NewTask(idr))
+		}
+
+		err := eg.Wait()
 
-		if err := eg.Wait(); err != nil {
-			cfg.Lg.Error("Errgroup failed", "error", err)
-		} else {
+		select {
+		case <-tasks.Done():
 			cfg.Lg.Debug("All workers completed successfully")
+			if coverage != nil {
+				validateCoverage(cfg.Parser.Validate, coverage, cfg.Lg)
+			}
+		default:
+			cfg.Lg.Error("Crawl is not completed", "error", err, "pending_tasks", tasks.Pending())
 		}
-
-		// terminate dispatcher workers
-		cancel()
 	}()
 
-	return outCh
+	return outCh, crawl
+}
+
+// validateCoverage reports fields went empty since the previous run and saves the coverage of this run
+func validateCoverage(path string, coverage *parser.Coverage, lg *slog.Logger) {
+	prev, err := parser.LoadCoverage(path)
+	if err != nil {
+		lg.Error("Parser validation", "err", err)
+		return
+	}
+
+	if empty := coverage.Empty(prev); len(empty) > 0 {
+		lg.Warn("Parser validation: fields went empty since the previous run", "fields", empty,
+			"profile", coverage.Profile, "previous_profile", prev.Profile)
+	} else {
+		lg.Info("Parser validation: no fields went empty", "profile", coverage.Profile, "sotrs", coverage.Sotrs)
+	}
+
+	if err = coverage.Save(path); err != nil {
+		lg.Error("Parser validation", "err", err)
+	}
+}
+
+// openAvatars opens the avatar store and imports avatars downloaded by previous versions
+// as files named by tabnum like "dir/8768768 (2).jpg", the latest version of the avatar is imported.
+func openAvatars(dir string, thumbs avatar.ThumbConfig, lg *slog.Logger) (st *avatar.Store, err error) {
+	if err = thumbs.Validate(); err != nil {
+		return
+	}
+	if st, err = avatar.NewStore(dir, lg); err != nil {
+		return
+	}
+	st.Thumbs = thumbs
+
+	legacy, err := getFileCollection(dir, lg)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := 0
+	for tabnum, info := range legacy {
+		if _, ok := st.Meta(tabnum); ok {
+			continue
+		}
+		if err = st.Import(tabnum, info.Path); err != nil {
+			return nil, err
+		}
+		imported++
+	}
+
+	if imported > 0 {
+		lg.Info("Imported avatars of previous versions", "num", imported, "dir", dir)
+	}
+	return
 }
 
-// Collect avatars that exits for avoid a double downloading
+// Collect avatars that exits for avoid a double downloading.
+// Directories of the avatar store are skipped.
 func getFileCollection(avatarsPath string, lg *slog.Logger) (fColection map[string]worker.AvatarInfo, err error) {
 	var (
 		key, sNum, hash string
@@ -135,6 +280,9 @@ func getFileCollection(avatarsPath string, lg *slog.Logger) (fColection map[stri
 		}
 
 		if info.IsDir() {
+			if path != avatarsPath && avatar.IsStoreDir(info.Name()) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -160,6 +308,7 @@ func getFileCollection(avatarsPath string, lg *slog.Logger) (fColection map[stri
 
 			fColection[key] = worker.AvatarInfo{
 				ActualName: info.Name(),
+				Path:       path,
 				Num:        num,
 				Size:       info.Size(),
 				Hash:       hash,
@@ -1,20 +1,41 @@
 package dump
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/avatar"
+	"github.com/mioxin/kbempgo/internal/coordinator"
+	httpclient "github.com/mioxin/kbempgo/internal/http_client"
+	"github.com/mioxin/kbempgo/internal/parser"
 	"github.com/mioxin/kbempgo/internal/worker"
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var updateFixtures = flag.Bool("update", false, "record fixtures of the dump from the test server")
+
 func TestGetFileCollection(t *testing.T) {
 	fexpected := map[string]worker.AvatarInfo{
-		"54747": {ActualName: "54747.jpg", Num: 1, Size: 29, Hash: "7549da98ec1383ce"},
-		"54755": {ActualName: "54755.jpg", Num: 1, Size: 29, Hash: "001d9c68e09e3b2f"},
-		"54760": {ActualName: "54760 (2).jpg", Num: 2, Size: 33, Hash: "a1b99ab927a22f02"},
-		"54877": {ActualName: "54877 (2).jpg", Num: 2, Size: 33, Hash: "23974fabd80666c1"},
+		"54747": {ActualName: "54747.jpg", Path: "testdata/avatars/54747.jpg", Num: 1, Size: 29, Hash: "7549da98ec1383ce"},
+		"54755": {ActualName: "54755.jpg", Path: "testdata/avatars/54755.jpg", Num: 1, Size: 29, Hash: "001d9c68e09e3b2f"},
+		"54760": {ActualName: "54760 (2).jpg", Path: "testdata/avatars/54760 (2).jpg", Num: 2, Size: 33, Hash: "a1b99ab927a22f02"},
+		"54877": {ActualName: "54877 (2).jpg", Path: "testdata/avatars/54877 (2).jpg", Num: 2, Size: 33, Hash: "23974fabd80666c1"},
 	}
 
 	fc, err := getFileCollection("./testdata/avatars", slog.Default())
@@ -22,3 +43,311 @@ func TestGetFileCollection(t *testing.T) {
 
 	assert.Equal(t, fc, fexpected)
 }
+
+// razdServer serves a tree: root -> razd1 (503 at first, slow) -> sotr1, root -> razd2 (empty at first) -> razd3 -> sotr2
+// and data of employees: middle names, mobiles and avatars
+func razdServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var razd1Calls, razd2Calls atomic.Int32
+	sotr := func(idr, parent, tabnum string) string {
+		text := fmt.Sprintf(`<tr data-tabnum="%s"><img src="/avatar/%s.jpg"><td width="300" class="s_1">Сотрудник %s<span`, tabnum, tabnum, tabnum)
+		b, _ := json.Marshal(map[string]any{"id": idr, "parent": parent, "text": text, "children": false})
+		return string(b)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/razd/", func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/razd/") {
+		case "root":
+			fmt.Fprint(w, `[{"id":"razd1","parent":"root","text":"Отдел 1","children":true},{"id":"razd2","parent":"root","text":"Отдел 2","children":true}]`)
+		case "razd1":
+			// retried by the client
+			if razd1Calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			// in flight longer than polling of queues
+			time.Sleep(300 * time.Millisecond)
+			fmt.Fprintf(w, "[%s]", sotr("sotr1", "razd1", "1"))
+		case "razd2":
+			// requeued with backoff
+			if razd2Calls.Add(1) == 1 {
+				fmt.Fprint(w, `[]`)
+				return
+			}
+			fmt.Fprint(w, `[{"id":"razd3","parent":"razd2","text":"Отдел 3","children":true}]`)
+		case "razd3":
+			fmt.Fprintf(w, "[%s]", sotr("sotr2", "razd3", "2"))
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	})
+	mux.HandleFunc("/fio/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/fio/")
+		tabnum := strings.TrimPrefix(name, "Сотрудник ")
+		fmt.Fprintf(w, `<div class=sotr_td2><img alt="" src="/avatar/%s.jpg"><a onclick="searchG('%s Иванович', '%s')">`, tabnum, name, tabnum)
+	})
+	mux.HandleFunc("/sotr/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"success":true,"user":{"mobile":"+7 (777) 123-45-67"}}`)
+	})
+	mux.HandleFunc("/mobile/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":"+7 (777) 123-45-%s","success":true}`, strings.TrimPrefix(r.URL.Path, "/mobile/"))
+	})
+	mux.HandleFunc("/avatar/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte{0xff, 0xd8, 0xff, 0xe0})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStartDumpCompletion(t *testing.T) {
+	srv := razdServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	itemsCh, crawl := StartDump(ctx, &Config{
+		Config: worker.Config{
+			KbUrl:          srv.URL,
+			UrlRazd:        "/razd/",
+			UrlSotr:        "/sotr/",
+			UrlFio:         "/fio/",
+			UrlMobile:      "/mobile/",
+			Avatars:        t.TempDir(),
+			HttpReqTimeout: 5 * time.Second,
+		},
+		Workers:  2,
+		RootRazd: "root",
+		Lg:       slog.Default(),
+	})
+
+	idrs := []string{}
+	for item := range itemsCh {
+		switch it := item.(type) {
+		case *kbv1.Dep:
+			idrs = append(idrs, it.Idr)
+		case *kbv1.Sotr:
+			idrs = append(idrs, it.Idr)
+		}
+	}
+
+	require.NoError(t, ctx.Err(), "output should be closed on completion, not by timeout")
+	assert.ElementsMatch(t, []string{"razd1", "razd2", "razd3", "sotr1", "sotr2"}, idrs)
+	// root, razd2 backoff 1s, razd3
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	assert.True(t, crawl.Completed())
+	assert.ElementsMatch(t, []string{"root", "razd1", "razd2", "razd3"}, crawl.Razds())
+}
+
+func TestStartDumpBranches(t *testing.T) {
+	srv := razdServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	itemsCh, crawl := StartDump(ctx, &Config{
+		Config: worker.Config{
+			KbUrl:          srv.URL,
+			UrlRazd:        "/razd/",
+			UrlSotr:        "/sotr/",
+			UrlFio:         "/fio/",
+			UrlMobile:      "/mobile/",
+			Avatars:        t.TempDir(),
+			HttpReqTimeout: 5 * time.Second,
+		},
+		Workers:  2,
+		RootRazd: "root",
+		Branches: []string{"razd1", "razd2"},
+		Depth:    1,
+		Lg:       slog.Default(),
+	})
+
+	idrs := []string{}
+	for item := range itemsCh {
+		switch it := item.(type) {
+		case *kbv1.Dep:
+			idrs = append(idrs, it.Idr)
+		case *kbv1.Sotr:
+			idrs = append(idrs, it.Idr)
+		}
+	}
+
+	// razd3 is deeper than the limit
+	assert.ElementsMatch(t, []string{"razd3", "sotr1"}, idrs)
+	assert.True(t, crawl.Completed())
+	assert.ElementsMatch(t, []string{"razd1", "razd2"}, crawl.Razds())
+}
+
+func TestStartDumpShared(t *testing.T) {
+	srv := razdServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	// processes of the crawl share the frontier, every item is got by one of them
+	var (
+		mt   sync.Mutex
+		idrs []string
+		wg   sync.WaitGroup
+	)
+	for i := range 2 {
+		coord := coordinator.New(rdb, coordinator.Config{Crawl: "test", Lease: time.Minute, Poll: 10 * time.Millisecond}, slog.Default())
+		require.NoError(t, coord.Start(ctx))
+		defer coord.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			itemsCh, crawl := StartDump(ctx, &Config{
+				Config: worker.Config{
+					KbUrl:          srv.URL,
+					UrlRazd:        "/razd/",
+					UrlSotr:        "/sotr/",
+					UrlFio:         "/fio/",
+					UrlMobile:      "/mobile/",
+					Avatars:        t.TempDir(),
+					HttpReqTimeout: 5 * time.Second,
+				},
+				Workers:  1,
+				RootRazd: "root",
+				Frontier: coord,
+				Lg:       slog.Default().With("process", i),
+			})
+			for item := range itemsCh {
+				mt.Lock()
+				switch it := item.(type) {
+				case *kbv1.Dep:
+					idrs = append(idrs, it.Idr)
+				case *kbv1.Sotr:
+					idrs = append(idrs, it.Idr)
+				}
+				mt.Unlock()
+			}
+			assert.True(t, crawl.Completed())
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, ctx.Err(), "output should be closed on completion, not by timeout")
+	assert.ElementsMatch(t, []string{"razd1", "razd2", "razd3", "sotr1", "sotr2"}, idrs)
+}
+
+func TestStartDumpReplay(t *testing.T) {
+	const dir = "testdata/fixtures"
+
+	conf := worker.Config{
+		UrlRazd:        "/razd/",
+		UrlSotr:        "/sotr/",
+		UrlFio:         "/fio/",
+		UrlMobile:      "/mobile/",
+		HttpReqTimeout: 5 * time.Second,
+	}
+
+	if *updateFixtures {
+		require.NoError(t, os.RemoveAll(dir))
+
+		rec := conf
+		rec.KbUrl = razdServer(t).URL
+		rec.Fixture = httpclient.FixtureConfig{Mode: httpclient.FixtureRecord, Dir: dir}
+		dumpSotrs(t, rec)
+	}
+
+	srv, err := httpclient.NewFixtureServer(dir)
+	require.NoError(t, err)
+	defer srv.Close()
+
+	server := conf
+	server.KbUrl = srv.URL
+
+	// the client replays fixtures without the server
+	client := conf
+	client.KbUrl = "http://kb.invalid"
+	client.Fixture = httpclient.FixtureConfig{Mode: httpclient.FixtureReplay, Dir: dir}
+
+	for name, c := range map[string]worker.Config{"server": server, "client": client} {
+		t.Run(name, func(t *testing.T) {
+			sotrs := dumpSotrs(t, c)
+
+			require.Len(t, sotrs, 2)
+			assert.Equal(t, "Иванович", sotrs["1"].MidName)
+			assert.Equal(t, []string{"+7 (777) 123-45-1"}, sotrs["1"].Mobile)
+			assert.Equal(t, "razd3", sotrs["2"].ParentId)
+		})
+	}
+}
+
+// dumpSotrs runs the dump to the end and returns employees by tabnum. Avatars of employees should be downloaded.
+func dumpSotrs(t *testing.T, conf worker.Config) map[string]*kbv1.Sotr {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	conf.Avatars = t.TempDir()
+	itemsCh, crawl := StartDump(ctx, &Config{
+		Config:   conf,
+		Workers:  2,
+		RootRazd: "root",
+		Lg:       slog.Default(),
+	})
+
+	sotrs := map[string]*kbv1.Sotr{}
+	for item := range itemsCh {
+		if s, ok := item.(*kbv1.Sotr); ok {
+			sotrs[s.Tabnum] = s
+		}
+	}
+	require.True(t, crawl.Completed())
+
+	avatars, err := avatar.NewStore(conf.Avatars, slog.Default())
+	require.NoError(t, err)
+
+	seen := map[string]*kbv1.Avatar{}
+	for _, a := range crawl.Avatars() {
+		seen[a.Tabnum] = a
+	}
+	for tabnum := range sotrs {
+		require.Contains(t, seen, tabnum)
+		assert.FileExists(t, avatars.Path(seen[tabnum].Hash))
+	}
+	return sotrs
+}
+
+func TestStartDumpParserValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coverage.json")
+
+	srv, err := httpclient.NewFixtureServer("testdata/fixtures")
+	require.NoError(t, err)
+	defer srv.Close()
+
+	dumpSotrs(t, worker.Config{
+		KbUrl:          srv.URL,
+		UrlRazd:        "/razd/",
+		UrlSotr:        "/sotr/",
+		UrlFio:         "/fio/",
+		UrlMobile:      "/mobile/",
+		HttpReqTimeout: 5 * time.Second,
+		Parser:         parser.Config{Profile: "index", Validate: path},
+	})
+
+	coverage, err := parser.LoadCoverage(path)
+	require.NoError(t, err)
+	require.NotNil(t, coverage, "coverage should be saved for the next run")
+	assert.Equal(t, "index/v1", coverage.Profile)
+	assert.Equal(t, 2, coverage.Sotrs)
+	assert.Equal(t, 2, coverage.Filled["mid_name"])
+	assert.Zero(t, coverage.Filled["grade"])
+}
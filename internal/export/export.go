@@ -0,0 +1,236 @@
+// Package export writes employees of the directory to CSV, XLSX, JSON, NDJSON, vCard and LDIF.
+// The tree of deps is walked from branches, employees are written dep by dep, so the whole
+// directory is never kept in memory.
+package export
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+)
+
+// Columns of the export
+const (
+	ColTabnum = "tabnum"
+	ColFio    = "fio"
+	ColPath   = "path"
+	ColPhone  = "phone"
+	ColMobile = "mobile"
+	ColEmail  = "email"
+	ColGrade  = "grade"
+)
+
+var columns = []string{ColTabnum, ColFio, ColPath, ColPhone, ColMobile, ColEmail, ColGrade}
+
+// DefaultColumns are exported if columns are not set
+var DefaultColumns = []string{ColFio, ColPath, ColPhone, ColMobile, ColEmail, ColGrade}
+
+// PathSep separates names of deps in the path of the employee
+const PathSep = " / "
+
+// Config of the export
+type Config struct {
+	Format  string   `name:"format" short:"f" default:"csv" enum:"csv,xlsx,json,ndjson,vcard,ldif" help:"Format of the export: csv, xlsx, json, ndjson, vcard, ldif"`
+	Columns []string `name:"columns" default:"fio,path,phone,mobile,email,grade" help:"Columns of the export: tabnum, fio, path, phone, mobile, email, grade"`
+	Zip     bool     `name:"vcard-zip" help:"vCards of every dep are the separate file of the zip archive"`
+	BaseDN  string   `name:"ldif-base-dn" default:"dc=kbemp" help:"Base DN of LDIF entries, deps are organizational units under it"`
+	Photo   bool     `name:"photo" negatable:"" default:"true" help:"Embed avatars to vCard and LDIF"`
+
+	// Photos are avatars embedded to vCard and LDIF
+	Photos Photos `kong:"-"`
+}
+
+func (c *Config) photos() Photos {
+	if !c.Photo {
+		return nil
+	}
+	return c.Photos
+}
+
+// Validate checks the format and columns
+func (c *Config) Validate() error {
+	if _, ok := formats[c.Format]; !ok {
+		return fmt.Errorf("unknown format of export %q", c.Format)
+	}
+	if c.Zip && c.Format != FormatVCard {
+		return fmt.Errorf("zip archive is made of vCards only")
+	}
+	if c.Format == FormatLDIF && c.BaseDN == "" {
+		return fmt.Errorf("base DN of LDIF is empty")
+	}
+	if c.Format == FormatVCard || c.Format == FormatLDIF {
+		return nil
+	}
+	if len(c.Columns) == 0 {
+		return fmt.Errorf("columns of export are empty")
+	}
+	for _, col := range c.Columns {
+		if !slices.Contains(columns, col) {
+			return fmt.Errorf("unknown column of export %q", col)
+		}
+	}
+	return nil
+}
+
+// Source is the part of the storage read by the export, it's implemented by storage.Store
+type Source interface {
+	GetDepsBy(context.Context, *kbv1.DepRequest) ([]*kbv1.Dep, error)
+	GetSotrsBy(context.Context, *kbv1.SotrRequest) ([]*kbv1.Sotr, error)
+}
+
+// Row is the exported employee
+type Row struct {
+	Sotr *kbv1.Sotr
+	// idr of the dep of the employee
+	Dep string
+	// names of deps from the top one to the dep of the employee
+	Path []string
+}
+
+// Value returns the value of the column, lists are joined by ", "
+func (r *Row) Value(col string) string {
+	switch col {
+	case ColTabnum:
+		return r.Sotr.Tabnum
+	case ColFio:
+		return Fio(r.Sotr)
+	case ColPath:
+		return strings.Join(r.Path, PathSep)
+	case ColPhone:
+		return strings.Join(r.Sotr.Phone, ", ")
+	case ColMobile:
+		return strings.Join(r.Sotr.Mobile, ", ")
+	case ColEmail:
+		return r.Sotr.Email
+	case ColGrade:
+		return r.Sotr.Grade
+	}
+	return ""
+}
+
+// Fio returns the full name of the employee with the mid name
+func Fio(s *kbv1.Sotr) string {
+	return strings.TrimSpace(s.Name + " " + s.MidName)
+}
+
+// Export writes employees of branches with their subtrees, employees of the dep are followed by
+// employees of its children ordered by names. The dep included in several branches is written once.
+// It returns the number of written employees.
+func Export(ctx context.Context, src Source, branches []string, w Writer) (int, error) {
+	e := &exporter{src: src, w: w, visited: make(map[string]struct{})}
+
+	for _, idr := range branches {
+		path, err := e.path(ctx, idr)
+		if err != nil {
+			return e.n, err
+		}
+		if err = e.dep(ctx, idr, path); err != nil {
+			return e.n, err
+		}
+	}
+	return e.n, nil
+}
+
+// ExportSotrs writes employees by tabnums with paths of their deps.
+// It returns the number of written employees, unknown tabnums are skipped.
+func ExportSotrs(ctx context.Context, src Source, tabnums []string, w Writer) (int, error) {
+	e := &exporter{src: src, w: w}
+	paths := map[string][]string{}
+
+	for _, tabnum := range tabnums {
+		sotrs, err := src.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: tabnum})
+		if err != nil {
+			return e.n, fmt.Errorf("get employee %s: %w", tabnum, err)
+		}
+
+		for _, s := range sotrs {
+			path, ok := paths[s.ParentId]
+			if !ok {
+				if path, err = e.path(ctx, s.ParentId); err != nil {
+					return e.n, err
+				}
+				paths[s.ParentId] = path
+			}
+
+			if err = w.Write(&Row{Sotr: s, Dep: s.ParentId, Path: path}); err != nil {
+				return e.n, fmt.Errorf("write employee %s: %w", s.Tabnum, err)
+			}
+			e.n++
+		}
+	}
+	return e.n, nil
+}
+
+type exporter struct {
+	src     Source
+	w       Writer
+	visited map[string]struct{}
+	n       int
+}
+
+// path returns names of the dep and its ancestors, the root section is absent in the storage
+func (e *exporter) path(ctx context.Context, idr string) ([]string, error) {
+	var path []string
+	seen := map[string]struct{}{}
+
+	for idr != "" {
+		if _, ok := seen[idr]; ok {
+			return nil, fmt.Errorf("cycle of deps at %s", idr)
+		}
+		seen[idr] = struct{}{}
+
+		deps, err := e.src.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_IDR, Str: idr})
+		if err != nil {
+			return nil, fmt.Errorf("get dep %s: %w", idr, err)
+		}
+		if len(deps) == 0 {
+			break
+		}
+		path = append(path, deps[0].Text)
+		idr = deps[0].Parent
+	}
+
+	slices.Reverse(path)
+	return path, nil
+}
+
+// dep writes employees of the dep and its subtree
+func (e *exporter) dep(ctx context.Context, idr string, path []string) error {
+	if _, ok := e.visited[idr]; ok {
+		return nil
+	}
+	e.visited[idr] = struct{}{}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sotrs, err := e.src.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_PARENT, Str: idr})
+	if err != nil {
+		return fmt.Errorf("get employees of dep %s: %w", idr, err)
+	}
+	slices.SortFunc(sotrs, func(a, b *kbv1.Sotr) int { return strings.Compare(Fio(a), Fio(b)) })
+
+	for _, s := range sotrs {
+		if err = e.w.Write(&Row{Sotr: s, Dep: idr, Path: path}); err != nil {
+			return fmt.Errorf("write employee %s: %w", s.Tabnum, err)
+		}
+		e.n++
+	}
+
+	deps, err := e.src.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_PARENT, Str: idr})
+	if err != nil {
+		return fmt.Errorf("get children of dep %s: %w", idr, err)
+	}
+	slices.SortFunc(deps, func(a, b *kbv1.Dep) int { return strings.Compare(a.Text, b.Text) })
+
+	for _, d := range deps {
+		if err = e.dep(ctx, d.Idr, append(slices.Clip(path), d.Text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
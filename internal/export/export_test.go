@@ -0,0 +1,357 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/avatar"
+	"github.com/mioxin/kbempgo/internal/storage/mem"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func newStore(t *testing.T) *mem.MemStore {
+	t.Helper()
+
+	store, err := mem.New("", slog.Default())
+	require.NoError(t, err)
+
+	deps := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(t, "dep.json", deps)
+	for _, d := range deps.Deps {
+		_, err = store.Save(context.Background(), d)
+		require.NoError(t, err)
+	}
+	// the mem store is flushed after deps and after employees
+	_, err = store.Flush(context.Background(), nil)
+	require.NoError(t, err)
+
+	sotrs := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(t, "sotr.json", sotrs)
+	for _, s := range sotrs.Sotrs {
+		_, err = store.Save(context.Background(), s)
+		require.NoError(t, err)
+	}
+	_, err = store.Flush(context.Background(), nil)
+	require.NoError(t, err)
+
+	return store
+}
+
+func export(t *testing.T, cfg *Config, branches ...string) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, cfg)
+	require.NoError(t, err)
+
+	_, err = Export(context.Background(), newStore(t), branches, w)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestExportCSV(t *testing.T) {
+	b := export(t, &Config{Format: FormatCSV, Columns: []string{ColTabnum, ColFio, ColPath, ColMobile}}, "razd1.27.2935.37")
+
+	require.True(t, bytes.HasPrefix(b, []byte("\ufeff")))
+	records, err := csv.NewReader(bytes.NewReader(b[3:])).ReadAll()
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"tabnum", "fio", "path", "mobile"},
+		{"63665", "Руда4444 Маргарита Владимировна", "Департамент финансовых институтов / Управление финансовых институтов", ""},
+		{"60609", "Са44444 Асемгуль Абатовна", "Департамент финансовых институтов / Управление финансовых институтов / Отдел корреспондентских отношений", "+7 (701) 0006700, +7 (701) 0006701"},
+		{"52957", "Та4444 Сабина", "Департамент финансовых институтов / Управление финансовых институтов / Отдел корреспондентских отношений", ""},
+	}, records)
+}
+
+func TestExportOrder(t *testing.T) {
+	b := export(t, &Config{Format: FormatNDJSON, Columns: []string{ColTabnum}}, "razd1.27")
+
+	var tabnums []string
+	for line := range strings.Lines(string(b)) {
+		row := map[string]string{}
+		require.NoError(t, json.Unmarshal([]byte(line), &row))
+		tabnums = append(tabnums, row[ColTabnum])
+	}
+	// employees of the dep are followed by subtrees of children ordered by names
+	assert.Equal(t, []string{"1600", "25301", "2681", "1122", "63665", "60609", "52957"}, tabnums)
+}
+
+func TestExportBranchesOnce(t *testing.T) {
+	b := export(t, &Config{Format: FormatNDJSON, Columns: []string{ColTabnum}},
+		"razd1.27.2935.37.70", "razd1.27.2935.37", "razd1.27.2935.69")
+
+	assert.Equal(t, 5, strings.Count(string(b), "\n"))
+}
+
+func TestExportJSON(t *testing.T) {
+	b := export(t, &Config{Format: FormatJSON, Columns: []string{ColFio, ColPhone, ColMobile, ColEmail, ColGrade}}, "razd1.27.2935.69")
+
+	var rows []map[string]any
+	require.NoError(t, json.Unmarshal(b, &rows))
+	require.Len(t, rows, 2)
+	assert.Equal(t, map[string]any{
+		"fio":    "Пал4444 Юлия Викторовна",
+		"phone":  []any{"400-16-32"},
+		"mobile": []any{},
+		"email":  "Yuliya@k.kom",
+		"grade":  "Начальник Отдела",
+	}, rows[1])
+
+	// the export without employees is the empty array
+	b = export(t, &Config{Format: FormatJSON, Columns: DefaultColumns}, "razd0")
+	require.NoError(t, json.Unmarshal(b, &rows))
+	assert.Empty(t, rows)
+}
+
+func TestExportXLSX(t *testing.T) {
+	b := export(t, &Config{Format: FormatXLSX, Columns: []string{ColTabnum, ColGrade}}, "razd1.27.2935.69")
+
+	f, err := excelize.OpenReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := f.GetRows(xlsxSheet)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"tabnum", "grade"},
+		{"25301", "Главный Специалист"},
+		{"2681", "Начальник Отдела"},
+	}, rows)
+}
+
+func TestConfigValidate(t *testing.T) {
+	for name, cfg := range map[string]*Config{
+		"format":        {Format: "xml", Columns: DefaultColumns},
+		"zip":           {Format: FormatCSV, Columns: DefaultColumns, Zip: true},
+		"base dn":       {Format: FormatLDIF, Columns: DefaultColumns},
+		"column":        {Format: FormatCSV, Columns: []string{ColFio, "salary"}},
+		"empty columns": {Format: FormatCSV},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewWriter(&bytes.Buffer{}, cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// photos is the avatar of 60609
+type photos struct{}
+
+func (photos) Photo(tabnum string) ([]byte, string, error) {
+	if tabnum != "60609" {
+		return nil, "", nil
+	}
+	return bytes.Repeat([]byte{0xff, 0xd8}, 40), "image/jpeg", nil
+}
+
+// unfold joins folded lines of vCard and LDIF
+func unfold(b []byte) string {
+	s := strings.ReplaceAll(string(b), "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n ", "")
+}
+
+func TestExportVCard(t *testing.T) {
+	b := export(t, &Config{Format: FormatVCard, Photo: true, Photos: photos{}}, "razd1.27.2935.37.70")
+
+	for line := range strings.Lines(string(b)) {
+		assert.True(t, strings.HasSuffix(line, "\r\n"), "line %q is ended by CRLF", line)
+		assert.LessOrEqual(t, len(line), vcardLineLen+2, "line %q is folded", line)
+	}
+
+	cards := strings.SplitAfter(unfold(b), "END:VCARD\n")
+	require.Len(t, cards, 3)
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"UID:urn:kbemp:tabnum:60609",
+		"FN:Са44444 Асемгуль Абатовна",
+		"N:Са44444;Асемгуль;Абатовна;;",
+		"ORG:Департамент финансовых институтов;Управление финансовых институтов;Отдел корреспондентских отношений",
+		"TITLE:Главный Специалист",
+		"TEL;TYPE=cell:+7 (701) 0006700",
+		"TEL;TYPE=cell:+7 (701) 0006701",
+		"EMAIL;TYPE=work:Assemgul@k.kom",
+		"PHOTO:data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xff, 0xd8}, 40)),
+		"END:VCARD",
+	}, "\n")+"\n", cards[0])
+	assert.NotContains(t, cards[1], "PHOTO")
+
+	// avatars are not embedded without photo
+	b = export(t, &Config{Format: FormatVCard, Photos: photos{}}, "razd1.27.2935.37.70")
+	assert.NotContains(t, string(b), "PHOTO")
+}
+
+func TestVCardEscape(t *testing.T) {
+	assert.Equal(t, `Отдел\, сектор\; группа \\ 1\n2`, vcardEscape("Отдел, сектор; группа \\ 1\n2"))
+}
+
+func TestWriteVCard3(t *testing.T) {
+	var b bytes.Buffer
+	row := &Row{Sotr: &kbv1.Sotr{Tabnum: "60609", Name: "Са44444 Асемгуль", Phone: []string{"1234"}}, Path: []string{"Отдел"}}
+	require.NoError(t, WriteVCard(&b, row, photos{}, VCard3))
+
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"UID:urn:kbemp:tabnum:60609",
+		"FN:Са44444 Асемгуль",
+		"N:Са44444;Асемгуль;;;",
+		"ORG:Отдел",
+		"TEL;TYPE=work,voice:1234",
+		"PHOTO;ENCODING=b;TYPE=JPEG:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xff, 0xd8}, 40)),
+		"END:VCARD",
+	}, "\n")+"\n", unfold(b.Bytes()))
+}
+
+func TestExportVCardZip(t *testing.T) {
+	b := export(t, &Config{Format: FormatVCard, Zip: true}, "razd1.27.2935")
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+
+	cards := map[string]int{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		cards[f.Name] = strings.Count(string(data), "BEGIN:VCARD")
+	}
+	assert.Equal(t, map[string]int{
+		"Департамент финансовых институтов.vcf":                                                                    1,
+		"Департамент финансовых институтов/Отдел экспортно-импортных операций.vcf":                                 2,
+		"Департамент финансовых институтов/Управление по Работе с Рынками Капитала.vcf":                            1,
+		"Департамент финансовых институтов/Управление финансовых институтов.vcf":                                   1,
+		"Департамент финансовых институтов/Управление финансовых институтов/Отдел корреспондентских отношений.vcf": 2,
+	}, cards)
+}
+
+func TestExportLDIF(t *testing.T) {
+	b := export(t, &Config{Format: FormatLDIF, BaseDN: "dc=example,dc=com", Photo: true, Photos: photos{}}, "razd1.27.2935.37")
+
+	for line := range strings.Lines(string(b)) {
+		assert.LessOrEqual(t, len(line), ldifLineLen+1, "line %q is folded", line)
+	}
+
+	entries := strings.Split(strings.TrimSuffix(unfold(b), "\n"), "\n\n")
+	require.Len(t, entries, 7)
+	assert.Equal(t, "version: 1", entries[0])
+
+	// attributes of entries with decoded values
+	attrs := make([]map[string][]string, len(entries)-1)
+	for i, e := range entries[1:] {
+		attrs[i] = map[string][]string{}
+		for line := range strings.Lines(e) {
+			name, value, _ := strings.Cut(strings.TrimSuffix(line, "\n"), ": ")
+			if n, ok := strings.CutSuffix(name, ":"); ok {
+				d, err := base64.StdEncoding.DecodeString(value)
+				require.NoError(t, err)
+				name, value = n, string(d)
+			}
+			attrs[i][name] = append(attrs[i][name], value)
+		}
+	}
+
+	// units are written before the first employee of the dep
+	var dns []string
+	for _, a := range attrs {
+		dns = append(dns, a["dn"][0])
+	}
+	assert.Equal(t, []string{
+		"ou=Департамент финансовых институтов,dc=example,dc=com",
+		"ou=Управление финансовых институтов,ou=Департамент финансовых институтов,dc=example,dc=com",
+		"uid=63665,ou=Управление финансовых институтов,ou=Департамент финансовых институтов,dc=example,dc=com",
+		"ou=Отдел корреспондентских отношений,ou=Управление финансовых институтов,ou=Департамент финансовых институтов,dc=example,dc=com",
+		"uid=60609,ou=Отдел корреспондентских отношений,ou=Управление финансовых институтов,ou=Департамент финансовых институтов,dc=example,dc=com",
+	}, dns[:5])
+
+	assert.Equal(t, map[string][]string{
+		"dn":             {dns[4]},
+		"objectClass":    {"top", "person", "organizationalPerson", "inetOrgPerson"},
+		"uid":            {"60609"},
+		"employeeNumber": {"60609"},
+		"cn":             {"Са44444 Асемгуль Абатовна"},
+		"sn":             {"Са44444"},
+		"givenName":      {"Асемгуль Абатовна"},
+		"displayName":    {"Са44444 Асемгуль Абатовна"},
+		"title":          {"Главный Специалист"},
+		"ou":             {"Отдел корреспондентских отношений"},
+		"mobile":         {"+7 (701) 0006700", "+7 (701) 0006701"},
+		"mail":           {"Assemgul@k.kom"},
+		"jpegPhoto":      {string(bytes.Repeat([]byte{0xff, 0xd8}, 40))},
+	}, attrs[4])
+}
+
+func TestEscapeRDN(t *testing.T) {
+	assert.Equal(t, `Отдел\, сектор \+ группа`, escapeRDN("Отдел, сектор + группа"))
+	assert.Equal(t, `\#1\ `, escapeRDN("#1 "))
+}
+
+func TestExportSotrs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, &Config{Format: FormatCSV, Columns: []string{ColTabnum, ColPath}})
+	require.NoError(t, err)
+
+	n, err := ExportSotrs(context.Background(), newStore(t), []string{"2681", "0", "1600"}, w)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, 2, n)
+
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes()[3:])).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"tabnum", "path"},
+		{"2681", "Департамент финансовых институтов / Отдел экспортно-импортных операций"},
+		{"1600", "Департамент финансовых институтов"},
+	}, records)
+}
+
+func TestAvatarPhotos(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	f, err := os.Create(filepath.Join(dir, "1600.jpg"))
+	require.NoError(t, err)
+	require.NoError(t, jpeg.Encode(f, img, nil))
+	require.NoError(t, f.Close())
+
+	s, err := avatar.NewStore(filepath.Join(dir, "store"), slog.Default())
+	require.NoError(t, err)
+	s.Thumbs = avatar.ThumbConfig{Sizes: []int{48}, Formats: []string{avatar.FormatJPEG}, Quality: 85}
+	require.NoError(t, s.Import("1600", filepath.Join(dir, "1600.jpg")))
+
+	// the missing thumbnail is made
+	data, contentType, err := AvatarPhotos(s, 48).Photo("1600")
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", contentType)
+	thumb, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 48, thumb.Bounds().Dx())
+
+	data, _, err = AvatarPhotos(s, 0).Photo("1600")
+	require.NoError(t, err)
+	orig, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 200, orig.Bounds().Dx())
+
+	data, _, err = AvatarPhotos(s, 48).Photo("2681")
+	require.NoError(t, err)
+	assert.Nil(t, data, "employee without avatar")
+}
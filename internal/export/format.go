@@ -0,0 +1,246 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats of the export
+const (
+	FormatCSV    = "csv"
+	FormatXLSX   = "xlsx"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatVCard  = "vcard"
+	FormatLDIF   = "ldif"
+)
+
+type format struct {
+	contentType string
+	ext         string
+}
+
+var formats = map[string]format{
+	FormatCSV:    {"text/csv; charset=utf-8", "csv"},
+	FormatXLSX:   {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx"},
+	FormatJSON:   {"application/json", "json"},
+	FormatNDJSON: {"application/x-ndjson", "ndjson"},
+	FormatVCard:  {"text/vcard; charset=utf-8", "vcf"},
+	FormatLDIF:   {"text/x-ldif", "ldif"},
+}
+
+// ContentType returns the MIME type of the export
+func (c *Config) ContentType() string {
+	if c.Zip {
+		return "application/zip"
+	}
+	return formats[c.Format].contentType
+}
+
+// Ext returns the extension of the file of the export
+func (c *Config) Ext() string {
+	if c.Zip {
+		return "zip"
+	}
+	return formats[c.Format].ext
+}
+
+// Writer writes rows of the export. Close completes the document, it doesn't close the underlying writer.
+type Writer interface {
+	Write(r *Row) error
+	Close() error
+}
+
+// NewWriter creates the writer of the format with columns, the header is written by the first row or by Close.
+// Columns are not used by vCard and LDIF.
+func NewWriter(w io.Writer, cfg *Config) (Writer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Format {
+	case FormatCSV:
+		return &csvWriter{w: w, csv: csv.NewWriter(w), cols: cfg.Columns}, nil
+	case FormatXLSX:
+		return newXlsxWriter(w, cfg.Columns)
+	case FormatJSON:
+		return &jsonWriter{w: bufio.NewWriter(w), cols: cfg.Columns, array: true}, nil
+	case FormatNDJSON:
+		return &jsonWriter{w: bufio.NewWriter(w), cols: cfg.Columns}, nil
+	case FormatVCard:
+		if cfg.Zip {
+			return &vcardZipWriter{zip: zip.NewWriter(w), photos: cfg.photos(), names: make(map[string]struct{})}, nil
+		}
+		return &vcardWriter{w: bufio.NewWriter(w), photos: cfg.photos()}, nil
+	case FormatLDIF:
+		return &ldifWriter{w: bufio.NewWriter(w), photos: cfg.photos(), baseDN: cfg.BaseDN, ous: make(map[string]struct{})}, nil
+	}
+	return nil, fmt.Errorf("unknown format of export %q", cfg.Format)
+}
+
+type csvWriter struct {
+	w      io.Writer
+	csv    *csv.Writer
+	cols   []string
+	header bool
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+
+	// BOM lets spreadsheets detect UTF-8 of cyrillic names
+	if _, err := io.WriteString(c.w, "\ufeff"); err != nil {
+		return err
+	}
+	return c.csv.Write(c.cols)
+}
+
+func (c *csvWriter) Write(r *Row) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	rec := make([]string, len(c.cols))
+	for i, col := range c.cols {
+		rec[i] = r.Value(col)
+	}
+	return c.csv.Write(rec)
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+// jsonWriter writes objects of rows with keys of columns as the array or as lines of NDJSON.
+// Phones and mobiles are arrays.
+type jsonWriter struct {
+	w     *bufio.Writer
+	cols  []string
+	array bool
+	n     int
+}
+
+func (j *jsonWriter) Write(r *Row) error {
+	switch {
+	case !j.array:
+	case j.n == 0:
+		j.w.WriteString("[\n")
+	default:
+		j.w.WriteString(",\n")
+	}
+	j.n++
+
+	j.w.WriteByte('{')
+	for i, col := range j.cols {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		var v any = r.Value(col)
+		switch col {
+		case ColPhone:
+			v = nonNil(r.Sotr.Phone)
+		case ColMobile:
+			v = nonNil(r.Sotr.Mobile)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(j.w, "%q:%s", col, b)
+	}
+	j.w.WriteByte('}')
+
+	if !j.array {
+		j.w.WriteByte('\n')
+	}
+	return nil
+}
+
+func (j *jsonWriter) Close() error {
+	if j.array {
+		if j.n == 0 {
+			j.w.WriteString("[")
+		}
+		j.w.WriteString("\n]\n")
+	}
+	return j.w.Flush()
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// xlsxWriter writes rows by the stream writer of excelize, rows above its memory limit are kept
+// in a temporary file until the document is written by Close
+type xlsxWriter struct {
+	w    io.Writer
+	f    *excelize.File
+	sw   *excelize.StreamWriter
+	cols []string
+	n    int
+}
+
+const xlsxSheet = "Employees"
+
+func newXlsxWriter(w io.Writer, cols []string) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return nil, err
+	}
+	sw, err := f.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &xlsxWriter{w: w, f: f, sw: sw, cols: cols}
+	header := make([]any, len(cols))
+	for i, col := range cols {
+		header[i] = col
+	}
+	if err = x.row(header); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) row(values []any) error {
+	x.n++
+	cell, err := excelize.CoordinatesToCellName(1, x.n)
+	if err != nil {
+		return err
+	}
+	return x.sw.SetRow(cell, values)
+}
+
+func (x *xlsxWriter) Write(r *Row) error {
+	values := make([]any, len(x.cols))
+	for i, col := range x.cols {
+		values[i] = r.Value(col)
+	}
+	return x.row(values)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.f.Close()
+
+	if err := x.sw.Flush(); err != nil {
+		return err
+	}
+	return x.f.Write(x.w)
+}
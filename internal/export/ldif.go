@@ -0,0 +1,180 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"slices"
+	"strings"
+)
+
+// LDIF (RFC 2849) of inetOrgPerson entries. Deps are organizationalUnit entries written before
+// the first employee of the dep, so entries are imported in order.
+
+// ldifLineLen is the max length of the LDIF line, longer lines are folded
+const ldifLineLen = 76
+
+type ldifWriter struct {
+	w      *bufio.Writer
+	photos Photos
+	baseDN string
+
+	version bool
+	// DNs of written units
+	ous map[string]struct{}
+}
+
+func (l *ldifWriter) Write(r *Row) error {
+	if !l.version {
+		l.version = true
+		l.w.WriteString("version: 1\n")
+	}
+
+	// units from the top dep, DN of the unit is under DN of its parent
+	for i, name := range r.Path {
+		dn := UnitDN(r.Path[:i+1], l.baseDN)
+		if _, ok := l.ous[dn]; ok {
+			continue
+		}
+		l.ous[dn] = struct{}{}
+
+		l.w.WriteString("\n")
+		l.attr("dn", dn)
+		for _, a := range UnitAttrs(name) {
+			l.attr(a.Name, a.Value)
+		}
+	}
+
+	l.w.WriteString("\n")
+	l.attr("dn", PersonDN(r, l.baseDN))
+	for _, a := range PersonAttrs(r) {
+		l.attr(a.Name, a.Value)
+	}
+
+	s := r.Sotr
+	if l.photos != nil {
+		data, contentType, err := l.photos.Photo(s.Tabnum)
+		if err != nil {
+			return err
+		}
+		// jpegPhoto keeps JPEG images only
+		if data != nil && contentType == "image/jpeg" {
+			l.fold("jpegPhoto:: " + base64.StdEncoding.EncodeToString(data))
+		}
+	}
+	return nil
+}
+
+func (l *ldifWriter) Close() error {
+	return l.w.Flush()
+}
+
+// Attr is the attribute of the LDAP entry
+type Attr struct {
+	Name, Value string
+}
+
+// UnitDN returns DN of the unit of the dep path under the base DN
+func UnitDN(path []string, baseDN string) string {
+	dn := baseDN
+	for _, name := range path {
+		dn = "ou=" + escapeRDN(name) + "," + dn
+	}
+	return dn
+}
+
+// PersonDN returns DN of the employee under the unit of its dep
+func PersonDN(r *Row, baseDN string) string {
+	return "uid=" + escapeRDN(r.Sotr.Tabnum) + "," + UnitDN(r.Path, baseDN)
+}
+
+// UnitAttrs returns attributes of the organizationalUnit entry of the dep
+func UnitAttrs(name string) []Attr {
+	return []Attr{{"objectClass", "top"}, {"objectClass", "organizationalUnit"}, {"ou", name}}
+}
+
+// PersonAttrs returns attributes of the inetOrgPerson entry of the employee, empty values are skipped
+func PersonAttrs(r *Row) []Attr {
+	s := r.Sotr
+	family, given, _ := strings.Cut(s.Name, " ")
+	if family == "" {
+		// sn is required by the person class
+		family = s.Tabnum
+	}
+
+	attrs := []Attr{
+		{"objectClass", "top"}, {"objectClass", "person"}, {"objectClass", "organizationalPerson"}, {"objectClass", "inetOrgPerson"},
+		{"uid", s.Tabnum},
+		{"employeeNumber", s.Tabnum},
+		{"cn", Fio(s)},
+		{"sn", family},
+		{"givenName", strings.TrimSpace(strings.TrimSpace(given) + " " + s.MidName)},
+		{"displayName", Fio(s)},
+		{"title", s.Grade},
+	}
+	if len(r.Path) > 0 {
+		attrs = append(attrs, Attr{"ou", r.Path[len(r.Path)-1]})
+	}
+	for _, p := range s.Phone {
+		attrs = append(attrs, Attr{"telephoneNumber", p})
+	}
+	for _, m := range s.Mobile {
+		attrs = append(attrs, Attr{"mobile", m})
+	}
+	attrs = append(attrs, Attr{"mail", s.Email})
+
+	return slices.DeleteFunc(attrs, func(a Attr) bool { return a.Value == "" })
+}
+
+// attr writes the attribute, the unsafe value is base64 encoded. Empty values are skipped.
+func (l *ldifWriter) attr(name, value string) {
+	if value == "" {
+		return
+	}
+	if ldifSafe(value) {
+		l.fold(name + ": " + value)
+	} else {
+		l.fold(name + ":: " + base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+}
+
+// fold writes the line, continuation lines are started by the space. Lines are ASCII.
+func (l *ldifWriter) fold(line string) {
+	n := ldifLineLen
+	for len(line) > n {
+		l.w.WriteString(line[:n])
+		l.w.WriteString("\n ")
+		line = line[n:]
+		// the space is counted in the length of continuation lines
+		n = ldifLineLen - 1
+	}
+	l.w.WriteString(line)
+	l.w.WriteString("\n")
+}
+
+// ldifSafe reports whether the value is SAFE-STRING of RFC 2849
+func ldifSafe(s string) bool {
+	if s[0] == ' ' || s[0] == ':' || s[0] == '<' || s[len(s)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == 0 || c == '\n' || c == '\r' || c > 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeRDN escapes the value of RDN by RFC 4514
+func escapeRDN(s string) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, c),
+			i == 0 && (c == ' ' || c == '#'),
+			i == len(s)-1 && c == ' ':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"errors"
+	"os"
+
+	"github.com/mioxin/kbempgo/internal/avatar"
+)
+
+// Photos returns avatars of employees embedded to vCard and LDIF
+type Photos interface {
+	// Photo returns the image of the employee, data is nil if the employee has no avatar
+	Photo(tabnum string) (data []byte, contentType string, err error)
+}
+
+// avatarPhotos reads avatars of the avatar store
+type avatarPhotos struct {
+	s    *avatar.Store
+	size int
+}
+
+// AvatarPhotos returns JPEG thumbnails of the size of the avatar store, originals are returned if the size is 0.
+// Missing thumbnails of sizes configured in the store are made.
+func AvatarPhotos(s *avatar.Store, size int) Photos {
+	return &avatarPhotos{s: s, size: size}
+}
+
+func (p *avatarPhotos) Photo(tabnum string) ([]byte, string, error) {
+	m, ok := p.s.Meta(tabnum)
+	if !ok {
+		return nil, "", nil
+	}
+
+	if p.size == 0 {
+		data, err := os.ReadFile(p.s.Path(m.Hash))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", nil
+		}
+		return data, m.ContentType, err
+	}
+
+	path := p.s.ThumbPath(m.Hash, p.size, avatar.FormatJPEG)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && p.s.Thumbs.HasThumb(p.size, avatar.FormatJPEG) {
+		if err = p.s.Thumbnails(m.Hash); err != nil {
+			return nil, "", err
+		}
+		data, err = os.ReadFile(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	return data, avatar.ContentType(avatar.FormatJPEG), err
+}
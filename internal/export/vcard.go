@@ -0,0 +1,167 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/base64"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// vCard 4.0 (RFC 6350), vCard 3.0 (RFC 2426) is written for CardDAV clients
+
+// Versions of vCard
+const (
+	VCard3 = "3.0"
+	VCard4 = "4.0"
+)
+
+// vcardLineLen is the max length of the vCard line in octets, longer lines are folded
+const vcardLineLen = 75
+
+// vcardWriter writes vCards of all employees to one file
+type vcardWriter struct {
+	w      *bufio.Writer
+	photos Photos
+}
+
+func (v *vcardWriter) Write(r *Row) error {
+	return WriteVCard(v.w, r, v.photos, VCard4)
+}
+
+func (v *vcardWriter) Close() error {
+	return v.w.Flush()
+}
+
+// vcardZipWriter writes vCards of every dep to the separate file of the zip archive,
+// the file is named by the path of the dep
+type vcardZipWriter struct {
+	zip    *zip.Writer
+	photos Photos
+
+	dep   string
+	file  io.Writer
+	names map[string]struct{}
+}
+
+func (v *vcardZipWriter) Write(r *Row) (err error) {
+	if v.file == nil || r.Dep != v.dep {
+		v.dep = r.Dep
+		v.file, err = v.zip.CreateHeader(&zip.FileHeader{Name: v.name(r), Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+	}
+	return WriteVCard(v.file, r, v.photos, VCard4)
+}
+
+// name returns the unique name of the file of the dep
+func (v *vcardZipWriter) name(r *Row) string {
+	parts := make([]string, 0, len(r.Path))
+	for _, p := range r.Path {
+		parts = append(parts, strings.NewReplacer("/", "_", "\\", "_").Replace(p))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, r.Dep)
+	}
+
+	name := strings.Join(parts, "/")
+	if _, ok := v.names[name]; ok {
+		name += " (" + r.Dep + ")"
+	}
+	v.names[name] = struct{}{}
+	return name + ".vcf"
+}
+
+func (v *vcardZipWriter) Close() error {
+	return v.zip.Close()
+}
+
+// WriteVCard writes the vCard of the version of the employee with the dep path as the organization
+func WriteVCard(w io.Writer, r *Row, photos Photos, version string) error {
+	s := r.Sotr
+	bw := &vcardLines{w: w}
+
+	bw.line("BEGIN:VCARD")
+	bw.line("VERSION:" + version)
+	if s.Tabnum != "" {
+		bw.line("UID:urn:kbemp:tabnum:" + s.Tabnum)
+	}
+	bw.line("FN:" + vcardEscape(Fio(s)))
+
+	family, given, _ := strings.Cut(s.Name, " ")
+	bw.line("N:" + vcardEscape(family) + ";" + vcardEscape(strings.TrimSpace(given)) + ";" + vcardEscape(s.MidName) + ";;")
+
+	if len(r.Path) > 0 {
+		units := make([]string, len(r.Path))
+		for i, p := range r.Path {
+			units[i] = vcardEscape(p)
+		}
+		bw.line("ORG:" + strings.Join(units, ";"))
+	}
+	if s.Grade != "" {
+		bw.line("TITLE:" + vcardEscape(s.Grade))
+	}
+	for _, p := range s.Phone {
+		bw.line("TEL;TYPE=work,voice:" + vcardEscape(p))
+	}
+	for _, m := range s.Mobile {
+		bw.line("TEL;TYPE=cell:" + vcardEscape(m))
+	}
+	if s.Email != "" {
+		bw.line("EMAIL;TYPE=work:" + vcardEscape(s.Email))
+	}
+
+	if photos != nil {
+		data, contentType, err := photos.Photo(s.Tabnum)
+		if err != nil {
+			return err
+		}
+		switch {
+		case data == nil:
+		case version == VCard3:
+			// image/jpeg is TYPE=JPEG
+			_, typ, _ := strings.Cut(contentType, "/")
+			bw.line("PHOTO;ENCODING=b;TYPE=" + strings.ToUpper(typ) + ":" + base64.StdEncoding.EncodeToString(data))
+		default:
+			bw.line("PHOTO:data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data))
+		}
+	}
+
+	bw.line("END:VCARD")
+	return bw.err
+}
+
+// vcardLines writes lines ended by CRLF, long lines are folded without splitting of UTF-8 characters
+type vcardLines struct {
+	w   io.Writer
+	err error
+}
+
+func (l *vcardLines) line(s string) {
+	if l.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := utf8.RuneLen(r)
+		if n+size > vcardLineLen {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+
+	_, l.err = io.WriteString(l.w, b.String())
+}
+
+// vcardEscape escapes the text value
+func vcardEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,186 @@
+// Package hrimport merges attributes of HR exports (CSV, XLSX) to employees of the storage.
+// Rows are matched to employees by tabnum, hire dates, normalized positions and tabnums of managers
+// are saved by Update of the storage, so changes get histories. Empty values don't clear saved ones,
+// the value "clear" of the mapping does.
+package hrimport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Store is the part of storage.Store used by the import
+type Store interface {
+	GetSotrsBy(context.Context, *kbv1.SotrRequest) ([]*kbv1.Sotr, error)
+	Update(context.Context, *kbv1.UpdateSotrRequest) (*emptypb.Empty, error)
+}
+
+// Field is the changed attribute of the employee, it's named like the field of histories
+type Field struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// Change of the employee
+type Change struct {
+	Line   int
+	Sotr   *kbv1.Sotr
+	Fields []Field
+	// Clear are names of cleared attributes
+	Clear []string
+}
+
+// Problem is the row or the value which isn't imported
+type Problem struct {
+	Line   int
+	Tabnum string
+	Msg    string
+}
+
+// Plan is the diff of the import
+type Plan struct {
+	Changes   []*Change
+	Unchanged int
+	Problems  []Problem
+}
+
+// NewPlan compares records with employees of the storage
+func NewPlan(ctx context.Context, store Store, records []*Record, m *Mapping) (*Plan, error) {
+	all, err := store.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE})
+	if err != nil {
+		return nil, fmt.Errorf("get employees: %w", err)
+	}
+	// the latest row of the tabnum is the actual one
+	sotrs := make(map[string]*kbv1.Sotr, len(all))
+	for _, s := range all {
+		sotrs[s.Tabnum] = s
+	}
+
+	p := &Plan{}
+	seen := map[string]int{}
+	for _, rec := range records {
+		problem := func(format string, a ...any) {
+			p.Problems = append(p.Problems, Problem{Line: rec.Line, Tabnum: rec.Tabnum, Msg: fmt.Sprintf(format, a...)})
+		}
+
+		if rec.Tabnum == "" {
+			problem("tabnum is empty")
+			continue
+		}
+		if line, ok := seen[rec.Tabnum]; ok {
+			problem("duplicate of line %d", line)
+			continue
+		}
+		seen[rec.Tabnum] = rec.Line
+
+		old, ok := sotrs[rec.Tabnum]
+		if !ok {
+			problem("employee not found")
+			continue
+		}
+
+		sotr := proto.Clone(old).(*kbv1.Sotr)
+		var clear []string
+		if m.clears(rec.HireDate) {
+			sotr.HireDate = nil
+			clear = append(clear, datasource.FieldHireDate)
+		} else if rec.HireDate != "" {
+			if t, err := m.date(rec.HireDate); err != nil {
+				problem("%v", err)
+			} else {
+				sotr.HireDate = timestamppb.New(t)
+			}
+		}
+		if m.clears(rec.Position) {
+			sotr.Position = ""
+			clear = append(clear, datasource.FieldPosition)
+		} else if pos := m.position(rec.Position); pos != "" {
+			sotr.Position = pos
+		}
+		if m.clears(rec.ManagerTabnum) {
+			sotr.ManagerTabnum = ""
+			clear = append(clear, datasource.FieldManagerTabnum)
+		} else if rec.ManagerTabnum != "" {
+			switch _, ok := sotrs[rec.ManagerTabnum]; {
+			case rec.ManagerTabnum == rec.Tabnum:
+				problem("employee is their own manager")
+			case !ok:
+				problem("manager %s not found", rec.ManagerTabnum)
+			default:
+				sotr.ManagerTabnum = rec.ManagerTabnum
+			}
+		}
+
+		fields := diff(old, sotr)
+		if len(fields) == 0 {
+			p.Unchanged++
+			continue
+		}
+		p.Changes = append(p.Changes, &Change{Line: rec.Line, Sotr: sotr, Fields: fields, Clear: clear})
+	}
+	return p, nil
+}
+
+// diff returns changed imported attributes
+func diff(old, sotr *kbv1.Sotr) (fields []Field) {
+	for _, f := range []Field{
+		{datasource.FieldHireDate, date(old.HireDate), date(sotr.HireDate)},
+		{datasource.FieldPosition, old.Position, sotr.Position},
+		{datasource.FieldManagerTabnum, old.ManagerTabnum, sotr.ManagerTabnum},
+	} {
+		if f.Old != f.New {
+			fields = append(fields, f)
+		}
+	}
+	return
+}
+
+func date(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return t.AsTime().Format(datasource.DateLayout)
+}
+
+// WriteDiff writes changes and problems of the plan
+func (p *Plan) WriteDiff(w io.Writer) error {
+	var b strings.Builder
+	for _, c := range p.Changes {
+		fmt.Fprintf(&b, "%s %s\n", c.Sotr.Tabnum, strings.TrimSpace(c.Sotr.Name+" "+c.Sotr.MidName))
+		for _, f := range c.Fields {
+			fmt.Fprintf(&b, "  %s: %q -> %q\n", f.Name, f.Old, f.New)
+		}
+	}
+	for _, pr := range p.Problems {
+		fmt.Fprintf(&b, "line %d: tabnum %q: %s\n", pr.Line, pr.Tabnum, pr.Msg)
+	}
+	fmt.Fprintf(&b, "changed: %d, unchanged: %d, problems: %d\n", len(p.Changes), p.Unchanged, len(p.Problems))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Apply saves changed employees to the storage, the number of saved employees is returned
+func (p *Plan) Apply(ctx context.Context, store Store) (n int, err error) {
+	for _, c := range p.Changes {
+		q := &kbv1.UpdateSotrRequest{Sotr: c.Sotr}
+		if len(c.Clear) > 0 {
+			q.Clear = &fieldmaskpb.FieldMask{Paths: c.Clear}
+		}
+		if _, err = store.Update(ctx, q); err != nil {
+			return n, fmt.Errorf("update employee %s (line %d): %w", c.Sotr.Tabnum, c.Line, err)
+		}
+		n++
+	}
+	return
+}
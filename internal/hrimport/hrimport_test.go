@@ -0,0 +1,195 @@
+package hrimport
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/mem"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func newStore(t *testing.T) *mem.MemStore {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := mem.New("", slog.Default())
+	require.NoError(t, err)
+
+	deps := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(t, "dep.json", deps)
+	for _, d := range deps.Deps {
+		_, err = store.Save(ctx, d)
+		require.NoError(t, err)
+	}
+	// the mem store is flushed after deps and after employees
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	sotrs := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(t, "sotr.json", sotrs)
+	for _, s := range sotrs.Sotrs {
+		_, err = store.Save(ctx, s)
+		require.NoError(t, err)
+	}
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	return store
+}
+
+const mapping = `
+comma: ";"
+date_formats: ["02.01.2006"]
+columns:
+  tabnum: Таб. номер
+  hire_date: Дата приёма
+  position: Должность
+  manager_tabnum: Руководитель
+positions:
+  гл. специалист: Главный специалист
+`
+
+func loadMapping(t *testing.T) *Mapping {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "mapping.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(mapping), 0o644))
+	m, err := LoadMapping(path)
+	require.NoError(t, err)
+	return m
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	m := loadMapping(t)
+
+	records, err := Read(strings.NewReader("\ufeffФИО;Таб. номер;Должность;Дата приёма;Руководитель\n"+
+		"Са44444;60609;гл.  специалист;02.03.2015;1600\n"+
+		";;;;\n"+
+		"Нет;404;Специалист;;\n"+
+		"Пал4444;2681;ведущий специалист;2015-03-02;2681\n"+
+		"Са44444;60609;;;\n"), FormatCSV, m)
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, &Record{Line: 2, Tabnum: "60609", HireDate: "02.03.2015", Position: "гл.  специалист", ManagerTabnum: "1600"}, records[0])
+
+	p, err := NewPlan(ctx, store, records, m)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, p.WriteDiff(buf))
+	assert.Equal(t, `60609 Са44444 Асемгуль Абатовна
+  hire_date: "" -> "2015-03-02"
+  position: "" -> "Главный специалист"
+  manager_tabnum: "" -> "1600"
+2681 Пал4444 Юлия Викторовна
+  position: "" -> "Ведущий специалист"
+line 4: tabnum "404": employee not found
+line 5: tabnum "2681": invalid hire date "2015-03-02"
+line 5: tabnum "2681": employee is their own manager
+line 6: tabnum "60609": duplicate of line 2
+changed: 2, unchanged: 0, problems: 4
+`, buf.String())
+
+	// nothing is saved before Apply
+	sotrs, err := store.GetSotrsBy(ctx, &kbv1.SotrRequest{Str: "60609", Field: kbv1.SotrRequest_TABNUM})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+	assert.Empty(t, sotrs[0].Position)
+
+	n, err := p.Apply(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	sotrs, err = store.GetSotrsBy(ctx, &kbv1.SotrRequest{Str: "60609", Field: kbv1.SotrRequest_TABNUM})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+	assert.Equal(t, "Главный специалист", sotrs[0].Position)
+	assert.Equal(t, "1600", sotrs[0].ManagerTabnum)
+	assert.Equal(t, "2015-03-02", date(sotrs[0].HireDate))
+
+	hist, err := store.GetHistory(ctx, &kbv1.HistRequest{SotrId: strconv.FormatUint(sotrs[0].Id, 10)})
+	require.NoError(t, err)
+	var fields []string
+	for _, h := range hist.HistoryList {
+		fields = append(fields, h.Field)
+	}
+	assert.Equal(t, []string{"hire_date", "position", "manager_tabnum"}, fields)
+
+	// the second import has no changes
+	p, err = NewPlan(ctx, store, records[:1], m)
+	require.NoError(t, err)
+	assert.Empty(t, p.Changes)
+	assert.Equal(t, 1, p.Unchanged)
+
+	// the clear value clears the saved manager, empty cells keep saved attributes
+	m.Clear = "-"
+	records, err = Read(strings.NewReader("Таб. номер;Должность;Руководитель\n60609;;-\n"), FormatCSV, m)
+	require.NoError(t, err)
+	p, err = NewPlan(ctx, store, records, m)
+	require.NoError(t, err)
+	require.Len(t, p.Changes, 1)
+	assert.Equal(t, []Field{{Name: "manager_tabnum", Old: "1600"}}, p.Changes[0].Fields)
+	assert.Equal(t, []string{"manager_tabnum"}, p.Changes[0].Clear)
+
+	_, err = p.Apply(ctx, store)
+	require.NoError(t, err)
+	sotrs, err = store.GetSotrsBy(ctx, &kbv1.SotrRequest{Str: "60609", Field: kbv1.SotrRequest_TABNUM})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+	assert.Equal(t, "Главный специалист", sotrs[0].Position)
+	assert.Empty(t, sotrs[0].ManagerTabnum)
+}
+
+func TestImportXLSX(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	require.NoError(t, f.SetSheetRow(sheet, "A1", &[]any{"tabnum", "hire_date", "position"}))
+	// the date cell is the number formatted by the style
+	require.NoError(t, f.SetSheetRow(sheet, "A2", &[]any{1600, 42065, " Директор "}))
+	style, err := f.NewStyle(&excelize.Style{NumFmt: 14})
+	require.NoError(t, err)
+	require.NoError(t, f.SetCellStyle(sheet, "B2", "B2", style))
+	buf, err := f.WriteToBuffer()
+	require.NoError(t, err)
+
+	records, err := Read(buf, FormatXLSX, DefaultMapping())
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, &Record{Line: 2, Tabnum: "1600", HireDate: "42065", Position: "Директор"}, records[0])
+
+	p, err := NewPlan(ctx, store, records, DefaultMapping())
+	require.NoError(t, err)
+	require.Len(t, p.Changes, 1)
+	assert.Equal(t, []Field{
+		{Name: "hire_date", New: "2015-03-02"},
+		{Name: "position", New: "Директор"},
+	}, p.Changes[0].Fields)
+}
+
+func TestRead(t *testing.T) {
+	_, err := Read(strings.NewReader("tabnum,grade\n1600,x\n"), FormatCSV, DefaultMapping())
+	assert.ErrorContains(t, err, "columns of imported attributes not found")
+	_, err = Read(strings.NewReader("position\nx\n"), FormatCSV, DefaultMapping())
+	assert.ErrorContains(t, err, `column "tabnum" of tabnum not found`)
+
+	_, err = FormatOf("hr.xls")
+	assert.Error(t, err)
+	format, err := FormatOf("HR.XLSX")
+	require.NoError(t, err)
+	assert.Equal(t, FormatXLSX, format)
+}
@@ -0,0 +1,127 @@
+package hrimport
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml"
+	"github.com/xuri/excelize/v2"
+)
+
+// Mapping describes columns of the HR export and normalization of values
+type Mapping struct {
+	// Sheet of XLSX, the first sheet by default
+	Sheet string `yaml:"sheet"`
+	// HeaderRow is the number of the row with names of columns, rows are numbered from 1
+	HeaderRow int `yaml:"header_row"`
+	// Comma separates values of CSV
+	Comma string `yaml:"comma"`
+	// DateFormats are Go layouts of hire dates, numbers are dates of Excel
+	DateFormats []string `yaml:"date_formats"`
+	Columns     Columns  `yaml:"columns"`
+	// Clear is the value of the cell clearing the saved attribute, e.g. "-". Empty cells keep saved attributes.
+	Clear string `yaml:"clear"`
+	// Positions are normalized positions keyed by positions of the export, keys are matched case-insensitively
+	Positions map[string]string `yaml:"positions"`
+}
+
+// Columns are names of columns in the header, an empty name skips the attribute
+type Columns struct {
+	Tabnum        string `yaml:"tabnum"`
+	HireDate      string `yaml:"hire_date"`
+	Position      string `yaml:"position"`
+	ManagerTabnum string `yaml:"manager_tabnum"`
+}
+
+// DefaultMapping is used without the mapping file, names of columns are names of attributes
+func DefaultMapping() *Mapping {
+	return &Mapping{
+		HeaderRow:   1,
+		Comma:       ",",
+		DateFormats: []string{"02.01.2006", "2006-01-02"},
+		Columns: Columns{
+			Tabnum:        "tabnum",
+			HireDate:      "hire_date",
+			Position:      "position",
+			ManagerTabnum: "manager_tabnum",
+		},
+	}
+}
+
+// LoadMapping loads the mapping from YAML file, missing settings are default ones
+func LoadMapping(path string) (*Mapping, error) {
+	m := DefaultMapping()
+	if path == "" {
+		return m, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load mapping: %w", err)
+	}
+	// columns of the file replace default ones
+	m.Columns = Columns{}
+	if err = yaml.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("parse mapping %s: %w", path, err)
+	}
+	return m, m.validate()
+}
+
+func (m *Mapping) validate() error {
+	if m.Columns.Tabnum == "" {
+		return fmt.Errorf("mapping: column of tabnum is not set")
+	}
+	if m.Columns.HireDate == "" && m.Columns.Position == "" && m.Columns.ManagerTabnum == "" {
+		return fmt.Errorf("mapping: columns of imported attributes are not set")
+	}
+	if m.HeaderRow < 1 {
+		return fmt.Errorf("mapping: header row %d is less than 1", m.HeaderRow)
+	}
+	if utf8.RuneCountInString(m.Comma) != 1 {
+		return fmt.Errorf("mapping: comma %q is not a single character", m.Comma)
+	}
+	return nil
+}
+
+// clears reports whether the value clears the saved attribute
+func (m *Mapping) clears(s string) bool {
+	return m.Clear != "" && s == m.Clear
+}
+
+// date parses the hire date by layouts of the mapping or as the serial number of Excel
+func (m *Mapping) date(s string) (time.Time, error) {
+	for _, layout := range m.DateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		t, err := excelize.ExcelDateToTime(n, false)
+		if err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid hire date %q", s)
+}
+
+// position returns the normalized position: spaces are collapsed, the mapped position is used
+// or the first letter is upper case
+func (m *Mapping) position(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return ""
+	}
+	for k, v := range m.Positions {
+		if strings.EqualFold(strings.Join(strings.Fields(k), " "), s) {
+			return v
+		}
+	}
+
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,141 @@
+package hrimport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats of HR exports
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// FormatOf returns the format of the file by the extension
+func FormatOf(path string) (string, error) {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); ext {
+	case FormatCSV, FormatXLSX:
+		return ext, nil
+	default:
+		return "", fmt.Errorf("unknown format of import %q, csv or xlsx is expected", ext)
+	}
+}
+
+// Record is the row of the HR export, values are trimmed as is
+type Record struct {
+	// Line is the number of the row in the file
+	Line          int
+	Tabnum        string
+	HireDate      string
+	Position      string
+	ManagerTabnum string
+}
+
+// Read reads records of the HR export by columns of the mapping
+func Read(r io.Reader, format string, m *Mapping) ([]*Record, error) {
+	var (
+		rows [][]string
+		err  error
+	)
+	switch format {
+	case FormatCSV:
+		rows, err = readCSV(r, m)
+	case FormatXLSX:
+		rows, err = readXLSX(r, m)
+	default:
+		err = fmt.Errorf("unknown format of import %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) < m.HeaderRow {
+		return nil, fmt.Errorf("header row %d not found", m.HeaderRow)
+	}
+
+	header := map[string]int{}
+	for i, name := range rows[m.HeaderRow-1] {
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	index := func(name string) int {
+		if i, ok := header[strings.ToLower(name)]; ok && name != "" {
+			return i
+		}
+		return -1
+	}
+
+	// columns of attributes absent in the file are skipped
+	cols := [4]int{index(m.Columns.Tabnum), index(m.Columns.HireDate), index(m.Columns.Position), index(m.Columns.ManagerTabnum)}
+	if cols[0] < 0 {
+		return nil, fmt.Errorf("column %q of tabnum not found in header", m.Columns.Tabnum)
+	}
+	if cols[1] < 0 && cols[2] < 0 && cols[3] < 0 {
+		return nil, fmt.Errorf("columns of imported attributes not found in header")
+	}
+
+	records := make([]*Record, 0, len(rows)-m.HeaderRow)
+	for n, row := range rows[m.HeaderRow:] {
+		value := func(i int) string {
+			if i < 0 || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		rec := &Record{
+			Line:          m.HeaderRow + n + 1,
+			Tabnum:        value(cols[0]),
+			HireDate:      value(cols[1]),
+			Position:      value(cols[2]),
+			ManagerTabnum: value(cols[3]),
+		}
+		// skip empty rows
+		if *rec == (Record{Line: rec.Line}) {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func readCSV(r io.Reader, m *Mapping) ([][]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comma, _ = utf8.DecodeRuneInString(m.Comma)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read csv: %w", err)
+	}
+	// BOM of files saved by Excel
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+	return rows, nil
+}
+
+func readXLSX(r io.Reader, m *Mapping) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read xlsx: %w", err)
+	}
+	defer f.Close()
+
+	sheet := m.Sheet
+	if sheet == "" {
+		sheet = f.GetSheetName(0)
+	}
+	// raw values keep numbers of dates and tabnums without formats of cells
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("read xlsx sheet %q: %w", sheet, err)
+	}
+	return rows, nil
+}
@@ -3,6 +3,7 @@ package httpclient
 import (
 	"fmt"
 	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -12,8 +13,9 @@ import (
 
 const MaxIdleConnsPerHost int = 20
 
-// NewHTTPClient create Http client
-func NewHTTPClient(debLevel int, headers []string) *req.Client {
+// NewHTTPClient create Http client.
+// If fixtures are not nil responses are recorded to them or replayed from them instead of requests.
+func NewHTTPClient(debLevel int, headers []string, fixtures *Fixtures) *req.Client {
 	hdrs := map[string]string{
 		"Accept":                    "*/*",
 		"Accept-Language":           "ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7",
@@ -71,13 +73,18 @@ func NewHTTPClient(debLevel int, headers []string) *req.Client {
 				fmt.Println("Retry request:", req.Method, req.URL, "; time: ", resp.TotalTime())
 			}
 		}).
+		// 429 is retried after the pause of Retry-After
 		AddCommonRetryCondition(func(resp *req.Response, err error) bool {
-			return err != nil || resp.StatusCode >= 500
+			return err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
 		})
 
 	cli.Transport.MaxIdleConnsPerHost = MaxIdleConnsPerHost
 	cli.Transport.IdleConnTimeout = 90 * time.Second
 
+	if fixtures != nil {
+		fixtures.wrap(cli)
+	}
+
 	if debLevel > 1 {
 		cli = cli.EnableDebugLog()
 	}
@@ -0,0 +1,267 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/imroc/req/v3"
+)
+
+// Modes of fixtures
+const (
+	FixtureOff    = "off"
+	FixtureRecord = "record"
+	FixtureReplay = "replay"
+)
+
+// FixtureConfig configures recording of http interactions to fixtures and replaying them
+type FixtureConfig struct {
+	Mode string `name:"mode" enum:"off,record,replay" default:"off" help:"Record responses to fixtures or replay them instead of requests: off, record, replay"`
+	Dir  string `name:"dir" help:"Directory of fixtures"`
+}
+
+// Fixture is recorded responses to one request, they are replayed in the recorded order
+type Fixture struct {
+	Method    string             `json:"method"`
+	URL       string             `json:"url"`
+	Responses []*FixtureResponse `json:"responses"`
+}
+
+// FixtureResponse is a recorded response. Text body is kept as is for readable fixtures.
+type FixtureResponse struct {
+	Status int                 `json:"status"`
+	Header map[string][]string `json:"header,omitempty"`
+	Body   string              `json:"body,omitempty"`
+	Binary []byte              `json:"binary,omitempty"`
+}
+
+// Fixtures is a directory of fixtures. It records responses of the real server
+// and replays them by the client or by the fake server.
+type Fixtures struct {
+	dir  string
+	mode string
+	mu   sync.Mutex
+	// loaded fixtures and number of replayed responses by key
+	fixtures map[string]*Fixture
+	replayed map[string]int
+}
+
+// OpenFixtures loads fixtures by the config, it returns nil if fixtures are off.
+// The fixtures should be shared by all clients, so responses are replayed in the recorded order.
+func OpenFixtures(conf FixtureConfig) (fs *Fixtures, err error) {
+	switch conf.Mode {
+	case "", FixtureOff:
+		return nil, nil
+	case FixtureRecord, FixtureReplay:
+	default:
+		return nil, fmt.Errorf("invalid mode of fixtures %q", conf.Mode)
+	}
+
+	if conf.Dir == "" {
+		return nil, errors.New("directory of fixtures is not set")
+	}
+
+	if conf.Mode == FixtureRecord {
+		// fixtures are recorded again
+		if err = os.MkdirAll(conf.Dir, 0750); err != nil {
+			return nil, fmt.Errorf("open fixtures: %w", err)
+		}
+		fs = &Fixtures{
+			dir:      conf.Dir,
+			fixtures: make(map[string]*Fixture),
+			replayed: make(map[string]int),
+		}
+	} else if fs, err = LoadFixtures(conf.Dir); err != nil {
+		return
+	}
+
+	fs.mode = conf.Mode
+	return
+}
+
+// LoadFixtures reads fixtures from the directory, the directory is created if it doesn't exist
+func LoadFixtures(dir string) (fs *Fixtures, err error) {
+	if err = os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("load fixtures: %w", err)
+	}
+
+	fs = &Fixtures{
+		dir:      dir,
+		fixtures: make(map[string]*Fixture),
+		replayed: make(map[string]int),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("load fixtures: %w", err)
+	}
+
+	for _, name := range files {
+		b, e := os.ReadFile(name)
+		if e != nil {
+			return nil, fmt.Errorf("load fixtures: %w", e)
+		}
+
+		f := &Fixture{}
+		if e := json.Unmarshal(b, f); e != nil {
+			return nil, fmt.Errorf("load fixture %s: %w", name, e)
+		}
+		fs.fixtures[fixtureKey(f.Method, f.URL)] = f
+	}
+	return
+}
+
+// RoundTrip is middleware of the transport of req.Client recording responses of the server
+func (fs *Fixtures) RoundTrip(rt http.RoundTripper) req.HttpRoundTripFunc {
+	return func(r *http.Request) (resp *http.Response, err error) {
+		resp, err = rt.RoundTrip(r)
+		if err != nil {
+			return
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		err = fs.Record(r.Method, r.URL.RequestURI(), resp.StatusCode, resp.Header, body)
+		return
+	}
+}
+
+// Replay is a transport of req.Client replaying responses instead of requests
+func (fs *Fixtures) Replay() req.HttpRoundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		fs.ServeHTTP(rec, r)
+
+		resp := rec.Result()
+		resp.Request = r
+		return resp, nil
+	}
+}
+
+// Record appends the response to the fixture of the request and saves one
+func (fs *Fixtures) Record(method, url string, status int, header http.Header, body []byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	key := fixtureKey(method, url)
+	f, ok := fs.fixtures[key]
+	if !ok {
+		f = &Fixture{Method: method, URL: url}
+		fs.fixtures[key] = f
+	}
+
+	fr := &FixtureResponse{Status: status, Header: header.Clone()}
+	// fixtures should not keep sessions of the real server
+	delete(fr.Header, "Set-Cookie")
+	delete(fr.Header, "Date")
+
+	if utf8.Valid(body) {
+		fr.Body = string(body)
+	} else {
+		fr.Binary = body
+	}
+	f.Responses = append(f.Responses, fr)
+
+	// html of bodies is kept readable
+	b := &bytes.Buffer{}
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(f); err != nil {
+		return fmt.Errorf("record fixture: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(fs.dir, fixtureName(method, url)), b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("record fixture: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP replays responses of the request in the recorded order, the last response is repeated.
+// Not recorded requests get 404.
+func (fs *Fixtures) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	key := fixtureKey(r.Method, r.URL.RequestURI())
+	f, ok := fs.fixtures[key]
+	if !ok || len(f.Responses) == 0 {
+		fs.mu.Unlock()
+		http.Error(w, "fixture not found: "+key, http.StatusNotFound)
+		return
+	}
+
+	n := min(fs.replayed[key], len(f.Responses)-1)
+	fs.replayed[key]++
+	fr := f.Responses[n]
+	fs.mu.Unlock()
+
+	for k, v := range fr.Header {
+		w.Header()[k] = v
+	}
+
+	status := fr.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	if fr.Binary != nil {
+		w.Write(fr.Binary)
+	} else {
+		io.WriteString(w, fr.Body)
+	}
+}
+
+// NewFixtureServer starts the fake server replaying fixtures of the directory
+func NewFixtureServer(dir string) (*httptest.Server, error) {
+	fs, err := LoadFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	return httptest.NewServer(fs), nil
+}
+
+// wrap sets recording or replaying to the transport of the client
+func (fs *Fixtures) wrap(cli *req.Client) {
+	switch fs.mode {
+	case FixtureRecord:
+		cli.GetTransport().WrapRoundTripFunc(fs.RoundTrip)
+	case FixtureReplay:
+		cli.GetTransport().WrapRoundTripFunc(func(http.RoundTripper) req.HttpRoundTripFunc {
+			return fs.Replay()
+		})
+	}
+}
+
+func fixtureKey(method, url string) string {
+	return method + " " + url
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// fixtureName is a readable file name of the request, the hash avoids collisions of escaped names
+func fixtureName(method, url string) string {
+	sum := sha256.Sum256([]byte(fixtureKey(method, url)))
+	name := strings.Trim(unsafeChars.ReplaceAllString(url, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return fmt.Sprintf("%s_%s_%s.json", strings.ToLower(method), name, hex.EncodeToString(sum[:4]))
+}
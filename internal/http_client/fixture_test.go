@@ -0,0 +1,50 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixturesRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`[{"id":"razd1"}]`))
+	}))
+
+	fs, err := OpenFixtures(FixtureConfig{Mode: FixtureRecord, Dir: dir})
+	require.NoError(t, err)
+
+	// 502 is retried by the client
+	resp, err := NewHTTPClient(0, nil, fs).R().Get(srv.URL + "/razd/root")
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":"razd1"}]`, resp.String())
+	srv.Close()
+
+	fs, err = OpenFixtures(FixtureConfig{Mode: FixtureReplay, Dir: dir})
+	require.NoError(t, err)
+	cli := NewHTTPClient(0, nil, fs).DisableAutoReadResponse().SetCommonRetryCount(0)
+
+	for _, status := range []int{http.StatusBadGateway, http.StatusOK, http.StatusOK} {
+		resp, err := cli.R().Get(srv.URL + "/razd/root")
+		require.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode, "responses should be replayed in the recorded order")
+	}
+
+	resp, err = cli.R().Get(srv.URL + "/razd/razd1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = OpenFixtures(FixtureConfig{Mode: FixtureReplay})
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package ldapsrv
+
+import (
+	"fmt"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/mioxin/kbempgo/internal/utils"
+)
+
+// aliases of names of attributes
+var aliases = map[string]string{
+	"commonname":             "cn",
+	"surname":                "sn",
+	"mobiletelephonenumber":  "mobile",
+	"rfc822mailbox":          "mail",
+	"organizationalunitname": "ou",
+	"userid":                 "uid",
+}
+
+// attrName returns the lower case name of the attribute
+func attrName(name string) string {
+	name = strings.ToLower(name)
+	if a, ok := aliases[name]; ok {
+		return a
+	}
+	return name
+}
+
+// normalize returns the value of the attribute compared by filters: digits of phones and lower case of other values
+func normalize(attr, value string) string {
+	if attr == "telephonenumber" || attr == "mobile" {
+		return utils.ExtractDigits(value)
+	}
+	return strings.ToLower(value)
+}
+
+// filter is the compiled search filter (RFC 4515), values are normalized
+type filter struct {
+	op   ber.Tag
+	attr string
+	// value is the assertion of equality, approx and ordering matches
+	value string
+	// substrings
+	initial, final string
+	any            []string
+
+	sub []*filter
+}
+
+func parseFilter(s string) (*filter, error) {
+	p, err := ldap.CompileFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	return compile(p)
+}
+
+func compile(p *ber.Packet) (*filter, error) {
+	f := &filter{op: p.Tag}
+
+	switch p.Tag {
+	case ldap.FilterAnd, ldap.FilterOr, ldap.FilterNot:
+		for _, c := range p.Children {
+			sub, err := compile(c)
+			if err != nil {
+				return nil, err
+			}
+			f.sub = append(f.sub, sub)
+		}
+		if p.Tag == ldap.FilterNot && len(f.sub) != 1 {
+			return nil, fmt.Errorf("invalid not filter")
+		}
+
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		if len(p.Children) != 2 {
+			return nil, fmt.Errorf("invalid %s filter", ldap.FilterMap[uint64(p.Tag)])
+		}
+		f.attr = attrName(ber.DecodeString(p.Children[0].Data.Bytes()))
+		f.value = normalize(f.attr, ber.DecodeString(p.Children[1].Data.Bytes()))
+
+	case ldap.FilterPresent:
+		f.attr = attrName(ber.DecodeString(p.Data.Bytes()))
+
+	case ldap.FilterSubstrings:
+		if len(p.Children) != 2 {
+			return nil, fmt.Errorf("invalid substrings filter")
+		}
+		f.attr = attrName(ber.DecodeString(p.Children[0].Data.Bytes()))
+		for _, c := range p.Children[1].Children {
+			s := normalize(f.attr, ber.DecodeString(c.Data.Bytes()))
+			switch c.Tag {
+			case ldap.FilterSubstringsInitial:
+				f.initial = s
+			case ldap.FilterSubstringsAny:
+				f.any = append(f.any, s)
+			case ldap.FilterSubstringsFinal:
+				f.final = s
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported %s filter", ldap.FilterMap[uint64(p.Tag)])
+	}
+	return f, nil
+}
+
+// match reports whether the entry matches the filter, attrs are keyed by lower case names
+func (f *filter) match(attrs map[string][]string) bool {
+	switch f.op {
+	case ldap.FilterAnd:
+		for _, sub := range f.sub {
+			if !sub.match(attrs) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, sub := range f.sub {
+			if sub.match(attrs) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !f.sub[0].match(attrs)
+	case ldap.FilterPresent:
+		return len(attrs[f.attr]) > 0
+	}
+
+	for _, v := range attrs[f.attr] {
+		if f.matchValue(normalize(f.attr, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *filter) matchValue(v string) bool {
+	switch f.op {
+	case ldap.FilterGreaterOrEqual:
+		return v >= f.value
+	case ldap.FilterLessOrEqual:
+		return v <= f.value
+	case ldap.FilterSubstrings:
+		if !strings.HasPrefix(v, f.initial) {
+			return false
+		}
+		v = v[len(f.initial):]
+		for _, s := range f.any {
+			i := strings.Index(v, s)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(s):]
+		}
+		return strings.HasSuffix(v, f.final)
+	}
+	// equality and approx
+	return v == f.value
+}
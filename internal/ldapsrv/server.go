@@ -0,0 +1,421 @@
+// Package ldapsrv is the read-only LDAPv3 server of the directory for PBX and legacy apps.
+// Deps are organizationalUnit entries under the base DN and employees are inetOrgPerson entries of the LDIF export.
+// Searches are served by the snapshot of entries loaded once in the generation of the storage.
+package ldapsrv
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/jimlambrt/gldap"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/export"
+)
+
+type Config struct {
+	Listen    string        `name:"listen" json:"listen" help:"Listen address of the read-only LDAP server, e.g. :3389. The server is disabled if empty"`
+	BaseDN    string        `name:"base-dn" json:"base-dn" default:"dc=kbemp" help:"Base DN of units of sections and employees"`
+	SizeLimit int           `name:"size-limit" json:"size-limit" default:"500" help:"Max number of entries of the search, 0 is unlimited"`
+	Timeout   time.Duration `name:"timeout" json:"timeout" default:"10s" help:"Timeout of storage queries of the search"`
+	MaxAge    time.Duration `name:"max-age" json:"max-age" default:"5m" help:"Max age of the snapshot of the directory, it's reloaded by changes of the storage anyway"`
+}
+
+// Source is the storage of the server, the generation of the storage is changed by every change of data
+type Source interface {
+	export.Source
+	Generation(ctx context.Context) (int64, error)
+}
+
+// Server is the LDAP server of deps and employees of the storage.
+// Only anonymous binds are accepted like requests of the gRPC API, modifications are refused.
+type Server struct {
+	cfg  Config
+	src  Source
+	lg   *slog.Logger
+	base *ldap.DN
+
+	mu   sync.Mutex
+	snap *snapshot
+	// loadMu serializes loads, so concurrent searches load the snapshot once
+	loadMu sync.Mutex
+
+	srv *gldap.Server
+}
+
+// New creates the server of the storage
+func New(cfg Config, src Source, lg *slog.Logger) (*Server, error) {
+	base, err := ldap.ParseDN(cfg.BaseDN)
+	if err != nil || len(base.RDNs) == 0 {
+		return nil, fmt.Errorf("invalid base DN %q: %w", cfg.BaseDN, err)
+	}
+
+	s := &Server{cfg: cfg, src: src, lg: lg, base: base}
+
+	mux, err := gldap.NewMux()
+	if err != nil {
+		return nil, err
+	}
+	if err = mux.Bind(s.bind); err != nil {
+		return nil, err
+	}
+	if err = mux.Search(s.search); err != nil {
+		return nil, err
+	}
+	// unbind has no response
+	if err = mux.Unbind(func(*gldap.ResponseWriter, *gldap.Request) {}); err != nil {
+		return nil, err
+	}
+	if err = mux.Modify(readOnly(gldap.ApplicationModifyResponse)); err != nil {
+		return nil, err
+	}
+	if err = mux.Add(readOnly(gldap.ApplicationAddResponse)); err != nil {
+		return nil, err
+	}
+	if err = mux.Delete(readOnly(gldap.ApplicationDelResponse)); err != nil {
+		return nil, err
+	}
+
+	// errors of connections are logged by the server
+	hlg := hclog.FromStandardLogger(slog.NewLogLogger(lg.Handler(), slog.LevelError), &hclog.LoggerOptions{Level: hclog.Error})
+	if s.srv, err = gldap.NewServer(gldap.WithLogger(hlg)); err != nil {
+		return nil, err
+	}
+	if err = s.srv.Router(mux); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run listens and serves connections until Stop
+func (s *Server) Run() error {
+	s.lg.Info("Starting LDAP listener on "+s.cfg.Listen, "base_dn", s.cfg.BaseDN)
+	return s.srv.Run(s.cfg.Listen)
+}
+
+// Stop closes the listener and waits for connections
+func (s *Server) Stop() error {
+	return s.srv.Stop()
+}
+
+// Ready reports whether the server listens
+func (s *Server) Ready() bool {
+	return s.srv.Ready()
+}
+
+func (s *Server) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	defer w.Write(resp)
+
+	m, err := r.GetSimpleBindMessage()
+	if err != nil {
+		resp.SetResultCode(gldap.ResultAuthMethodNotSupported)
+		resp.SetDiagnosticMessage("only anonymous simple bind is supported")
+		return
+	}
+	if m.UserName != "" || m.Password != "" {
+		resp.SetResultCode(gldap.ResultInappropriateAuthentication)
+		resp.SetDiagnosticMessage("authentication is not configured, use anonymous bind")
+	}
+}
+
+// readOnly refuses modifications by the response of the operation
+func readOnly(code int) gldap.HandlerFunc {
+	return func(w *gldap.ResponseWriter, r *gldap.Request) {
+		w.Write(r.NewResponse(gldap.WithApplicationCode(code), gldap.WithResponseCode(gldap.ResultUnwillingToPerform),
+			gldap.WithDiagnosticMessage("the directory is read-only")))
+	}
+}
+
+func (s *Server) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	done := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+	defer w.Write(done)
+
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		done.SetResultCode(gldap.ResultProtocolError)
+		done.SetDiagnosticMessage(err.Error())
+		return
+	}
+
+	base, err := ldap.ParseDN(m.BaseDN)
+	if err != nil {
+		done.SetResultCode(gldap.ResultInvalidDNSyntax)
+		done.SetDiagnosticMessage(err.Error())
+		return
+	}
+	if !s.base.EqualFold(base) && !s.base.AncestorOfFold(base) {
+		done.SetResultCode(gldap.ResultNoSuchObject)
+		return
+	}
+
+	f, err := parseFilter(m.Filter)
+	if err != nil {
+		done.SetResultCode(gldap.ResultProtocolError)
+		done.SetDiagnosticMessage(err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	snap, err := s.snapshot(ctx)
+	if err != nil {
+		s.lg.Error("LDAP search", "base_dn", m.BaseDN, "filter", m.Filter, "err", err)
+		done.SetResultCode(gldap.ResultOperationsError)
+		done.SetDiagnosticMessage(err.Error())
+		return
+	}
+	if snap.byDN[dnKey(base)] == nil {
+		done.SetResultCode(gldap.ResultNoSuchObject)
+		return
+	}
+
+	limit := int64(s.cfg.SizeLimit)
+	if m.SizeLimit > 0 && (limit == 0 || m.SizeLimit < limit) {
+		limit = m.SizeLimit
+	}
+
+	n := int64(0)
+	for _, e := range snap.scope(base, m.Scope) {
+		if !f.match(e.attrs) {
+			continue
+		}
+		if limit > 0 && n == limit {
+			done.SetResultCode(gldap.ResultSizeLimitExceeded)
+			return
+		}
+		w.Write(r.NewSearchResponseEntry(e.raw, gldap.WithAttributes(e.selected(m.Attributes, m.TypesOnly))))
+		n++
+	}
+
+	s.lg.Debug("LDAP search", "base_dn", m.BaseDN, "scope", m.Scope, "filter", m.Filter, "entries", n)
+}
+
+// entry of the directory
+type entry struct {
+	// DN as it's written by the LDIF export
+	raw   string
+	dn    *ldap.DN
+	names []string
+	// values keyed by lower case names
+	attrs map[string][]string
+}
+
+func newEntry(dn string, attrs []export.Attr) (*entry, error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return nil, fmt.Errorf("DN %q: %w", dn, err)
+	}
+
+	e := &entry{raw: dn, dn: parsed, attrs: make(map[string][]string, len(attrs))}
+	for _, a := range attrs {
+		name := strings.ToLower(a.Name)
+		if _, ok := e.attrs[name]; !ok {
+			e.names = append(e.names, a.Name)
+		}
+		e.attrs[name] = append(e.attrs[name], a.Value)
+	}
+	return e, nil
+}
+
+// selected returns requested attributes, all attributes are returned if nothing or * is requested
+func (e *entry) selected(requested []string, typesOnly bool) map[string][]string {
+	all := len(requested) == 0
+	want := map[string]bool{}
+	for _, r := range requested {
+		if r == "*" {
+			all = true
+		}
+		want[attrName(r)] = true
+	}
+
+	attrs := map[string][]string{}
+	for _, name := range e.names {
+		lower := strings.ToLower(name)
+		if !all && !want[attrName(lower)] {
+			continue
+		}
+		attrs[name] = e.attrs[lower]
+		if typesOnly {
+			attrs[name] = nil
+		}
+	}
+	return attrs
+}
+
+// snapshot returns entries of the current generation of the storage, they are loaded on changes
+// of the storage and after MaxAge
+func (s *Server) snapshot(ctx context.Context) (*snapshot, error) {
+	gen, err := s.src.Generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snap := s.current(gen); snap != nil {
+		return snap, nil
+	}
+
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	// the snapshot is loaded by the concurrent search
+	if snap := s.current(gen); snap != nil {
+		return snap, nil
+	}
+
+	snap, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snap.gen = gen
+
+	s.mu.Lock()
+	s.snap = snap
+	s.mu.Unlock()
+	s.lg.Debug("LDAP snapshot loaded", "generation", gen, "entries", len(snap.entries))
+	return snap, nil
+}
+
+// current returns the snapshot of the generation or nil
+func (s *Server) current(gen int64) *snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.snap == nil || s.snap.gen != gen || time.Since(s.snap.loaded) > s.cfg.MaxAge {
+		return nil
+	}
+	return s.snap
+}
+
+// load reads all deps and employees of the storage to the snapshot: the base, units and employees
+func (s *Server) load(ctx context.Context) (*snapshot, error) {
+	deps, err := s.src.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+	if err != nil {
+		return nil, err
+	}
+	sotrs, err := s.src.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE})
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &snapshot{loaded: time.Now(), byDN: map[string]*entry{}, children: map[string][]*entry{}}
+
+	rdn := s.base.RDNs[0].Attributes[0]
+	root, err := newEntry(s.cfg.BaseDN, []export.Attr{{Name: "objectClass", Value: "top"}, {Name: rdn.Type, Value: rdn.Value}})
+	if err != nil {
+		return nil, err
+	}
+	snap.add(root)
+
+	t := newTree(deps)
+	for _, d := range deps {
+		e, err := newEntry(export.UnitDN(t.path(d.Idr), s.cfg.BaseDN), export.UnitAttrs(d.Text))
+		if err != nil {
+			return nil, err
+		}
+		snap.add(e)
+	}
+
+	for _, sotr := range sotrs {
+		if sotr.Tabnum == "" {
+			continue
+		}
+		row := &export.Row{Sotr: sotr, Dep: sotr.ParentId, Path: t.path(sotr.ParentId)}
+		e, err := newEntry(export.PersonDN(row, s.cfg.BaseDN), export.PersonAttrs(row))
+		if err != nil {
+			return nil, err
+		}
+		snap.add(e)
+	}
+	return snap, nil
+}
+
+// snapshot is entries of the directory in the generation of the storage
+type snapshot struct {
+	gen    int64
+	loaded time.Time
+	// the base, units and employees in the order of the LDIF export
+	entries []*entry
+	// entries and children of entries by keys of DNs
+	byDN     map[string]*entry
+	children map[string][]*entry
+}
+
+// add adds the entry, the entry with the same DN is skipped
+func (snap *snapshot) add(e *entry) {
+	key := dnKey(e.dn)
+	if _, ok := snap.byDN[key]; ok {
+		return
+	}
+	snap.byDN[key] = e
+	snap.entries = append(snap.entries, e)
+
+	if len(e.dn.RDNs) > 1 {
+		parent := dnKey(&ldap.DN{RDNs: e.dn.RDNs[1:]})
+		snap.children[parent] = append(snap.children[parent], e)
+	}
+}
+
+// scope returns entries in the scope of the search from the base DN
+func (snap *snapshot) scope(base *ldap.DN, scope gldap.Scope) []*entry {
+	switch scope {
+	case gldap.BaseObject:
+		return []*entry{snap.byDN[dnKey(base)]}
+	case gldap.SingleLevel:
+		return snap.children[dnKey(base)]
+	}
+	if base.EqualFold(snap.entries[0].dn) {
+		return snap.entries
+	}
+
+	var entries []*entry
+	for _, e := range snap.entries {
+		if base.EqualFold(e.dn) || base.AncestorOfFold(e.dn) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// dnKey returns the key of the DN, DNs are compared case-insensitively
+func dnKey(dn *ldap.DN) string {
+	return strings.ToLower(dn.String())
+}
+
+// tree of deps
+type tree struct {
+	deps  map[string]*kbv1.Dep
+	paths map[string][]string
+}
+
+func newTree(deps []*kbv1.Dep) *tree {
+	t := &tree{deps: make(map[string]*kbv1.Dep, len(deps)), paths: map[string][]string{}}
+	for _, d := range deps {
+		t.deps[d.Idr] = d
+	}
+	return t
+}
+
+// path returns names of deps from the top dep to the dep, the root section is not a dep
+func (t *tree) path(idr string) []string {
+	if p, ok := t.paths[idr]; ok {
+		return p
+	}
+
+	var path []string
+	seen := map[string]struct{}{}
+	for d, ok := t.deps[idr]; ok; d, ok = t.deps[d.Parent] {
+		if _, loop := seen[d.Idr]; loop {
+			break
+		}
+		seen[d.Idr] = struct{}{}
+		path = append([]string{d.Text}, path...)
+	}
+
+	t.paths[idr] = path
+	return path
+}
@@ -0,0 +1,213 @@
+package ldapsrv
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/mem"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	baseDN = "dc=example,dc=com"
+	depDN  = "ou=Департамент финансовых институтов," + baseDN
+)
+
+func newStore(t *testing.T) *mem.MemStore {
+	t.Helper()
+	ctx := context.Background()
+
+	store, err := mem.New("", slog.Default())
+	require.NoError(t, err)
+
+	deps := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(t, "dep.json", deps)
+	for _, d := range deps.Deps {
+		_, err = store.Save(ctx, d)
+		require.NoError(t, err)
+	}
+	// the mem store is flushed after deps and after employees
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	sotrs := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(t, "sotr.json", sotrs)
+	for _, s := range sotrs.Sotrs {
+		_, err = store.Save(ctx, s)
+		require.NoError(t, err)
+	}
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	return store
+}
+
+// source is the storage of the server with the generation changed by tests, loads of all deps are counted
+type source struct {
+	*mem.MemStore
+	gen   atomic.Int64
+	loads atomic.Int32
+}
+
+func (s *source) Generation(context.Context) (int64, error) {
+	return s.gen.Load(), nil
+}
+
+func (s *source) GetDepsBy(ctx context.Context, q *kbv1.DepRequest) ([]*kbv1.Dep, error) {
+	if q.Field == kbv1.DepRequest_NONE {
+		s.loads.Add(1)
+	}
+	return s.MemStore.GetDepsBy(ctx, q)
+}
+
+// dial starts the server on the free port and connects to it by the anonymous bind
+func dial(t *testing.T, sizeLimit int) (*ldap.Conn, *source) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	src := &source{MemStore: newStore(t)}
+	srv, err := New(Config{Listen: addr, BaseDN: baseDN, SizeLimit: sizeLimit, Timeout: time.Second, MaxAge: time.Minute}, src, slog.Default())
+	require.NoError(t, err)
+	go srv.Run()
+	t.Cleanup(func() { srv.Stop() })
+
+	var conn *ldap.Conn
+	require.Eventually(t, func() bool {
+		conn, err = ldap.DialURL("ldap://" + addr)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	t.Cleanup(func() { conn.Close() })
+
+	require.NoError(t, conn.UnauthenticatedBind(""))
+	return conn, src
+}
+
+func search(t *testing.T, conn *ldap.Conn, base string, scope int, filter string, attrs ...string) []*ldap.Entry {
+	t.Helper()
+
+	res, err := conn.Search(ldap.NewSearchRequest(base, scope, ldap.NeverDerefAliases, 0, 0, false, filter, attrs, nil))
+	require.NoError(t, err)
+	return res.Entries
+}
+
+func dns(entries []*ldap.Entry) (dns []string) {
+	for _, e := range entries {
+		dns = append(dns, e.DN)
+	}
+	return
+}
+
+func TestSearch(t *testing.T) {
+	conn, _ := dial(t, 0)
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{"cn", "(cn=Са44444 Асемгуль Абатовна)", []string{"60609"}},
+		{"cn case", "(cn=са44444 асемгуль абатовна)", []string{"60609"}},
+		{"sn", "(sn=Пал4444)", []string{"2681"}},
+		{"phone", "(telephoneNumber=4001052)", []string{"1600"}},
+		{"phone substring", "(telephoneNumber=*99-91)", []string{"1122"}},
+		{"mobile", "(mobile=+7 701 000 6700)", []string{"60609"}},
+		{"mail", "(mail=MARI@k.kom)", []string{"1122"}},
+		{"ou", "(&(objectClass=inetOrgPerson)(ou=Отдел э*))", []string{"2681", "25301"}},
+		{"or", "(|(uid=1600)(employeeNumber=52957))", []string{"1600", "52957"}},
+		{"not", "(&(objectClass=person)(!(mail=*@k.kom)))", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var uids []string
+			for _, e := range search(t, conn, baseDN, ldap.ScopeWholeSubtree, tt.filter, "uid") {
+				uids = append(uids, e.GetAttributeValue("uid"))
+			}
+			assert.ElementsMatch(t, tt.want, uids)
+		})
+	}
+}
+
+func TestSearchTree(t *testing.T) {
+	conn, _ := dial(t, 0)
+
+	// units of the top dep
+	entries := search(t, conn, depDN, ldap.ScopeSingleLevel, "(objectClass=organizationalUnit)", "ou")
+	assert.ElementsMatch(t, []string{
+		"ou=Управление финансовых институтов," + depDN,
+		"ou=Управление по Работе с Рынками Капитала," + depDN,
+		"ou=Отдел экспортно-импортных операций," + depDN,
+	}, dns(entries))
+
+	// employees of the dep
+	entries = search(t, conn, depDN, ldap.ScopeSingleLevel, "(objectClass=inetOrgPerson)")
+	assert.Equal(t, []string{"uid=1600," + depDN}, dns(entries))
+
+	// the employee with requested attributes
+	dn := "uid=60609,ou=Отдел корреспондентских отношений,ou=Управление финансовых институтов," + depDN
+	entries = search(t, conn, dn, ldap.ScopeBaseObject, "(objectClass=*)", "cn", "mobile")
+	require.Len(t, entries, 1)
+	assert.Equal(t, dn, entries[0].DN)
+	assert.Equal(t, "Са44444 Асемгуль Абатовна", entries[0].GetAttributeValue("cn"))
+	assert.Equal(t, []string{"+7 (701) 0006700", "+7 (701) 0006701"}, entries[0].GetAttributeValues("mobile"))
+	assert.Empty(t, entries[0].GetAttributeValue("mail"))
+
+	// the base entry
+	entries = search(t, conn, baseDN, ldap.ScopeBaseObject, "(objectClass=*)")
+	require.Len(t, entries, 1)
+	assert.Equal(t, "example", entries[0].GetAttributeValue("dc"))
+}
+
+func TestSearchErrors(t *testing.T) {
+	conn, _ := dial(t, 2)
+
+	res, err := conn.Search(ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=inetOrgPerson)", nil, nil))
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded), err)
+	assert.Len(t, res.Entries, 2)
+
+	for _, base := range []string{"dc=other", "ou=Нет," + baseDN, "uid=404," + depDN} {
+		_, err = conn.Search(ldap.NewSearchRequest(base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			"(objectClass=*)", nil, nil))
+		assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject), "%s: %v", base, err)
+	}
+
+	// the directory is read-only without authentication
+	err = conn.Bind("cn=admin,"+baseDN, "secret")
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultInappropriateAuthentication), err)
+	err = conn.Del(ldap.NewDelRequest("uid=1600,"+depDN, nil))
+	assert.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform), err)
+}
+
+func TestSnapshot(t *testing.T) {
+	conn, src := dial(t, 0)
+	ctx := context.Background()
+
+	// searches are served by the snapshot of the generation
+	for _, filter := range []string{"(cn=Са44*)", "(sn=Пал4444)", "(telephoneNumber=4001052)"} {
+		assert.Len(t, search(t, conn, baseDN, ldap.ScopeWholeSubtree, filter, "uid"), 1, filter)
+	}
+	assert.EqualValues(t, 1, src.loads.Load())
+
+	// changes are found after the change of the generation
+	_, err := src.Update(ctx, &kbv1.UpdateSotrRequest{Sotr: &kbv1.Sotr{Tabnum: "777", Name: "Новый Сотрудник", ParentId: "razd1.27.2935.69"}})
+	require.NoError(t, err)
+	assert.Empty(t, search(t, conn, baseDN, ldap.ScopeWholeSubtree, "(uid=777)", "uid"))
+	src.gen.Add(1)
+	assert.Len(t, search(t, conn, baseDN, ldap.ScopeWholeSubtree, "(uid=777)", "uid"), 1)
+	assert.EqualValues(t, 2, src.loads.Load())
+
+	_, err = parseFilter("(cn:dn:=x)")
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package news
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage"
+)
+
+// Linker links authors of news and comments to employees. The author with the tabnum in the source
+// is linked by one, otherwise the author is found by the full or short name.
+type Linker struct {
+	st storage.Store
+	lg *slog.Logger
+	// tabnums by names of authors, it's empty if the employee is not found or the name is ambiguous
+	tabnums map[string]string
+}
+
+func NewLinker(st storage.Store, lg *slog.Logger) *Linker {
+	return &Linker{
+		st:      st,
+		lg:      lg.With("news", "link"),
+		tabnums: make(map[string]string),
+	}
+}
+
+// Link sets tabnums of authors of the news and its comments.
+// It returns numbers of linked and all authors.
+func (l *Linker) Link(ctx context.Context, n *kbv1.News) (linked, total int) {
+	link := func(author string, tabnum *string) {
+		if author == "" && *tabnum == "" {
+			return
+		}
+		total++
+
+		if *tabnum == "" {
+			*tabnum = l.tabnum(ctx, author)
+		}
+		if *tabnum != "" {
+			linked++
+		}
+	}
+
+	link(n.Author, &n.AuthorTabnum)
+	for _, c := range n.Comments {
+		link(c.Author, &c.AuthorTabnum)
+	}
+	return
+}
+
+func (l *Linker) tabnum(ctx context.Context, author string) string {
+	name := strings.Join(strings.Fields(author), " ")
+	if tabnum, ok := l.tabnums[name]; ok {
+		return tabnum
+	}
+
+	sotrs, err := l.st.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_FIO, Str: name})
+	if err != nil {
+		l.lg.Debug("Find author", "author", name, "err", err)
+	}
+
+	tabnum := ""
+	if len(sotrs) == 1 {
+		tabnum = sotrs[0].Tabnum
+	} else if len(sotrs) > 1 {
+		l.lg.Warn("Author is ambiguous", "author", name, "employees", len(sotrs))
+	}
+
+	l.tabnums[name] = tabnum
+	return tabnum
+}
@@ -0,0 +1,135 @@
+// Package news scrapes news of the intranet and their comments by the pool of workers
+// and links authors to employees.
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	httpclient "github.com/mioxin/kbempgo/internal/http_client"
+	"github.com/mioxin/kbempgo/internal/parser"
+	"github.com/mioxin/kbempgo/internal/worker"
+	"golang.org/x/sync/errgroup"
+)
+
+type Config struct {
+	worker.Config
+	Workers int
+	// Pages of the news list got from the first one
+	Pages int
+	// Profile of news pages, the built-in one by default
+	Profile string
+	Debug   int
+
+	Lg *slog.Logger
+}
+
+// Scrape gets news of pages of the news list and pages of news with comments.
+// News whose pages are not got are skipped, so saved news are not replaced by incomplete ones.
+func Scrape(ctx context.Context, cfg *Config) (news []*kbv1.News, err error) {
+	if cfg.UrlNews == "" || cfg.UrlNewsItem == "" {
+		return nil, errors.New("urls of news list and news are not set")
+	}
+
+	prs, err := parser.LoadNews(cfg.Profile)
+	if err != nil {
+		return
+	}
+	cfg.Lg.Info("News profile", "profile", prs.Profile())
+
+	// fixtures are shared by workers for replaying responses in the recorded order
+	if cfg.Fixtures == nil {
+		if cfg.Fixtures, err = httpclient.OpenFixtures(cfg.Fixture); err != nil {
+			return
+		}
+	}
+
+	limiters := worker.NewLimiters(&cfg.Config)
+	pool := make([]*worker.Worker, max(cfg.Workers, 1))
+	for i := range pool {
+		pool[i] = worker.NewWorker(&cfg.Config, fmt.Sprintf("news-%d", i), cfg.Debug, cfg.Lg)
+		pool[i].Limiters = limiters
+		pool[i].NewsParser = prs
+	}
+
+	list, err := getList(ctx, pool[0], cfg.Pages, cfg.Lg)
+	if err != nil {
+		return
+	}
+
+	// pages of news are got by the pool
+	got := make([]bool, len(list))
+	idx := make(chan int)
+	eg, ctxEg := errgroup.WithContext(ctx)
+	for _, w := range pool {
+		eg.Go(func() error {
+			for i := range idx {
+				if e := w.GetNews(ctxEg, list[i]); e != nil {
+					cfg.Lg.Error("News is skipped", "idn", list[i].Idn, "err", e)
+					continue
+				}
+				got[i] = true
+			}
+			return nil
+		})
+	}
+
+LOOP:
+	for i := range list {
+		select {
+		case idx <- i:
+		case <-ctxEg.Done():
+			break LOOP
+		}
+	}
+	close(idx)
+	eg.Wait()
+
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, n := range list {
+		if got[i] {
+			news = append(news, n)
+		}
+	}
+
+	var requests, errs int64
+	for _, w := range pool {
+		requests += w.Stats.Requests.Load()
+		errs += w.Stats.Errors.Load()
+	}
+	cfg.Lg.Info("News scraped", "news", len(news), "skipped", len(list)-len(news), "requests", requests, "errors", errs)
+	return
+}
+
+// getList gets news of pages of the news list in order. Pages are finished by the page without new news.
+func getList(ctx context.Context, w *worker.Worker, pages int, lg *slog.Logger) (list []*kbv1.News, err error) {
+	seen := make(map[string]bool)
+
+	for page := 1; page <= pages; page++ {
+		news, e := w.GetNewsList(ctx, page)
+		if e != nil {
+			return nil, e
+		}
+
+		added := 0
+		for _, n := range news {
+			if !seen[n.Idn] {
+				seen[n.Idn] = true
+				list = append(list, n)
+				added++
+			}
+		}
+
+		if added == 0 {
+			lg.Debug("News list is finished", "page", page)
+			break
+		}
+	}
+	return
+}
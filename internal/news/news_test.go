@@ -0,0 +1,136 @@
+package news
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/mem"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/mioxin/kbempgo/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newsServer serves the list of news by any page, so the list is finished by the second page
+func newsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/news/list", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/list_1.html")
+	})
+	mux.HandleFunc("/news/item", func(w http.ResponseWriter, r *http.Request) {
+		switch id := r.URL.Query().Get("id"); id {
+		case "101", "102":
+			http.ServeFile(w, r, "testdata/news_"+id+".html")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newsConfig(url string) *Config {
+	return &Config{
+		Config: worker.Config{
+			KbUrl:          url,
+			UrlNews:        "/news/list?page=",
+			UrlNewsItem:    "/news/item?id=",
+			HttpReqTimeout: 5 * time.Second,
+		},
+		Workers: 2,
+		Pages:   3,
+		Lg:      slog.Default(),
+	}
+}
+
+func TestScrape(t *testing.T) {
+	srv := newsServer(t)
+
+	news, err := Scrape(context.Background(), newsConfig(srv.URL))
+	require.NoError(t, err)
+	require.Len(t, news, 2)
+
+	n := news[0]
+	assert.Equal(t, "101", n.Idn)
+	assert.Equal(t, "Новый офис открыт", n.Title)
+	assert.Equal(t, "Пал4444 Юлия", n.Author)
+	assert.Equal(t, "2681", n.AuthorTabnum)
+	assert.Equal(t, "/news/item?id=101", n.Url)
+	assert.Equal(t, time.Date(2025, 3, 12, 10, 30, 0, 0, time.Local), n.Date.AsTime().In(time.Local))
+	require.Len(t, n.Comments, 2)
+	assert.Equal(t, "1", n.Comments[1].ParentIdc)
+
+	assert.Equal(t, "102", news[1].Idn)
+	assert.Empty(t, news[1].Comments)
+}
+
+func TestScrapeSkipsFailedNews(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/news/list", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/list_1.html")
+	})
+	mux.HandleFunc("/news/item", func(w http.ResponseWriter, r *http.Request) {
+		// the news is removed after getting the list
+		if r.URL.Query().Get("id") == "101" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "testdata/news_102.html")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	news, err := Scrape(context.Background(), newsConfig(srv.URL))
+	require.NoError(t, err)
+	require.Len(t, news, 1)
+	assert.Equal(t, "102", news[0].Idn)
+}
+
+func TestLink(t *testing.T) {
+	store, err := mem.New("", slog.Default())
+	require.NoError(t, err)
+
+	deps := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(t, "dep.json", deps)
+	for _, d := range deps.Deps {
+		_, err = store.Save(context.Background(), d)
+		require.NoError(t, err)
+	}
+	// the mem store is flushed after deps and after employees
+	_, err = store.Flush(context.Background(), nil)
+	require.NoError(t, err)
+
+	sotrs := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(t, "sotr.json", sotrs)
+	for _, s := range sotrs.Sotrs {
+		_, err = store.Save(context.Background(), s)
+		require.NoError(t, err)
+	}
+	_, err = store.Flush(context.Background(), nil)
+	require.NoError(t, err)
+
+	news, err := Scrape(context.Background(), newsConfig(newsServer(t).URL))
+	require.NoError(t, err)
+	require.Len(t, news, 2)
+
+	l := NewLinker(store, slog.Default())
+
+	linked, total := l.Link(context.Background(), news[0])
+	assert.Equal(t, 3, linked)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, "63665", news[0].Comments[0].AuthorTabnum, "author of the comment should be found by the name")
+
+	linked, total = l.Link(context.Background(), news[1])
+	assert.Equal(t, 0, linked)
+	assert.Equal(t, 1, total)
+	assert.Empty(t, news[1].AuthorTabnum)
+}
@@ -0,0 +1,182 @@
+// Package orgchart resolves reporting lines of employees.
+// The manager of the employee is manager_tabnum set by Update or the HR import. Otherwise it's the head
+// of the dep of the employee found by the grade, heads report to heads of parent deps.
+package orgchart
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+)
+
+// Config of the inference of managers
+type Config struct {
+	HeadGrades []string `name:"head-grades" json:"head-grades" default:"начальник,руководитель,директор,заведующий,председатель" help:"Words starting grades of heads of deps, managers of employees without manager_tabnum are inferred by them"`
+}
+
+// Source is the part of the storage read by the chart, it's implemented by storage.Store
+type Source interface {
+	GetDepsBy(context.Context, *kbv1.DepRequest) ([]*kbv1.Dep, error)
+	GetSotrsBy(context.Context, *kbv1.SotrRequest) ([]*kbv1.Sotr, error)
+}
+
+// Chart is the snapshot of reporting lines of all employees
+type Chart struct {
+	sotrs map[string]*kbv1.Sotr
+	// managers keyed by tabnums of employees
+	managers map[string]string
+	// reports keyed by tabnums of managers, they are sorted by tabnums
+	reports map[string][]string
+}
+
+// Load reads deps and employees of the storage and resolves their managers
+func Load(ctx context.Context, src Source, cfg Config) (*Chart, error) {
+	deps, err := src.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+	if err != nil {
+		return nil, err
+	}
+	sotrs, err := src.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE})
+	if err != nil {
+		return nil, err
+	}
+	return New(deps, sotrs, cfg), nil
+}
+
+// New resolves managers of employees
+func New(deps []*kbv1.Dep, sotrs []*kbv1.Sotr, cfg Config) *Chart {
+	parents := make(map[string]string, len(deps))
+	for _, d := range deps {
+		parents[d.Idr] = d.Parent
+	}
+
+	c := &Chart{
+		sotrs:    make(map[string]*kbv1.Sotr, len(sotrs)),
+		managers: make(map[string]string, len(sotrs)),
+		reports:  map[string][]string{},
+	}
+	// the latest row of the tabnum is the actual one
+	for _, s := range sotrs {
+		if s.Tabnum != "" {
+			c.sotrs[s.Tabnum] = s
+		}
+	}
+
+	tabnums := make([]string, 0, len(c.sotrs))
+	for tabnum := range c.sotrs {
+		tabnums = append(tabnums, tabnum)
+	}
+	slices.Sort(tabnums)
+
+	// the first head of the dep by tabnum
+	heads := map[string]string{}
+	for _, tabnum := range tabnums {
+		s := c.sotrs[tabnum]
+		if _, ok := heads[s.ParentId]; !ok && isHead(s.Grade, cfg.HeadGrades) {
+			heads[s.ParentId] = tabnum
+		}
+	}
+
+	for _, tabnum := range tabnums {
+		m := c.manager(c.sotrs[tabnum], parents, heads)
+		if m == "" {
+			continue
+		}
+		c.managers[tabnum] = m
+		c.reports[m] = append(c.reports[m], tabnum)
+	}
+	return c
+}
+
+// isHead reports whether the grade starts with the word of grades of heads
+func isHead(grade string, headGrades []string) bool {
+	words := strings.Fields(strings.ToLower(grade))
+	if len(words) == 0 {
+		return false
+	}
+	for _, h := range headGrades {
+		if words[0] == strings.ToLower(strings.TrimSpace(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// manager returns the tabnum of the manager of the employee or empty string if there is no manager
+func (c *Chart) manager(s *kbv1.Sotr, parents, heads map[string]string) string {
+	if _, ok := c.sotrs[s.ManagerTabnum]; ok && s.ManagerTabnum != s.Tabnum {
+		return s.ManagerTabnum
+	}
+
+	seen := map[string]struct{}{}
+	for idr := s.ParentId; idr != ""; idr = parents[idr] {
+		if _, loop := seen[idr]; loop {
+			break
+		}
+		seen[idr] = struct{}{}
+
+		if h, ok := heads[idr]; ok && h != s.Tabnum {
+			return h
+		}
+		// the root section is not a dep
+		if _, ok := parents[idr]; !ok {
+			break
+		}
+	}
+	return ""
+}
+
+// Sotr returns the employee by tabnum
+func (c *Chart) Sotr(tabnum string) (*kbv1.Sotr, bool) {
+	s, ok := c.sotrs[tabnum]
+	return s, ok
+}
+
+// Manager returns the manager of the employee
+func (c *Chart) Manager(tabnum string) (*kbv1.Sotr, bool) {
+	m, ok := c.managers[tabnum]
+	if !ok {
+		return nil, false
+	}
+	return c.sotrs[m], true
+}
+
+// Reports returns employees of the manager sorted by tabnums, reports of all levels if recursive.
+// Reports of the lower level follow reports of the upper one.
+func (c *Chart) Reports(tabnum string, recursive bool) (sotrs []*kbv1.Sotr) {
+	seen := map[string]struct{}{tabnum: {}}
+	level := []string{tabnum}
+	for len(level) > 0 {
+		var next []string
+		for _, m := range level {
+			for _, r := range c.reports[m] {
+				if _, ok := seen[r]; ok {
+					continue
+				}
+				seen[r] = struct{}{}
+				sotrs = append(sotrs, c.sotrs[r])
+				next = append(next, r)
+			}
+		}
+		if !recursive {
+			break
+		}
+		level = next
+	}
+	return
+}
+
+// ManagerChain returns managers of the employee from the direct manager to the top one
+func (c *Chart) ManagerChain(tabnum string) (sotrs []*kbv1.Sotr) {
+	seen := map[string]struct{}{tabnum: {}}
+	for m, ok := c.managers[tabnum]; ok; m, ok = c.managers[m] {
+		// explicit managers may make the loop
+		if _, loop := seen[m]; loop {
+			break
+		}
+		seen[m] = struct{}{}
+		sotrs = append(sotrs, c.sotrs[m])
+	}
+	return
+}
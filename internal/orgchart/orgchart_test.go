@@ -0,0 +1,95 @@
+package orgchart
+
+import (
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var cfg = Config{HeadGrades: []string{"начальник", "директор"}}
+
+func fixtures(t *testing.T) ([]*kbv1.Dep, []*kbv1.Sotr) {
+	t.Helper()
+
+	deps := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(t, "dep.json", deps)
+	sotrs := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(t, "sotr.json", sotrs)
+	return deps.Deps, sotrs.Sotrs
+}
+
+func tabnums(sotrs []*kbv1.Sotr) (tn []string) {
+	for _, s := range sotrs {
+		tn = append(tn, s.Tabnum)
+	}
+	return
+}
+
+func TestInferred(t *testing.T) {
+	deps, sotrs := fixtures(t)
+	c := New(deps, sotrs, cfg)
+
+	tests := []struct {
+		tabnum  string
+		manager string
+	}{
+		// the head of the top dep
+		{"1600", ""},
+		// heads report to heads of parent deps
+		{"63665", "1600"},
+		{"52957", "63665"},
+		// employees report to the head of their dep
+		{"60609", "52957"},
+		{"25301", "2681"},
+	}
+	for _, tt := range tests {
+		m, ok := c.Manager(tt.tabnum)
+		assert.Equal(t, tt.manager != "", ok, tt.tabnum)
+		if ok {
+			assert.Equal(t, tt.manager, m.Tabnum, tt.tabnum)
+		}
+	}
+
+	assert.Equal(t, []string{"52957", "63665", "1600"}, tabnums(c.ManagerChain("60609")))
+	assert.Empty(t, c.ManagerChain("1600"))
+
+	assert.Equal(t, []string{"1122", "2681", "63665"}, tabnums(c.Reports("1600", false)))
+	assert.Equal(t, []string{"1122", "2681", "63665", "25301", "52957", "60609"}, tabnums(c.Reports("1600", true)))
+	assert.Empty(t, c.Reports("60609", true))
+}
+
+func TestExplicit(t *testing.T) {
+	deps, sotrs := fixtures(t)
+	for _, s := range sotrs {
+		switch s.Tabnum {
+		case "60609":
+			s.ManagerTabnum = "1600"
+		case "25301":
+			// unknown managers are ignored
+			s.ManagerTabnum = "404"
+		case "1600":
+			// the loop of explicit managers
+			s.ManagerTabnum = "52957"
+		}
+	}
+	c := New(deps, sotrs, cfg)
+
+	m, ok := c.Manager("25301")
+	require.True(t, ok)
+	assert.Equal(t, "2681", m.Tabnum)
+
+	assert.Equal(t, []string{"1600", "52957", "63665"}, tabnums(c.ManagerChain("60609")))
+	assert.Equal(t, []string{"1122", "2681", "60609", "63665"}, tabnums(c.Reports("1600", false)))
+	assert.Contains(t, tabnums(c.Reports("52957", false)), "1600")
+}
+
+func TestIsHead(t *testing.T) {
+	heads := []string{"Начальник", "руководитель"}
+	assert.True(t, isHead("Начальник Отдела", heads))
+	assert.True(t, isHead(" руководитель  службы", heads))
+	assert.False(t, isHead("Заместитель начальника", heads))
+	assert.False(t, isHead("", heads))
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+)
+
+// coverageFields are fields of the employee counted by the coverage
+var coverageFields = []string{"tabnum", "name", "mid_name", "phone", "mobile", "email", "avatar", "grade"}
+
+// Coverage counts filled fields of parsed employees. Comparing with the coverage of the previous run
+// reveals fields went empty after changes of the markup.
+type Coverage struct {
+	mu      sync.Mutex
+	Profile string         `json:"profile"`
+	Sotrs   int            `json:"sotrs"`
+	Filled  map[string]int `json:"filled"`
+}
+
+func NewCoverage(profile string) *Coverage {
+	return &Coverage{Profile: profile, Filled: make(map[string]int)}
+}
+
+// LoadCoverage reads the coverage saved by the previous run, it returns nil if the file doesn't exist
+func LoadCoverage(path string) (c *Coverage, err error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load coverage: %w", err)
+	}
+
+	c = &Coverage{}
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("load coverage %s: %w", path, err)
+	}
+	return
+}
+
+// Add counts filled fields of the employee
+func (c *Coverage) Add(sotr *kbv1.Sotr) {
+	values := map[string]bool{
+		"tabnum":   sotr.Tabnum != "",
+		"name":     sotr.Name != "",
+		"mid_name": sotr.MidName != "",
+		"phone":    len(sotr.Phone) > 0,
+		"mobile":   len(sotr.Mobile) > 0,
+		"email":    sotr.Email != "",
+		"avatar":   sotr.Avatar != "",
+		"grade":    sotr.Grade != "",
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Sotrs++
+	for f, ok := range values {
+		if ok {
+			c.Filled[f]++
+		}
+	}
+}
+
+// Empty returns fields filled in the previous coverage and empty in this one
+func (c *Coverage) Empty(prev *Coverage) (fields []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if prev == nil || c.Sotrs == 0 {
+		return
+	}
+
+	for _, f := range coverageFields {
+		if prev.Filled[f] > 0 && c.Filled[f] == 0 {
+			fields = append(fields, f)
+		}
+	}
+	return
+}
+
+// Save writes the coverage for comparing by the next run
+func (c *Coverage) Save(path string) error {
+	c.mu.Lock()
+	b, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("save coverage: %w", err)
+	}
+
+	if err = os.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("save coverage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package parser
+
+import (
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/utils"
+)
+
+// Index is the legacy parser searching fixed fragments of html by string index
+type Index struct {
+	Name    string
+	Version int
+}
+
+func (p *Index) Profile() string {
+	return profileName(p.Name, p.Version)
+}
+
+func (p *Index) ParseSotr(unescaped string) *kbv1.Sotr {
+	return utils.ParseSotr(unescaped)
+}
+
+func (p *Index) ParseMidName(sotr *kbv1.Sotr, unescaped string) string {
+	return utils.ParseMidName(sotr, unescaped)
+}
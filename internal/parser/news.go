@@ -0,0 +1,234 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/goccy/go-yaml"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultNewsProfile is the name of the built-in profile of news pages
+const DefaultNewsProfile = "news"
+
+// NewsProfile is YAML of the profile of news pages. Selectors are optional except items of the list and ids.
+type NewsProfile struct {
+	Name    string `yaml:"name"`
+	Version int    `yaml:"version"`
+	// DateLayouts are layouts of dates of the source in Go format, dates are in the local time
+	DateLayouts []string    `yaml:"date_layouts"`
+	List        ListRule    `yaml:"list"`
+	News        NewsRule    `yaml:"news"`
+	Comment     CommentRule `yaml:"comment"`
+}
+
+// ListRule finds news in the page of the news list
+type ListRule struct {
+	// Item selects every news
+	Item         string    `yaml:"item"`
+	Idn          *Selector `yaml:"idn"`
+	Title        *Selector `yaml:"title"`
+	Author       *Selector `yaml:"author"`
+	AuthorTabnum *Selector `yaml:"author_tabnum"`
+	Date         *Selector `yaml:"date"`
+
+	item cascadia.Selector
+}
+
+// NewsRule extracts fields of the page of the news
+type NewsRule struct {
+	Title        *Selector `yaml:"title"`
+	Author       *Selector `yaml:"author"`
+	AuthorTabnum *Selector `yaml:"author_tabnum"`
+	Date         *Selector `yaml:"date"`
+	Body         *Selector `yaml:"body"`
+}
+
+// CommentRule finds comments in the page of the news or in the page of comments
+type CommentRule struct {
+	// Item selects every comment
+	Item         string    `yaml:"item"`
+	Idc          *Selector `yaml:"idc"`
+	ParentIdc    *Selector `yaml:"parent_idc"`
+	Author       *Selector `yaml:"author"`
+	AuthorTabnum *Selector `yaml:"author_tabnum"`
+	Date         *Selector `yaml:"date"`
+	Text         *Selector `yaml:"text"`
+
+	item cascadia.Selector
+}
+
+// News parses news and comments by CSS selectors of the profile
+type News struct {
+	prof *NewsProfile
+}
+
+// LoadNews loads the built-in profile of news pages by name or the profile from YAML file
+func LoadNews(profile string) (p *News, err error) {
+	if profile == "" {
+		profile = DefaultNewsProfile
+	}
+
+	b, err := builtin.ReadFile("profiles/news/" + profile + ".yaml")
+	if err != nil {
+		if b, err = os.ReadFile(profile); err != nil {
+			return nil, fmt.Errorf("load news profile %s: %w", profile, err)
+		}
+	}
+
+	return ParseNews(b)
+}
+
+// ParseNews creates the news parser from YAML of the profile
+func ParseNews(b []byte) (p *News, err error) {
+	prof := &NewsProfile{}
+	if err = yaml.Unmarshal(b, prof); err != nil {
+		return nil, fmt.Errorf("parse news profile: %w", err)
+	}
+
+	if prof.Name == "" {
+		return nil, errors.New("parse news profile: name is not set")
+	}
+	if prof.List.Item == "" || prof.List.Idn == nil {
+		return nil, fmt.Errorf("news profile %s: list item and idn are required", prof.Name)
+	}
+	if prof.List.item, err = cascadia.Compile(prof.List.Item); err != nil {
+		return nil, fmt.Errorf("news profile %s: list item: %w", prof.Name, err)
+	}
+	if prof.Comment.Item != "" {
+		if prof.Comment.item, err = cascadia.Compile(prof.Comment.Item); err != nil {
+			return nil, fmt.Errorf("news profile %s: comment item: %w", prof.Name, err)
+		}
+	}
+
+	for name, sel := range map[string]*Selector{
+		"list idn": prof.List.Idn, "list title": prof.List.Title, "list author": prof.List.Author,
+		"list author_tabnum": prof.List.AuthorTabnum, "list date": prof.List.Date,
+		"news title": prof.News.Title, "news author": prof.News.Author, "news author_tabnum": prof.News.AuthorTabnum,
+		"news date": prof.News.Date, "news body": prof.News.Body,
+		"comment idc": prof.Comment.Idc, "comment parent_idc": prof.Comment.ParentIdc, "comment author": prof.Comment.Author,
+		"comment author_tabnum": prof.Comment.AuthorTabnum, "comment date": prof.Comment.Date, "comment text": prof.Comment.Text,
+	} {
+		if sel == nil {
+			continue
+		}
+		if err = sel.compile(); err != nil {
+			return nil, fmt.Errorf("news profile %s: %s: %w", prof.Name, name, err)
+		}
+	}
+
+	return &News{prof: prof}, nil
+}
+
+func (p *News) Profile() string {
+	return profileName(p.prof.Name, p.prof.Version)
+}
+
+// ParseList parses news of the unescaped page of the news list, news without id are skipped
+func (p *News) ParseList(unescaped string) (news []*kbv1.News) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(unescaped))
+	if err != nil {
+		return nil
+	}
+
+	l := &p.prof.List
+	doc.FindMatcher(l.item).Each(func(_ int, s *goquery.Selection) {
+		n := &kbv1.News{
+			Idn:          l.Idn.value(s),
+			Title:        opt(l.Title, s),
+			Author:       opt(l.Author, s),
+			AuthorTabnum: opt(l.AuthorTabnum, s),
+			Date:         p.date(opt(l.Date, s)),
+		}
+		if n.Idn != "" {
+			news = append(news, n)
+		}
+	})
+	return
+}
+
+// ParseNews fills the news by the unescaped page of the news, fields of the list are kept if the page has no ones.
+// Comments are parsed if the page has them.
+func (p *News) ParseNews(n *kbv1.News, unescaped string) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(unescaped))
+	if err != nil {
+		return
+	}
+
+	r := &p.prof.News
+	set := func(field *string, sel *Selector) {
+		if v := opt(sel, doc.Selection); v != "" {
+			*field = v
+		}
+	}
+	set(&n.Title, r.Title)
+	set(&n.Author, r.Author)
+	set(&n.AuthorTabnum, r.AuthorTabnum)
+	set(&n.Body, r.Body)
+	if d := p.date(opt(r.Date, doc.Selection)); d != nil {
+		n.Date = d
+	}
+
+	if comments := p.comments(doc); len(comments) > 0 {
+		n.Comments = comments
+	}
+}
+
+// ParseComments parses comments of the unescaped page of comments
+func (p *News) ParseComments(unescaped string) []*kbv1.Comment {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(unescaped))
+	if err != nil {
+		return nil
+	}
+	return p.comments(doc)
+}
+
+// comments without id are skipped
+func (p *News) comments(doc *goquery.Document) (comments []*kbv1.Comment) {
+	c := &p.prof.Comment
+	if c.item == nil || c.Idc == nil {
+		return nil
+	}
+
+	doc.FindMatcher(c.item).Each(func(_ int, s *goquery.Selection) {
+		cm := &kbv1.Comment{
+			Idc:          c.Idc.value(s),
+			ParentIdc:    opt(c.ParentIdc, s),
+			Author:       opt(c.Author, s),
+			AuthorTabnum: opt(c.AuthorTabnum, s),
+			Date:         p.date(opt(c.Date, s)),
+			Text:         opt(c.Text, s),
+		}
+		if cm.Idc != "" {
+			comments = append(comments, cm)
+		}
+	})
+	return
+}
+
+// date parses the date by layouts of the profile, it returns nil if the date is not parsed
+func (p *News) date(v string) *timestamppb.Timestamp {
+	if v == "" {
+		return nil
+	}
+	for _, layout := range p.prof.DateLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return timestamppb.New(t)
+		}
+	}
+	return nil
+}
+
+// opt extracts the value by the optional selector
+func opt(sel *Selector, s *goquery.Selection) string {
+	if sel == nil {
+		return ""
+	}
+	return sel.value(s)
+}
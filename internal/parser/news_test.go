@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewsProfile(t *testing.T) {
+	p, err := LoadNews("")
+	require.NoError(t, err)
+	assert.Equal(t, "news/v1", p.Profile())
+
+	b, err := os.ReadFile("../news/testdata/list_1.html")
+	require.NoError(t, err)
+
+	list := p.ParseList(string(b))
+	require.Len(t, list, 2)
+	assert.Equal(t, "101", list[0].Idn)
+	assert.Equal(t, "Новый офис", list[0].Title)
+	assert.Equal(t, time.Date(2025, 3, 12, 0, 0, 0, 0, time.Local), list[0].Date.AsTime().In(time.Local))
+
+	b, err = os.ReadFile("../news/testdata/news_101.html")
+	require.NoError(t, err)
+
+	n := list[0]
+	p.ParseNews(n, string(b))
+	assert.Equal(t, "Новый офис открыт", n.Title)
+	assert.Equal(t, "Пал4444 Юлия", n.Author)
+	assert.Equal(t, "2681", n.AuthorTabnum)
+	assert.Equal(t, "<p>Офис на <b>Абая 10</b> открыт.</p>", n.Body)
+	assert.Equal(t, time.Date(2025, 3, 12, 10, 30, 0, 0, time.Local), n.Date.AsTime().In(time.Local))
+
+	require.Len(t, n.Comments, 2)
+	assert.Equal(t, &kbv1.Comment{Idc: "1", Author: "Руда4444 Маргарита", Text: "Поздравляем!",
+		Date: n.Comments[0].Date}, n.Comments[0])
+	assert.Equal(t, "1", n.Comments[1].ParentIdc)
+	assert.Equal(t, "2681", n.Comments[1].AuthorTabnum)
+}
+
+func TestParseNewsProfile(t *testing.T) {
+	_, err := ParseNews([]byte("name: x\nversion: 1\n"))
+	assert.Error(t, err, "list item is required")
+
+	_, err = ParseNews([]byte("name: x\nlist:\n  item: div\n  idn:\n    css: '[['\n"))
+	assert.Error(t, err)
+
+	_, err = LoadNews("not-exists.yaml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+// Package parser extracts employees from the html of the source by versioned profiles.
+// A profile is either the legacy string-index parser or a set of CSS selectors loaded from YAML.
+package parser
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+)
+
+// Engines of profiles
+const (
+	EngineIndex = "index"
+	EngineCSS   = "css"
+)
+
+// Config selects the parser profile
+type Config struct {
+	Profile  string `name:"profile" env:"KB_PARSER_PROFILE" default:"index" help:"Parser profile of the source html: name of built-in profile (index, css) or path to YAML file"`
+	Validate string `name:"validate" help:"File of filled fields of the previous run. Fields went empty since the previous run are reported after the dump."`
+}
+
+// Parser extracts employees from the html of the source
+type Parser interface {
+	// Profile returns the name and the version of the profile
+	Profile() string
+	// ParseSotr parses the employee from the unescaped entry of razd
+	ParseSotr(unescaped string) *kbv1.Sotr
+	// ParseMidName finds the middle name of the employee in the unescaped result of search by name
+	ParseMidName(sotr *kbv1.Sotr, unescaped string) string
+}
+
+//go:embed profiles/*.yaml profiles/news/*.yaml
+var builtin embed.FS
+
+// Load loads the built-in profile by name or the profile from YAML file
+func Load(profile string) (p Parser, err error) {
+	if profile == "" {
+		profile = EngineIndex
+	}
+
+	b, err := builtin.ReadFile("profiles/" + profile + ".yaml")
+	if err != nil {
+		if b, err = os.ReadFile(profile); err != nil {
+			return nil, fmt.Errorf("load parser profile %s: %w", profile, err)
+		}
+	}
+
+	return Parse(b)
+}
+
+// Parse creates the parser from YAML of the profile
+func Parse(b []byte) (p Parser, err error) {
+	prof := &Profile{}
+	if err = yaml.Unmarshal(b, prof); err != nil {
+		return nil, fmt.Errorf("parse parser profile: %w", err)
+	}
+
+	if prof.Name == "" {
+		return nil, errors.New("parse parser profile: name is not set")
+	}
+
+	switch strings.ToLower(prof.Engine) {
+	case EngineIndex:
+		return &Index{Name: prof.Name, Version: prof.Version}, nil
+	case "", EngineCSS:
+		return NewSelectors(prof)
+	default:
+		return nil, fmt.Errorf("parse parser profile %s: unknown engine %q", prof.Name, prof.Engine)
+	}
+}
+
+func profileName(name string, version int) string {
+	return fmt.Sprintf("%s/v%d", name, version)
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sotrText = `<div data-tabnum="1000380"  class="sotr_block">
+  <table cellpadding="0" cellspacing="0" width=100%>
+	  <tr onMouseOver="tr_over(this)"><td>
+		  <table>
+			  <tr>
+				  <td width="35" rowspan="2"><img src="/avatar/1000380.jpg?v=KMS6TdPNde" width=30></td>
+				  <td width="300" class="s_1">ГасХХХХХХ Ольга<span class="s_1_1"></span> <span class="s_1_2"></span></td>
+				  <td width="200" class="s_2"><span class="s_3">вн</span> <b>400-11-27</b></td>
+				  <td width="130" class="s_2">+7 (701) 872-11-11,+7 (701) 911-01-11</td>
+				  <td width="300" class="s_2"><a href="mailto:Olga.Gasxxxxxx@xxxxx.kz" class="ln7">Olga.Gasxxxxxx@xxxxx.kz</a></td>
+			  </tr>
+			  <tr>
+				  <td colspan="4"class="s_4">Главный бухгалтер</td>
+			  </tr>
+		  </table>
+	  </td></tr>
+  </table>
+</div>`
+
+	midNameText = `<div class=sotr_td3 onclick="searchG('Палий Юлия Андреевна', 'sotrSearchList');">
+	<table><tr><td rowspan="2"><img alt="" src="/avatar/2680.jpg?v=1" /></td></tr></table>
+</div><div class=sotr_td3 onclick="searchG('Палий Юлия Викторовна', 'sotrSearchList');">
+	<table><tr><td rowspan="2"><img alt="" src="/avatar/2681.jpg?v=48H33Koas2" /></td></tr></table>
+</div>`
+)
+
+func TestProfiles(t *testing.T) {
+	expect := &kbv1.Sotr{
+		Tabnum: "1000380",
+		Name:   "ГасХХХХХХ Ольга",
+		Phone:  []string{"400-11-27"},
+		Mobile: []string{"+7 (701) 872-11-11", "+7 (701) 911-01-11"},
+		Email:  "Olga.Gasxxxxxx@xxxxx.kz",
+		Avatar: "/avatar/1000380.jpg",
+		Grade:  "Главный бухгалтер",
+	}
+
+	for _, name := range []string{"index", "css"} {
+		t.Run(name, func(t *testing.T) {
+			p, err := Load(name)
+			require.NoError(t, err)
+			assert.Equal(t, name+"/v1", p.Profile())
+
+			assert.Equal(t, expect, p.ParseSotr(sotrText))
+
+			mid := p.ParseMidName(&kbv1.Sotr{Name: "Палий Юлия", Avatar: "/avatar/2681.jpg"}, midNameText)
+			assert.Equal(t, "Викторовна", mid)
+		})
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	p, err := Parse([]byte(`
+name: custom
+version: 2
+sotr:
+  name:
+    css: td.fio
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "custom/v2", p.Profile())
+	assert.Equal(t, "Иванов Иван", p.ParseSotr(`<table><tr><td class="fio"> Иванов Иван </td></tr></table>`).Name)
+
+	for _, prof := range []string{
+		"version: 1",
+		"name: bad\nsotr:\n  name:\n    css: 'td['",
+		"name: bad\nsotr:\n  fio:\n    css: td",
+		"name: bad\nengine: xpath",
+	} {
+		_, err = Parse([]byte(prof))
+		assert.Error(t, err, prof)
+	}
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestCoverage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coverage.json")
+
+	prev, err := LoadCoverage(path)
+	require.NoError(t, err)
+	assert.Nil(t, prev, "the first run has no previous coverage")
+
+	c := NewCoverage("css/v1")
+	c.Add(&kbv1.Sotr{Tabnum: "1", Name: "Иванов Иван", Grade: "Бухгалтер"})
+	c.Add(&kbv1.Sotr{Tabnum: "2", Name: "Петров Петр"})
+	assert.Empty(t, c.Empty(prev))
+	require.NoError(t, c.Save(path))
+
+	prev, err = LoadCoverage(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, prev.Sotrs)
+
+	c = NewCoverage("css/v1")
+	c.Add(&kbv1.Sotr{Tabnum: "1", Name: "Иванов Иван", Email: "ivanov@example.kz"})
+	assert.Equal(t, []string{"grade"}, c.Empty(prev))
+}
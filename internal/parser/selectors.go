@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"golang.org/x/net/html"
+)
+
+// Profile is YAML of the parser profile
+type Profile struct {
+	Name    string `yaml:"name"`
+	Version int    `yaml:"version"`
+	// Engine is index or css, css by default
+	Engine string `yaml:"engine"`
+	// Sotr are selectors of fields of the employee in the entry of razd by field name:
+	// tabnum, name, phone, mobile, email, avatar, grade
+	Sotr    map[string]*Selector `yaml:"sotr"`
+	MidName MidNameRule          `yaml:"mid_name"`
+}
+
+// Selector extracts the value of a field
+type Selector struct {
+	// CSS selects the first matched element, the element itself if it's empty
+	CSS string `yaml:"css"`
+	// Attr is the attribute of the element, the text of the element if it's empty
+	Attr string `yaml:"attr"`
+	// OwnText takes text nodes of the element only, without children elements
+	OwnText bool `yaml:"own_text"`
+	// HTML takes the inner html of the element
+	HTML bool `yaml:"html"`
+	// Regexp takes the first submatch of the value
+	Regexp string `yaml:"regexp"`
+	// CutQuery removes query params of URL
+	CutQuery bool `yaml:"cut_query"`
+	// Split splits the value to the list
+	Split string `yaml:"split"`
+
+	css cascadia.Selector
+	re  *regexp.Regexp
+}
+
+// MidNameRule finds the employee in results of search by name
+type MidNameRule struct {
+	// Item selects every result
+	Item     string    `yaml:"item"`
+	FullName *Selector `yaml:"full_name"`
+	Avatar   *Selector `yaml:"avatar"`
+
+	item cascadia.Selector
+}
+
+// sotrFields are fields of the employee supported by profiles
+var sotrFields = []string{"tabnum", "name", "phone", "mobile", "email", "avatar", "grade"}
+
+// Selectors is the parser by CSS selectors of the profile
+type Selectors struct {
+	prof *Profile
+}
+
+// NewSelectors compiles selectors of the profile
+func NewSelectors(prof *Profile) (p *Selectors, err error) {
+	for field, sel := range prof.Sotr {
+		if !slices.Contains(sotrFields, field) {
+			return nil, fmt.Errorf("parser profile %s: unknown field %q", prof.Name, field)
+		}
+		if err = sel.compile(); err != nil {
+			return nil, fmt.Errorf("parser profile %s: field %s: %w", prof.Name, field, err)
+		}
+	}
+
+	mn := &prof.MidName
+	if mn.Item != "" {
+		if mn.item, err = cascadia.Compile(mn.Item); err != nil {
+			return nil, fmt.Errorf("parser profile %s: mid_name item: %w", prof.Name, err)
+		}
+		if mn.FullName == nil || mn.Avatar == nil {
+			return nil, fmt.Errorf("parser profile %s: mid_name full_name and avatar are required", prof.Name)
+		}
+		for _, sel := range []*Selector{mn.FullName, mn.Avatar} {
+			if err = sel.compile(); err != nil {
+				return nil, fmt.Errorf("parser profile %s: mid_name: %w", prof.Name, err)
+			}
+		}
+	}
+
+	return &Selectors{prof: prof}, nil
+}
+
+func (p *Selectors) Profile() string {
+	return profileName(p.prof.Name, p.prof.Version)
+}
+
+func (p *Selectors) ParseSotr(unescaped string) *kbv1.Sotr {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(unescaped))
+	if err != nil {
+		return &kbv1.Sotr{}
+	}
+
+	value := func(field string) string {
+		if sel, ok := p.prof.Sotr[field]; ok {
+			return sel.value(doc.Selection)
+		}
+		return ""
+	}
+	list := func(field string) []string {
+		if sel, ok := p.prof.Sotr[field]; ok {
+			return sel.list(doc.Selection)
+		}
+		return nil
+	}
+
+	return &kbv1.Sotr{
+		Tabnum: value("tabnum"),
+		Name:   value("name"),
+		Phone:  list("phone"),
+		Mobile: list("mobile"),
+		Email:  value("email"),
+		Avatar: value("avatar"),
+		Grade:  value("grade"),
+	}
+}
+
+func (p *Selectors) ParseMidName(sotr *kbv1.Sotr, unescaped string) (mid string) {
+	mn := &p.prof.MidName
+	if mn.item == nil {
+		return ""
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(unescaped))
+	if err != nil {
+		return ""
+	}
+
+	doc.FindMatcher(mn.item).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		m, ok := strings.CutPrefix(mn.FullName.value(s), sotr.Name)
+		if ok && mn.Avatar.value(s) == sotr.Avatar {
+			mid = strings.TrimSpace(m)
+			return false
+		}
+		return true
+	})
+	return
+}
+
+func (sel *Selector) compile() (err error) {
+	if sel == nil {
+		return fmt.Errorf("selector is not set")
+	}
+	if sel.CSS != "" {
+		if sel.css, err = cascadia.Compile(sel.CSS); err != nil {
+			return
+		}
+	}
+	if sel.Regexp != "" {
+		if sel.re, err = regexp.Compile(sel.Regexp); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// value extracts the value from the first matched element of s
+func (sel *Selector) value(s *goquery.Selection) (v string) {
+	if sel.css != nil {
+		s = s.FindMatcher(sel.css)
+	}
+	s = s.First()
+	if s.Length() == 0 {
+		return ""
+	}
+
+	switch {
+	case sel.Attr != "":
+		v = s.AttrOr(sel.Attr, "")
+	case sel.OwnText:
+		v = ownText(s)
+	case sel.HTML:
+		v, _ = s.Html()
+	default:
+		v = s.Text()
+	}
+
+	if sel.re != nil {
+		m := sel.re.FindStringSubmatch(v)
+		if len(m) < 2 {
+			return ""
+		}
+		v = m[1]
+	}
+
+	if sel.CutQuery {
+		v, _, _ = strings.Cut(v, "?")
+	}
+	return strings.TrimSpace(v)
+}
+
+func (sel *Selector) list(s *goquery.Selection) []string {
+	v := sel.value(s)
+	if v == "" {
+		return nil
+	}
+	if sel.Split == "" {
+		return []string{v}
+	}
+	return strings.Split(v, sel.Split)
+}
+
+func ownText(s *goquery.Selection) string {
+	b := &strings.Builder{}
+	for c := s.Nodes[0].FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
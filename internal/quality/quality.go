@@ -0,0 +1,288 @@
+// Package quality checks scraped employees before they are written to the storage.
+// A broken parse is revealed by fill rates of fields, formats of values and by changes
+// compared with employees saved by the last run.
+package quality
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"os"
+	"slices"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/utils"
+)
+
+// Config of the data-quality guard
+type Config struct {
+	MaxChangeRatio float64 `name:"max-change-ratio" default:"0.1" help:"Max ratio of employees disappeared, lost mobile or changed grade or email since the last run and max drop of fill rate of a field. The dump is not saved if it's exceeded. If =0 then the guard is disabled."`
+	Report         string  `name:"report" help:"File of the data-quality report in JSON"`
+}
+
+// ErrGuard is returned if the dump exceeds limits of the guard
+var ErrGuard = errors.New("data quality guard")
+
+// fields of employees checked by fill rate
+var fields = []string{"tabnum", "name", "mid_name", "phone", "mobile", "email", "avatar", "grade"}
+
+// FieldRate is the fill rate of the field
+type FieldRate struct {
+	Filled   int     `json:"filled"`
+	Rate     float64 `json:"rate"`
+	PrevRate float64 `json:"prev_rate"`
+}
+
+// Report of the data quality of the dump
+type Report struct {
+	Deps  int `json:"deps"`
+	Sotrs int `json:"sotrs"`
+	// employees of the last run compared with the dump
+	Previous int                   `json:"previous"`
+	Fields   map[string]*FieldRate `json:"fields"`
+
+	// tabnums of employees with invalid values
+	EmptyTabnum      int      `json:"empty_tabnum"`
+	DuplicateTabnums []string `json:"duplicate_tabnums,omitempty"`
+	InvalidEmails    []string `json:"invalid_emails,omitempty"`
+	InvalidPhones    []string `json:"invalid_phones,omitempty"`
+	InvalidMobiles   []string `json:"invalid_mobiles,omitempty"`
+
+	// tabnums of employees changed since the last run
+	Disappeared  []string `json:"disappeared,omitempty"`
+	LostMobile   []string `json:"lost_mobile,omitempty"`
+	GradeChanged []string `json:"grade_changed,omitempty"`
+	EmailChanged []string `json:"email_changed,omitempty"`
+
+	// Violations are exceeded limits of the guard
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Guard collects items of the dump and checks them before saving
+type Guard struct {
+	cfg   Config
+	prev  []*kbv1.Sotr
+	items []models.Item
+	lg    *slog.Logger
+}
+
+// NewGuard creates the guard comparing the dump with employees saved by the last run
+func NewGuard(cfg Config, prev []*kbv1.Sotr, lg *slog.Logger) *Guard {
+	return &Guard{
+		cfg:  cfg,
+		prev: prev,
+		lg:   lg.With("dump", "quality"),
+	}
+}
+
+// Add collects the item of the dump
+func (g *Guard) Add(item models.Item) {
+	g.items = append(g.items, item)
+}
+
+// Items returns collected items in the order of adding
+func (g *Guard) Items() []models.Item {
+	return g.items
+}
+
+// Check builds the report of collected items. Employees of the last run are missed only
+// if their deps are in scope, deps out of scope are not crawled.
+// It returns ErrGuard if limits are exceeded.
+func (g *Guard) Check(scope []string) (r *Report, err error) {
+	r = &Report{Fields: make(map[string]*FieldRate, len(fields))}
+	for _, f := range fields {
+		r.Fields[f] = &FieldRate{}
+	}
+
+	sotrs := make(map[string]*kbv1.Sotr, len(g.items))
+	for _, item := range g.items {
+		if item.GetChildren() {
+			r.Deps++
+			continue
+		}
+
+		sotr, ok := item.(*kbv1.Sotr)
+		if !ok {
+			continue
+		}
+		r.Sotrs++
+		r.count(sotr)
+
+		switch _, dup := sotrs[sotr.Tabnum]; {
+		case sotr.Tabnum == "":
+			r.EmptyTabnum++
+		case dup:
+			r.DuplicateTabnums = append(r.DuplicateTabnums, sotr.Tabnum)
+		default:
+			sotrs[sotr.Tabnum] = sotr
+		}
+	}
+
+	prevFilled := r.compare(g.prev, sotrs, scope)
+	for _, f := range fields {
+		fr := r.Fields[f]
+		fr.Rate = ratio(fr.Filled, r.Sotrs)
+		fr.PrevRate = ratio(prevFilled[f], r.Previous)
+	}
+
+	r.guard(g.cfg.MaxChangeRatio)
+	r.log(g.lg)
+
+	if g.cfg.Report != "" {
+		if e := r.save(g.cfg.Report); e != nil {
+			g.lg.Error("Save data quality report", "err", e)
+		}
+	}
+
+	if len(r.Violations) > 0 {
+		err = fmt.Errorf("%w: %v", ErrGuard, r.Violations)
+	}
+	return
+}
+
+// count fill rates and invalid values of the employee
+func (r *Report) count(sotr *kbv1.Sotr) {
+	for f, v := range filled(sotr) {
+		if v {
+			r.Fields[f].Filled++
+		}
+	}
+
+	if sotr.Email != "" {
+		if _, err := mail.ParseAddress(sotr.Email); err != nil {
+			r.InvalidEmails = append(r.InvalidEmails, sotr.Tabnum)
+		}
+	}
+	if slices.ContainsFunc(sotr.Phone, func(p string) bool { return utils.ExtractDigits(p) == "" }) {
+		r.InvalidPhones = append(r.InvalidPhones, sotr.Tabnum)
+	}
+	// mobiles are saved as numbers by SQL storages
+	if slices.ContainsFunc(sotr.Mobile, func(m string) bool {
+		n := len(utils.ExtractDigits(m))
+		return n < 10 || n > 15
+	}) {
+		r.InvalidMobiles = append(r.InvalidMobiles, sotr.Tabnum)
+	}
+}
+
+// compare finds employees changed since the last run and counts filled fields of compared employees of the last run
+func (r *Report) compare(prev []*kbv1.Sotr, sotrs map[string]*kbv1.Sotr, scope []string) (prevFilled map[string]int) {
+	prevFilled = make(map[string]int, len(fields))
+
+	crawled := make(map[string]bool, len(scope))
+	for _, idr := range scope {
+		crawled[idr] = true
+	}
+
+	for _, p := range prev {
+		s, ok := sotrs[p.Tabnum]
+		if !ok && !crawled[p.ParentId] {
+			continue
+		}
+
+		r.Previous++
+		for f, v := range filled(p) {
+			if v {
+				prevFilled[f]++
+			}
+		}
+
+		if !ok {
+			r.Disappeared = append(r.Disappeared, p.Tabnum)
+			continue
+		}
+
+		if len(p.Mobile) > 0 && len(s.Mobile) == 0 {
+			r.LostMobile = append(r.LostMobile, p.Tabnum)
+		}
+		if p.Grade != s.Grade {
+			r.GradeChanged = append(r.GradeChanged, p.Tabnum)
+		}
+		if p.Email != s.Email {
+			r.EmailChanged = append(r.EmailChanged, p.Tabnum)
+		}
+	}
+	return
+}
+
+// guard checks limits of changes
+func (r *Report) guard(maxRatio float64) {
+	if maxRatio <= 0 {
+		return
+	}
+	defer slices.Sort(r.Violations)
+
+	// employees with the same or empty tabnum overwrite each other
+	if rt := ratio(len(r.DuplicateTabnums)+r.EmptyTabnum, r.Sotrs); rt > maxRatio {
+		r.Violations = append(r.Violations, fmt.Sprintf("duplicate or empty tabnum %.1f%% of employees", rt*100))
+	}
+
+	if r.Previous == 0 {
+		return
+	}
+
+	for name, tabnums := range map[string][]string{
+		"disappeared":   r.Disappeared,
+		"lost mobile":   r.LostMobile,
+		"grade changed": r.GradeChanged,
+		"email changed": r.EmailChanged,
+	} {
+		if rt := ratio(len(tabnums), r.Previous); rt > maxRatio {
+			r.Violations = append(r.Violations, fmt.Sprintf("%s %.1f%% of employees", name, rt*100))
+		}
+	}
+
+	for _, f := range fields {
+		if fr := r.Fields[f]; fr.PrevRate-fr.Rate > maxRatio {
+			r.Violations = append(r.Violations, fmt.Sprintf("fill rate of %s dropped from %.1f%% to %.1f%%", f, fr.PrevRate*100, fr.Rate*100))
+		}
+	}
+}
+
+func (r *Report) log(lg *slog.Logger) {
+	rates := make([]any, 0, len(fields))
+	for _, f := range fields {
+		rates = append(rates, slog.String(f, fmt.Sprintf("%.1f%%", r.Fields[f].Rate*100)))
+	}
+
+	lg.Info("Data quality", "deps", r.Deps, "sotrs", r.Sotrs, "previous", r.Previous, slog.Group("fill_rate", rates...),
+		"empty_tabnum", r.EmptyTabnum, "duplicate_tabnums", len(r.DuplicateTabnums),
+		"invalid_emails", len(r.InvalidEmails), "invalid_phones", len(r.InvalidPhones), "invalid_mobiles", len(r.InvalidMobiles),
+		"disappeared", len(r.Disappeared), "lost_mobile", len(r.LostMobile),
+		"grade_changed", len(r.GradeChanged), "email_changed", len(r.EmailChanged))
+
+	if len(r.Violations) > 0 {
+		lg.Error("Data quality: limits of the guard are exceeded", "violations", r.Violations)
+	}
+}
+
+func (r *Report) save(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+func filled(s *kbv1.Sotr) map[string]bool {
+	return map[string]bool{
+		"tabnum":   s.Tabnum != "",
+		"name":     s.Name != "",
+		"mid_name": s.MidName != "",
+		"phone":    len(s.Phone) > 0,
+		"mobile":   len(s.Mobile) > 0,
+		"email":    s.Email != "",
+		"avatar":   s.Avatar != "",
+		"grade":    s.Grade != "",
+	}
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
@@ -0,0 +1,148 @@
+package quality
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sotrs(n int, parent string) []*kbv1.Sotr {
+	s := make([]*kbv1.Sotr, n)
+	for i := range s {
+		s[i] = &kbv1.Sotr{
+			Tabnum:   fmt.Sprint(i + 1),
+			Name:     "Иванов Иван",
+			Email:    fmt.Sprintf("user%d@example.kz", i+1),
+			Mobile:   []string{"+7 (777) 123-45-67"},
+			Grade:    "Бухгалтер",
+			ParentId: parent,
+		}
+	}
+	return s
+}
+
+func TestGuardPasses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	g := NewGuard(Config{MaxChangeRatio: 0.1, Report: path}, sotrs(20, "razd1"), slog.Default())
+
+	g.Add(&kbv1.Dep{Idr: "razd1", Children: true})
+	cur := sotrs(20, "razd1")
+	// one of twenty is changed
+	cur[0].Mobile = nil
+	cur[1].Email = "invalid"
+	cur[2].Mobile = []string{"+7 (777)"}
+	for _, s := range cur[:19] {
+		g.Add(s)
+	}
+
+	r, err := g.Check([]string{"razd1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.Deps)
+	assert.Equal(t, 19, r.Sotrs)
+	assert.Equal(t, 20, r.Previous)
+	assert.Equal(t, []string{"20"}, r.Disappeared)
+	assert.Equal(t, []string{"1"}, r.LostMobile)
+	assert.Equal(t, []string{"2"}, r.InvalidEmails)
+	assert.Equal(t, []string{"3"}, r.InvalidMobiles)
+	assert.Len(t, g.Items(), 20)
+	assert.FileExists(t, path)
+}
+
+func TestGuardRefuses(t *testing.T) {
+	prev := append(sotrs(10, "razd1"), sotrs(10, "razd2")...)
+	for i, s := range prev[10:] {
+		s.Tabnum = fmt.Sprint(i + 100)
+	}
+
+	tests := []struct {
+		name  string
+		scope []string
+		edit  func(cur []*kbv1.Sotr) []*kbv1.Sotr
+		want  string
+	}{
+		{
+			name:  "disappeared",
+			scope: []string{"razd1", "razd2"},
+			edit:  func(cur []*kbv1.Sotr) []*kbv1.Sotr { return cur[:10] },
+			want:  "disappeared 50.0% of employees",
+		},
+		{
+			name: "lost mobile",
+			edit: func(cur []*kbv1.Sotr) []*kbv1.Sotr {
+				for _, s := range cur[:5] {
+					s.Mobile = nil
+				}
+				return cur
+			},
+			want: "lost mobile 25.0% of employees",
+		},
+		{
+			name: "grade",
+			edit: func(cur []*kbv1.Sotr) []*kbv1.Sotr {
+				for _, s := range cur {
+					s.Grade = ""
+				}
+				return cur
+			},
+			want: "fill rate of grade dropped from 100.0% to 0.0%",
+		},
+		{
+			name: "tabnum",
+			edit: func(cur []*kbv1.Sotr) []*kbv1.Sotr {
+				for _, s := range cur[:4] {
+					s.Tabnum = "1"
+				}
+				return cur
+			},
+			want: "duplicate or empty tabnum 15.0% of employees",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := append(sotrs(10, "razd1"), sotrs(10, "razd2")...)
+			for i, s := range cur[10:] {
+				s.Tabnum = fmt.Sprint(i + 100)
+			}
+
+			g := NewGuard(Config{MaxChangeRatio: 0.1}, prev, slog.Default())
+			for _, s := range tt.edit(cur) {
+				g.Add(s)
+			}
+
+			r, err := g.Check(tt.scope)
+			require.ErrorIs(t, err, ErrGuard)
+			assert.Contains(t, r.Violations, tt.want)
+		})
+	}
+}
+
+func TestGuardScope(t *testing.T) {
+	prev := append(sotrs(10, "razd1"), sotrs(10, "razd2")...)
+	for i, s := range prev[10:] {
+		s.Tabnum = fmt.Sprint(i + 100)
+	}
+
+	// razd2 is not crawled, its employees are not missed
+	g := NewGuard(Config{MaxChangeRatio: 0.1}, prev, slog.Default())
+	for _, s := range sotrs(10, "razd1") {
+		g.Add(s)
+	}
+
+	r, err := g.Check([]string{"razd1"})
+	require.NoError(t, err)
+	assert.Equal(t, 10, r.Previous)
+	assert.Empty(t, r.Disappeared)
+
+	// disabled guard only reports
+	g = NewGuard(Config{}, prev, slog.Default())
+	r, err = g.Check([]string{"razd1", "razd2"})
+	require.NoError(t, err)
+	assert.Len(t, r.Disappeared, 20)
+	assert.Empty(t, r.Violations)
+}
@@ -0,0 +1,273 @@
+// Package cache provides read-through cache of storage queries in Redis
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/storage"
+	"github.com/mioxin/kbempgo/pkg/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// KeyPrefix of all cache keys in Redis
+	KeyPrefix = "kbemp:cache:"
+	// generation of cached queries, it's incremented for invalidate all cached queries
+	genKey = KeyPrefix + "gen"
+)
+
+// CachedStore is storage.Store decorator caches results of GetSotrsBy and GetDepsBy in Redis.
+// Cached queries are invalidated on Update and Flush. Save invalidates them too,
+// unless the storage stages saved items until Flush (storage.Stager).
+type CachedStore struct {
+	storage.Store
+
+	rdb redis.UniversalClient
+	ttl time.Duration
+	Log *slog.Logger
+	// every query fails while Redis is unreachable, so errors of Redis are logged once in the interval
+	warns rate.Sometimes
+
+	hits, misses *prometheus.CounterVec
+}
+
+// New wraps the storage. The cache owns the Redis client and closes it with the storage.
+func New(st storage.Store, rdb redis.UniversalClient, ttl time.Duration, log *slog.Logger) *CachedStore {
+	return &CachedStore{
+		Store: st,
+		rdb:   rdb,
+		ttl:   ttl,
+		Log:   log.With("storage", "cache"),
+		warns: rate.Sometimes{First: 1, Interval: time.Minute},
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kbemp",
+			Subsystem: "storage_cache",
+			Name:      "hits_total",
+			Help:      "Number of storage queries returned from the cache",
+		}, []string{"query"}),
+		misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kbemp",
+			Subsystem: "storage_cache",
+			Name:      "misses_total",
+			Help:      "Number of storage queries not found in the cache",
+		}, []string{"query"}),
+	}
+}
+
+func (c *CachedStore) GetDepsBy(ctx context.Context, q *kbv1.DepRequest) (deps []*kbv1.Dep, err error) {
+	// the generation is read once, so the response of the storage isn't cached in the generation of the later flush
+	key := c.key(ctx, fmt.Sprintf("deps:%s:%s", q.Field.String(), q.Str))
+	resp := &kbv1.DepsResponse{}
+
+	if c.get(ctx, "GetDepsBy", key, resp) {
+		return resp.Deps, nil
+	}
+
+	deps, err = c.Store.GetDepsBy(ctx, q)
+	if err != nil {
+		return
+	}
+
+	c.set(ctx, key, &kbv1.DepsResponse{Deps: deps})
+	return
+}
+
+// GetSotrsBy returns employee data
+func (c *CachedStore) GetSotrsBy(ctx context.Context, q *kbv1.SotrRequest) (sotrs []*kbv1.Sotr, err error) {
+	key := c.key(ctx, fmt.Sprintf("sotrs:%s:%s", q.Field.String(), q.Str))
+	resp := &kbv1.SotrsResponse{}
+
+	if c.get(ctx, "GetSotrsBy", key, resp) {
+		return resp.Sotrs, nil
+	}
+
+	sotrs, err = c.Store.GetSotrsBy(ctx, q)
+	if err != nil {
+		return
+	}
+
+	c.set(ctx, key, &kbv1.SotrsResponse{Sotrs: sotrs})
+	return
+}
+
+func (c *CachedStore) Save(ctx context.Context, item models.Item) (em *emptypb.Empty, err error) {
+	em, err = c.Store.Save(ctx, item)
+	if st, ok := c.Store.(storage.Stager); !ok || !st.Staging() {
+		c.invalidate(ctx)
+	}
+	return
+}
+
+func (c *CachedStore) Update(ctx context.Context, q *kbv1.UpdateSotrRequest) (em *emptypb.Empty, err error) {
+	em, err = c.Store.Update(ctx, q)
+	c.invalidate(ctx)
+	return
+}
+
+func (c *CachedStore) Flush(ctx context.Context, e *emptypb.Empty) (em *emptypb.Empty, err error) {
+	em, err = c.Store.Flush(ctx, e)
+	c.invalidate(ctx)
+	return
+}
+
+// SetScope sets idrs of deps crawled by the dump if the storage removes absent employees
+func (c *CachedStore) SetScope(idrs []string) {
+	if sc, ok := c.Store.(storage.Scoper); ok {
+		sc.SetScope(idrs)
+	}
+}
+
+// SaveAvatars saves avatars if the storage keeps ones
+func (c *CachedStore) SaveAvatars(ctx context.Context, avatars []*kbv1.Avatar) error {
+	if as, ok := c.Store.(storage.Avatars); ok {
+		return as.SaveAvatars(ctx, avatars)
+	}
+	return nil
+}
+
+// GetAvatar returns the current avatar of the employee if the storage keeps avatars
+func (c *CachedStore) GetAvatar(ctx context.Context, tabnum string) (*kbv1.Avatar, error) {
+	if as, ok := c.Store.(storage.Avatars); ok {
+		return as.GetAvatar(ctx, tabnum)
+	}
+	return nil, nil
+}
+
+// SaveNews saves news if the storage keeps ones
+func (c *CachedStore) SaveNews(ctx context.Context, news []*kbv1.News) error {
+	if ns, ok := c.Store.(storage.News); ok {
+		return ns.SaveNews(ctx, news)
+	}
+	return errors.New("storage doesn't keep news")
+}
+
+// ListNews returns news if the storage keeps ones
+func (c *CachedStore) ListNews(ctx context.Context, q *kbv1.ListNewsRequest) ([]*kbv1.News, error) {
+	if ns, ok := c.Store.(storage.News); ok {
+		return ns.ListNews(ctx, q)
+	}
+	return nil, nil
+}
+
+// GetNews returns the news if the storage keeps news
+func (c *CachedStore) GetNews(ctx context.Context, idn string) (*kbv1.News, error) {
+	if ns, ok := c.Store.(storage.News); ok {
+		return ns.GetNews(ctx, idn)
+	}
+	return nil, nil
+}
+
+// Close closes the storage and Redis client
+func (c *CachedStore) Close() error {
+	return errors.Join(c.Store.Close(), c.rdb.Close())
+}
+
+// PromCollector returns metrics of the storage and the cache
+func (c *CachedStore) PromCollector() prometheus.Collector {
+	cs := collectors{c.hits, c.misses}
+	if st := c.Store.PromCollector(); st != nil {
+		cs = append(cs, st)
+	}
+	return cs
+}
+
+// key returns Redis key of the query in the current generation of the cache.
+// The empty key is returned if the generation is unknown, the query is not cached then.
+func (c *CachedStore) key(ctx context.Context, query string) string {
+	gen, err := c.Generation(ctx)
+	if err != nil {
+		c.warn("Get cache generation", "err", err)
+		return ""
+	}
+	return fmt.Sprintf("%s%d:%s", KeyPrefix, gen, query)
+}
+
+// Generation returns the generation of the cache, it's changed by every Update and Flush
+func (c *CachedStore) Generation(ctx context.Context) (int64, error) {
+	gen, err := c.rdb.Get(ctx, genKey).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	return gen, nil
+}
+
+// get reads cached response of the key to out. Errors of Redis are logged and treated as miss.
+func (c *CachedStore) get(ctx context.Context, method, key string, out proto.Message) (ok bool) {
+	defer func() {
+		if ok {
+			c.hits.WithLabelValues(method).Inc()
+		} else {
+			c.misses.WithLabelValues(method).Inc()
+		}
+	}()
+
+	if key == "" {
+		return
+	}
+
+	b, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.warn("Get from cache", "key", key, "err", err)
+		}
+		return
+	}
+
+	if err = proto.Unmarshal(b, out); err != nil {
+		c.Log.Warn("Unmarshal cached query", "key", key, "err", err)
+		return
+	}
+	return true
+}
+
+func (c *CachedStore) set(ctx context.Context, key string, resp proto.Message) {
+	if key == "" {
+		return
+	}
+
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		c.Log.Warn("Marshal query for cache", "key", key, "err", err)
+		return
+	}
+
+	if err = c.rdb.Set(ctx, key, b, c.ttl).Err(); err != nil {
+		c.warn("Set to cache", "key", key, "err", err)
+	}
+}
+
+// invalidate switches the cache to a new generation, keys of old one are expired by TTL
+func (c *CachedStore) invalidate(ctx context.Context) {
+	if err := c.rdb.Incr(ctx, genKey).Err(); err != nil {
+		c.warns.Do(func() { c.Log.Error("Invalidate cache", "err", err) })
+	}
+}
+
+// warn logs the error of Redis if no one was logged in the interval of warns
+func (c *CachedStore) warn(msg string, args ...any) {
+	c.warns.Do(func() { c.Log.Warn(msg, args...) })
+}
+
+// collectors is a set of prometheus collectors registered together
+type collectors []prometheus.Collector
+
+func (cs collectors) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range cs {
+		c.Describe(ch)
+	}
+}
+
+func (cs collectors) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range cs {
+		c.Collect(ch)
+	}
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func newCachedStore(t *testing.T) (*CachedStore, *miniredis.Miniredis) {
+	t.Helper()
+	return newCachedStoreOf(t, "mem://", slog.Default())
+}
+
+func newCachedStoreOf(t *testing.T, source string, log *slog.Logger) (*CachedStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+
+	st, err := storage.NewStore(source, log)
+	require.NoError(t, err)
+
+	c := New(st, rdb, time.Minute, log)
+	t.Cleanup(func() { c.Close() })
+
+	return c, mr
+}
+
+func hitsMisses(c *CachedStore, query string) (hits, misses float64) {
+	hits = testutil.ToFloat64(c.hits.WithLabelValues(query))
+	misses = testutil.ToFloat64(c.misses.WithLabelValues(query))
+	return
+}
+
+func TestCachedStoreConformance(t *testing.T) {
+	suite.Run(t, &storetest.ConformanceSuite{
+		NewStore: func(t *testing.T) storetest.Store {
+			c, _ := newCachedStore(t)
+			return c
+		},
+	})
+}
+
+func TestReadThrough(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newCachedStore(t)
+
+	dep := &kbv1.Dep{Idr: "razd1", Parent: "razd", Text: "Отдел", Children: true}
+	sotr := &kbv1.Sotr{Idr: "sotr1", Tabnum: "1", Name: "Иванов Иван", Grade: "Специалист", ParentId: "razd1"}
+
+	_, err := c.Save(ctx, dep)
+	require.NoError(t, err)
+	_, err = c.Flush(ctx, nil)
+	require.NoError(t, err)
+	_, err = c.Save(ctx, sotr)
+	require.NoError(t, err)
+	// staged items don't invalidate the cache
+	gen, err := c.Generation(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), gen)
+	_, err = c.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	q := &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: "1"}
+
+	for range 3 {
+		sotrs, err := c.GetSotrsBy(ctx, q)
+		require.NoError(t, err)
+		require.Len(t, sotrs, 1)
+		assert.Equal(t, "Специалист", sotrs[0].Grade)
+	}
+
+	hits, misses := hitsMisses(c, "GetSotrsBy")
+	assert.Equal(t, 2.0, hits)
+	assert.Equal(t, 1.0, misses)
+
+	// update invalidates cached queries
+	sotr.Grade = "Начальник"
+	_, err = c.Update(ctx, &kbv1.UpdateSotrRequest{Sotr: sotr})
+	require.NoError(t, err)
+
+	sotrs, err := c.GetSotrsBy(ctx, q)
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+	assert.Equal(t, "Начальник", sotrs[0].Grade)
+
+	hits, misses = hitsMisses(c, "GetSotrsBy")
+	assert.Equal(t, 2.0, hits)
+	assert.Equal(t, 2.0, misses)
+
+	// expired by TTL
+	_, err = c.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_IDR, Str: "razd1"})
+	require.NoError(t, err)
+	mr.FastForward(2 * time.Minute)
+	_, err = c.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_IDR, Str: "razd1"})
+	require.NoError(t, err)
+
+	hits, misses = hitsMisses(c, "GetDepsBy")
+	assert.Equal(t, 0.0, hits)
+	assert.Equal(t, 2.0, misses)
+}
+
+func TestSaveInvalidates(t *testing.T) {
+	ctx := context.Background()
+	// the file storage doesn't stage saved items, they are written as the buffer is filled
+	c, _ := newCachedStoreOf(t, "file://"+t.TempDir(), slog.Default())
+
+	dep := &kbv1.Dep{Idr: "razd1", Parent: "razd", Text: "Отдел", Children: true}
+	q := &kbv1.DepRequest{Field: kbv1.DepRequest_IDR, Str: "razd1"}
+
+	deps, err := c.GetDepsBy(ctx, q)
+	require.NoError(t, err)
+	assert.Empty(t, deps)
+
+	_, err = c.Save(ctx, dep)
+	require.NoError(t, err)
+	gen, err := c.Generation(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), gen)
+
+	_, err = c.GetDepsBy(ctx, q)
+	require.NoError(t, err)
+	hits, misses := hitsMisses(c, "GetDepsBy")
+	assert.Equal(t, 0.0, hits)
+	assert.Equal(t, 2.0, misses)
+}
+
+func TestRedisDown(t *testing.T) {
+	ctx := context.Background()
+	logs := &bytes.Buffer{}
+	c, mr := newCachedStoreOf(t, "mem://", slog.New(slog.NewTextHandler(logs, nil)))
+	mr.Close()
+
+	// queries go to the storage
+	for range 3 {
+		deps, err := c.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+		require.NoError(t, err)
+		assert.Empty(t, deps)
+	}
+
+	_, misses := hitsMisses(c, "GetDepsBy")
+	assert.Equal(t, 3.0, misses)
+	// the error of Redis is logged once in the interval
+	assert.Equal(t, 1, strings.Count(logs.String(), "Get cache generation"))
+}
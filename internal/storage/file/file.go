@@ -9,12 +9,17 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
 	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/storage/metrics"
+	"github.com/mioxin/kbempgo/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -30,14 +35,18 @@ func (e FieldNameError) Error() string {
 }
 
 // string of directory path contains DepsResponse.json and SotrsResponse.json
+// and history.json with histories of changed sotrs
 type FileStore struct {
 	kbv1.UnimplementedStorAPIServer
 
-	BaseDir         string
-	rwrDep, rwrSotr *bufio.ReadWriter
-	flD, flS        *os.File
-	mt              sync.Mutex
-	Log             *slog.Logger
+	BaseDir                  string
+	rwrDep, rwrSotr, rwrHist *bufio.ReadWriter
+	flD, flS, flH            *os.File
+	mt                       sync.Mutex
+	Log                      *slog.Logger
+	// last ID of saved sotrs
+	lastID uint64
+	metrx  *metrics.Collector
 }
 
 func NewFileStore(fname string, log *slog.Logger) (*FileStore, error) {
@@ -60,21 +69,43 @@ func NewFileStore(fname string, log *slog.Logger) (*FileStore, error) {
 		return nil, err
 	}
 
-	return &FileStore{
+	fPath = filepath.Join(string(fname), "history.json")
+
+	flH, err := os.OpenFile(fPath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStore{
 		BaseDir: fname,
 		rwrDep:  bufio.NewReadWriter(bufio.NewReader(flD), bufio.NewWriter(flD)),
 		rwrSotr: bufio.NewReadWriter(bufio.NewReader(flS), bufio.NewWriter(flS)),
+		rwrHist: bufio.NewReadWriter(bufio.NewReader(flH), bufio.NewWriter(flH)),
 		flD:     flD,
 		flS:     flS,
+		flH:     flH,
 		Log:     log.With("storage", "files"),
-	}, nil
+	}
+	f.metrx = metrics.New("files", f.countRows, f.Log, newFileStats(fname))
+
+	// define last ID for new sotrs
+	sotrs, err := f.GetSotrsBy(context.Background(), &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for _, s := range sotrs {
+		f.lastID = max(f.lastID, s.Id)
+	}
+
+	return f, nil
 }
 
 func (f *FileStore) Save(_ context.Context, item models.Item) (_ *emptypb.Empty, err error) {
 
 	if !item.GetChildren() {
 		if sotr, ok := any(item).(*kbv1.Sotr); ok {
-			err = f.saveSotr(sotr)
+			err = f.saveSotr(sotr, nil)
 
 			if err != nil {
 				return
@@ -135,78 +166,55 @@ func (f *FileStore) saveDep(dep *kbv1.Dep) (err error) {
 		return
 	}
 
+	f.metrx.AddRows(metrics.Deps, 1)
 	f.Log.Debug("saved", "dep", string(b))
 	return
 }
 
-func (f *FileStore) saveSotr(sotr *kbv1.Sotr) (err error) {
-
-	ctx := context.Background()
+func (f *FileStore) saveSotr(sotr *kbv1.Sotr, cleared []string) (err error) {
 	var SotrsResponse []*kbv1.Sotr
 
-	// get SotrsResponse if exists for define double raw
-	SotrsResponse, err = f.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: sotr.Tabnum})
+	// get saved sotr if exists for define double raw
+	SotrsResponse, err = f.GetSotrsBy(context.Background(), &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: sotr.Tabnum})
 	if err != nil {
 		return
 	}
 
-	// doublicates found
-	if len(SotrsResponse) > 0 {
+	var hs []*kbv1.History
 
-		f.Log.Debug("saved: doublicates by Tabnum is found", "num", len(SotrsResponse))
-
-		sort.Slice(SotrsResponse, func(i, j int) bool {
-			if SotrsResponse[i].Date == nil {
-				return true
-			}
-			if SotrsResponse[j].Date == nil {
-				return false
-			}
-			return SotrsResponse[i].Date.AsTime().Before(SotrsResponse[j].Date.AsTime())
-		})
-		// get newest raw
+	if len(SotrsResponse) > 0 {
 		oldSotr := SotrsResponse[len(SotrsResponse)-1]
+		utils.KeepImported(oldSotr, sotr, cleared)
 
 		// if double raw exists then compare for define difference
-		diff, _ := kbv1.CompareSotr(oldSotr, sotr)
-		// if diff exists than save diff to history and update old SotrsResponse
-		if len(diff) > 0 {
-			f.Log.Debug("saved: doublicate have diffs", "num", len(diff))
-
-			hs := make([]*kbv1.History, 0)
-			for _, df := range diff {
-
-				value := ""
-
-				switch t := df.Val.(type) {
-				case string:
-					value = string(t)
-				case []string:
-					value = strings.Join(t, ",")
-				}
-
-				hs = append(hs, &kbv1.History{
-					Date:     timestamppb.Now(),
-					Field:    df.FieldName,
-					OldValue: value,
-					SotrId:   oldSotr.Id,
-				})
-			}
-
-			f.Log.Debug("saved: history of diff", "hs", hs)
-			_, err = f.Update(ctx, &kbv1.UpdateSotrRequest{Sotr: sotr, HistoryList: hs})
+		hs = utils.DiffSotr(oldSotr, sotr)
+		if len(hs) == 0 {
+			sotr.Id = oldSotr.Id
+			return
+		}
+		f.Log.Debug("saved: doublicate have diffs", "num", len(hs))
 
-			if err != nil {
-				f.Log.Error("saved: update", "err", err)
-			}
+		sotr.Id = oldSotr.Id
+		if sotr.Date == nil {
+			sotr.Date = oldSotr.Date
 		}
-		return
 	}
 
 	marshaler := protojson.MarshalOptions{
 		EmitUnpopulated: true, // for sure includes bool fields =  false/0/""
 	}
 
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	if sotr.Id == 0 {
+		f.lastID++
+		sotr.Id = f.lastID
+	}
+	if sotr.Date == nil {
+		sotr.Date = timestamppb.Now()
+	}
+
 	b, err := marshaler.Marshal(sotr)
 	if err != nil {
 		return
@@ -214,42 +222,61 @@ func (f *FileStore) saveSotr(sotr *kbv1.Sotr) (err error) {
 
 	b = append(b, "\n"...)
 
-	f.mt.Lock()
-	defer f.mt.Unlock()
-
 	_, err = f.rwrSotr.Write(b)
 	if err != nil {
 		err = fmt.Errorf("error save Sotr to Stor: %w", err)
 		return
 	}
 
+	f.metrx.AddRows(metrics.Sotrs, 1)
 	f.Log.Debug("saved", "sotr", string(b))
 
+	for _, h := range hs {
+		b, err = marshaler.Marshal(h)
+		if err != nil {
+			return
+		}
+
+		b = append(b, "\n"...)
+
+		_, err = f.rwrHist.Write(b)
+		if err != nil {
+			err = fmt.Errorf("error save History to Stor: %w", err)
+			return
+		}
+		f.metrx.AddRows(metrics.Histories, 1)
+	}
+
 	return
 }
 
-func (f *FileStore) Update(_ context.Context, query *kbv1.UpdateSotrRequest) (_ *emptypb.Empty, err error) {
-	marshaler := protojson.MarshalOptions{
-		EmitUnpopulated: true, // for sure includes bool fields =  false/0/""
+// Update saves the employee by tabnum.
+// Histories of changed fields are generated by comparing with the saved sotr,
+// so HistoryList of the request is not used.
+func (f *FileStore) Update(ctx context.Context, query *kbv1.UpdateSotrRequest) (_ *emptypb.Empty, err error) {
+	if query.GetSotr() == nil || query.Sotr.Tabnum == "" {
+		return nil, fmt.Errorf("update: tabnum of sotr is empty")
+	}
+
+	cleared := query.GetClear().GetPaths()
+	if err = datasource.CheckCleared(cleared); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
 	}
 
-	b, err := marshaler.Marshal(query.Sotr)
+	err = f.saveSotr(query.Sotr, cleared)
 	if err != nil {
 		return
 	}
-	b = append(b, "\n"...)
-
-	f.mt.Lock()
-	defer f.mt.Unlock()
-
-	_, err = f.rwrSotr.Write(b)
 
+	// update is not a part of dump, so it is written immediately
+	_, err = f.Flush(ctx, nil)
 	return
 }
 
 func (f *FileStore) Flush(ctx context.Context, _ *emptypb.Empty) (_ *emptypb.Empty, err error) {
 	f.mt.Lock()
 	defer f.mt.Unlock()
+	defer f.metrx.ObserveFlush(time.Now())
 
 	errs := make([]error, 0)
 
@@ -265,6 +292,12 @@ func (f *FileStore) Flush(ctx context.Context, _ *emptypb.Empty) (_ *emptypb.Emp
 		errs = append(errs, err)
 	}
 
+	e2 := f.rwrHist.Flush()
+	if e2 != nil {
+		err = fmt.Errorf("%w; %w", err, e2)
+		errs = append(errs, err)
+	}
+
 	err = errors.Join(errs...)
 	return
 }
@@ -287,16 +320,17 @@ func (f *FileStore) Close() (err error) {
 		errs = append(errs, err)
 	}
 
-	e2 := f.flD.Close()
+	e2 := f.rwrHist.Flush()
 	if e2 != nil {
 		err = fmt.Errorf("%w; %w", err, e2)
 		errs = append(errs, err)
 	}
 
-	e3 := f.flS.Close()
-	if e3 != nil {
-		err = fmt.Errorf("%w; %w", err, e3)
-		errs = append(errs, err)
+	for _, fl := range []*os.File{f.flD, f.flS, f.flH} {
+		if e := fl.Close(); e != nil {
+			err = fmt.Errorf("%w; %w", err, e)
+			errs = append(errs, err)
+		}
 	}
 
 	return errors.Join(errs...)
@@ -365,74 +399,181 @@ func (f *FileStore) GetDepsBy(ctx context.Context, query *kbv1.DepRequest) (Deps
 }
 
 func (f *FileStore) GetSotrsBy(ctx context.Context, query *kbv1.SotrRequest) (SotrsResponse []*kbv1.Sotr, err error) {
-	var s string
-
-	f.mt.Lock()
-	defer f.mt.Unlock()
+	var checkEqualValue func(d *kbv1.Sotr, val string) bool
 
-	f.flS.Seek(0, io.SeekStart)
 	field := query.Field
 
-	SotrsResponse = make([]*kbv1.Sotr, 0, 3)
-	findByFieldVal := func(checkEqualValue func(d *kbv1.Sotr, val string) bool) (err error) {
-
-		for {
-			sotr := &kbv1.Sotr{}
-			s, err = f.rwrSotr.ReadString('\n')
-
-			if err == io.EOF {
-				err = nil
-				break
-			}
-			if err != nil {
-				return
-			}
-
-			// opts := &protojson.UnmarshalOptions{DiscardUnknown: true, AllowPartial: true}
-			err = protojson.Unmarshal([]byte(s), sotr)
-			if err != nil {
-				f.Log.Error("GetSotrsBy: unmurshall json", "error", err, "field", field, "json", s)
-				continue
-			}
-			if checkEqualValue(sotr, query.Str) {
-				SotrsResponse = append(SotrsResponse, sotr)
-			}
-		}
-
-		return
-	}
-
 	switch field {
 	case kbv1.SotrRequest_NONE:
-		err = findByFieldVal(func(d *kbv1.Sotr, val string) bool {
+		checkEqualValue = func(d *kbv1.Sotr, val string) bool {
 			return true
-		})
+		}
 
 	case kbv1.SotrRequest_IDR:
-		err = findByFieldVal(func(d *kbv1.Sotr, val string) bool {
+		checkEqualValue = func(d *kbv1.Sotr, val string) bool {
 			return d.Idr == val
-		})
+		}
+	case kbv1.SotrRequest_PARENT:
+		checkEqualValue = func(d *kbv1.Sotr, val string) bool {
+			return d.ParentId == val
+		}
 	case kbv1.SotrRequest_MOBILE:
-		err = findByFieldVal(func(d *kbv1.Sotr, val string) bool {
-			return d.Mobile[0] == val
-		})
+		mob := utils.ExtractDigits(query.Str)
+		if _, err = strconv.ParseUint(mob, 10, 64); err != nil {
+			return
+		}
+		// any mobile of sotr in any format
+		checkEqualValue = func(d *kbv1.Sotr, val string) bool {
+			return slices.ContainsFunc(d.Mobile, func(m string) bool {
+				return utils.ExtractDigits(m) == mob
+			})
+		}
 	case kbv1.SotrRequest_FIO:
-		err = findByFieldVal(func(d *kbv1.Sotr, val string) bool {
-			return d.Name == val
-		})
+		// split FIO on name and mid_name
+		slFio := strings.Fields(query.Str)
+		name, midName := strings.Join(slFio, " "), ""
+		if len(slFio) > 2 {
+			name, midName = strings.Join(slFio[:2], " "), slFio[2]
+		}
+		checkEqualValue = func(d *kbv1.Sotr, val string) bool {
+			return d.Name == name && (midName == "" || d.MidName == midName)
+		}
 	case kbv1.SotrRequest_TABNUM:
-		err = findByFieldVal(func(d *kbv1.Sotr, val string) bool {
+		checkEqualValue = func(d *kbv1.Sotr, val string) bool {
 			return d.Tabnum == val
-		})
+		}
 	default:
 		// err = fmt.Errorf("invalid field name; field=%s", field)
 		err = &FieldNameError{Name: "undefined"}
 		return
 	}
 
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	f.flS.Seek(0, io.SeekStart)
+
+	// updated sotr is appended to the file, so the last raw by tabnum is actual
+	tabnums := make([]string, 0)
+	actual := make(map[string]*kbv1.Sotr)
+
+	for {
+		sotr := &kbv1.Sotr{}
+		s, e := f.rwrSotr.ReadString('\n')
+
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			err = e
+			return
+		}
+
+		// opts := &protojson.UnmarshalOptions{DiscardUnknown: true, AllowPartial: true}
+		e = protojson.Unmarshal([]byte(s), sotr)
+		if e != nil {
+			f.Log.Error("GetSotrsBy: unmurshall json", "error", e, "field", field, "json", s)
+			continue
+		}
+
+		if _, ok := actual[sotr.Tabnum]; !ok {
+			tabnums = append(tabnums, sotr.Tabnum)
+		}
+		actual[sotr.Tabnum] = sotr
+	}
+
+	SotrsResponse = make([]*kbv1.Sotr, 0, 3)
+	for _, tn := range tabnums {
+		if checkEqualValue(actual[tn], query.Str) {
+			SotrsResponse = append(SotrsResponse, actual[tn])
+		}
+	}
+
+	return
+}
+
+// GetHistory returns histories of the sotr by ID
+func (f *FileStore) GetHistory(_ context.Context, query *kbv1.HistRequest) (lhist *kbv1.HistoryListResponse, err error) {
+	id, err := strconv.ParseUint(query.SotrId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sotr id \"%s\": %w", query.SotrId, err)
+	}
+
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	f.flH.Seek(0, io.SeekStart)
+
+	lhist = &kbv1.HistoryListResponse{}
+	for {
+		h := &kbv1.History{}
+		s, e := f.rwrHist.ReadString('\n')
+
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			err = e
+			return
+		}
+
+		e = protojson.Unmarshal([]byte(s), h)
+		if e != nil {
+			f.Log.Error("GetHistory: unmurshall json", "error", e, "json", s)
+			continue
+		}
+
+		if h.SotrId == id {
+			lhist.HistoryList = append(lhist.HistoryList, h)
+		}
+	}
+
+	return
+}
+
+// GetVersions returns times of the latest changes in histories of employees by one read of the history file
+func (f *FileStore) GetVersions(_ context.Context, q *kbv1.VersionsRequest) (resp *kbv1.VersionsResponse, err error) {
+	ids := make(map[uint64]struct{}, len(q.SotrIds))
+	for _, id := range q.SotrIds {
+		ids[id] = struct{}{}
+	}
+
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	f.flH.Seek(0, io.SeekStart)
+
+	resp = &kbv1.VersionsResponse{Versions: make(map[uint64]*timestamppb.Timestamp)}
+	for {
+		h := &kbv1.History{}
+		s, e := f.rwrHist.ReadString('\n')
+
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			err = e
+			return
+		}
+
+		e = protojson.Unmarshal([]byte(s), h)
+		if e != nil {
+			f.Log.Error("GetVersions: unmurshall json", "error", e, "json", s)
+			continue
+		}
+
+		if _, ok := ids[h.SotrId]; !ok {
+			continue
+		}
+		if v, ok := resp.Versions[h.SotrId]; !ok || h.Date.AsTime().After(v.AsTime()) {
+			resp.Versions[h.SotrId] = h.Date
+		}
+	}
+
 	return
 }
 
+// PromCollector returns metrics of the storage: row counts, file sizes and line counts
 func (f *FileStore) PromCollector() prometheus.Collector {
-	return nil
+	return f.metrx
 }
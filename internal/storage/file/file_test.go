@@ -4,9 +4,11 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"strings"
 	"testing"
 
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
@@ -98,3 +100,55 @@ func TestSotrsResponseBy(t *testing.T) {
 		})
 	}
 }
+
+func TestPromCollector(t *testing.T) {
+	ctx := context.Background()
+	stor, err := NewFileStore(t.TempDir(), slog.Default())
+	require.NoError(t, err)
+	defer stor.Close()
+
+	dep := &kbv1.Dep{Idr: "razd1", Parent: "razd", Text: "Отдел", Children: true}
+	sotr := proto.Clone(&expectSotr).(*kbv1.Sotr)
+	sotr.ParentId = dep.Idr
+
+	_, err = stor.Save(ctx, dep)
+	require.NoError(t, err)
+	_, err = stor.Save(ctx, sotr)
+	require.NoError(t, err)
+	_, err = stor.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	// changed sotr is saved as a new line with history
+	sotr = proto.Clone(sotr).(*kbv1.Sotr)
+	sotr.Grade = "Специалист"
+	_, err = stor.Save(ctx, sotr)
+	require.NoError(t, err)
+
+	_, err = stor.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	expected := `
+# HELP kbemp_storage_file_lines Number of lines in the file of storage
+# TYPE kbemp_storage_file_lines gauge
+kbemp_storage_file_lines{file="dep.json",storage="files"} 1
+kbemp_storage_file_lines{file="history.json",storage="files"} 1
+kbemp_storage_file_lines{file="sotr.json",storage="files"} 2
+# HELP kbemp_storage_rows Number of rows in the storage
+# TYPE kbemp_storage_rows gauge
+kbemp_storage_rows{storage="files",table="deps"} 1
+kbemp_storage_rows{storage="files",table="histories"} 1
+kbemp_storage_rows{storage="files",table="mobiles"} 1
+kbemp_storage_rows{storage="files",table="phones"} 1
+kbemp_storage_rows{storage="files",table="sotrs"} 1
+# HELP kbemp_storage_rows_affected_total Number of rows inserted, updated or deleted by flush and update
+# TYPE kbemp_storage_rows_affected_total counter
+kbemp_storage_rows_affected_total{storage="files",table="deps"} 1
+kbemp_storage_rows_affected_total{storage="files",table="histories"} 1
+kbemp_storage_rows_affected_total{storage="files",table="sotrs"} 2
+`
+	err = testutil.CollectAndCompare(stor.PromCollector(), strings.NewReader(expected),
+		"kbemp_storage_file_lines", "kbemp_storage_rows", "kbemp_storage_rows_affected_total")
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, testutil.CollectAndCount(stor.PromCollector(), "kbemp_storage_file_size_bytes"))
+}
@@ -0,0 +1,111 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// files of the storage by table name
+var files = map[string]string{
+	metrics.Deps:      "dep.json",
+	metrics.Sotrs:     "sotr.json",
+	metrics.Histories: "history.json",
+}
+
+// fileStats collects sizes and line counts of the storage files
+type fileStats struct {
+	dir         string
+	size, lines *prometheus.Desc
+}
+
+func newFileStats(dir string) *fileStats {
+	return &fileStats{
+		dir: dir,
+		size: prometheus.NewDesc("kbemp_storage_file_size_bytes",
+			"Size of the file of storage", []string{"file"}, prometheus.Labels{"storage": "files"}),
+		lines: prometheus.NewDesc("kbemp_storage_file_lines",
+			"Number of lines in the file of storage", []string{"file"}, prometheus.Labels{"storage": "files"}),
+	}
+}
+
+func (fs *fileStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fs.size
+	ch <- fs.lines
+}
+
+func (fs *fileStats) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range files {
+		path := filepath.Join(fs.dir, name)
+
+		st, err := os.Stat(path)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(fs.size, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(fs.size, prometheus.GaugeValue, float64(st.Size()), name)
+
+		n, err := countLines(path)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(fs.lines, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(fs.lines, prometheus.GaugeValue, float64(n), name)
+	}
+}
+
+// countRows counts deps and actual sotrs with their phones. A changed sotr is saved as a new line,
+// so sotrs are counted by tabnum.
+func (f *FileStore) countRows(ctx context.Context) (counts map[string]int64, err error) {
+	counts = make(map[string]int64, 5)
+
+	deps, err := f.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+	if err != nil {
+		return
+	}
+	counts[metrics.Deps] = int64(len(deps))
+
+	sotrs, err := f.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE})
+	if err != nil {
+		return
+	}
+	counts[metrics.Sotrs] = int64(len(sotrs))
+
+	for _, s := range sotrs {
+		counts[metrics.Phones] += int64(len(s.Phone))
+		counts[metrics.Mobiles] += int64(len(s.Mobile))
+	}
+
+	counts[metrics.Histories], err = countLines(filepath.Join(f.BaseDir, files[metrics.Histories]))
+	return
+}
+
+func countLines(path string) (n int64, err error) {
+	fl, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer fl.Close()
+
+	buf := make([]byte, 32*1024)
+	rd := bufio.NewReader(fl)
+
+	for {
+		c, e := rd.Read(buf)
+		n += int64(bytes.Count(buf[:c], []byte{'\n'}))
+
+		if e == io.EOF {
+			return
+		}
+		if e != nil {
+			return n, e
+		}
+	}
+}
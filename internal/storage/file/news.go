@@ -0,0 +1,140 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// newsFile contains news with comments, the changed news is appended,
+// so the last raw by idn is actual
+const newsFile = "news.json"
+
+// SaveNews appends new and changed news with comments
+func (f *FileStore) SaveNews(_ context.Context, news []*kbv1.News) (err error) {
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	actual, err := f.readNews()
+	if err != nil {
+		return
+	}
+
+	var lastID uint64
+	byIdn := make(map[string]*kbv1.News, len(actual))
+	for _, n := range actual {
+		byIdn[n.Idn] = n
+		lastID = max(lastID, n.Id)
+	}
+
+	fl, err := os.OpenFile(filepath.Join(f.BaseDir, newsFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("save news: %w", err)
+	}
+	defer fl.Close()
+
+	w := bufio.NewWriter(fl)
+	saved := 0
+	for _, n := range news {
+		if old, ok := byIdn[n.Idn]; ok {
+			n.Id = old.Id
+			if proto.Equal(old, n) {
+				continue
+			}
+		} else {
+			lastID++
+			n.Id = lastID
+		}
+
+		b, e := protojson.Marshal(n)
+		if e != nil {
+			return fmt.Errorf("save news %s: %w", n.Idn, e)
+		}
+		w.Write(append(b, '\n'))
+		byIdn[n.Idn] = n
+		saved++
+	}
+
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("save news: %w", err)
+	}
+
+	f.Log.Debug("saved news", "num", saved, "unchanged", len(news)-saved)
+	return
+}
+
+// ListNews returns news matched by the query without comments, the newest first
+func (f *FileStore) ListNews(_ context.Context, q *kbv1.ListNewsRequest) ([]*kbv1.News, error) {
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	news, err := f.readNews()
+	if err != nil {
+		return nil, err
+	}
+	return utils.FilterNews(news, q), nil
+}
+
+// GetNews returns the news with comments or nil if it's absent
+func (f *FileStore) GetNews(_ context.Context, idn string) (*kbv1.News, error) {
+	f.mt.Lock()
+	defer f.mt.Unlock()
+
+	news, err := f.readNews()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range news {
+		if n.Idn == idn {
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+// readNews returns actual news in order of the first saving
+func (f *FileStore) readNews() (news []*kbv1.News, err error) {
+	fl, err := os.Open(filepath.Join(f.BaseDir, newsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read news: %w", err)
+	}
+	defer fl.Close()
+
+	idx := make(map[string]int)
+	r := bufio.NewReader(fl)
+	for {
+		s, e := r.ReadString('\n')
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return nil, fmt.Errorf("read news: %w", e)
+		}
+
+		n := &kbv1.News{}
+		if e = protojson.Unmarshal([]byte(s), n); e != nil {
+			f.Log.Error("readNews: unmurshall json", "error", e, "json", s)
+			continue
+		}
+
+		if i, ok := idx[n.Idn]; ok {
+			news[i] = n
+		} else {
+			idx[n.Idn] = len(news)
+			news = append(news, n)
+		}
+	}
+	return
+}
@@ -0,0 +1,132 @@
+package gormdb
+
+import (
+	"slices"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// link of the removed employee to the deleted one
+type link struct {
+	sotr    uint
+	deleted uint
+}
+
+// RemoveAbsent moves employees of deps of the scope which are not saved to sotr_deleteds.
+// Phones, mobiles, histories and avatars of removed employees are linked to the deleted ones.
+// Queries are batched by maxBinds values, the number of removed employees is returned.
+func RemoveAbsent(tx *gorm.DB, scope []string, saved map[string]*kbv1.Sotr) (int, error) {
+	// saved employees are skipped here, NOT IN of all tabnums of the dump exceeds limits of placeholders
+	absent := []datasource.Sotr{}
+	for idrs := range slices.Chunk(scope, maxBinds) {
+		sotrs := []datasource.Sotr{}
+		if err := tx.Where("parent_idr IN ?", idrs).Find(&sotrs).Error; err != nil {
+			return 0, err
+		}
+		for _, s := range sotrs {
+			if _, ok := saved[s.Tabnum]; !ok {
+				absent = append(absent, s)
+			}
+		}
+	}
+	if len(absent) == 0 {
+		return 0, nil
+	}
+
+	// the employee could be removed earlier
+	deletedIDs := make(map[string]uint)
+	for sotrs := range slices.Chunk(absent, maxBinds) {
+		tabnums := make([]string, 0, len(sotrs))
+		for _, s := range sotrs {
+			tabnums = append(tabnums, s.Tabnum)
+		}
+
+		var rows []struct {
+			ID     uint
+			Tabnum string
+		}
+		if err := tx.Model(&datasource.SotrDeleted{}).Select("id", "tabnum").Where("tabnum IN ?", tabnums).Scan(&rows).Error; err != nil {
+			return 0, err
+		}
+		for _, r := range rows {
+			deletedIDs[r.Tabnum] = r.ID
+		}
+	}
+
+	dels := make([]*datasource.SotrDeleted, 0, len(absent))
+	var added, replaced []*datasource.SotrDeleted
+	for _, s := range absent {
+		del := &datasource.SotrDeleted{Sotr: s}
+		del.ID = deletedIDs[s.Tabnum]
+		dels = append(dels, del)
+		if del.ID == 0 {
+			added = append(added, del)
+		} else {
+			replaced = append(replaced, del)
+		}
+	}
+	if len(added) > 0 {
+		if err := tx.Omit(clause.Associations).CreateInBatches(added, 100).Error; err != nil {
+			return 0, err
+		}
+	}
+	if len(replaced) > 0 {
+		if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).
+			CreateInBatches(replaced, 100).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	links := make([]link, 0, len(absent))
+	for i, s := range absent {
+		links = append(links, link{sotr: s.ID, deleted: dels[i].ID})
+	}
+	// a link binds 3 values: the id in IN and the pair of CASE
+	for chunk := range slices.Chunk(links, maxBinds/3) {
+		if err := relink(tx, chunk); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(absent), nil
+}
+
+// relink links phones, mobiles, histories and avatars of removed employees to deleted ones and removes employees
+func relink(tx *gorm.DB, links []link) error {
+	ids := make([]uint, 0, len(links))
+	deleted := make([]uint, 0, len(links))
+	var (
+		b    strings.Builder
+		args []any
+	)
+	b.WriteString("CASE sotr_id")
+	for _, l := range links {
+		ids = append(ids, l.sotr)
+		deleted = append(deleted, l.deleted)
+		b.WriteString(" WHEN ? THEN ?")
+		args = append(args, l.sotr, l.deleted)
+	}
+	b.WriteString(" END")
+
+	// phones and mobiles of the employee removed earlier are replaced by current ones, they are unique for the deleted one
+	for _, model := range []any{&datasource.Phone{}, &datasource.Mobile{}} {
+		if err := tx.Where("sotr_deleted_id IN ?", deleted).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, model := range []any{&datasource.Phone{}, &datasource.Mobile{}, &datasource.History{}, &datasource.Avatar{}} {
+		// gorm sets columns in the order of names, so MySQL evaluates CASE before sotr_id is NULL
+		err := tx.Model(model).Where("sotr_id IN ?", ids).
+			Updates(map[string]any{"sotr_deleted_id": gorm.Expr(b.String(), args...), "sotr_id": nil}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&datasource.Sotr{}).Error
+}
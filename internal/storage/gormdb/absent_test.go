@@ -0,0 +1,32 @@
+package gormdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestRelink(t *testing.T) {
+	// the dry run renders SQL of MySQL without the connection
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user@tcp(localhost:3306)/kbemp", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+
+	var sqls []string
+	trace := func(tx *gorm.DB) {
+		sqls = append(sqls, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	}
+	require.NoError(t, db.Callback().Update().After("gorm:update").Register("test:trace", trace))
+	require.NoError(t, db.Callback().Delete().After("gorm:delete").Register("test:trace", trace))
+
+	require.NoError(t, relink(db, []link{{sotr: 1, deleted: 10}, {sotr: 2, deleted: 20}}))
+	require.Len(t, sqls, 7)
+	assert.Equal(t, "DELETE FROM `phones` WHERE sotr_deleted_id IN (10,20)", sqls[0])
+	// MySQL sets columns from left to right, sotr_deleted_id is set by sotr_id before it's NULL
+	assert.Equal(t, "UPDATE `histories` SET `sotr_deleted_id`=CASE sotr_id WHEN 1 THEN 10 WHEN 2 THEN 20 END,`sotr_id`=NULL "+
+		"WHERE sotr_id IN (1,2)", sqls[4])
+	assert.Equal(t, "DELETE FROM `sotrs` WHERE id IN (1,2)", sqls[6])
+}
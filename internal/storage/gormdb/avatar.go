@@ -0,0 +1,73 @@
+package gormdb
+
+import (
+	"context"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveAvatars saves avatars seen by the dump in one transaction. If the hash of the employee is changed
+// the new avatar is added and the old hash is saved to the history.
+func SaveAvatars(ctx context.Context, db *gorm.DB, avatars []*kbv1.Avatar) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, a := range avatars {
+			if e := saveAvatar(tx, a); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+func saveAvatar(tx *gorm.DB, a *kbv1.Avatar) (err error) {
+	cur := &datasource.Avatar{}
+	r := tx.Where("tabnum = ?", a.Tabnum).Order("last_seen desc").Limit(1).Find(cur)
+	if r.Error != nil {
+		return r.Error
+	}
+
+	if r.RowsAffected > 0 && cur.Hash == a.Hash {
+		return tx.Model(cur).Update("last_seen", a.LastSeen.AsTime()).Error
+	}
+
+	sotr := &datasource.Sotr{}
+	if err = tx.Where("tabnum = ?", a.Tabnum).Limit(1).Find(sotr).Error; err != nil {
+		return
+	}
+	var sotrID *uint
+	if sotr.ID != 0 {
+		sotrID = &sotr.ID
+	}
+
+	// the employee could return to the image seen earlier
+	err = tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tabnum"}, {Name: "hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_seen", "sotr_id"}),
+	}).Create(&datasource.Avatar{
+		Tabnum:      a.Tabnum,
+		Hash:        a.Hash,
+		Size:        a.Size,
+		ContentType: a.ContentType,
+		FirstSeen:   a.FirstSeen.AsTime(),
+		LastSeen:    a.LastSeen.AsTime(),
+		SotrID:      sotrID,
+	}).Error
+	if err != nil || r.RowsAffected == 0 || sotrID == nil {
+		return
+	}
+
+	return tx.Create(&datasource.History{Field: "avatar_hash", OldValue: cur.Hash, SotrID: sotrID}).Error
+}
+
+// GetAvatar returns the current avatar of the employee or nil if it's absent
+func GetAvatar(ctx context.Context, db *gorm.DB, tabnum string) (*kbv1.Avatar, error) {
+	cur := &datasource.Avatar{}
+	r := db.WithContext(ctx).Where("tabnum = ?", tabnum).Order("last_seen desc").Limit(1).Find(cur)
+	if r.Error != nil || r.RowsAffected == 0 {
+		return nil, r.Error
+	}
+	return cur.Conv2Kbv(), nil
+}
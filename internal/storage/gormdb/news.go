@@ -0,0 +1,125 @@
+package gormdb
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveNews saves news with comments in one transaction, the news with the same idn is replaced
+func SaveNews(ctx context.Context, db *gorm.DB, news []*kbv1.News) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, n := range news {
+			if e := saveNews(tx, n); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+}
+
+func saveNews(tx *gorm.DB, n *kbv1.News) (err error) {
+	cur := &datasource.News{}
+	if err = tx.Where("idn = ?", n.Idn).Limit(1).Find(cur).Error; err != nil {
+		return
+	}
+
+	ds := &datasource.News{
+		Idn:          n.Idn,
+		Title:        n.Title,
+		Author:       n.Author,
+		AuthorTabnum: n.AuthorTabnum,
+		Date:         n.Date.AsTime(),
+		Body:         n.Body,
+		Url:          n.Url,
+	}
+	ds.ID, ds.CreatedAt = cur.ID, cur.CreatedAt
+	if n.Date == nil {
+		ds.Date = cur.Date
+	}
+
+	if err = tx.Omit(clause.Associations).Save(ds).Error; err != nil {
+		return
+	}
+	n.Id = uint64(ds.ID)
+
+	// comments are replaced by the actual thread
+	if err = tx.Where("news_id = ?", ds.ID).Delete(&datasource.Comment{}).Error; err != nil {
+		return
+	}
+	if len(n.Comments) == 0 {
+		return
+	}
+
+	comments := make([]datasource.Comment, 0, len(n.Comments))
+	for _, c := range n.Comments {
+		dc := datasource.Comment{
+			Idc:          c.Idc,
+			ParentIdc:    c.ParentIdc,
+			Author:       c.Author,
+			AuthorTabnum: c.AuthorTabnum,
+			Text:         c.Text,
+			NewsID:       ds.ID,
+		}
+		if c.Date != nil {
+			dc.Date = c.Date.AsTime()
+		}
+		comments = append(comments, dc)
+	}
+	return tx.Create(&comments).Error
+}
+
+// ListNews returns news matched by the query without comments, the newest first
+func ListNews(ctx context.Context, db *gorm.DB, q *kbv1.ListNewsRequest) (news []*kbv1.News, err error) {
+	dsNews := []datasource.News{}
+	if err = newsQuery(db.WithContext(ctx), q).Find(&dsNews).Error; err != nil {
+		return
+	}
+
+	for _, n := range dsNews {
+		news = append(news, n.Conv2Kbv())
+	}
+	return
+}
+
+// newsQuery returns the query of news of the request.
+// MySQL has no OFFSET without LIMIT, so the offset without the limit is given with the maximal one.
+func newsQuery(db *gorm.DB, q *kbv1.ListNewsRequest) *gorm.DB {
+	r := db.Model(&datasource.News{})
+	if q.AuthorTabnum != "" {
+		r = r.Where("author_tabnum = ?", q.AuthorTabnum)
+	}
+	if q.Since != nil {
+		r = r.Where("date >= ?", q.Since.AsTime())
+	}
+	if q.Until != nil {
+		r = r.Where("date < ?", q.Until.AsTime())
+	}
+	if q.Query != "" {
+		r = r.Where("LOWER(title) LIKE ?", "%"+strings.ToLower(q.Query)+"%")
+	}
+
+	switch {
+	case q.Limit > 0:
+		r = r.Limit(int(q.Limit))
+	case q.Offset > 0:
+		r = r.Limit(math.MaxInt)
+	}
+	return r.Order("date desc").Order("id").Offset(int(q.Offset))
+}
+
+// GetNews returns the news with comments or nil if it's absent
+func GetNews(ctx context.Context, db *gorm.DB, idn string) (n *kbv1.News, err error) {
+	ds := &datasource.News{}
+	r := db.WithContext(ctx).Preload("Comments", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("idn = ?", idn).Limit(1).Find(ds)
+	if r.Error != nil || r.RowsAffected == 0 {
+		return nil, r.Error
+	}
+	return ds.Conv2Kbv(), nil
+}
@@ -0,0 +1,39 @@
+package gormdb
+
+import (
+	"strings"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestNewsQuery(t *testing.T) {
+	// the dry run renders SQL of MySQL without the connection
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user@tcp(localhost:3306)/kbemp", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name  string
+		q     *kbv1.ListNewsRequest
+		limit string
+	}{
+		{"all", &kbv1.ListNewsRequest{}, "ORDER BY date desc,id"},
+		{"limit", &kbv1.ListNewsRequest{Limit: 10}, "LIMIT 10"},
+		{"limit offset", &kbv1.ListNewsRequest{Limit: 10, Offset: 20}, "LIMIT 10 OFFSET 20"},
+		{"offset", &kbv1.ListNewsRequest{Offset: 20}, "LIMIT 9223372036854775807 OFFSET 20"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+				return newsQuery(tx, tc.q).Find(&[]datasource.News{})
+			})
+			assert.True(t, strings.HasSuffix(strings.TrimSpace(sql), tc.limit), sql)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// Package gormdb is the gorm code shared by SQL storages of PostgreSQL and MySQL
+package gormdb
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// maxBinds is the number of values bound by one IN of the query,
+// it's far below limits of placeholders of PostgreSQL and MySQL
+const maxBinds = 10000
+
+// GetVersions returns times of the latest changes in histories of employees
+// by one grouped query for every maxBinds ids
+func GetVersions(ctx context.Context, db *gorm.DB, q *kbv1.VersionsRequest) (*kbv1.VersionsResponse, error) {
+	resp := &kbv1.VersionsResponse{Versions: make(map[uint64]*timestamppb.Timestamp)}
+
+	for ids := range slices.Chunk(q.SotrIds, maxBinds) {
+		var rows []struct {
+			SotrID  uint64
+			Version time.Time
+		}
+		r := db.WithContext(ctx).Model(&datasource.History{}).
+			Select("sotr_id, MAX(created_at) AS version").
+			Where("sotr_id IN ?", ids).
+			Group("sotr_id").
+			Scan(&rows)
+		if r.Error != nil {
+			return nil, r.Error
+		}
+
+		for _, row := range rows {
+			resp.Versions[row.SotrID] = timestamppb.New(row.Version)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package mem
+
+import (
+	"context"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SaveAvatars saves avatars seen by the dump. If the hash of the employee is changed
+// the new avatar is added and the old hash is saved to the history.
+func (m *MemStore) SaveAvatars(_ context.Context, avatars []*kbv1.Avatar) error {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	if m.avatars == nil {
+		m.avatars = make(map[string][]*kbv1.Avatar, len(avatars))
+	}
+
+	for _, a := range avatars {
+		list := m.avatars[a.Tabnum]
+		if n := len(list); n > 0 && list[n-1].Hash == a.Hash {
+			list[n-1].LastSeen = a.LastSeen
+			continue
+		}
+
+		var old string
+		if n := len(list); n > 0 {
+			old = list[n-1].Hash
+		}
+		// the employee could return to the image seen earlier
+		for i, prev := range list {
+			if prev.Hash == a.Hash {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		m.avatars[a.Tabnum] = append(list, proto.Clone(a).(*kbv1.Avatar))
+
+		if s, ok := m.sotrs[a.Tabnum]; ok && old != "" {
+			m.histories = append(m.histories, &kbv1.History{
+				Date:     timestamppb.Now(),
+				Field:    "avatar_hash",
+				OldValue: old,
+				SotrId:   s.Id,
+			})
+		}
+	}
+	return nil
+}
+
+// GetAvatar returns the current avatar of the employee or nil if it's absent
+func (m *MemStore) GetAvatar(_ context.Context, tabnum string) (*kbv1.Avatar, error) {
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	list := m.avatars[tabnum]
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return proto.Clone(list[len(list)-1]).(*kbv1.Avatar), nil
+}
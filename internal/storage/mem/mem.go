@@ -0,0 +1,486 @@
+package mem
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/storage/file"
+	"github.com/mioxin/kbempgo/internal/storage/metrics"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MemStore keeps deps, sotrs and histories in memory.
+// It has the same semantic as SQL storages and is used for tests and demo.
+type MemStore struct {
+	kbv1.UnimplementedStorAPIServer
+
+	Log *slog.Logger
+	// Counter difine 2nd flash it's means load to internal maps 2nd and final part of data
+	// after that we can sync storage with internal maps
+	FlashCounter atomic.Int32
+	// Internal map contains Sotr's items by Tabnum key for save
+	Sotrmap map[string]*kbv1.Sotr
+	// Internal map contains Dep's items by Idr key for save
+	Depmap map[string]*kbv1.Dep
+
+	mt        sync.RWMutex
+	deps      []*kbv1.Dep
+	sotrs     map[string]*kbv1.Sotr
+	histories []*kbv1.History
+	// avatars of employees by tabnum, the current avatar is the last one
+	avatars map[string][]*kbv1.Avatar
+	// news by idn
+	news map[string]*kbv1.News
+	// sotrs removed from crawled deps
+	removed   []*kbv1.Sotr
+	scope     []string
+	lastDepID uint64
+	lastID    uint64
+	metrx     *metrics.Collector
+}
+
+// New creates the empty storage.
+// If seed is not empty it is a directory of file storage (dep.json and sotr.json)
+// loaded to the storage.
+func New(seed string, log *slog.Logger) (m *MemStore, err error) {
+	m = &MemStore{
+		Log:     log.With("storage", "memory"),
+		Depmap:  make(map[string]*kbv1.Dep, 50),
+		Sotrmap: make(map[string]*kbv1.Sotr, 100),
+		sotrs:   make(map[string]*kbv1.Sotr, 100),
+	}
+	m.metrx = metrics.New("memory", m.countRows, m.Log)
+
+	if seed != "" {
+		err = m.seed(seed)
+	}
+	return
+}
+
+// seed loads deps and sotrs from the dump directory
+func (m *MemStore) seed(dir string) (err error) {
+	// FileStore creates missing directory, but the seed should exist
+	if _, err = os.Stat(dir); err != nil {
+		return fmt.Errorf("seed storage: %w", err)
+	}
+
+	fs, err := file.NewFileStore(dir, m.Log)
+	if err != nil {
+		return fmt.Errorf("seed storage: %w", err)
+	}
+	defer fs.Close()
+
+	ctx := context.Background()
+
+	deps, err := fs.GetDepsBy(ctx, &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+	if err != nil {
+		return fmt.Errorf("seed storage: %w", err)
+	}
+	for _, d := range deps {
+		m.Save(ctx, d)
+	}
+	m.Flush(ctx, nil)
+
+	// updated sotrs are appended to the file, so the last one is the newest
+	sotrs, err := fs.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE})
+	if err != nil {
+		return fmt.Errorf("seed storage: %w", err)
+	}
+	for _, s := range sotrs {
+		m.Save(ctx, s)
+	}
+	m.Flush(ctx, nil)
+
+	m.Log.Info("Storage seeded", "dir", dir, "deps", len(deps), "sotrs", len(sotrs))
+	return
+}
+
+func (m *MemStore) GetDepsBy(_ context.Context, q *kbv1.DepRequest) (deps []*kbv1.Dep, err error) {
+	var match func(d *kbv1.Dep) bool
+
+	switch q.Field {
+	case kbv1.DepRequest_NONE:
+		match = func(d *kbv1.Dep) bool { return true }
+	case kbv1.DepRequest_IDR:
+		match = func(d *kbv1.Dep) bool { return d.Idr == q.Str }
+	case kbv1.DepRequest_PARENT:
+		match = func(d *kbv1.Dep) bool { return d.Parent == q.Str }
+	default:
+		return nil, &file.FieldNameError{Name: q.Field.String()}
+	}
+
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	for _, d := range m.deps {
+		if match(d) {
+			deps = append(deps, proto.Clone(d).(*kbv1.Dep))
+		}
+	}
+	return
+}
+
+// GetSotrsBy returns employee data
+func (m *MemStore) GetSotrsBy(_ context.Context, q *kbv1.SotrRequest) (sotrs []*kbv1.Sotr, err error) {
+	var match func(s *kbv1.Sotr) bool
+
+	switch q.Field {
+	case kbv1.SotrRequest_NONE:
+		match = func(s *kbv1.Sotr) bool { return true }
+	case kbv1.SotrRequest_IDR:
+		match = func(s *kbv1.Sotr) bool { return s.Idr == q.Str }
+	case kbv1.SotrRequest_PARENT:
+		match = func(s *kbv1.Sotr) bool { return s.ParentId == q.Str }
+	case kbv1.SotrRequest_TABNUM:
+		match = func(s *kbv1.Sotr) bool { return s.Tabnum == q.Str }
+	case kbv1.SotrRequest_MOBILE:
+		mob := utils.ExtractDigits(q.Str)
+		if _, e := strconv.ParseUint(mob, 10, 64); e != nil {
+			return nil, e
+		}
+		match = func(s *kbv1.Sotr) bool {
+			return slices.ContainsFunc(s.Mobile, func(m string) bool {
+				return utils.ExtractDigits(m) == mob
+			})
+		}
+	case kbv1.SotrRequest_FIO:
+		// split FIO on name and mid_name
+		slFio := strings.Fields(q.Str)
+		if len(slFio) > 2 {
+			name, midName := strings.Join(slFio[:2], " "), slFio[2]
+			match = func(s *kbv1.Sotr) bool { return s.Name == name && s.MidName == midName }
+		} else {
+			name := strings.Join(slFio, " ")
+			match = func(s *kbv1.Sotr) bool { return s.Name == name }
+		}
+	default:
+		return nil, &file.FieldNameError{Name: q.Field.String()}
+	}
+
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	for _, s := range m.sotrs {
+		if match(s) {
+			sotrs = append(sotrs, proto.Clone(s).(*kbv1.Sotr))
+		}
+	}
+	sort.Slice(sotrs, func(i, j int) bool { return sotrs[i].Id < sotrs[j].Id })
+	return
+}
+
+// GetHistory returns histories of the sotr by ID
+func (m *MemStore) GetHistory(_ context.Context, q *kbv1.HistRequest) (lhist *kbv1.HistoryListResponse, err error) {
+	id, err := strconv.ParseUint(q.SotrId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sotr id \"%s\": %w", q.SotrId, err)
+	}
+
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	lhist = &kbv1.HistoryListResponse{}
+	for _, h := range m.histories {
+		if h.SotrId == id {
+			lhist.HistoryList = append(lhist.HistoryList, proto.Clone(h).(*kbv1.History))
+		}
+	}
+	return
+}
+
+// GetVersions returns times of the latest changes in histories of employees
+func (m *MemStore) GetVersions(_ context.Context, q *kbv1.VersionsRequest) (*kbv1.VersionsResponse, error) {
+	ids := make(map[uint64]struct{}, len(q.SotrIds))
+	for _, id := range q.SotrIds {
+		ids[id] = struct{}{}
+	}
+
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	resp := &kbv1.VersionsResponse{Versions: make(map[uint64]*timestamppb.Timestamp)}
+	for _, h := range m.histories {
+		if _, ok := ids[h.SotrId]; !ok {
+			continue
+		}
+		if v, ok := resp.Versions[h.SotrId]; !ok || h.Date.AsTime().After(v.AsTime()) {
+			resp.Versions[h.SotrId] = h.Date
+		}
+	}
+	return resp, nil
+}
+
+// Staging reports that saved items are staged in maps until Flush
+func (m *MemStore) Staging() bool { return true }
+
+// Save Item data to internal maps
+func (m *MemStore) Save(_ context.Context, item models.Item) (em *emptypb.Empty, err error) {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	if item.GetChildren() {
+		kbvDep, ok := item.(*kbv1.Dep)
+		if !ok {
+			err = fmt.Errorf("not kbv1_item: %v", item)
+			return
+		}
+
+		m.Depmap[kbvDep.Idr] = kbvDep
+		m.metrx.Staged.WithLabelValues(metrics.Deps).Set(float64(len(m.Depmap)))
+	} else {
+		kbvSotr, ok := item.(*kbv1.Sotr)
+		if !ok {
+			err = fmt.Errorf("not kbv1_item: %v", item)
+			return
+		}
+
+		m.Sotrmap[kbvSotr.Tabnum] = kbvSotr
+		m.metrx.Staged.WithLabelValues(metrics.Sotrs).Set(float64(len(m.Sotrmap)))
+	}
+	return
+}
+
+// Update saves the employee by tabnum immediately.
+// Histories of changed fields are generated by comparing with the saved sotr,
+// so HistoryList of the request is not used.
+func (m *MemStore) Update(_ context.Context, q *kbv1.UpdateSotrRequest) (em *emptypb.Empty, err error) {
+	sotr := q.GetSotr()
+	if sotr == nil || sotr.Tabnum == "" {
+		return nil, fmt.Errorf("update: tabnum of sotr is empty")
+	}
+
+	cleared := q.GetClear().GetPaths()
+	if err = datasource.CheckCleared(cleared); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	m.upsertSotr(sotr, time.Now(), cleared)
+	return
+}
+
+func (m *MemStore) Close() (err error) {
+	return
+}
+
+// Sync data in storage with internal maps
+func (m *MemStore) Flush(_ context.Context, _ *emptypb.Empty) (_ *emptypb.Empty, err error) {
+	m.FlashCounter.Add(1)
+	// 1st Flash after saving DepsResponse, and 2nd final flash after saving SotrsResponse
+	if m.FlashCounter.Load() < 2 {
+		return
+	}
+	defer m.FlashCounter.Store(0)
+
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	now := time.Now()
+	defer m.metrx.ObserveFlush(now)
+
+	// sorted keys give the same IDs for the same data
+	for _, idr := range sortedKeys(m.Depmap) {
+		m.upsertDep(m.Depmap[idr])
+	}
+	m.Log.Info("Flash: upsert deps", "len_Depmap", len(m.Depmap))
+	m.metrx.AddRows(metrics.Deps, int64(len(m.Depmap)))
+
+	idrs := make(map[string]struct{}, len(m.deps))
+	for _, d := range m.deps {
+		idrs[d.Idr] = struct{}{}
+	}
+
+	num := 0
+	for _, tabnum := range sortedKeys(m.Sotrmap) {
+		s := m.Sotrmap[tabnum]
+
+		if _, ok := idrs[s.ParentId]; !ok {
+			m.Log.Warn("Flash: skip item, Dep not found for", "kbv1_sotr", s)
+			continue
+		}
+
+		m.upsertSotr(s, now, nil)
+		num++
+	}
+	m.Log.Info("Flash: upsert sotrs", "num", num, "len_Sotrmap", len(m.Sotrmap))
+	m.metrx.AddRows(metrics.Sotrs, int64(num))
+
+	m.removeAbsent()
+	return
+}
+
+// SetScope sets idrs of deps crawled by the dump
+func (m *MemStore) SetScope(idrs []string) {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	m.scope = idrs
+}
+
+// removeAbsent removes sotrs of deps of the scope which are not saved. The scope is reset.
+func (m *MemStore) removeAbsent() {
+	if m.scope == nil {
+		return
+	}
+
+	scope := make(map[string]struct{}, len(m.scope))
+	for _, idr := range m.scope {
+		scope[idr] = struct{}{}
+	}
+	m.scope = nil
+
+	num := 0
+	for _, tabnum := range sortedKeys(m.sotrs) {
+		s := m.sotrs[tabnum]
+		if _, ok := scope[s.ParentId]; !ok {
+			continue
+		}
+		if _, ok := m.Sotrmap[tabnum]; ok {
+			continue
+		}
+
+		delete(m.sotrs, tabnum)
+		m.removed = append(m.removed, s)
+		num++
+	}
+	m.Log.Info("Flash: remove sotrs absent in crawled deps", "num", num, "len_scope", len(scope))
+	m.metrx.AddRows(metrics.Sotrs, int64(num))
+
+	// items of the next dump are staged from scratch, otherwise they stay saved
+	m.resetStaged()
+}
+
+// resetStaged clears items staged by the dump
+func (m *MemStore) resetStaged() {
+	clear(m.Sotrmap)
+	clear(m.Depmap)
+	m.metrx.Staged.WithLabelValues(metrics.Sotrs).Set(0)
+	m.metrx.Staged.WithLabelValues(metrics.Deps).Set(0)
+}
+
+// upsertDep inserts the dep or updates one with the same idr, parent and text
+func (m *MemStore) upsertDep(dep *kbv1.Dep) {
+	for _, d := range m.deps {
+		if d.Idr == dep.Idr && d.Parent == dep.Parent && d.Text == dep.Text {
+			d.Children = dep.Children
+			dep.Id = d.Id
+			return
+		}
+	}
+
+	m.lastDepID++
+	dep.Id = m.lastDepID
+	m.deps = append(m.deps, proto.Clone(dep).(*kbv1.Dep))
+}
+
+// upsertSotr inserts the sotr or updates one with the same tabnum and saves histories of changes.
+// Sotr is converted like in SQL storages, so mobiles get the same format and duplicates of phones are removed.
+// Empty attributes of HR exports keep saved values except cleared ones.
+func (m *MemStore) upsertSotr(sotr *kbv1.Sotr, now time.Time, cleared []string) {
+	ds := utils.ConvKbv2Ds(sotr).(*datasource.Sotr)
+	ds.Phone = uniq(ds.Phone, func(p datasource.Phone) string { return p.Phone })
+	ds.Mobile = uniq(ds.Mobile, func(p datasource.Mobile) uint { return p.Mobile })
+
+	s := ds.Conv2Kbv().GetSotr()
+
+	old, ok := m.sotrs[sotr.Tabnum]
+	if ok {
+		s.Id = old.Id
+		s.Date = old.Date
+		utils.KeepImported(old, s, cleared)
+
+		m.histories = append(m.histories, utils.DiffSotr(old, s)...)
+	} else {
+		m.lastID++
+		s.Id = m.lastID
+		s.Date = timestamppb.New(now)
+	}
+
+	m.sotrs[s.Tabnum] = s
+	sotr.Id = s.Id
+}
+
+// PromCollector returns metrics of the storage
+func (m *MemStore) PromCollector() prometheus.Collector {
+	return m.metrx
+}
+
+// Migrate does nothing for the memory storage
+func (m *MemStore) Migrate(ctx context.Context, down bool) (err error) {
+	return
+}
+
+// Retention deletes histories older than passed time
+func (m *MemStore) Retention(ctx context.Context, olderThan time.Time) (err error) {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	m.histories = slices.DeleteFunc(m.histories, func(h *kbv1.History) bool {
+		return h.Date.AsTime().Before(olderThan)
+	})
+	return
+}
+
+// Counts returns numbers of deps, sotrs, phones, mobiles and histories in the storage
+func (m *MemStore) Counts() (deps, sotrs, phones, mobiles, histories int) {
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	for _, s := range m.sotrs {
+		phones += len(s.Phone)
+		mobiles += len(s.Mobile)
+	}
+	return len(m.deps), len(m.sotrs), phones, mobiles, len(m.histories)
+}
+
+func (m *MemStore) countRows(_ context.Context) (map[string]int64, error) {
+	deps, sotrs, phones, mobiles, histories := m.Counts()
+
+	return map[string]int64{
+		metrics.Deps:      int64(deps),
+		metrics.Sotrs:     int64(sotrs),
+		metrics.Phones:    int64(phones),
+		metrics.Mobiles:   int64(mobiles),
+		metrics.Histories: int64(histories),
+	}, nil
+}
+
+func sortedKeys[V any](mp map[string]V) []string {
+	keys := make([]string, 0, len(mp))
+	for k := range mp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// uniq removes items with duplicate keys keeping the order
+func uniq[T any, K comparable](items []T, key func(T) K) []T {
+	seen := make(map[K]struct{}, len(items))
+	return slices.DeleteFunc(items, func(it T) bool {
+		k := key(it)
+		if _, ok := seen[k]; ok {
+			return true
+		}
+		seen[k] = struct{}{}
+		return false
+	})
+}
@@ -0,0 +1,351 @@
+package mem
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func loadStore(t *testing.T) *MemStore {
+	t.Helper()
+
+	store, err := New("", slog.Default())
+	require.NoError(t, err)
+
+	loadDB(t, store)
+	return store
+}
+
+func loadDB(t *testing.T, store *MemStore) {
+	t.Helper()
+
+	depsResponse := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(t, "dep.json", depsResponse)
+
+	sotrsResponse := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(t, "sotr.json", sotrsResponse)
+
+	for _, d := range depsResponse.Deps {
+		_, err := store.Save(context.Background(), d)
+		require.NoError(t, err)
+	}
+	// 1st Flash should be skiped
+	store.Flush(context.Background(), nil)
+
+	for _, s := range sotrsResponse.Sotrs {
+		if ms, ok := store.Sotrmap[s.Tabnum]; ok {
+			// keep mutations of the test
+			s = ms
+		}
+		_, err := store.Save(context.Background(), s)
+		require.NoError(t, err)
+	}
+
+	_, err := store.Flush(context.Background(), nil)
+	require.NoError(t, err)
+}
+
+func counts(store *MemStore) *storetest.Counts {
+	c := &storetest.Counts{}
+	deps, sotrs, phones, mobiles, histories := store.Counts()
+
+	c.AddDeps(deps)
+	c.AddSotrs(sotrs)
+	c.AddPhones(phones)
+	c.AddMobiles(mobiles)
+	c.AddHistories(histories)
+	return c
+}
+
+func TestAddSotr(t *testing.T) {
+	mid := "Gulim.99999999@k.kom"
+	newSotr := &kbv1.Sotr{
+		Idr:      "s99999",
+		Tabnum:   "99999",
+		Name:     "999999999 Гулим",
+		Email:    mid,
+		Phone:    []string{"400-88888"},
+		Avatar:   "/avatar/25301.jpg",
+		Grade:    "Главный Специалист",
+		ParentId: "razd1.27.2935.69",
+	}
+
+	store := loadStore(t)
+	expectedCounts := counts(store)
+
+	store.Sotrmap[newSotr.Tabnum] = newSotr
+	loadDB(t, store)
+
+	expectedCounts.AddSotrs(1)
+	expectedCounts.AddPhones(1)
+	assert.EqualValues(t, expectedCounts, counts(store))
+	assert.NotZero(t, newSotr.Id)
+}
+
+func TestHistory(t *testing.T) {
+	for _, tc := range storetest.HistCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			store := loadStore(t)
+			expectedCounts := counts(store)
+
+			storetest.UpdateSotr(store.Sotrmap[tc.TabMutate], tc)
+			loadDB(t, store)
+
+			tc.UpdateCounts(expectedCounts)
+			assert.EqualValues(t, expectedCounts, counts(store))
+
+			sotrID := store.Sotrmap[tc.TabMutate].Id
+			hl, err := store.GetHistory(context.Background(), &kbv1.HistRequest{SotrId: strconv.FormatUint(sotrID, 10)})
+			require.NoError(t, err)
+
+			actualHist := make([]datasource.History, 0, len(hl.HistoryList))
+			for _, h := range hl.HistoryList {
+				assert.Equal(t, sotrID, h.SotrId)
+				actualHist = append(actualHist, datasource.History{Field: h.Field, OldValue: h.OldValue})
+			}
+			assert.EqualValues(t, tc.ExpectedHistories, actualHist)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	store := loadStore(t)
+
+	sotrs, err := store.GetSotrsBy(context.Background(), &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: "60609"})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+
+	s := sotrs[0]
+	s.Grade = "LLLLLLLL"
+	_, err = store.Update(context.Background(), &kbv1.UpdateSotrRequest{Sotr: s})
+	require.NoError(t, err)
+
+	sotrs, err = store.GetSotrsBy(context.Background(), &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: "60609"})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+	assert.Equal(t, "LLLLLLLL", sotrs[0].Grade)
+	assert.Equal(t, s.Id, sotrs[0].Id)
+
+	hl, err := store.GetHistory(context.Background(), &kbv1.HistRequest{SotrId: strconv.FormatUint(s.Id, 10)})
+	require.NoError(t, err)
+	require.Len(t, hl.HistoryList, 1)
+	assert.Equal(t, "grade", hl.HistoryList[0].Field)
+	assert.Equal(t, "Главный Специалист", hl.HistoryList[0].OldValue)
+}
+
+func TestSaveAvatars(t *testing.T) {
+	store := loadStore(t)
+	ctx := context.Background()
+
+	sotrs, err := store.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: "60609"})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+
+	a, err := store.GetAvatar(ctx, "60609")
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	avatar := func(hash string, seen time.Time) *kbv1.Avatar {
+		return &kbv1.Avatar{Tabnum: "60609", Hash: hash, Size: 10, FirstSeen: timestamppb.New(seen), LastSeen: timestamppb.New(seen)}
+	}
+
+	// the same image is seen again
+	require.NoError(t, store.SaveAvatars(ctx, []*kbv1.Avatar{avatar("aaa", day)}))
+	require.NoError(t, store.SaveAvatars(ctx, []*kbv1.Avatar{avatar("aaa", day.AddDate(0, 0, 1))}))
+
+	a, err = store.GetAvatar(ctx, "60609")
+	require.NoError(t, err)
+	assert.Equal(t, "aaa", a.Hash)
+	assert.Equal(t, day, a.FirstSeen.AsTime())
+	assert.Equal(t, day.AddDate(0, 0, 1), a.LastSeen.AsTime())
+
+	hl, err := store.GetHistory(ctx, &kbv1.HistRequest{SotrId: strconv.FormatUint(sotrs[0].Id, 10)})
+	require.NoError(t, err)
+	assert.Empty(t, hl.HistoryList)
+
+	// the image is changed
+	require.NoError(t, store.SaveAvatars(ctx, []*kbv1.Avatar{avatar("bbb", day.AddDate(0, 0, 2))}))
+
+	a, err = store.GetAvatar(ctx, "60609")
+	require.NoError(t, err)
+	assert.Equal(t, "bbb", a.Hash)
+
+	hl, err = store.GetHistory(ctx, &kbv1.HistRequest{SotrId: strconv.FormatUint(sotrs[0].Id, 10)})
+	require.NoError(t, err)
+	require.Len(t, hl.HistoryList, 1)
+	assert.Equal(t, "avatar_hash", hl.HistoryList[0].Field)
+	assert.Equal(t, "aaa", hl.HistoryList[0].OldValue)
+}
+
+func TestGetSotrsBy(t *testing.T) {
+	store := loadStore(t)
+
+	for _, tc := range storetest.GetSotrsCases {
+		t.Run(tc.By, func(t *testing.T) {
+			expextedSotrs := &kbv1.SotrsResponse{}
+			err := protojson.Unmarshal([]byte(tc.Expected), expextedSotrs)
+			require.NoError(t, err)
+
+			i, ok := kbv1.SotrRequest_DBField_value[tc.By]
+			require.True(t, ok, "invalid field name for get sotrs by", tc.By)
+
+			sotrs, err := store.GetSotrsBy(context.Background(), &kbv1.SotrRequest{
+				Field: kbv1.SotrRequest_DBField(i),
+				Str:   tc.Val,
+			})
+			require.NoError(t, err)
+			require.NotEmpty(t, sotrs)
+			if len(expextedSotrs.Sotrs) != len(sotrs) {
+				return
+			}
+
+			exp := utils.ConvKbv2Ds(expextedSotrs.Sotrs[0])
+			actual := utils.ConvKbv2Ds(sotrs[0])
+			assert.EqualValues(t, exp, actual)
+		})
+	}
+}
+
+func TestGetDepsBy(t *testing.T) {
+	store := loadStore(t)
+
+	for _, tc := range storetest.GetDepsCases {
+		t.Run(tc.By, func(t *testing.T) {
+			expextedDeps := &kbv1.DepsResponse{}
+			err := protojson.Unmarshal([]byte(tc.Expected), expextedDeps)
+			require.NoError(t, err)
+
+			i, ok := kbv1.DepRequest_DBField_value[tc.By]
+			require.True(t, ok, "invalid field name for get deps by", tc.By)
+
+			deps, err := store.GetDepsBy(context.Background(), &kbv1.DepRequest{
+				Field: kbv1.DepRequest_DBField(i),
+				Str:   tc.Val,
+			})
+			require.NoError(t, err)
+			require.Len(t, deps, len(expextedDeps.Deps))
+
+			for i := range deps {
+				deps[i].Id = 0
+				assert.EqualValues(t, utils.ConvKbv2Ds(expextedDeps.Deps[i]), utils.ConvKbv2Ds(deps[i]))
+			}
+		})
+	}
+}
+
+func TestSeed(t *testing.T) {
+	store, err := New("../file/testdata", slog.Default())
+	require.NoError(t, err)
+
+	deps, err := store.GetDepsBy(context.Background(), &kbv1.DepRequest{Field: kbv1.DepRequest_IDR, Str: "razd1941.840"})
+	require.NoError(t, err)
+	require.Len(t, deps, 1)
+	assert.Equal(t, "Управление разработки", deps[0].Text)
+
+	_, err = New("./testdata/not_exists", slog.Default())
+	assert.Error(t, err)
+}
+
+func TestSetScope(t *testing.T) {
+	store := loadStore(t)
+	ctx := context.Background()
+
+	sotr := func(tabnum string) *kbv1.Sotr {
+		sotrs, err := store.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: tabnum})
+		require.NoError(t, err)
+		if len(sotrs) == 0 {
+			return nil
+		}
+		return sotrs[0]
+	}
+
+	// the next dump refreshes razd1.27.2935.69 only, 25301 is absent in it
+	store.Sotrmap = make(map[string]*kbv1.Sotr)
+	_, err := store.Save(ctx, sotr("2681"))
+	require.NoError(t, err)
+
+	store.SetScope([]string{"razd1.27.2935.69"})
+	store.Flush(ctx, nil)
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	assert.Nil(t, sotr("25301"), "absent sotr of crawled dep should be removed")
+	assert.NotNil(t, sotr("2681"))
+	assert.NotNil(t, sotr("1600"), "sotr outside of crawled deps should be kept")
+	assert.Len(t, store.removed, 1)
+
+	// the scope is reset by flush
+	store.Sotrmap = make(map[string]*kbv1.Sotr)
+	store.Flush(ctx, nil)
+	store.Flush(ctx, nil)
+	assert.NotNil(t, sotr("2681"))
+}
+
+func TestSetScopeDumps(t *testing.T) {
+	store, err := New("", slog.Default())
+	require.NoError(t, err)
+	ctx := context.Background()
+	scope := []string{"razd1.27.2935.69"}
+
+	// the first dump saves all sotrs of the scope
+	store.SetScope(scope)
+	loadDB(t, store)
+	assert.Empty(t, store.Sotrmap, "staged sotrs should be reset by the scoped flush")
+
+	// the second dump misses 25301
+	sotrs, err := store.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: "2681"})
+	require.NoError(t, err)
+	require.Len(t, sotrs, 1)
+	_, err = store.Save(ctx, sotrs[0])
+	require.NoError(t, err)
+
+	store.SetScope(scope)
+	store.Flush(ctx, nil)
+	_, err = store.Flush(ctx, nil)
+	require.NoError(t, err)
+
+	sotrs, err = store.GetSotrsBy(ctx, &kbv1.SotrRequest{Field: kbv1.SotrRequest_TABNUM, Str: "25301"})
+	require.NoError(t, err)
+	assert.Empty(t, sotrs, "sotr absent in the second dump should be removed")
+	assert.Len(t, store.removed, 1)
+}
+
+func TestPromCollector(t *testing.T) {
+	store := loadStore(t)
+	deps, sotrs, phones, mobiles, histories := store.Counts()
+
+	expected := fmt.Sprintf(`
+# HELP kbemp_storage_rows Number of rows in the storage
+# TYPE kbemp_storage_rows gauge
+kbemp_storage_rows{storage="memory",table="deps"} %d
+kbemp_storage_rows{storage="memory",table="histories"} %d
+kbemp_storage_rows{storage="memory",table="mobiles"} %d
+kbemp_storage_rows{storage="memory",table="phones"} %d
+kbemp_storage_rows{storage="memory",table="sotrs"} %d
+# HELP kbemp_storage_staged_items Number of saved items waiting for flush
+# TYPE kbemp_storage_staged_items gauge
+kbemp_storage_staged_items{map="deps",storage="memory"} %d
+kbemp_storage_staged_items{map="sotrs",storage="memory"} %d
+`, deps, histories, mobiles, phones, sotrs, len(store.Depmap), len(store.Sotrmap))
+
+	err := testutil.CollectAndCompare(store.PromCollector(), strings.NewReader(expected),
+		"kbemp_storage_rows", "kbemp_storage_staged_items")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(store.PromCollector(), "kbemp_storage_flush_duration_seconds"))
+}
@@ -0,0 +1,54 @@
+package mem
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+// SaveNews saves news with comments, the news with the same idn is replaced
+func (m *MemStore) SaveNews(_ context.Context, news []*kbv1.News) error {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	if m.news == nil {
+		m.news = make(map[string]*kbv1.News, len(news))
+	}
+
+	for _, n := range news {
+		c := proto.Clone(n).(*kbv1.News)
+		if old, ok := m.news[n.Idn]; ok {
+			c.Id = old.Id
+		} else {
+			c.Id = uint64(len(m.news) + 1)
+		}
+		n.Id = c.Id
+		m.news[n.Idn] = c
+	}
+	return nil
+}
+
+// ListNews returns news matched by the query without comments, the newest first
+func (m *MemStore) ListNews(_ context.Context, q *kbv1.ListNewsRequest) ([]*kbv1.News, error) {
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	news := slices.SortedFunc(maps.Values(m.news), func(a, b *kbv1.News) int { return int(a.Id) - int(b.Id) })
+	return utils.FilterNews(news, q), nil
+}
+
+// GetNews returns the news with comments or nil if it's absent
+func (m *MemStore) GetNews(_ context.Context, idn string) (*kbv1.News, error) {
+	m.mt.RLock()
+	defer m.mt.RUnlock()
+
+	n, ok := m.news[idn]
+	if !ok {
+		return nil, nil
+	}
+	return proto.Clone(n).(*kbv1.News), nil
+}
@@ -0,0 +1,124 @@
+// Package metrics provides prometheus collector of storage metrics
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "kbemp"
+	subsystem = "storage"
+)
+
+// Tables of storage. File storage uses the same names for its data.
+const (
+	Deps      = "deps"
+	Sotrs     = "sotrs"
+	Phones    = "phones"
+	Mobiles   = "mobiles"
+	Histories = "histories"
+)
+
+// RowCounter returns numbers of rows by table name
+type RowCounter func(ctx context.Context) (map[string]int64, error)
+
+// Collector is prometheus.Collector of a storage.
+// Row counts are taken by RowCounter on scrape, other metrics are updated by the storage.
+type Collector struct {
+	FlushDuration prometheus.Histogram
+	// rows inserted, updated or deleted by flush and update by table
+	RowsAffected *prometheus.CounterVec
+	// size of internal maps of items waiting for flush
+	Staged *prometheus.GaugeVec
+
+	rows      *prometheus.Desc
+	countRows RowCounter
+	extra     []prometheus.Collector
+	log       *slog.Logger
+}
+
+// New creates collector of the storage. Extra collectors are collected with the storage metrics.
+func New(storage string, countRows RowCounter, log *slog.Logger, extra ...prometheus.Collector) *Collector {
+	labels := prometheus.Labels{"storage": storage}
+
+	return &Collector{
+		FlushDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "flush_duration_seconds",
+			Help:        "Duration of flush of saved items to the storage",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.01, 4, 8),
+		}),
+		RowsAffected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "rows_affected_total",
+			Help:        "Number of rows inserted, updated or deleted by flush and update",
+			ConstLabels: labels,
+		}, []string{"table"}),
+		Staged: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "staged_items",
+			Help:        "Number of saved items waiting for flush",
+			ConstLabels: labels,
+		}, []string{"map"}),
+		rows: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "rows"),
+			"Number of rows in the storage",
+			[]string{"table"}, labels,
+		),
+		countRows: countRows,
+		extra:     extra,
+		log:       log,
+	}
+}
+
+// ObserveFlush observes duration of the flush started at start
+func (c *Collector) ObserveFlush(start time.Time) {
+	c.FlushDuration.Observe(time.Since(start).Seconds())
+}
+
+// AddRows adds affected rows of the table
+func (c *Collector) AddRows(table string, n int64) {
+	c.RowsAffected.WithLabelValues(table).Add(float64(n))
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rows
+	c.FlushDuration.Describe(ch)
+	c.RowsAffected.Describe(ch)
+	c.Staged.Describe(ch)
+
+	for _, e := range c.extra {
+		e.Describe(ch)
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	counts, err := c.countRows(ctx)
+	if err != nil {
+		c.log.Error("Metrics: count rows", "err", err)
+		ch <- prometheus.NewInvalidMetric(c.rows, err)
+	}
+
+	for table, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.rows, prometheus.GaugeValue, float64(n), table)
+	}
+
+	c.FlushDuration.Collect(ch)
+	c.RowsAffected.Collect(ch)
+	c.Staged.Collect(ch)
+
+	for _, e := range c.extra {
+		e.Collect(ch)
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"gorm.io/gorm"
+)
+
+// SQLRowCounter counts rows of the tables in SQL storage
+func SQLRowCounter(db *gorm.DB) RowCounter {
+	models := map[string]any{
+		Deps:      &datasource.Dep{},
+		Sotrs:     &datasource.Sotr{},
+		Phones:    &datasource.Phone{},
+		Mobiles:   &datasource.Mobile{},
+		Histories: &datasource.History{},
+	}
+
+	return func(ctx context.Context) (counts map[string]int64, err error) {
+		counts = make(map[string]int64, len(models))
+
+		for table, model := range models {
+			var n int64
+			if err = db.WithContext(ctx).Model(model).Count(&n).Error; err != nil {
+				return
+			}
+			counts[table] = n
+		}
+		return
+	}
+}
@@ -0,0 +1,671 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	mysqldrv "github.com/go-sql-driver/mysql"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/storage/file"
+	"github.com/mioxin/kbempgo/internal/storage/gormdb"
+	"github.com/mioxin/kbempgo/internal/storage/metrics"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	gmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultStringSize is the size of varchar for string fields without size tag.
+// MySQL can't build the unique index of deps on a TEXT column.
+const DefaultStringSize uint = 255
+
+// MysqlStore is a storage in MySQL or MariaDB
+type MysqlStore struct {
+	kbv1.UnimplementedStorAPIServer
+
+	DB  *gorm.DB
+	Log *slog.Logger
+	// Counter difine 2nd flash it's means load to internal maps 2nd and final part of data
+	// after that we can sync DB with internal maps
+	FlashCounter atomic.Int32
+	// Internal map contains Sotr's items by Tabnum key for save
+	Sotrmap map[string]*kbv1.Sotr
+	// Internal map contains Dep's items by Idr key for save
+	Depmap map[string]*kbv1.Dep
+
+	// idrs of deps crawled by the dump
+	scope []string
+
+	mt    sync.Mutex
+	metrx *metrics.Collector
+}
+
+// New opens the storage. dsn is in the go-sql-driver format:
+// user:password@tcp(localhost:3306)/dbname?params
+func New(dsn string, log *slog.Logger) (ms *MysqlStore, err error) {
+	cfg, err := mysqldrv.ParseDSN(dsn)
+	if err != nil {
+		err = fmt.Errorf("error create Store, invalid source. %w", err)
+		return
+	}
+	// scan DATETIME to time.Time of gorm.Model
+	cfg.ParseTime = true
+
+	db, err := gorm.Open(newDialector(gmysql.Config{
+		DSN:               cfg.FormatDSN(),
+		DefaultStringSize: DefaultStringSize,
+	}), &gorm.Config{})
+
+	if err != nil {
+		err = fmt.Errorf("error create Store, invalid source. %w", err)
+		return
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+
+	ms = &MysqlStore{
+		DB:      db,
+		Log:     log.With("storage", "mysql"),
+		Depmap:  make(map[string]*kbv1.Dep, 50),
+		Sotrmap: make(map[string]*kbv1.Sotr, 100),
+	}
+	ms.metrx = metrics.New("mysql", metrics.SQLRowCounter(db), ms.Log,
+		collectors.NewDBStatsCollector(sqlDB, cfg.DBName))
+
+	return ms, nil
+}
+
+func (m *MysqlStore) GetDepsBy(ctx context.Context, q *kbv1.DepRequest) (deps []*kbv1.Dep, err error) {
+	var (
+		r     *gorm.DB
+		items []datasource.Dep
+	)
+	db := m.DB.WithContext(ctx)
+
+	switch q.Field {
+	case kbv1.DepRequest_NONE:
+		r = db.Find(&items)
+	case kbv1.DepRequest_IDR:
+		r = db.Where("idr = ?", q.Str).Find(&items)
+	case kbv1.DepRequest_PARENT:
+		r = db.Where("parent = ?", q.Str).Find(&items)
+	default:
+		return nil, &file.FieldNameError{Name: q.Field.String()}
+	}
+
+	if r.Error != nil {
+		err = r.Error
+		return
+	}
+
+	for _, dsDep := range items {
+		deps = append(deps, dsDep.Conv2Kbv().GetDep())
+	}
+	return
+}
+
+// GetSotrsBy returns employee data
+func (m *MysqlStore) GetSotrsBy(ctx context.Context, q *kbv1.SotrRequest) (sotrs []*kbv1.Sotr, err error) {
+	var (
+		datasourceSotrs []datasource.Sotr
+		sotrIds         []uint
+		r               *gorm.DB
+	)
+	db := m.DB.WithContext(ctx).Preload("Phone").Preload("Mobile")
+
+	switch q.Field {
+	case kbv1.SotrRequest_MOBILE:
+		mob, e := strconv.ParseUint(utils.ExtractDigits(q.Str), 10, 64)
+		if e != nil {
+			return nil, e
+		}
+		r = m.DB.WithContext(ctx).Model(&datasource.Mobile{}).Where("mobile = ?", mob).Pluck("sotr_id", &sotrIds)
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if len(sotrIds) == 0 {
+			return
+		}
+
+		r = db.Find(&datasourceSotrs, sotrIds)
+
+	case kbv1.SotrRequest_FIO:
+		// split FIO on name and mid_name
+		slFio := strings.Fields(q.Str)
+		if len(slFio) > 2 {
+			r = db.Where("name = ? and mid_name = ?", strings.Join(slFio[:2], " "), slFio[2]).Find(&datasourceSotrs)
+		} else {
+			r = db.Where("name = ?", strings.Join(slFio, " ")).Find(&datasourceSotrs)
+		}
+
+	case kbv1.SotrRequest_TABNUM:
+		r = db.Where("tabnum = ?", q.Str).Find(&datasourceSotrs)
+
+	case kbv1.SotrRequest_IDR:
+		r = db.Where("idr = ?", q.Str).Find(&datasourceSotrs)
+
+	case kbv1.SotrRequest_PARENT:
+		r = db.Where("parent_idr = ?", q.Str).Find(&datasourceSotrs)
+
+	case kbv1.SotrRequest_NONE:
+		r = db.Find(&datasourceSotrs)
+
+	default:
+		return nil, &file.FieldNameError{Name: q.Field.String()}
+	}
+
+	if r.Error != nil {
+		err = r.Error
+		return
+	}
+
+	for _, dsSotr := range datasourceSotrs {
+		sotrs = append(sotrs, dsSotr.Conv2Kbv().GetSotr())
+	}
+	return
+}
+
+// GetHistory returns histories of the sotr by ID
+func (m *MysqlStore) GetHistory(ctx context.Context, q *kbv1.HistRequest) (lhist *kbv1.HistoryListResponse, err error) {
+	id, err := strconv.ParseUint(q.SotrId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sotr id \"%s\": %w", q.SotrId, err)
+	}
+
+	hist := []datasource.History{}
+	if r := m.DB.WithContext(ctx).Where("sotr_id = ?", id).Order("id").Find(&hist); r.Error != nil {
+		return nil, r.Error
+	}
+
+	lhist = &kbv1.HistoryListResponse{}
+	for _, h := range hist {
+		lhist.HistoryList = append(lhist.HistoryList, &kbv1.History{
+			Date:     timestamppb.New(h.CreatedAt),
+			Field:    h.Field,
+			OldValue: h.OldValue,
+			SotrId:   id,
+		})
+	}
+	return
+}
+
+// GetVersions returns times of the latest changes in histories of employees
+func (m *MysqlStore) GetVersions(ctx context.Context, q *kbv1.VersionsRequest) (*kbv1.VersionsResponse, error) {
+	return gormdb.GetVersions(ctx, m.DB, q)
+}
+
+// Staging reports that saved items are staged in maps until Flush
+func (m *MysqlStore) Staging() bool { return true }
+
+// Save Item data to internal maps
+func (m *MysqlStore) Save(_ context.Context, item models.Item) (em *emptypb.Empty, err error) {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	if item.GetChildren() {
+		kbvDep, ok := item.(*kbv1.Dep)
+		if !ok {
+			err = fmt.Errorf("not kbv1_item: %v", item)
+			return
+		}
+
+		m.Depmap[kbvDep.Idr] = kbvDep
+		m.metrx.Staged.WithLabelValues(metrics.Deps).Set(float64(len(m.Depmap)))
+	} else {
+		kbvSotr, ok := item.(*kbv1.Sotr)
+		if !ok {
+			err = fmt.Errorf("not kbv1_item: %v", item)
+			return
+		}
+
+		m.Sotrmap[kbvSotr.Tabnum] = kbvSotr
+		m.metrx.Staged.WithLabelValues(metrics.Sotrs).Set(float64(len(m.Sotrmap)))
+	}
+	return
+}
+
+// Update saves the employee by tabnum immediately.
+// Histories of changed fields are generated by comparing with the saved row,
+// so HistoryList of the request is not used.
+func (m *MysqlStore) Update(ctx context.Context, q *kbv1.UpdateSotrRequest) (em *emptypb.Empty, err error) {
+	sotr := q.GetSotr()
+	if sotr == nil || sotr.Tabnum == "" {
+		return nil, fmt.Errorf("update: tabnum of sotr is empty")
+	}
+	cleared := q.GetClear().GetPaths()
+	if err = datasource.CheckCleared(cleared); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+	sotrs := map[string]*kbv1.Sotr{sotr.Tabnum: sotr}
+
+	// cleared attributes are not kept by the hook of saving
+	err = m.DB.WithContext(datasource.WithCleared(ctx, cleared)).Transaction(func(tx *gorm.DB) error {
+		depIDs, e := m.depIDs(tx, nil, sotrs)
+		if e != nil {
+			return e
+		}
+
+		slSotr, e := m.upsertSotrs(tx, depIDs, sotrs)
+		if e != nil {
+			return e
+		}
+		if len(slSotr) == 0 {
+			return fmt.Errorf("update: dep %s of sotr %s not found", sotr.ParentId, sotr.Tabnum)
+		}
+
+		if e = m.saveHistories(tx, slSotr); e != nil {
+			return e
+		}
+
+		return m.preparePhones(tx, slSotr, sotrs)
+	})
+	return
+}
+
+// Close closes the DB connection
+func (m *MysqlStore) Close() (err error) {
+	sqlDB, err := m.DB.DB()
+	if err != nil {
+		return
+	}
+	return sqlDB.Close()
+}
+
+// Sync data in DB with internal maps
+func (m *MysqlStore) Flush(ctx context.Context, _ *emptypb.Empty) (_ *emptypb.Empty, err error) {
+	m.FlashCounter.Add(1)
+	// 1st Flash after saving DepsResponse, and 2nd final flash after saving SotrsResponse
+	if m.FlashCounter.Load() < 2 {
+		return
+	}
+	defer m.FlashCounter.Store(0)
+
+	m.mt.Lock()
+	defer m.mt.Unlock()
+	defer m.metrx.ObserveFlush(time.Now())
+
+	m.Log.Info("Start transaction flash ...")
+	err = m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if e := m.upsertDeps(tx); e != nil {
+			return e
+		}
+
+		depIDs, e := m.depIDs(tx, m.Depmap, m.Sotrmap)
+		if e != nil {
+			return e
+		}
+
+		slSotr, e := m.upsertSotrs(tx, depIDs, m.Sotrmap)
+		if e != nil {
+			return e
+		}
+
+		if e = m.saveHistories(tx, slSotr); e != nil {
+			return e
+		}
+
+		if e = m.preparePhones(tx, slSotr, m.Sotrmap); e != nil {
+			return e
+		}
+
+		return m.removeAbsent(tx)
+	})
+	// items of the next dump are staged from scratch, otherwise they stay saved
+	if err == nil && m.scope != nil {
+		clear(m.Sotrmap)
+		clear(m.Depmap)
+		m.metrx.Staged.WithLabelValues(metrics.Sotrs).Set(0)
+		m.metrx.Staged.WithLabelValues(metrics.Deps).Set(0)
+	}
+	m.scope = nil
+
+	if err != nil {
+		m.Log.Error("Rollback flash on error...", "err", err)
+	}
+	return
+}
+
+// Batch upserting deps from the internal map.
+func (m *MysqlStore) upsertDeps(tx *gorm.DB) (err error) {
+	if len(m.Depmap) == 0 {
+		return
+	}
+
+	slDep := make([]*datasource.Dep, 0, len(m.Depmap))
+	for _, d := range m.Depmap {
+		slDep = append(slDep, utils.ConvKbv2Ds(d).(*datasource.Dep))
+	}
+
+	gdb := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(&slDep, 100)
+	if gdb.Error != nil {
+		err = gdb.Error
+		m.Log.Error("Flash: upsert deps", "num", gdb.RowsAffected, "err", gdb.Error)
+	} else {
+		m.Log.Info("Flash: upsert deps", "num", gdb.RowsAffected, "len_Depmap", len(slDep))
+		m.metrx.AddRows(metrics.Deps, gdb.RowsAffected)
+	}
+	return
+}
+
+// depIDs returns ID of deps by idr for the deps and the parents of sotrs.
+// MySQL doesn't return ID of updated rows on upsert, so ones are read back.
+// If there are some deps with the same idr then the dep equal to saved one or the newest one is used.
+func (m *MysqlStore) depIDs(tx *gorm.DB, deps map[string]*kbv1.Dep, sotrs map[string]*kbv1.Sotr) (ids map[string]uint, err error) {
+	idrs := make([]string, 0, len(deps)+len(sotrs))
+	for idr := range deps {
+		idrs = append(idrs, idr)
+	}
+	for _, s := range sotrs {
+		idrs = append(idrs, s.ParentId)
+	}
+
+	ids = make(map[string]uint, len(idrs))
+	if len(idrs) == 0 {
+		return
+	}
+
+	rows := []datasource.Dep{}
+	if r := tx.Where("idr IN ?", idrs).Order("id").Find(&rows); r.Error != nil {
+		return nil, r.Error
+	}
+
+	matched := make(map[string]bool, len(deps))
+	for _, row := range rows {
+		if matched[row.Idr] {
+			continue
+		}
+		// rows are ordered by ID so the newest one is the last
+		ids[row.Idr] = row.ID
+
+		if d, ok := deps[row.Idr]; ok && d.Parent == row.Parent && d.Text == row.Text {
+			matched[row.Idr] = true
+		}
+	}
+	return
+}
+
+// Batch upserting sotrs without associations.
+// History of sotr is generated in BeforeSave hook and phones are reconciled later.
+func (m *MysqlStore) upsertSotrs(tx *gorm.DB, depIDs map[string]uint, sotrs map[string]*kbv1.Sotr) (slSotr []*datasource.Sotr, err error) {
+	slSotr = make([]*datasource.Sotr, 0, len(sotrs))
+	tabnums := make([]string, 0, len(sotrs))
+
+	for _, s := range sotrs {
+		ds := utils.ConvKbv2Ds(s).(*datasource.Sotr)
+
+		depID, ok := depIDs[ds.ParentIdr]
+		if !ok {
+			m.Log.Warn("Flash: skip item, Dep not found for", "kbv1_sotr", ds)
+			continue
+		}
+		ds.DepID = &depID
+
+		slSotr = append(slSotr, ds)
+		tabnums = append(tabnums, ds.Tabnum)
+	}
+
+	if len(slSotr) == 0 {
+		return
+	}
+
+	gdb := tx.Omit(clause.Associations).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		CreateInBatches(&slSotr, 100)
+
+	if gdb.Error != nil {
+		err = gdb.Error
+		m.Log.Error("Flash: upsert sotrs", "num", gdb.RowsAffected, "err", gdb.Error)
+		return
+	}
+	m.Log.Info("Flash: upsert sotrs", "num", gdb.RowsAffected, "len_Sotrmap", len(slSotr))
+	m.metrx.AddRows(metrics.Sotrs, gdb.RowsAffected)
+
+	// read back actual ID
+	rows := []datasource.Sotr{}
+	if r := tx.Unscoped().Select("id", "tabnum").Where("tabnum IN ?", tabnums).Find(&rows); r.Error != nil {
+		return nil, r.Error
+	}
+
+	ids := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		ids[row.Tabnum] = row.ID
+	}
+
+	for _, ds := range slSotr {
+		ds.ID = ids[ds.Tabnum]
+		sotrs[ds.Tabnum].Id = uint64(ds.ID)
+	}
+	return
+}
+
+// Insert histories generated by BeforeSave hook of sotrs
+func (m *MysqlStore) saveHistories(tx *gorm.DB, slSotr []*datasource.Sotr) (err error) {
+	hist := make([]datasource.History, 0)
+
+	for _, s := range slSotr {
+		for _, h := range s.History {
+			h.SotrID = &s.ID
+			hist = append(hist, h)
+		}
+	}
+
+	if len(hist) == 0 {
+		return
+	}
+
+	if r := tx.CreateInBatches(&hist, 100); r.Error != nil {
+		return r.Error
+	} else {
+		m.Log.Info("Insert histories", "num", r.RowsAffected)
+		m.metrx.AddRows(metrics.Histories, r.RowsAffected)
+	}
+	return
+}
+
+// prepare Phone and Mobile
+func (m *MysqlStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr, sotrs map[string]*kbv1.Sotr) (err error) {
+	var (
+		PhonesForAdd  []datasource.Phone
+		MobilesForAdd []datasource.Mobile
+		PhonesForDel  [][]any
+		MobilesForDel [][]any
+	)
+
+	for _, s := range slSotr {
+		sotrID := s.ID
+		kbvSotr := sotrs[s.Tabnum]
+
+		// get new phones&mobiles for upsert
+		for _, ph := range utils.ConvKbv2Phone(kbvSotr) {
+			ph.SotrID = &sotrID
+			PhonesForAdd = append(PhonesForAdd, ph)
+		}
+
+		for _, mob := range utils.ConvKbv2Mobile(kbvSotr) {
+			mob.SotrID = &sotrID
+			MobilesForAdd = append(MobilesForAdd, mob)
+		}
+
+		// get old phones&mobiles from last history for delete
+		for _, hist := range s.History {
+			switch hist.Field {
+			case "phone":
+				oldSotr := &kbv1.Sotr{
+					Phone: strings.Split(hist.OldValue, ","),
+				}
+				for _, ph := range utils.ConvKbv2Phone(oldSotr) {
+					PhonesForDel = append(PhonesForDel, []any{sotrID, ph.Phone})
+				}
+			case "mobile":
+				oldSotr := &kbv1.Sotr{
+					Mobile: strings.Split(hist.OldValue, ","),
+				}
+				for _, mob := range utils.ConvKbv2Mobile(oldSotr) {
+					MobilesForDel = append(MobilesForDel, []any{sotrID, mob.Mobile})
+				}
+			}
+		}
+	}
+
+	// del phone and Mobile.
+	if len(PhonesForDel) > 0 {
+		if err = m.deleteRows(tx, "phones", PhonesForDel); err != nil {
+			return
+		}
+	}
+
+	if len(MobilesForDel) > 0 {
+		if err = m.deleteRows(tx, "mobiles", MobilesForDel); err != nil {
+			return
+		}
+	}
+
+	// upsert on conflict
+	if len(PhonesForAdd) > 0 {
+		if r := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&PhonesForAdd, 100); r.Error != nil {
+			err = r.Error
+			return
+		} else {
+			m.Log.Info("Upsert phones", "num", r.RowsAffected)
+			m.metrx.AddRows(metrics.Phones, r.RowsAffected)
+		}
+	}
+
+	if len(MobilesForAdd) > 0 {
+		if r := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&MobilesForAdd, 100); r.Error != nil {
+			err = r.Error
+			return
+		} else {
+			m.Log.Info("Upsert mobiles", "num", r.RowsAffected)
+			m.metrx.AddRows(metrics.Mobiles, r.RowsAffected)
+		}
+	}
+
+	return
+}
+
+// deleteRows unlinks rows of deleted sotrs and deletes other rows by pairs (sotr_id, key)
+func (m *MysqlStore) deleteRows(tx *gorm.DB, tab string, pairs [][]any) (err error) {
+	queries, err := deleteQueries(tab)
+	if err != nil {
+		return
+	}
+
+	for _, q := range queries {
+		result := tx.Exec(q, pairs)
+		if result.Error != nil {
+			return result.Error
+		}
+		m.Log.Info("Delete "+tab, "num", result.RowsAffected)
+		m.metrx.AddRows(tab, result.RowsAffected)
+	}
+	return
+}
+
+// SetScope sets idrs of deps crawled by the dump
+func (m *MysqlStore) SetScope(idrs []string) {
+	m.mt.Lock()
+	defer m.mt.Unlock()
+
+	m.scope = idrs
+}
+
+// removeAbsent moves sotrs of deps of the scope which are not saved to sotr_deleteds.
+// Phones, mobiles, histories and avatars of removed sotrs are linked to the deleted ones.
+func (m *MysqlStore) removeAbsent(tx *gorm.DB) (err error) {
+	if m.scope == nil {
+		return
+	}
+
+	n, err := gormdb.RemoveAbsent(tx, m.scope, m.Sotrmap)
+	if err != nil {
+		return
+	}
+
+	m.Log.Info("Flash: remove sotrs absent in crawled deps", "num", n, "len_scope", len(m.scope))
+	m.metrx.AddRows(metrics.Sotrs, int64(n))
+	return
+}
+
+// PromCollector returns metrics of the storage: row counts, flush and DB pool stats
+func (m *MysqlStore) PromCollector() prometheus.Collector {
+	return m.metrx
+}
+
+// Migrate apply migrations to the DB
+func (m *MysqlStore) Migrate(ctx context.Context, down bool) (err error) {
+	errs := make([]error, 0, 6)
+	db := m.DB.WithContext(ctx)
+
+	for _, model := range []any{
+		&datasource.Dep{},
+		&datasource.Sotr{},
+		&datasource.SotrDeleted{},
+		&datasource.Phone{},
+		&datasource.Mobile{},
+		&datasource.History{},
+		&datasource.Avatar{},
+		&datasource.News{},
+		&datasource.Comment{},
+	} {
+		errs = append(errs, db.AutoMigrate(model))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Retention deletes entries older than passed time
+func (m *MysqlStore) Retention(ctx context.Context, olderThan time.Time) (err error) {
+	return
+}
+
+type Params struct {
+	TableName string
+	KeyField  string
+}
+
+// deleteQueries returns queries for unlink and delete rows of phones or mobiles
+func deleteQueries(tab string) (q []string, err error) {
+	var p Params
+
+	switch tab {
+	case "mobiles":
+		p = Params{TableName: "mobiles", KeyField: "mobile"}
+	case "phones":
+		p = Params{TableName: "phones", KeyField: "phone"}
+	default:
+		return nil, fmt.Errorf("ivalid table name '%s'", tab)
+	}
+
+	for _, t := range []string{unlinkQueryTemplate, deleteQueryTemplate} {
+		tmpl, err := template.New("universalQuery").Parse(t)
+		if err != nil {
+			return nil, fmt.Errorf("template parse failed: %w", err)
+		}
+
+		var sqlBuf bytes.Buffer
+		if err := tmpl.Execute(&sqlBuf, p); err != nil {
+			return nil, fmt.Errorf("template execute failed: %w", err)
+		}
+		q = append(q, sqlBuf.String())
+	}
+	return
+}
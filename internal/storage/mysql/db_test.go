@@ -0,0 +1,267 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/encoding/protojson"
+	gmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+var (
+	mid     string = "Gulim.99999999@k.kom"
+	newSotr        = &datasource.Sotr{
+		Idr:    "s99999",
+		Tabnum: "99999",
+		Name:   "999999999 Гулим",
+		Email:  &mid,
+		Phone: []datasource.Phone{
+			{
+				Phone: "400-88888",
+			},
+		},
+		Avatar:    "/avatar/25301.jpg",
+		Grade:     "Главный Специалист",
+		Children:  false,
+		ParentIdr: "razd1.27.2935.69",
+	}
+)
+
+func (st *DBTestSuite) Test_AddSotr() {
+
+	st.loadDB(st.T())
+	expectedCounts := st.counts(st.T())
+
+	// load new sotr
+	st.store.Sotrmap[newSotr.Tabnum] = newSotr.Conv2Kbv().GetSotr()
+	st.loadDB(st.T())
+
+	actualCounts := st.counts(st.T())
+	expectedCounts.AddSotrs(1)
+	expectedCounts.AddPhones(1)
+
+	st.Assert().EqualValues(expectedCounts, actualCounts)
+}
+
+func (st *DBTestSuite) Test_History() {
+	actualHist := []datasource.History{}
+
+	for _, tc := range storetest.HistCases {
+		st.T().Run(tc.Name, func(t *testing.T) {
+			// load original sotrs
+			st.loadDB(st.T())
+			expectedCounts := st.counts(st.T())
+
+			// update DB
+			storetest.UpdateSotr(st.store.Sotrmap[tc.TabMutate], tc)
+			st.loadDB(st.T())
+
+			tc.UpdateCounts(expectedCounts)
+			actualCounts := st.counts(st.T())
+			st.Assert().EqualValues(expectedCounts, actualCounts)
+
+			sotrID := st.store.Sotrmap[tc.TabMutate].Id
+			r := st.store.DB.Where("sotr_id = ?", sotrID).Find(&actualHist)
+
+			for i := range actualHist {
+				actualHist[i].ID = 0
+				actualHist[i].CreatedAt = *new(time.Time)
+				actualHist[i].SotrID = nil
+				actualHist[i].SotrDeletedID = nil
+			}
+
+			if st.Assert().NoError(r.Error) {
+				st.Assert().EqualValues(tc.ExpectedHistories, actualHist)
+			}
+
+			// clear updates
+			st.store.DB.Exec("DELETE FROM phones")
+			st.store.DB.Exec("DELETE FROM mobiles")
+			st.store.DB.Exec("DELETE FROM histories")
+			st.store.DB.Exec("DELETE FROM sotrs")
+			clear(st.store.Sotrmap)
+		})
+
+	}
+}
+
+func (st *DBTestSuite) Test_GetSotrsBy() {
+	var (
+		q   *kbv1.SotrRequest
+		err error
+	)
+	expextedSotrs := &kbv1.SotrsResponse{}
+	st.loadDB(st.T())
+
+	for _, tc := range storetest.GetSotrsCases {
+		st.T().Run(tc.By, func(t *testing.T) {
+			err = protojson.Unmarshal([]byte(tc.Expected), expextedSotrs)
+			st.Require().NoError(err)
+
+			if i, ok := kbv1.SotrRequest_DBField_value[tc.By]; ok {
+				q = &kbv1.SotrRequest{
+					Field: kbv1.SotrRequest_DBField(i),
+					Str:   tc.Val,
+				}
+			} else {
+				st.Assert().FailNow("invalid field name for get sotrs by", tc.By)
+			}
+
+			sotrs, err := st.store.GetSotrsBy(context.Background(), q)
+
+			if !st.Assert().NoError(err) {
+				return
+			}
+			require.Len(t, sotrs, len(expextedSotrs.Sotrs))
+			sotrs[0].Id = 0
+			sotrs[0].Date = nil
+			exp := utils.ConvKbv2Ds(expextedSotrs.Sotrs[0])
+			actual := utils.ConvKbv2Ds(sotrs[0])
+
+			st.Assert().EqualValues(exp, actual)
+
+		})
+	}
+}
+
+func (st *DBTestSuite) Test_GetDepsBy() {
+	var (
+		q   *kbv1.DepRequest
+		err error
+	)
+	expextedDeps := &kbv1.DepsResponse{}
+	st.loadDB(st.T())
+
+	for _, tc := range storetest.GetDepsCases {
+		st.T().Run(tc.By, func(t *testing.T) {
+			err = protojson.Unmarshal([]byte(tc.Expected), expextedDeps)
+			st.Require().NoError(err)
+
+			if i, ok := kbv1.DepRequest_DBField_value[tc.By]; ok {
+				q = &kbv1.DepRequest{
+					Field: kbv1.DepRequest_DBField(i),
+					Str:   tc.Val,
+				}
+			} else {
+				st.Assert().FailNow("invalid field name for get Deps by", tc.By)
+			}
+
+			deps, err := st.store.GetDepsBy(context.Background(), q)
+
+			if !st.Assert().NoError(err) {
+				return
+			}
+			if len(expextedDeps.Deps) != len(deps) {
+				return
+			}
+			deps[0].Id = 0
+			exp := utils.ConvKbv2Ds(expextedDeps.Deps[0])
+			actual := utils.ConvKbv2Ds(deps[0])
+
+			st.Assert().EqualValues(exp, actual)
+
+		})
+	}
+}
+
+func (st *DBTestSuite) Test_Conformance() {
+	suite.Run(st.T(), &storetest.ConformanceSuite{
+		NewStore: func(t *testing.T) storetest.Store {
+			st.resetDB(t)
+			return st.store
+		},
+	})
+}
+
+func (st *DBTestSuite) Test_News() {
+	suite.Run(st.T(), &storetest.NewsSuite{
+		NewStore: func(t *testing.T) storetest.NewsStore {
+			st.resetDB(t)
+			return st.store
+		},
+	})
+}
+
+func (st *DBTestSuite) Test_RemoveAbsent() {
+	ctx := context.Background()
+	db := st.store.DB
+
+	// dumps refresh razd1.27.2935.69 only, 25301 is absent in the second one
+	scope := []string{"razd1.27.2935.69"}
+	st.resetDB(st.T())
+	removeAbsent := func() {
+		st.store.SetScope(scope)
+		st.loadDB(st.T())
+		st.Require().Empty(st.store.Sotrmap, "staged sotrs should be reset by the scoped flush")
+
+		for _, s := range st.Sotrs {
+			if s.Tabnum == "2681" {
+				_, err := st.store.Save(ctx, s)
+				st.Require().NoError(err)
+			}
+		}
+		st.store.SetScope(scope)
+		st.store.Flush(ctx, nil)
+		_, err := st.store.Flush(ctx, nil)
+		st.Require().NoError(err)
+	}
+	removeAbsent()
+
+	var tabnums []string
+	st.Require().NoError(db.Model(&datasource.Sotr{}).Pluck("tabnum", &tabnums).Error)
+	st.NotContains(tabnums, "25301")
+	st.Contains(tabnums, "2681")
+	st.Contains(tabnums, "1600", "sotr outside of crawled deps should be kept")
+
+	del := &datasource.SotrDeleted{}
+	st.Require().NoError(db.Where("tabnum = ?", "25301").Take(del).Error)
+	for _, model := range []any{&datasource.Phone{}, &datasource.Mobile{}} {
+		var n int64
+		st.Require().NoError(db.Model(model).Where("sotr_deleted_id = ? AND sotr_id IS NULL", del.ID).Count(&n).Error)
+		st.EqualValues(1, n)
+	}
+
+	// the returned and removed again sotr replaces the deleted one with its phones
+	removeAbsent()
+	var n int64
+	st.Require().NoError(db.Model(&datasource.SotrDeleted{}).Where("tabnum = ?", "25301").Count(&n).Error)
+	st.EqualValues(1, n)
+	st.Require().NoError(db.Model(&datasource.Phone{}).Where("sotr_deleted_id = ?", del.ID).Count(&n).Error)
+	st.EqualValues(1, n)
+}
+
+func TestMigratorIndexOptions(t *testing.T) {
+	db, err := gorm.Open(newDialector(gmysql.Config{DSN: testDSN, SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	var queries []string
+	require.NoError(t, db.Callback().Raw().After("gorm:raw").Register("test:trace", func(tx *gorm.DB) {
+		queries = append(queries, tx.Statement.SQL.String())
+	}))
+
+	m, ok := db.Migrator().(migrator)
+	require.True(t, ok)
+	assert.True(t, m.CreateIndexAfterCreateTable)
+
+	require.NoError(t, m.CreateIndex(&datasource.Sotr{}, "idx_fio"))
+	assert.Equal(t, []string{"CREATE INDEX `idx_fio` ON `sotrs`(`name`,`mid_name`)"}, queries)
+
+	// the cached schema keeps the option of PostgreSQL
+	stmt := &gorm.Statement{DB: db}
+	require.NoError(t, stmt.Parse(&datasource.Sotr{}))
+	assert.Equal(t, "CONCURRENTLY", stmt.Schema.LookIndex("idx_fio").Option)
+}
+
+func TestDBSuite(t *testing.T) {
+	suite.Run(t, new(DBTestSuite))
+}
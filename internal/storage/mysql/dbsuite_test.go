@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DBTestSuite struct {
+	suite.Suite
+
+	store *MysqlStore
+	Sotrs []*kbv1.Sotr
+	Deps  []*kbv1.Dep
+}
+
+// DSN of the test DB, it's can be redefined by KB_TEST_MYSQL env
+var testDSN = "kb:123456@tcp(localhost:3306)/test_www_int"
+
+func (suite *DBTestSuite) SetupTest() {
+	var err error
+
+	if dsn := os.Getenv("KB_TEST_MYSQL"); dsn != "" {
+		testDSN = dsn
+	}
+
+	suite.store, err = New(testDSN, slog.Default())
+	if err != nil {
+		suite.T().Skip("MySQL is not available:", err)
+	}
+
+	err = suite.store.Migrate(context.TODO(), false)
+	suite.Require().NoError(err)
+
+	// load SotrsResponse and DepsResponse
+	depsResponse := &kbv1.DepsResponse{}
+	storetest.LoadJSONPb(suite.T(), "dep.json", depsResponse)
+	suite.Deps = depsResponse.Deps
+
+	sotrsResponse := &kbv1.SotrsResponse{}
+	storetest.LoadJSONPb(suite.T(), "sotr.json", sotrsResponse)
+	suite.Sotrs = sotrsResponse.Sotrs
+}
+
+func (suite *DBTestSuite) TearDownTest() {
+	if suite.store == nil {
+		return
+	}
+	err := suite.store.Migrate(context.TODO(), true)
+	suite.Require().NoError(err)
+
+	db := suite.store.DB
+	tables, err := db.Migrator().GetTables()
+	suite.Require().NoError(err)
+	// defer
+
+	for _, table := range tables {
+		suite.store.Log.Debug("Drop table", "table", table)
+
+		err = db.Migrator().DropTable(table) // nosemgrep
+		suite.Require().NoError(err)
+	}
+}
+
+// resetDB drops tables and clears internal maps of the store
+func (suite *DBTestSuite) resetDB(t *testing.T) {
+	t.Helper()
+	db := suite.store.DB
+
+	tables, err := db.Migrator().GetTables()
+	require.NoError(t, err)
+
+	for _, table := range tables {
+		err = db.Migrator().DropTable(table) // nosemgrep
+		require.NoError(t, err)
+	}
+
+	err = suite.store.Migrate(context.TODO(), false)
+	require.NoError(t, err)
+
+	clear(suite.store.Depmap)
+	clear(suite.store.Sotrmap)
+	suite.store.FlashCounter.Store(0)
+}
+
+func (st *DBTestSuite) loadDB(t *testing.T) {
+	t.Helper()
+	var err error
+
+	for _, d := range st.Deps {
+		_, err = st.store.Save(context.Background(), d)
+		require.NoError(t, err)
+	}
+	// 1st Flash should be skiped
+	st.store.Flush(context.Background(), nil)
+
+	for _, s := range st.Sotrs {
+		_, err = st.store.Save(context.Background(), s)
+		require.NoError(t, err)
+	}
+
+	_, err = st.store.Flush(context.Background(), nil)
+	require.NoError(t, err)
+}
+
+func (suite *DBTestSuite) MustQueryCount(t *testing.T, query string, args ...any) (ret int) {
+	t.Helper()
+	db := suite.store.DB
+
+	r := db.Exec(query, args...)
+	suite.Require().NoError(r.Error)
+
+	ret = int(r.RowsAffected)
+	return
+}
+
+func (suite *DBTestSuite) counts(t *testing.T) *storetest.Counts {
+	t.Helper()
+	return storetest.CountRows(t, suite.store.DB)
+}
@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+
+	gmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dialector is the MySQL dialector with the migrator skipping options of indexes.
+// Indexes of models are built CONCURRENTLY by PostgreSQL, MySQL builds indexes online and fails on the option.
+type dialector struct {
+	*gmysql.Dialector
+}
+
+func newDialector(cfg gmysql.Config) gorm.Dialector {
+	return dialector{Dialector: gmysql.New(cfg).(*gmysql.Dialector)}
+}
+
+func (d dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	m := d.Dialector.Migrator(db).(gmysql.Migrator)
+	// indexes are created by CreateIndex, not by definitions of the table
+	m.CreateIndexAfterCreateTable = true
+	return migrator{Migrator: m}
+}
+
+type migrator struct {
+	gmysql.Migrator
+}
+
+// CreateIndex creates the index `name` without the option of the index
+func (m migrator) CreateIndex(value any, name string) error {
+	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if stmt.Schema == nil {
+			return errors.New("failed to get schema")
+		}
+		idx := stmt.Schema.LookIndex(name)
+		if idx == nil {
+			return fmt.Errorf("failed to create index with name %s", name)
+		}
+
+		opts := m.BuildIndexOptions(idx.Fields, stmt)
+		values := []any{clause.Column{Name: idx.Name}, m.CurrentTable(stmt), opts}
+
+		sql := "CREATE "
+		if idx.Class != "" {
+			sql += idx.Class + " "
+		}
+		sql += "INDEX ? ON ??"
+		if idx.Type != "" {
+			sql += " USING " + idx.Type
+		}
+		if idx.Comment != "" {
+			sql += fmt.Sprintf(" COMMENT '%s'", idx.Comment)
+		}
+
+		return m.DB.Exec(sql, values...).Error
+	})
+}
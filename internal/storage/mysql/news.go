@@ -0,0 +1,31 @@
+package mysql
+
+import (
+	"context"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/gormdb"
+)
+
+// SaveNews saves news with comments, the news with the same idn is replaced
+func (m *MysqlStore) SaveNews(ctx context.Context, news []*kbv1.News) (err error) {
+	if len(news) == 0 {
+		return
+	}
+
+	err = gormdb.SaveNews(ctx, m.DB, news)
+	if err == nil {
+		m.Log.Info("Save news", "num", len(news))
+	}
+	return
+}
+
+// ListNews returns news matched by the query without comments, the newest first
+func (m *MysqlStore) ListNews(ctx context.Context, q *kbv1.ListNewsRequest) ([]*kbv1.News, error) {
+	return gormdb.ListNews(ctx, m.DB, q)
+}
+
+// GetNews returns the news with comments or nil if it's absent
+func (m *MysqlStore) GetNews(ctx context.Context, idn string) (*kbv1.News, error) {
+	return gormdb.GetNews(ctx, m.DB, idn)
+}
@@ -0,0 +1,19 @@
+package mysql
+
+// MySQL and MariaDB don't support a derived table like
+// (VALUES (...)) AS v(sotr_id, phone), so pairs of (sotr_id, phone) are matched
+// with a row constructor: (sotr_id, phone) IN ((1, '...'), (2, '...')).
+// The list of pairs is passed as the query argument.
+
+// set sotr_id = NULL in phones of deleted sotrs
+const unlinkQueryTemplate = `
+UPDATE {{.TableName}}
+SET sotr_id = NULL
+WHERE (sotr_id, {{.KeyField}}) IN ? AND sotr_deleted_id IS NOT NULL
+`
+
+// delete row if sotr_deleted_id IS NULL
+const deleteQueryTemplate = `
+DELETE FROM {{.TableName}}
+WHERE (sotr_id, {{.KeyField}}) IN ? AND sotr_deleted_id IS NULL
+`
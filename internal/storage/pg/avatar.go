@@ -0,0 +1,27 @@
+package pg
+
+import (
+	"context"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/gormdb"
+)
+
+// SaveAvatars saves avatars seen by the dump. If the hash of the employee is changed
+// the new avatar is added and the old hash is saved to the history.
+func (p *PgStore) SaveAvatars(ctx context.Context, avatars []*kbv1.Avatar) (err error) {
+	if len(avatars) == 0 {
+		return
+	}
+
+	err = gormdb.SaveAvatars(ctx, p.DB, avatars)
+	if err == nil {
+		p.Log.Info("Save avatars", "num", len(avatars))
+	}
+	return
+}
+
+// GetAvatar returns the current avatar of the employee or nil if it's absent
+func (p *PgStore) GetAvatar(ctx context.Context, tabnum string) (*kbv1.Avatar, error) {
+	return gormdb.GetAvatar(ctx, p.DB, tabnum)
+}
@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"text/template"
 	"time"
@@ -16,9 +17,14 @@ import (
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
 	"github.com/mioxin/kbempgo/internal/datasource"
 	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/storage/file"
+	"github.com/mioxin/kbempgo/internal/storage/gormdb"
+	"github.com/mioxin/kbempgo/internal/storage/metrics"
 	"github.com/mioxin/kbempgo/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -47,6 +53,11 @@ type PgStore struct {
 	Sotrmap map[string]*kbv1.Sotr
 	// Internal map contains Dep's items by Idr key for save
 	Depmap map[string]*kbv1.Dep
+	// idrs of deps crawled by the dump
+	scope []string
+
+	mt    sync.Mutex
+	metrx *metrics.Collector
 }
 
 func New(dsn string, log *slog.Logger) (pgs *PgStore, err error) {
@@ -73,12 +84,16 @@ func New(dsn string, log *slog.Logger) (pgs *PgStore, err error) {
 		return nil, fmt.Errorf("set search_path: %w", err)
 	}
 
-	return &PgStore{
+	pgs = &PgStore{
 		DB:      db,
 		Log:     log.With("storage", "postgres"),
 		Depmap:  make(map[string]*kbv1.Dep, 50),
 		Sotrmap: make(map[string]*kbv1.Sotr, 100),
-	}, nil
+	}
+	pgs.metrx = metrics.New("postgres", metrics.SQLRowCounter(db), pgs.Log,
+		collectors.NewDBStatsCollector(sqlDB, "postgres"))
+
+	return pgs, nil
 }
 
 func (p *PgStore) GetDepsBy(ctx context.Context, q *kbv1.DepRequest) (deps []*kbv1.Dep, err error) {
@@ -86,11 +101,16 @@ func (p *PgStore) GetDepsBy(ctx context.Context, q *kbv1.DepRequest) (deps []*kb
 		r     *gorm.DB
 		items []datasource.Dep
 	)
-	field := q.Field.Enum().String()
-	if field == "NONE" {
+
+	switch q.Field {
+	case kbv1.DepRequest_NONE:
 		r = p.DB.Find(&items)
-	} else {
-		r = p.DB.Where(fmt.Sprintf("%s = ?", field), q.Str).Find(&items)
+	case kbv1.DepRequest_IDR:
+		r = p.DB.Where("idr = ?", q.Str).Find(&items)
+	case kbv1.DepRequest_PARENT:
+		r = p.DB.Where("parent = ?", q.Str).Find(&items)
+	default:
+		return nil, &file.FieldNameError{Name: q.Field.String()}
 	}
 
 	if r.Error != nil {
@@ -111,11 +131,10 @@ func (p *PgStore) GetSotrsBy(ctx context.Context, q *kbv1.SotrRequest) (sotrs []
 		sotrIds         []int
 		r               *gorm.DB
 	)
-	f := q.Field.Enum().String()
+	db := p.DB.Preload("Phone").Preload("Mobile")
 
-	switch f {
-	case "MOBILE":
-		// rows := make([]datasource.Mobile, 0)
+	switch q.Field {
+	case kbv1.SotrRequest_MOBILE:
 		mob, err := strconv.Atoi(utils.ExtractDigits(q.Str))
 		if err != nil {
 			return nil, err
@@ -125,27 +144,37 @@ func (p *PgStore) GetSotrsBy(ctx context.Context, q *kbv1.SotrRequest) (sotrs []
 			err = r.Error
 			return nil, err
 		}
+		// empty list of IDs selects all rows
+		if len(sotrIds) == 0 {
+			return nil, nil
+		}
 
-		r = p.DB.Preload("Phone").Preload("Mobile").Find(&datasourceSotrs, sotrIds)
+		r = db.Find(&datasourceSotrs, sotrIds)
 
-	case "FIO":
+	case kbv1.SotrRequest_FIO:
 		// split FIO on name and mid_name
 		slFio := strings.Fields(q.Str)
-		name := fmt.Sprintf("%s %s", slFio[0], slFio[1])
-		midName := ""
 
 		if len(slFio) > 2 {
-			midName = slFio[2]
-			r = p.DB.Where("name = ? and mid_name = ?", name, midName).Preload("Phone").Preload("Mobile").Find(&datasourceSotrs)
+			r = db.Where("name = ? and mid_name = ?", strings.Join(slFio[:2], " "), slFio[2]).Find(&datasourceSotrs)
 		} else {
-			r = p.DB.Where("name = ?", name).Preload("Phone").Preload("Mobile").Find(&datasourceSotrs)
+			r = db.Where("name = ?", strings.Join(slFio, " ")).Find(&datasourceSotrs)
 		}
 
-	case "NONE":
-		r = p.DB.Preload("Mobile").Find(&datasourceSotrs)
+	case kbv1.SotrRequest_TABNUM:
+		r = db.Where("tabnum = ?", q.Str).Find(&datasourceSotrs)
+
+	case kbv1.SotrRequest_IDR:
+		r = db.Where("idr = ?", q.Str).Find(&datasourceSotrs)
+
+	case kbv1.SotrRequest_PARENT:
+		r = db.Where("parent_idr = ?", q.Str).Find(&datasourceSotrs)
+
+	case kbv1.SotrRequest_NONE:
+		r = db.Find(&datasourceSotrs)
 
 	default:
-		r = p.DB.Where(fmt.Sprintf("%s = ?", q.Field.Enum().String()), q.Str).Preload("Phone").Preload("Mobile").Find(&datasourceSotrs)
+		return nil, &file.FieldNameError{Name: q.Field.String()}
 	}
 
 	if r.Error != nil {
@@ -159,8 +188,43 @@ func (p *PgStore) GetSotrsBy(ctx context.Context, q *kbv1.SotrRequest) (sotrs []
 	return
 }
 
+// GetHistory returns histories of the sotr by ID
+func (p *PgStore) GetHistory(ctx context.Context, q *kbv1.HistRequest) (lhist *kbv1.HistoryListResponse, err error) {
+	id, err := strconv.ParseUint(q.SotrId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sotr id \"%s\": %w", q.SotrId, err)
+	}
+
+	hist := []datasource.History{}
+	if r := p.DB.Where("sotr_id = ?", id).Order("id").Find(&hist); r.Error != nil {
+		return nil, r.Error
+	}
+
+	lhist = &kbv1.HistoryListResponse{}
+	for _, h := range hist {
+		lhist.HistoryList = append(lhist.HistoryList, &kbv1.History{
+			Date:     timestamppb.New(h.CreatedAt),
+			Field:    h.Field,
+			OldValue: h.OldValue,
+			SotrId:   id,
+		})
+	}
+	return
+}
+
+// GetVersions returns times of the latest changes in histories of employees
+func (p *PgStore) GetVersions(ctx context.Context, q *kbv1.VersionsRequest) (*kbv1.VersionsResponse, error) {
+	return gormdb.GetVersions(ctx, p.DB, q)
+}
+
+// Staging reports that saved items are staged in maps until Flush
+func (p *PgStore) Staging() bool { return true }
+
 // Save Item data to internal maps
 func (p *PgStore) Save(_ context.Context, item models.Item) (em *emptypb.Empty, err error) {
+	p.mt.Lock()
+	defer p.mt.Unlock()
+
 	if item.GetChildren() {
 		// **************************
 		// save Dep
@@ -172,6 +236,7 @@ func (p *PgStore) Save(_ context.Context, item models.Item) (em *emptypb.Empty,
 		}
 
 		p.Depmap[kbvDep.Idr] = kbvDep
+		p.metrx.Staged.WithLabelValues(metrics.Deps).Set(float64(len(p.Depmap)))
 	} else {
 		// **************************
 		// save Sotr
@@ -183,11 +248,44 @@ func (p *PgStore) Save(_ context.Context, item models.Item) (em *emptypb.Empty,
 		}
 
 		p.Sotrmap[kbvSotr.Tabnum] = kbvSotr
+		p.metrx.Staged.WithLabelValues(metrics.Sotrs).Set(float64(len(p.Sotrmap)))
 	}
 	return
 }
 
+// Update saves the employee by tabnum immediately.
+// Histories of changed fields are generated by comparing with the saved row,
+// so HistoryList of the request is not used.
 func (p *PgStore) Update(ctx context.Context, q *kbv1.UpdateSotrRequest) (em *emptypb.Empty, err error) {
+	sotr := q.GetSotr()
+	if sotr == nil || sotr.Tabnum == "" {
+		return nil, fmt.Errorf("update: tabnum of sotr is empty")
+	}
+	cleared := q.GetClear().GetPaths()
+	if err = datasource.CheckCleared(cleared); err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+	sotrs := map[string]*kbv1.Sotr{sotr.Tabnum: sotr}
+
+	// cleared attributes are not kept by the hook of saving
+	err = p.DB.WithContext(datasource.WithCleared(ctx, cleared)).Transaction(func(tx *gorm.DB) error {
+		// the newest dep of sotr
+		dep := &datasource.Dep{}
+		if r := tx.Where("idr = ?", sotr.ParentId).Order("id desc").Limit(1).Find(dep); r.Error != nil {
+			return r.Error
+		} else if r.RowsAffected == 0 {
+			return fmt.Errorf("update: dep %s of sotr %s not found", sotr.ParentId, sotr.Tabnum)
+		}
+
+		slSotr, e := p.prepareSotrsResponse(tx, map[string]*datasource.Dep{dep.Idr: dep}, sotrs)
+		if e != nil {
+			return e
+		}
+
+		sotr.Id = uint64(slSotr[0].ID)
+
+		return p.preparePhones(tx, slSotr, sotrs)
+	})
 	return
 }
 
@@ -204,11 +302,15 @@ func (p *PgStore) Flush(ctx context.Context, _ *emptypb.Empty) (_ *emptypb.Empty
 		return
 	}
 
+	p.mt.Lock()
+	defer p.mt.Unlock()
+
 	p.DB = p.DB.Debug()
+	defer p.metrx.ObserveFlush(time.Now())
 
 	// sync DB
 	p.Log.Info("Start transaction flash ...")
-	p.DB.Transaction(func(tx *gorm.DB) error {
+	err = p.DB.Transaction(func(tx *gorm.DB) error {
 		var e error
 		defer func() {
 			if e != nil {
@@ -221,7 +323,7 @@ func (p *PgStore) Flush(ctx context.Context, _ *emptypb.Empty) (_ *emptypb.Empty
 			return e
 		}
 
-		slSotr, e := p.prepareSotrsResponse(tx, dsDepMap)
+		slSotr, e := p.prepareSotrsResponse(tx, dsDepMap, p.Sotrmap)
 		if e != nil {
 			return e
 		}
@@ -231,13 +333,22 @@ func (p *PgStore) Flush(ctx context.Context, _ *emptypb.Empty) (_ *emptypb.Empty
 			p.Sotrmap[s.Tabnum].Id = uint64(s.ID)
 		}
 
-		e = p.preparePhones(tx, slSotr)
+		e = p.preparePhones(tx, slSotr, p.Sotrmap)
 		if e != nil {
 			return e
 		}
 
+		e = p.removeAbsent(tx)
 		return e
 	})
+	// items of the next dump are staged from scratch, otherwise they stay saved
+	if err == nil && p.scope != nil {
+		clear(p.Sotrmap)
+		clear(p.Depmap)
+		p.metrx.Staged.WithLabelValues(metrics.Sotrs).Set(0)
+		p.metrx.Staged.WithLabelValues(metrics.Deps).Set(0)
+	}
+	p.scope = nil
 	p.FlashCounter.Store(0)
 	return
 }
@@ -266,15 +377,16 @@ func (p *PgStore) prepareDepsResponse(tx *gorm.DB) (dsDepMap map[string]*datasou
 		p.Log.Error("Flash: prepare DepsResponse", "num", gdb.RowsAffected, "err", gdb.Error)
 	} else {
 		p.Log.Info("Flash: prepare DepsResponse", "num", gdb.RowsAffected, "len_Sotrmap", len(slDep))
+		p.metrx.AddRows(metrics.Deps, gdb.RowsAffected)
 	}
 	return
 }
 
 // prepare SotrsResponse. Create slice of sotrs as datasorce structs and batch inserting ones.
-func (p *PgStore) prepareSotrsResponse(tx *gorm.DB, dsDepMap map[string]*datasource.Dep) (slSotr []*datasource.Sotr, err error) {
+func (p *PgStore) prepareSotrsResponse(tx *gorm.DB, dsDepMap map[string]*datasource.Dep, sotrs map[string]*kbv1.Sotr) (slSotr []*datasource.Sotr, err error) {
 	slSotr = make([]*datasource.Sotr, 0, 100)
 
-	for _, s := range p.Sotrmap {
+	for _, s := range sotrs {
 
 		ds := utils.ConvKbv2Ds(s).(*datasource.Sotr)
 
@@ -322,13 +434,19 @@ func (p *PgStore) prepareSotrsResponse(tx *gorm.DB, dsDepMap map[string]*datasou
 		p.Log.Error("Flash: sync Sotr", "num", gdb.RowsAffected, "err", gdb.Error)
 	} else {
 		p.Log.Info("Flash: sync Sotr", "num", gdb.RowsAffected, "len_Sotrmap", len(slSotr))
+		p.metrx.AddRows(metrics.Sotrs, gdb.RowsAffected)
+
+		// histories are inserted with sotrs as association
+		for _, s := range slSotr {
+			p.metrx.AddRows(metrics.Histories, int64(len(s.History)))
+		}
 	}
 
 	return
 }
 
 // prepare Phone and Mobile
-func (p *PgStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr) (err error) {
+func (p *PgStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr, sotrs map[string]*kbv1.Sotr) (err error) {
 	var (
 		PhonesForAdd  []datasource.Phone
 		MobilesForAdd []datasource.Mobile
@@ -338,7 +456,7 @@ func (p *PgStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr) (err err
 
 	for _, s := range slSotr {
 		sotrID := s.ID
-		kbvSotr := p.Sotrmap[s.Tabnum]
+		kbvSotr := sotrs[s.Tabnum]
 
 		// get new phones&mobiles for upsert
 		// becouse we can't solve a conflicts in dependent fields (Phone Mobile) while upsert a main struct (Sotr)
@@ -397,6 +515,7 @@ func (p *PgStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr) (err err
 			return err
 		} else {
 			p.Log.Info("Delete phones", "num", result.RowsAffected)
+			p.metrx.AddRows(metrics.Phones, result.RowsAffected)
 		}
 	}
 
@@ -411,6 +530,7 @@ func (p *PgStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr) (err err
 			return err
 		} else {
 			p.Log.Info("Delete mobiles", "num", result.RowsAffected)
+			p.metrx.AddRows(metrics.Mobiles, result.RowsAffected)
 		}
 	}
 
@@ -425,6 +545,7 @@ func (p *PgStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr) (err err
 			return
 		} else {
 			p.Log.Info("Upsert phones", "num", r.RowsAffected)
+			p.metrx.AddRows(metrics.Phones, r.RowsAffected)
 		}
 	}
 
@@ -438,16 +559,43 @@ func (p *PgStore) preparePhones(tx *gorm.DB, slSotr []*datasource.Sotr) (err err
 			return
 		} else {
 			p.Log.Info("Upsert mobiles", "num", r.RowsAffected)
+			p.metrx.AddRows(metrics.Mobiles, r.RowsAffected)
 		}
 	}
 
 	return
 }
 
-func (p *PgStore) PromCollector() (prom prometheus.Collector) {
+// SetScope sets idrs of deps crawled by the dump
+func (p *PgStore) SetScope(idrs []string) {
+	p.mt.Lock()
+	defer p.mt.Unlock()
+
+	p.scope = idrs
+}
+
+// removeAbsent moves sotrs of deps of the scope which are not saved to sotr_deleteds.
+// Phones, mobiles, histories and avatars of removed sotrs are linked to the deleted ones.
+func (p *PgStore) removeAbsent(tx *gorm.DB) (err error) {
+	if p.scope == nil {
+		return
+	}
+
+	n, err := gormdb.RemoveAbsent(tx, p.scope, p.Sotrmap)
+	if err != nil {
+		return
+	}
+
+	p.Log.Info("Flash: remove sotrs absent in crawled deps", "num", n, "len_scope", len(p.scope))
+	p.metrx.AddRows(metrics.Sotrs, int64(n))
 	return
 }
 
+// PromCollector returns metrics of the storage: row counts, flush and DB pool stats
+func (p *PgStore) PromCollector() prometheus.Collector {
+	return p.metrx
+}
+
 // Migrate apply migrations to the DB
 func (p *PgStore) Migrate(ctx context.Context, down bool) (err error) {
 	p.DB.Exec("SET search_path TO public")
@@ -464,6 +612,10 @@ func (p *PgStore) Migrate(ctx context.Context, down bool) (err error) {
 	errs = append(errs, err)
 	err = p.DB.AutoMigrate(&datasource.History{})
 	errs = append(errs, err)
+	err = p.DB.AutoMigrate(&datasource.Avatar{})
+	errs = append(errs, err)
+	err = p.DB.AutoMigrate(&datasource.News{}, &datasource.Comment{})
+	errs = append(errs, err)
 
 	return errors.Join(errs...)
 }
@@ -2,14 +2,14 @@ package pg
 
 import (
 	"context"
-	"fmt"
-	"strings"
 	"testing"
 	"time"
 
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
 	"github.com/mioxin/kbempgo/internal/datasource"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
 	"github.com/mioxin/kbempgo/internal/utils"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/protobuf/encoding/protojson"
 )
@@ -73,21 +73,21 @@ func (st *DBTestSuite) Test_AddSotr() {
 func (st *DBTestSuite) Test_History() {
 	actualHist := []datasource.History{}
 
-	for _, tc := range testHistCases {
-		st.T().Run(tc.name, func(t *testing.T) {
+	for _, tc := range storetest.HistCases {
+		st.T().Run(tc.Name, func(t *testing.T) {
 			// load original sotrs
 			st.loadDB(st.T())
 			expectedCounts := st.counts(st.T())
 
 			// update DB
-			updateSotr(st.store.Sotrmap[tc.tabMutate], tc)
+			storetest.UpdateSotr(st.store.Sotrmap[tc.TabMutate], tc)
 			st.loadDB(st.T())
 
-			tc.updateCounts(expectedCounts)
+			tc.UpdateCounts(expectedCounts)
 			actualCounts := st.counts(st.T())
 			st.Assert().EqualValues(expectedCounts, actualCounts)
 
-			sotrID := st.store.Sotrmap[tc.tabMutate].Id
+			sotrID := st.store.Sotrmap[tc.TabMutate].Id
 			r := st.store.DB.Where("sotr_id = ?", sotrID).Find(&actualHist)
 
 			for i := range actualHist {
@@ -98,7 +98,7 @@ func (st *DBTestSuite) Test_History() {
 			}
 
 			if st.Assert().NoError(r.Error) {
-				st.Assert().EqualValues(tc.expectedHistories, actualHist)
+				st.Assert().EqualValues(tc.ExpectedHistories, actualHist)
 			}
 
 			// clear updates
@@ -120,18 +120,18 @@ func (st *DBTestSuite) Test_GetSotrsBy() {
 	expextedSotrs := &kbv1.SotrsResponse{}
 	st.loadDB(st.T())
 
-	for _, tc := range testGetSotrsCases {
-		st.T().Run(tc.by, func(t *testing.T) {
-			err = protojson.Unmarshal([]byte(tc.expected), expextedSotrs)
+	for _, tc := range storetest.GetSotrsCases {
+		st.T().Run(tc.By, func(t *testing.T) {
+			err = protojson.Unmarshal([]byte(tc.Expected), expextedSotrs)
 			st.Require().NoError(err)
 
-			if i, ok := kbv1.SotrRequest_DBField_value[tc.by]; ok {
+			if i, ok := kbv1.SotrRequest_DBField_value[tc.By]; ok {
 				q = &kbv1.SotrRequest{
 					Field: kbv1.SotrRequest_DBField(i),
-					Str:   tc.val,
+					Str:   tc.Val,
 				}
 			} else {
-				st.Assert().FailNow("invalid field name for get sotrs by", tc.by)
+				st.Assert().FailNow("invalid field name for get sotrs by", tc.By)
 			}
 
 			sotrs, err := st.store.GetSotrsBy(context.Background(), q)
@@ -139,9 +139,7 @@ func (st *DBTestSuite) Test_GetSotrsBy() {
 			if !st.Assert().NoError(err) {
 				return
 			}
-			if len(expextedSotrs.Sotrs) != len(sotrs) {
-				return
-			}
+			require.Len(t, sotrs, len(expextedSotrs.Sotrs))
 			sotrs[0].Id = 0
 			sotrs[0].Date = nil
 			exp := utils.ConvKbv2Ds(expextedSotrs.Sotrs[0])
@@ -161,18 +159,18 @@ func (st *DBTestSuite) Test_GetDepsBy() {
 	expextedDeps := &kbv1.DepsResponse{}
 	st.loadDB(st.T())
 
-	for _, tc := range testGetDepsCases {
-		st.T().Run(tc.by, func(t *testing.T) {
-			err = protojson.Unmarshal([]byte(tc.expected), expextedDeps)
+	for _, tc := range storetest.GetDepsCases {
+		st.T().Run(tc.By, func(t *testing.T) {
+			err = protojson.Unmarshal([]byte(tc.Expected), expextedDeps)
 			st.Require().NoError(err)
 
-			if i, ok := kbv1.DepRequest_DBField_value[tc.by]; ok {
+			if i, ok := kbv1.DepRequest_DBField_value[tc.By]; ok {
 				q = &kbv1.DepRequest{
 					Field: kbv1.DepRequest_DBField(i),
-					Str:   tc.val,
+					Str:   tc.Val,
 				}
 			} else {
-				st.Assert().FailNow("invalid field name for get Deps by", tc.by)
+				st.Assert().FailNow("invalid field name for get Deps by", tc.By)
 			}
 
 			deps, err := st.store.GetDepsBy(context.Background(), q)
@@ -193,39 +191,70 @@ func (st *DBTestSuite) Test_GetDepsBy() {
 	}
 }
 
-func updateSotr(s *kbv1.Sotr, tc histTest) {
-	for fl, v := range tc.fieldsMutate {
-		switch fl {
-		case "phone":
-			v = strings.ReplaceAll(v, " ", "")
-			ph := strings.Split(v, ",")
-			s.Phone = ph
-		case "mobile":
-			if v == "" {
-				s.Mobile = nil
-				continue
-			}
-			m := strings.Split(v, ",")
-			for i, mstr := range m {
-				if len(mstr) > 5 {
-					m[i] = fmt.Sprintf("+%s (%s) %s", mstr[0:1], mstr[1:4], mstr[4:])
-				}
+func (st *DBTestSuite) Test_Conformance() {
+	suite.Run(st.T(), &storetest.ConformanceSuite{
+		NewStore: func(t *testing.T) storetest.Store {
+			st.resetDB(t)
+			return st.store
+		},
+	})
+}
+
+func (st *DBTestSuite) Test_News() {
+	suite.Run(st.T(), &storetest.NewsSuite{
+		NewStore: func(t *testing.T) storetest.NewsStore {
+			st.resetDB(t)
+			return st.store
+		},
+	})
+}
+
+func (st *DBTestSuite) Test_RemoveAbsent() {
+	ctx := context.Background()
+	db := st.store.DB
+
+	// dumps refresh razd1.27.2935.69 only, 25301 is absent in the second one
+	scope := []string{"razd1.27.2935.69"}
+	st.resetDB(st.T())
+	removeAbsent := func() {
+		st.store.SetScope(scope)
+		st.loadDB(st.T())
+		st.Require().Empty(st.store.Sotrmap, "staged sotrs should be reset by the scoped flush")
+
+		for _, s := range st.Sotrs {
+			if s.Tabnum == "2681" {
+				_, err := st.store.Save(ctx, s)
+				st.Require().NoError(err)
 			}
-			s.Mobile = m
-		case "name":
-			s.Name = v
-		case "grade":
-			s.Grade = v
-		case "idr":
-			s.Idr = v
-		case "email":
-			s.Email = v
-		case "parenr_idr":
-			s.ParentId = v
-		case "avatar":
-			s.Avatar = v
 		}
+		st.store.SetScope(scope)
+		st.store.Flush(ctx, nil)
+		_, err := st.store.Flush(ctx, nil)
+		st.Require().NoError(err)
+	}
+	removeAbsent()
+
+	var tabnums []string
+	st.Require().NoError(db.Model(&datasource.Sotr{}).Pluck("tabnum", &tabnums).Error)
+	st.NotContains(tabnums, "25301")
+	st.Contains(tabnums, "2681")
+	st.Contains(tabnums, "1600", "sotr outside of crawled deps should be kept")
+
+	del := &datasource.SotrDeleted{}
+	st.Require().NoError(db.Where("tabnum = ?", "25301").Take(del).Error)
+	for _, model := range []any{&datasource.Phone{}, &datasource.Mobile{}} {
+		var n int64
+		st.Require().NoError(db.Model(model).Where("sotr_deleted_id = ? AND sotr_id IS NULL", del.ID).Count(&n).Error)
+		st.EqualValues(1, n)
 	}
+
+	// the returned and removed again sotr replaces the deleted one with its phones
+	removeAbsent()
+	var n int64
+	st.Require().NoError(db.Model(&datasource.SotrDeleted{}).Where("tabnum = ?", "25301").Count(&n).Error)
+	st.EqualValues(1, n)
+	st.Require().NoError(db.Model(&datasource.Phone{}).Where("sotr_deleted_id = ?", del.ID).Count(&n).Error)
+	st.EqualValues(1, n)
 }
 
 func TestDBSuite(t *testing.T) {
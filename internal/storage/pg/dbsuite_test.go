@@ -3,15 +3,12 @@ package pg
 import (
 	"context"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"testing"
 
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
-	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 type DBTestSuite struct {
@@ -33,11 +30,11 @@ func (suite *DBTestSuite) SetupTest() {
 
 	// load SotrsResponse and DepsResponse
 	depsResponse := &kbv1.DepsResponse{}
-	suite.LoadJSONPb(suite.T(), "dep.json", depsResponse)
+	storetest.LoadJSONPb(suite.T(), "dep.json", depsResponse)
 	suite.Deps = depsResponse.Deps
 
 	sotrsResponse := &kbv1.SotrsResponse{}
-	suite.LoadJSONPb(suite.T(), "sotr.json", sotrsResponse)
+	storetest.LoadJSONPb(suite.T(), "sotr.json", sotrsResponse)
 	suite.Sotrs = sotrsResponse.Sotrs
 }
 
@@ -58,14 +55,25 @@ func (suite *DBTestSuite) TearDownTest() {
 	}
 }
 
-func (suite *DBTestSuite) LoadJSONPb(t *testing.T, filename string, out protoreflect.ProtoMessage) {
+// resetDB drops tables and clears internal maps of the store
+func (suite *DBTestSuite) resetDB(t *testing.T) {
 	t.Helper()
+	db := suite.store.DB
 
-	buf, err := os.ReadFile(filepath.Join("", "testdata", filename))
+	tables, err := db.Migrator().GetTables()
 	require.NoError(t, err)
 
-	err = protojson.Unmarshal(buf, out)
+	for _, table := range tables {
+		err = db.Migrator().DropTable(table) // nosemgrep
+		require.NoError(t, err)
+	}
+
+	err = suite.store.Migrate(context.TODO(), false)
 	require.NoError(t, err)
+
+	clear(suite.store.Depmap)
+	clear(suite.store.Sotrmap)
+	suite.store.FlashCounter.Store(0)
 }
 
 func (st *DBTestSuite) loadDB(t *testing.T) {
@@ -99,55 +107,7 @@ func (suite *DBTestSuite) MustQueryCount(t *testing.T, query string, args ...any
 	return
 }
 
-type Counts struct {
-	deps, sotrs, sotrs_deleted, phones, mobiles, histories int
-}
-
-func (cn *Counts) AddDeps(i int) {
-	cn.deps += i
-}
-func (cn *Counts) AddSotrs(i int) {
-	cn.sotrs += i
-}
-func (cn *Counts) AddSotrsD(i int) {
-	cn.sotrs_deleted += i
-}
-func (cn *Counts) AddPhones(i int) {
-	cn.phones += i
-}
-func (cn *Counts) AddMobiles(i int) {
-	cn.mobiles += i
-}
-func (cn *Counts) AddHistories(i int) {
-	cn.histories += i
-}
-func (suite *DBTestSuite) counts(t *testing.T) *Counts {
+func (suite *DBTestSuite) counts(t *testing.T) *storetest.Counts {
 	t.Helper()
-
-	var ret int64
-
-	c := &Counts{}
-	db := suite.store.DB
-
-	for _, tb := range []string{"deps", "sotrs", "sotrs_deleted", "phones", "mobiles", "histories"} {
-		err := db.Table(tb).Count(&ret).Error
-		suite.Require().NoError(err)
-
-		switch tb {
-		case "deps":
-			c.deps = int(int(ret))
-		case "sotrs":
-			c.sotrs = int(ret)
-		case "sotrs_deleted":
-			c.sotrs_deleted = int(ret)
-		case "phones":
-			c.phones = int(ret)
-		case "mobiles":
-			c.mobiles = int(ret)
-		case "histories":
-			c.histories = int(ret)
-		}
-	}
-
-	return c
+	return storetest.CountRows(t, suite.store.DB)
 }
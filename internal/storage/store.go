@@ -10,6 +10,8 @@ import (
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
 	"github.com/mioxin/kbempgo/internal/models"
 	"github.com/mioxin/kbempgo/internal/storage/file"
+	"github.com/mioxin/kbempgo/internal/storage/mem"
+	"github.com/mioxin/kbempgo/internal/storage/mysql"
 	"github.com/mioxin/kbempgo/internal/storage/pg"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -26,6 +28,11 @@ type Store interface {
 	Update(context.Context, *kbv1.UpdateSotrRequest) (*emptypb.Empty, error)
 	// Save(item models.Item) error
 
+	// GetHistory returns histories of changes of the employee
+	GetHistory(context.Context, *kbv1.HistRequest) (*kbv1.HistoryListResponse, error)
+	// GetVersions returns times of the latest changes in histories of employees by their ids
+	GetVersions(context.Context, *kbv1.VersionsRequest) (*kbv1.VersionsResponse, error)
+
 	Close() error
 	Flush(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
 	PromCollector() prometheus.Collector
@@ -39,6 +46,45 @@ type StoreManager interface {
 	Retention(ctx context.Context, olderThan time.Time) error
 }
 
+// Scoper is implemented by storages removing employees which are absent in the dump
+type Scoper interface {
+	// SetScope sets idrs of deps crawled by the dump. On the next final flush employees of these deps
+	// which are not saved are removed, employees of other deps are kept.
+	// Without scope employees are never removed.
+	SetScope(idrs []string)
+}
+
+// Avatars is implemented by storages keeping metadata of avatars of the avatar store
+type Avatars interface {
+	// SaveAvatars saves avatars seen by the dump. The changed avatar of the employee is a new row
+	// and the history of the avatar hash.
+	SaveAvatars(ctx context.Context, avatars []*kbv1.Avatar) error
+	// GetAvatar returns the current avatar of the employee or nil if it's absent
+	GetAvatar(ctx context.Context, tabnum string) (*kbv1.Avatar, error)
+}
+
+// News is implemented by storages keeping news of the intranet
+type News interface {
+	// SaveNews saves news with comments, the news with the same idn is replaced
+	SaveNews(ctx context.Context, news []*kbv1.News) error
+	// ListNews returns news matched by the query without comments, the newest first
+	ListNews(ctx context.Context, q *kbv1.ListNewsRequest) ([]*kbv1.News, error)
+	// GetNews returns the news with comments or nil if it's absent
+	GetNews(ctx context.Context, idn string) (*kbv1.News, error)
+}
+
+// Generations is implemented by storages counting changes of their data
+type Generations interface {
+	// Generation returns the generation of data, it's changed by every Update and Flush
+	Generation(ctx context.Context) (int64, error)
+}
+
+// Stager is implemented by storages staging items of Save in memory until Flush
+type Stager interface {
+	// Staging reports that saved items aren't queried until Flush
+	Staging() bool
+}
+
 func NewStore(source string, log *slog.Logger) (st Store, err error) {
 	if source == "" {
 		return nil, fmt.Errorf("error create Store, source is empty")
@@ -55,6 +101,16 @@ func NewStore(source string, log *slog.Logger) (st Store, err error) {
 		}
 		err = st.(*pg.PgStore).Migrate(context.TODO(), false)
 
+	case "mysql", "mariadb":
+		// go-sql-driver DSN has no scheme: user:password@tcp(host:3306)/dbname
+		_, dsn, _ := strings.Cut(source, "://")
+		st, err = mysql.New(dsn, log)
+		if err != nil {
+			err = fmt.Errorf("error create Store, invalid source, %s. %w", dbType, err)
+			break
+		}
+		err = st.(*mysql.MysqlStore).Migrate(context.TODO(), false)
+
 	case "file":
 		s, ok := strings.CutPrefix(source, "file://")
 		if !ok {
@@ -63,6 +119,15 @@ func NewStore(source string, log *slog.Logger) (st Store, err error) {
 		}
 
 		st, err = file.NewFileStore(s, log)
+
+	case "mem":
+		// mem:// is empty storage, mem://<dir> is seeded from the dump directory of file storage
+		s, _ := strings.CutPrefix(source, "mem://")
+		st, err = mem.New(s, log)
+		if err != nil {
+			err = fmt.Errorf("error create Store, invalid source, %s. %w", source, err)
+		}
+
 	default:
 		err = fmt.Errorf("error create Store, invalid db type in the source \"%v\" (%s)", dbType, source)
 	}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/mioxin/kbempgo/internal/storage/storetest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestFileStoreConformance(t *testing.T) {
+	suite.Run(t, &storetest.ConformanceSuite{
+		NewStore: func(t *testing.T) storetest.Store {
+			st, err := NewStore("file://"+t.TempDir(), slog.Default())
+			require.NoError(t, err)
+			t.Cleanup(func() { st.Close() })
+
+			return st
+		},
+	})
+}
+
+func TestMemStoreConformance(t *testing.T) {
+	suite.Run(t, &storetest.ConformanceSuite{
+		NewStore: func(t *testing.T) storetest.Store {
+			st, err := NewStore("mem://", slog.Default())
+			require.NoError(t, err)
+
+			return st
+		},
+	})
+}
+
+func TestFileStoreNews(t *testing.T) {
+	suite.Run(t, &storetest.NewsSuite{
+		NewStore: func(t *testing.T) storetest.NewsStore {
+			st, err := NewStore("file://"+t.TempDir(), slog.Default())
+			require.NoError(t, err)
+			t.Cleanup(func() { st.Close() })
+
+			return st.(News)
+		},
+	})
+}
+
+func TestMemStoreNews(t *testing.T) {
+	suite.Run(t, &storetest.NewsSuite{
+		NewStore: func(t *testing.T) storetest.NewsStore {
+			st, err := NewStore("mem://", slog.Default())
+			require.NoError(t, err)
+
+			return st.(News)
+		},
+	})
+}
@@ -0,0 +1,369 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/datasource"
+	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/storage/file"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Store is a storage under the conformance test.
+// It's a part of storage.Store, the storage package isn't imported
+// because it imports all storages and it makes import cycle in their tests.
+type Store interface {
+	GetDepsBy(context.Context, *kbv1.DepRequest) ([]*kbv1.Dep, error)
+	GetSotrsBy(context.Context, *kbv1.SotrRequest) ([]*kbv1.Sotr, error)
+	GetHistory(context.Context, *kbv1.HistRequest) (*kbv1.HistoryListResponse, error)
+	GetVersions(context.Context, *kbv1.VersionsRequest) (*kbv1.VersionsResponse, error)
+	Save(context.Context, models.Item) (*emptypb.Empty, error)
+	Update(context.Context, *kbv1.UpdateSotrRequest) (*emptypb.Empty, error)
+	Flush(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+}
+
+// ConformanceSuite checks the common behaviour of storages.
+// Run it for a storage by suite.Run(t, &storetest.ConformanceSuite{NewStore: ...})
+type ConformanceSuite struct {
+	suite.Suite
+
+	// NewStore returns an empty storage. It is called before every test.
+	NewStore func(t *testing.T) Store
+
+	store Store
+	Deps  []*kbv1.Dep
+	Sotrs []*kbv1.Sotr
+}
+
+// sotrsQuery is GetSotrsBy query with expected tabnums of found sotrs
+type sotrsQuery struct {
+	Name    string
+	Field   kbv1.SotrRequest_DBField
+	Val     string
+	Tabnums []string
+}
+
+var conformanceSotrsCases = []sotrsQuery{
+	{"idr", kbv1.SotrRequest_IDR, "sotr4918", []string{"60609"}},
+	{"idr not found", kbv1.SotrRequest_IDR, "sotr0", nil},
+	{"parent", kbv1.SotrRequest_PARENT, "razd1.27.2935.69", []string{"2681", "25301"}},
+	{"parent without sotrs", kbv1.SotrRequest_PARENT, "razd1.27", nil},
+	{"tabnum", kbv1.SotrRequest_TABNUM, "52957", []string{"52957"}},
+	{"mobile formatted", kbv1.SotrRequest_MOBILE, "+7 (701) 000-67-00", []string{"60609"}},
+	{"mobile digits", kbv1.SotrRequest_MOBILE, "77010006700", []string{"60609"}},
+	{"mobile not first", kbv1.SotrRequest_MOBILE, "+7 (701) 0006701", []string{"60609"}},
+	{"mobile not found", kbv1.SotrRequest_MOBILE, "+7 (701) 111-11-11", nil},
+	{"fio with mid name", kbv1.SotrRequest_FIO, "Са44444 Асемгуль Абатовна", []string{"60609"}},
+	{"fio without mid name", kbv1.SotrRequest_FIO, "Са44444 Асемгуль", []string{"60609"}},
+	{"fio with wrong mid name", kbv1.SotrRequest_FIO, "Са44444 Асемгуль Иванова", nil},
+	{"fio of empty mid name", kbv1.SotrRequest_FIO, "Та4444 Сабина", []string{"52957"}},
+}
+
+func (s *ConformanceSuite) SetupTest() {
+	s.store = s.NewStore(s.T())
+
+	depsResponse := &kbv1.DepsResponse{}
+	LoadJSONPb(s.T(), "dep.json", depsResponse)
+	s.Deps = depsResponse.Deps
+
+	sotrsResponse := &kbv1.SotrsResponse{}
+	LoadJSONPb(s.T(), "sotr.json", sotrsResponse)
+	s.Sotrs = sotrsResponse.Sotrs
+
+	s.load()
+}
+
+// load saves deps and sotrs like a dump: deps, 1st flush, sotrs, final flush
+func (s *ConformanceSuite) load() {
+	ctx := context.Background()
+
+	for _, d := range s.Deps {
+		_, err := s.store.Save(ctx, d)
+		s.Require().NoError(err)
+	}
+	_, err := s.store.Flush(ctx, &emptypb.Empty{})
+	s.Require().NoError(err)
+
+	for _, sotr := range s.Sotrs {
+		_, err = s.store.Save(ctx, sotr)
+		s.Require().NoError(err)
+	}
+	_, err = s.store.Flush(ctx, &emptypb.Empty{})
+	s.Require().NoError(err)
+}
+
+func (s *ConformanceSuite) sotrsBy(field kbv1.SotrRequest_DBField, val string) []*kbv1.Sotr {
+	sotrs, err := s.store.GetSotrsBy(context.Background(), &kbv1.SotrRequest{Field: field, Str: val})
+	s.Require().NoError(err)
+	return sotrs
+}
+
+func (s *ConformanceSuite) histories(id uint64) []datasource.History {
+	lhist, err := s.store.GetHistory(context.Background(), &kbv1.HistRequest{SotrId: strconv.FormatUint(id, 10)})
+	s.Require().NoError(err)
+
+	hs := make([]datasource.History, 0, len(lhist.GetHistoryList()))
+	for _, h := range lhist.GetHistoryList() {
+		s.Equal(id, h.SotrId)
+		hs = append(hs, datasource.History{Field: h.Field, OldValue: h.OldValue})
+	}
+	return hs
+}
+
+// equalSotr compares sotrs as they are saved in SQL storage
+func (s *ConformanceSuite) equalSotr(expected, actual *kbv1.Sotr) {
+	s.EqualValues(utils.ConvKbv2Ds(expected), utils.ConvKbv2Ds(actual), "tabnum %s", expected.Tabnum)
+}
+
+func (s *ConformanceSuite) fixtureSotr(tabnum string) *kbv1.Sotr {
+	for _, sotr := range s.Sotrs {
+		if sotr.Tabnum == tabnum {
+			return sotr
+		}
+	}
+	s.FailNow("sotr not found in fixture", tabnum)
+	return nil
+}
+
+func tabnums(sotrs []*kbv1.Sotr) (tn []string) {
+	for _, s := range sotrs {
+		tn = append(tn, s.Tabnum)
+	}
+	return
+}
+
+func (s *ConformanceSuite) TestSaveFlush() {
+	deps, err := s.store.GetDepsBy(context.Background(), &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+	s.Require().NoError(err)
+	s.Len(deps, len(s.Deps))
+
+	sotrs := s.sotrsBy(kbv1.SotrRequest_NONE, "")
+	s.Require().Len(sotrs, len(s.Sotrs))
+
+	ids := make(map[uint64]struct{}, len(sotrs))
+	for _, sotr := range sotrs {
+		s.NotZero(sotr.Id, "tabnum %s", sotr.Tabnum)
+		ids[sotr.Id] = struct{}{}
+
+		s.equalSotr(s.fixtureSotr(sotr.Tabnum), sotr)
+	}
+	s.Len(ids, len(sotrs), "IDs of sotrs should be unique")
+}
+
+func (s *ConformanceSuite) TestSaveUnchanged() {
+	before := s.sotrsBy(kbv1.SotrRequest_NONE, "")
+
+	// the same data again
+	s.load()
+
+	after := s.sotrsBy(kbv1.SotrRequest_NONE, "")
+	s.Require().Len(after, len(before))
+
+	deps, err := s.store.GetDepsBy(context.Background(), &kbv1.DepRequest{Field: kbv1.DepRequest_NONE})
+	s.Require().NoError(err)
+	s.Len(deps, len(s.Deps))
+
+	for _, sotr := range before {
+		s.Empty(s.histories(sotr.Id), "tabnum %s", sotr.Tabnum)
+	}
+}
+
+func (s *ConformanceSuite) TestGetDepsBy() {
+	for _, tc := range GetDepsCases {
+		s.Run(tc.By, func() {
+			expected := &kbv1.DepsResponse{}
+			s.Require().NoError(protojson.Unmarshal([]byte(tc.Expected), expected))
+
+			deps, err := s.store.GetDepsBy(context.Background(), &kbv1.DepRequest{
+				Field: kbv1.DepRequest_DBField(kbv1.DepRequest_DBField_value[tc.By]),
+				Str:   tc.Val,
+			})
+			s.Require().NoError(err)
+
+			exp := make([]datasource.Item, 0, len(expected.Deps))
+			for _, d := range expected.Deps {
+				exp = append(exp, utils.ConvKbv2Ds(d))
+			}
+			actual := make([]datasource.Item, 0, len(deps))
+			for _, d := range deps {
+				actual = append(actual, utils.ConvKbv2Ds(d))
+			}
+			s.ElementsMatch(exp, actual)
+		})
+	}
+
+	s.Run("not found", func() {
+		deps, err := s.store.GetDepsBy(context.Background(), &kbv1.DepRequest{Field: kbv1.DepRequest_PARENT, Str: "razd0"})
+		s.Require().NoError(err)
+		s.Empty(deps)
+	})
+
+	s.Run("invalid field", func() {
+		_, err := s.store.GetDepsBy(context.Background(), &kbv1.DepRequest{Field: 10})
+		var fe *file.FieldNameError
+		s.True(errors.As(err, &fe), "expected FieldNameError, got %v", err)
+	})
+}
+
+func (s *ConformanceSuite) TestGetSotrsBy() {
+	for _, tc := range conformanceSotrsCases {
+		s.Run(tc.Name, func() {
+			sotrs := s.sotrsBy(tc.Field, tc.Val)
+			s.ElementsMatch(tc.Tabnums, tabnums(sotrs))
+
+			for _, sotr := range sotrs {
+				s.equalSotr(s.fixtureSotr(sotr.Tabnum), sotr)
+			}
+		})
+	}
+
+	s.Run("none", func() {
+		expected := tabnums(s.Sotrs)
+		s.ElementsMatch(expected, tabnums(s.sotrsBy(kbv1.SotrRequest_NONE, "")))
+	})
+
+	s.Run("invalid mobile", func() {
+		_, err := s.store.GetSotrsBy(context.Background(), &kbv1.SotrRequest{Field: kbv1.SotrRequest_MOBILE, Str: "none"})
+		s.Error(err)
+	})
+
+	s.Run("invalid field", func() {
+		_, err := s.store.GetSotrsBy(context.Background(), &kbv1.SotrRequest{Field: 10})
+		var fe *file.FieldNameError
+		s.True(errors.As(err, &fe), "expected FieldNameError, got %v", err)
+	})
+}
+
+func (s *ConformanceSuite) TestHistory() {
+	for _, tc := range HistCases {
+		s.Run(tc.Name, func() {
+			s.SetupTest()
+
+			before := s.sotrsBy(kbv1.SotrRequest_TABNUM, tc.TabMutate)
+			s.Require().Len(before, 1)
+
+			sotr := s.fixtureSotr(tc.TabMutate)
+			UpdateSotr(sotr, tc)
+			s.load()
+
+			after := s.sotrsBy(kbv1.SotrRequest_TABNUM, tc.TabMutate)
+			s.Require().Len(after, 1)
+			s.Equal(before[0].Id, after[0].Id)
+			s.equalSotr(sotr, after[0])
+
+			s.Equal(tc.ExpectedHistories, s.histories(after[0].Id))
+		})
+	}
+}
+
+func (s *ConformanceSuite) TestUpdate() {
+	sotrs := s.sotrsBy(kbv1.SotrRequest_TABNUM, "60609")
+	s.Require().Len(sotrs, 1)
+
+	sotr := sotrs[0]
+	oldGrade := sotr.Grade
+	sotr.Grade = "LLLLLLLL"
+
+	_, err := s.store.Update(context.Background(), &kbv1.UpdateSotrRequest{Sotr: sotr})
+	s.Require().NoError(err)
+
+	after := s.sotrsBy(kbv1.SotrRequest_TABNUM, "60609")
+	s.Require().Len(after, 1)
+	s.Equal(sotr.Id, after[0].Id)
+	s.equalSotr(sotr, after[0])
+
+	s.Equal([]datasource.History{{Field: "grade", OldValue: oldGrade}}, s.histories(sotr.Id))
+
+	s.Run("empty tabnum", func() {
+		_, err := s.store.Update(context.Background(), &kbv1.UpdateSotrRequest{Sotr: &kbv1.Sotr{}})
+		s.Error(err)
+	})
+}
+
+func (s *ConformanceSuite) TestVersions() {
+	ctx := context.Background()
+	changed := s.sotrsBy(kbv1.SotrRequest_TABNUM, "60609")
+	s.Require().Len(changed, 1)
+	kept := s.sotrsBy(kbv1.SotrRequest_TABNUM, "52957")
+	s.Require().Len(kept, 1)
+
+	sotr := changed[0]
+	sotr.Grade = "LLLLLLLL"
+	_, err := s.store.Update(ctx, &kbv1.UpdateSotrRequest{Sotr: sotr})
+	s.Require().NoError(err)
+
+	lhist, err := s.store.GetHistory(ctx, &kbv1.HistRequest{SotrId: strconv.FormatUint(sotr.Id, 10)})
+	s.Require().NoError(err)
+	s.Require().Len(lhist.HistoryList, 1)
+
+	resp, err := s.store.GetVersions(ctx, &kbv1.VersionsRequest{SotrIds: []uint64{sotr.Id, kept[0].Id}})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Versions, 1, "employees without history are absent")
+	s.Require().Contains(resp.Versions, sotr.Id)
+	s.True(lhist.HistoryList[0].Date.AsTime().Equal(resp.Versions[sotr.Id].AsTime()))
+
+	resp, err = s.store.GetVersions(ctx, &kbv1.VersionsRequest{})
+	s.Require().NoError(err)
+	s.Empty(resp.Versions)
+}
+
+func (s *ConformanceSuite) TestUpdateImported() {
+	sotrs := s.sotrsBy(kbv1.SotrRequest_TABNUM, "60609")
+	s.Require().Len(sotrs, 1)
+
+	sotr := sotrs[0]
+	sotr.HireDate = timestamppb.New(time.Date(2015, 3, 2, 0, 0, 0, 0, time.UTC))
+	sotr.Position = "Главный специалист"
+	sotr.ManagerTabnum = "1600"
+
+	_, err := s.store.Update(context.Background(), &kbv1.UpdateSotrRequest{Sotr: sotr})
+	s.Require().NoError(err)
+
+	after := s.sotrsBy(kbv1.SotrRequest_TABNUM, "60609")
+	s.Require().Len(after, 1)
+	s.equalSotr(sotr, after[0])
+
+	expected := []datasource.History{{Field: "hire_date"}, {Field: "position"}, {Field: "manager_tabnum"}}
+	s.Equal(expected, s.histories(sotr.Id))
+
+	// the dump has no imported attributes, they are kept
+	s.load()
+
+	after = s.sotrsBy(kbv1.SotrRequest_TABNUM, "60609")
+	s.Require().Len(after, 1)
+	s.equalSotr(sotr, after[0])
+	s.Equal(expected, s.histories(sotr.Id))
+
+	// cleared attributes are not kept
+	cleared := after[0]
+	cleared.Position, cleared.ManagerTabnum = "", ""
+	_, err = s.store.Update(context.Background(), &kbv1.UpdateSotrRequest{
+		Sotr:  cleared,
+		Clear: &fieldmaskpb.FieldMask{Paths: []string{"manager_tabnum"}},
+	})
+	s.Require().NoError(err)
+
+	after = s.sotrsBy(kbv1.SotrRequest_TABNUM, "60609")
+	s.Require().Len(after, 1)
+	s.Empty(after[0].ManagerTabnum)
+	s.Equal("Главный специалист", after[0].Position)
+	s.Equal(append(expected, datasource.History{Field: "manager_tabnum", OldValue: "1600"}), s.histories(sotr.Id))
+
+	s.Run("unknown field", func() {
+		_, err := s.store.Update(context.Background(), &kbv1.UpdateSotrRequest{
+			Sotr:  cleared,
+			Clear: &fieldmaskpb.FieldMask{Paths: []string{"grade"}},
+		})
+		s.Error(err)
+	})
+}
@@ -0,0 +1,121 @@
+package storetest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gorm.io/gorm"
+)
+
+// Tables of SQL storage counted by CountRows
+var Tables = []string{"deps", "sotrs", "sotr_deleteds", "phones", "mobiles", "histories"}
+
+type Counts struct {
+	deps, sotrs, sotrs_deleted, phones, mobiles, histories int
+}
+
+func (cn *Counts) AddDeps(i int) {
+	cn.deps += i
+}
+func (cn *Counts) AddSotrs(i int) {
+	cn.sotrs += i
+}
+func (cn *Counts) AddSotrsD(i int) {
+	cn.sotrs_deleted += i
+}
+func (cn *Counts) AddPhones(i int) {
+	cn.phones += i
+}
+func (cn *Counts) AddMobiles(i int) {
+	cn.mobiles += i
+}
+func (cn *Counts) AddHistories(i int) {
+	cn.histories += i
+}
+
+// CountRows returns numbers of rows in the SQL storage tables
+func CountRows(t *testing.T, db *gorm.DB) *Counts {
+	t.Helper()
+
+	var ret int64
+
+	c := &Counts{}
+
+	for _, tb := range Tables {
+		err := db.Table(tb).Count(&ret).Error
+		require.NoError(t, err)
+
+		switch tb {
+		case "deps":
+			c.deps = int(ret)
+		case "sotrs":
+			c.sotrs = int(ret)
+		case "sotr_deleteds":
+			c.sotrs_deleted = int(ret)
+		case "phones":
+			c.phones = int(ret)
+		case "mobiles":
+			c.mobiles = int(ret)
+		case "histories":
+			c.histories = int(ret)
+		}
+	}
+
+	return c
+}
+
+// LoadJSONPb loads proto message from the json file in storetest/testdata
+func LoadJSONPb(t *testing.T, filename string, out protoreflect.ProtoMessage) {
+	t.Helper()
+
+	_, src, _, _ := runtime.Caller(0)
+	buf, err := os.ReadFile(filepath.Join(filepath.Dir(src), "testdata", filename))
+	require.NoError(t, err)
+
+	err = protojson.Unmarshal(buf, out)
+	require.NoError(t, err)
+}
+
+// UpdateSotr applies mutations of the test case to the employee
+func UpdateSotr(s *kbv1.Sotr, tc HistTest) {
+	for fl, v := range tc.FieldsMutate {
+		switch fl {
+		case "phone":
+			v = strings.ReplaceAll(v, " ", "")
+			ph := strings.Split(v, ",")
+			s.Phone = ph
+		case "mobile":
+			if v == "" {
+				s.Mobile = nil
+				continue
+			}
+			m := strings.Split(v, ",")
+			for i, mstr := range m {
+				if len(mstr) > 5 {
+					m[i] = fmt.Sprintf("+%s (%s) %s", mstr[0:1], mstr[1:4], mstr[4:])
+				}
+			}
+			s.Mobile = m
+		case "name":
+			s.Name = v
+		case "grade":
+			s.Grade = v
+		case "idr":
+			s.Idr = v
+		case "email":
+			s.Email = v
+		case "parenr_idr":
+			s.ParentId = v
+		case "avatar":
+			s.Avatar = v
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NewsStore is a storage of news under the test, it's a copy of storage.News
+type NewsStore interface {
+	SaveNews(context.Context, []*kbv1.News) error
+	ListNews(context.Context, *kbv1.ListNewsRequest) ([]*kbv1.News, error)
+	GetNews(context.Context, string) (*kbv1.News, error)
+}
+
+// NewsSuite checks the common behaviour of storages of news.
+// Run it for a storage by suite.Run(t, &storetest.NewsSuite{NewStore: ...})
+type NewsSuite struct {
+	suite.Suite
+
+	// NewStore returns an empty storage. It is called before every test.
+	NewStore func(t *testing.T) NewsStore
+
+	store NewsStore
+}
+
+var newsDay = time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+func fixtureNews() []*kbv1.News {
+	return []*kbv1.News{
+		{
+			Idn:          "101",
+			Title:        "Новый офис открыт",
+			Author:       "Пал4444 Юлия",
+			AuthorTabnum: "2681",
+			Date:         timestamppb.New(newsDay.Add(50 * time.Hour)),
+			Body:         "<p>Офис открыт.</p>",
+			Url:          "/news/item?id=101",
+			Comments: []*kbv1.Comment{
+				{Idc: "1", Author: "Руда4444 Маргарита", AuthorTabnum: "63665", Date: timestamppb.New(newsDay.Add(51 * time.Hour)), Text: "Поздравляем!"},
+				{Idc: "2", ParentIdc: "1", Author: "Пал4444 Юлия", AuthorTabnum: "2681", Date: timestamppb.New(newsDay.Add(52 * time.Hour)), Text: "Спасибо"},
+			},
+		},
+		{
+			Idn:    "102",
+			Title:  "День открытых дверей",
+			Author: "Неизвестный Автор",
+			Date:   timestamppb.New(newsDay),
+			Body:   "<p>Приглашаем всех.</p>",
+			Url:    "/news/item?id=102",
+		},
+	}
+}
+
+func (s *NewsSuite) SetupTest() {
+	s.store = s.NewStore(s.T())
+	s.Require().NoError(s.store.SaveNews(context.Background(), fixtureNews()))
+}
+
+func (s *NewsSuite) list(q *kbv1.ListNewsRequest) (idns []string) {
+	news, err := s.store.ListNews(context.Background(), q)
+	s.Require().NoError(err)
+
+	for _, n := range news {
+		s.Empty(n.Comments, "list of news shouldn't contain comments")
+		idns = append(idns, n.Idn)
+	}
+	return
+}
+
+func (s *NewsSuite) TestListNews() {
+	cases := []struct {
+		Name  string
+		Query *kbv1.ListNewsRequest
+		Idns  []string
+	}{
+		{"all newest first", &kbv1.ListNewsRequest{}, []string{"101", "102"}},
+		{"author", &kbv1.ListNewsRequest{AuthorTabnum: "2681"}, []string{"101"}},
+		{"since", &kbv1.ListNewsRequest{Since: timestamppb.New(newsDay.Add(time.Hour))}, []string{"101"}},
+		{"until", &kbv1.ListNewsRequest{Until: timestamppb.New(newsDay.Add(time.Hour))}, []string{"102"}},
+		{"query ignores case", &kbv1.ListNewsRequest{Query: "офис"}, []string{"101"}},
+		{"limit", &kbv1.ListNewsRequest{Limit: 1}, []string{"101"}},
+		{"offset", &kbv1.ListNewsRequest{Offset: 1}, []string{"102"}},
+		{"not found", &kbv1.ListNewsRequest{AuthorTabnum: "0"}, nil},
+	}
+
+	for _, tc := range cases {
+		s.Run(tc.Name, func() {
+			s.Equal(tc.Idns, s.list(tc.Query))
+		})
+	}
+}
+
+func (s *NewsSuite) TestGetNews() {
+	n, err := s.store.GetNews(context.Background(), "101")
+	s.Require().NoError(err)
+	s.Require().NotNil(n)
+
+	expected := fixtureNews()[0]
+	s.NotZero(n.Id)
+	s.Equal(expected.Title, n.Title)
+	s.Equal(expected.AuthorTabnum, n.AuthorTabnum)
+	s.Equal(expected.Body, n.Body)
+	s.True(expected.Date.AsTime().Equal(n.Date.AsTime()))
+
+	s.Require().Len(n.Comments, 2)
+	for i, c := range n.Comments {
+		s.Equal(expected.Comments[i].Idc, c.Idc)
+		s.Equal(expected.Comments[i].ParentIdc, c.ParentIdc)
+		s.Equal(expected.Comments[i].AuthorTabnum, c.AuthorTabnum)
+		s.Equal(expected.Comments[i].Text, c.Text)
+	}
+
+	n, err = s.store.GetNews(context.Background(), "999")
+	s.Require().NoError(err)
+	s.Nil(n)
+}
+
+func (s *NewsSuite) TestSaveNewsReplaces() {
+	ctx := context.Background()
+	before, err := s.store.GetNews(ctx, "101")
+	s.Require().NoError(err)
+
+	n := fixtureNews()[0]
+	n.Title = "Новый офис открыт!"
+	n.Comments = n.Comments[:1]
+	s.Require().NoError(s.store.SaveNews(ctx, []*kbv1.News{n}))
+	s.Equal(before.Id, n.Id, "saved news should keep its id")
+
+	after, err := s.store.GetNews(ctx, "101")
+	s.Require().NoError(err)
+	s.Equal(before.Id, after.Id)
+	s.Equal("Новый офис открыт!", after.Title)
+	s.Len(after.Comments, 1)
+
+	s.Equal([]string{"101", "102"}, s.list(&kbv1.ListNewsRequest{}))
+}
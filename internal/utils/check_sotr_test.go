@@ -0,0 +1,120 @@
+package utils_test
+
+// The test uses file storage, so it is in the external package
+// to avoid import cycle with the storage.
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/storage/file"
+	"github.com/mioxin/kbempgo/internal/utils"
+	"github.com/stretchr/testify/require"
+)
+
+var fname []string = []string{
+	"/mnt/c/Arch/UTIL/curl/bin/1.html",
+	"/mnt/c/Arch/UTIL/curl/bin/10.html",
+	"/mnt/c/Arch/UTIL/curl/bin/11.html",
+	"/mnt/c/Arch/UTIL/curl/bin/12.html",
+	"/mnt/c/Arch/UTIL/curl/bin/2.html",
+	"/mnt/c/Arch/UTIL/curl/bin/3.html",
+	"/mnt/c/Arch/UTIL/curl/bin/4.html",
+	"/mnt/c/Arch/UTIL/curl/bin/5.html",
+	"/mnt/c/Arch/UTIL/curl/bin/6.html",
+	"/mnt/c/Arch/UTIL/curl/bin/7.html",
+	"/mnt/c/Arch/UTIL/curl/bin/8.html",
+	"/mnt/c/Arch/UTIL/curl/bin/9.html",
+}
+
+func TestCheckSotr(t *testing.T) {
+
+	t.Skip("test for local test a scraped data")
+
+	stor, err := file.NewFileStore("../../.tmp", slog.Default())
+
+	require.NoError(t, err)
+	defer stor.Close()
+
+	s, err := stor.GetSotrsBy(context.TODO(), &kbv1.SotrRequest{Field: kbv1.SotrRequest_NONE, Str: ""})
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+
+	users, err := ToMap(s)
+	require.NoError(t, err)
+	require.LessOrEqual(t, 0, len(users))
+
+	onDiv := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		i := strings.Index(string(data), "class=div5b")
+		if i == -1 {
+			if !atEOF {
+				return 0, nil, nil
+			}
+
+			return 0, data, bufio.ErrFinalToken
+		}
+		// Otherwise, return the token before .
+		return i + 1, data[:i], nil
+	}
+
+	lookFIO := func(count int, fn string) int {
+		file, err := os.Open(fn)
+		require.NoError(t, err)
+
+		defer file.Close() // nolint
+
+		scan := bufio.NewScanner(file)
+
+		scan.Split(onDiv)
+
+		for scan.Scan() {
+			sotrText := html.UnescapeString(scan.Text())
+			tabnum := utils.FindBetween(sotrText, `onclick="opencard('`, `')"`)
+
+			if tabnum == "" {
+				continue
+			}
+
+			fio := utils.FindBetween(sotrText, `class="ln4">`, `</a>`)
+			dep := utils.FindBetween(sotrText, `color:#666;">`, `</span>`)
+
+			_, ok := users[tabnum]
+			if ok {
+				continue
+			}
+
+			fmt.Printf("%d:\t%s\t%s\t%s\n", count, tabnum, fio, dep)
+			count++
+		}
+
+		return count
+	}
+
+	count := 1
+
+	for i, fn := range fname {
+		if i > 12 {
+			break
+		}
+
+		count = lookFIO(count, fn)
+	}
+}
+
+func ToMap(sl []*kbv1.Sotr) (map[string]*kbv1.Sotr, error) {
+	users := make(map[string]*kbv1.Sotr)
+
+	for _, v := range sl {
+		users[v.Tabnum] = v
+	}
+	return users, nil
+}
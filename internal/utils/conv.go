@@ -2,12 +2,15 @@ package utils
 
 import (
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
 	"github.com/mioxin/kbempgo/internal/datasource"
 	"github.com/mioxin/kbempgo/internal/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // convers kbv1 items struct to datasource items
@@ -35,6 +38,11 @@ func ConvKbv2Ds(item models.Item) (ds datasource.Item) {
 			if sotr.Email != "" {
 				email = &sotr.Email
 			}
+			var hireDate *time.Time
+			if sotr.HireDate != nil {
+				t := sotr.HireDate.AsTime()
+				hireDate = &t
+			}
 			return &datasource.Sotr{
 				Idr:       sotr.Idr,
 				Tabnum:    sotr.Tabnum,
@@ -47,6 +55,10 @@ func ConvKbv2Ds(item models.Item) (ds datasource.Item) {
 				Grade:     sotr.Grade,
 				Children:  sotr.Children,
 				ParentIdr: sotr.ParentId,
+
+				HireDate:      hireDate,
+				Position:      sotr.Position,
+				ManagerTabnum: sotr.ManagerTabnum,
 			}
 		}
 	}
@@ -87,3 +99,35 @@ func ExtractDigits(s string) string {
 	}
 	return builder.String()
 }
+
+// DiffSotr compares the saved sotr with the new one and returns histories
+// of changed fields with old values. Histories are the same as generated by SQL storages.
+func DiffSotr(oldSotr, newSotr *kbv1.Sotr) (hs []*kbv1.History) {
+	oldDs := ConvKbv2Ds(oldSotr).(*datasource.Sotr)
+	newDs := ConvKbv2Ds(newSotr).(*datasource.Sotr)
+
+	now := timestamppb.Now()
+	for _, h := range newDs.Diff(*oldDs) {
+		hs = append(hs, &kbv1.History{
+			Date:     now,
+			Field:    h.Field,
+			OldValue: h.OldValue,
+			SotrId:   oldSotr.Id,
+		})
+	}
+	return
+}
+
+// KeepImported sets empty attributes of HR exports of the new sotr from the saved one except cleared ones,
+// like SQL storages do before saving
+func KeepImported(oldSotr, newSotr *kbv1.Sotr, cleared []string) {
+	if newSotr.HireDate == nil && !slices.Contains(cleared, datasource.FieldHireDate) {
+		newSotr.HireDate = oldSotr.HireDate
+	}
+	if newSotr.Position == "" && !slices.Contains(cleared, datasource.FieldPosition) {
+		newSotr.Position = oldSotr.Position
+	}
+	if newSotr.ManagerTabnum == "" && !slices.Contains(cleared, datasource.FieldManagerTabnum) {
+		newSotr.ManagerTabnum = oldSotr.ManagerTabnum
+	}
+}
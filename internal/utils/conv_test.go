@@ -7,6 +7,7 @@ import (
 	"github.com/mioxin/kbempgo/internal/datasource"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestExtractDigits(t *testing.T) {
@@ -145,3 +146,58 @@ func TestConvKbv2Mobile(t *testing.T) {
 		})
 	}
 }
+
+func TestDiffSotr(t *testing.T) {
+	oldSotr := &kbv1.Sotr{
+		Id:     7,
+		Tabnum: "100",
+		Name:   "Иванов Иван",
+		Mobile: []string{"+7 (701) 000-67-00"},
+		Grade:  "Специалист",
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(s *kbv1.Sotr)
+		expected map[string]string
+	}{
+		{
+			name:     "no changes",
+			mutate:   func(s *kbv1.Sotr) {},
+			expected: map[string]string{},
+		},
+		{
+			name:     "other format of mobile",
+			mutate:   func(s *kbv1.Sotr) { s.Mobile = []string{"+7 (701) 0006700"} },
+			expected: map[string]string{},
+		},
+		{
+			name: "grade and email",
+			mutate: func(s *kbv1.Sotr) {
+				s.Grade = "Начальник"
+				s.Email = "a@b.c"
+			},
+			expected: map[string]string{"grade": "Специалист", "email": ""},
+		},
+		{
+			name:     "mobile",
+			mutate:   func(s *kbv1.Sotr) { s.Mobile = nil },
+			expected: map[string]string{"mobile": "+7 (701) 0006700"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSotr := proto.Clone(oldSotr).(*kbv1.Sotr)
+			tt.mutate(newSotr)
+
+			actual := map[string]string{}
+			for _, h := range DiffSotr(oldSotr, newSotr) {
+				assert.Equal(t, oldSotr.Id, h.SotrId)
+				assert.NotNil(t, h.Date)
+				actual[h.Field] = h.OldValue
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
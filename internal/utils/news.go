@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// FilterNews returns news matched by the query without comments, the newest first.
+// It's used by storages keeping news in memory.
+func FilterNews(news []*kbv1.News, q *kbv1.ListNewsRequest) (res []*kbv1.News) {
+	query := strings.ToLower(q.GetQuery())
+
+	for _, n := range news {
+		switch {
+		case q.GetAuthorTabnum() != "" && n.AuthorTabnum != q.GetAuthorTabnum():
+			continue
+		case q.GetSince() != nil && n.Date.AsTime().Before(q.GetSince().AsTime()):
+			continue
+		case q.GetUntil() != nil && !n.Date.AsTime().Before(q.GetUntil().AsTime()):
+			continue
+		case query != "" && !strings.Contains(strings.ToLower(n.Title), query):
+			continue
+		}
+
+		c := proto.Clone(n).(*kbv1.News)
+		c.Comments = nil
+		res = append(res, c)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Date.AsTime().After(res[j].Date.AsTime()) })
+
+	off := min(int(q.GetOffset()), len(res))
+	res = res[off:]
+	if q.GetLimit() > 0 && len(res) > int(q.GetLimit()) {
+		res = res[:q.GetLimit()]
+	}
+	return
+}
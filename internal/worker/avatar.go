@@ -14,6 +14,7 @@ import (
 
 type AvatarInfo struct {
 	ActualName string
+	Path       string
 	Num        int
 	Size       int64
 	Hash       string
@@ -51,6 +52,7 @@ func NewAvatarInfo(path string) (AvatarInfo, error) {
 
 	return AvatarInfo{
 		ActualName: fileInfo.Name(),
+		Path:       path,
 		Num:        num,
 		Size:       fileInfo.Size(),
 		Hash:       hash,
@@ -3,20 +3,36 @@ package worker
 import (
 	"time"
 
+	"github.com/mioxin/kbempgo/internal/avatar"
+	httpclient "github.com/mioxin/kbempgo/internal/http_client"
+	"github.com/mioxin/kbempgo/internal/parser"
 	"github.com/mioxin/kbempgo/internal/storage"
 )
 
 type Config struct {
-	KbUrl            string        `name:"scrape-url" placeholder:"URL" help:"Base Url"`
-	UrlRazd          string        `name:"scrape-razd" env:"KB_URL_RAZD" help:"Url of section"`
-	UrlSotr          string        `name:"scrape-sotr" env:"KB_URL_SOTR" help:"Url of employer"`
-	UrlFio           string        `name:"scrape-fio" env:"KB_URL_FIO" help:"Url of employer full name"`
-	UrlMobile        string        `name:"scrape-mobil" env:"KB_URL_MOBIL" help:"Url of employer mobile"`
-	Avatars          string        `name:"scrape-avatars" env:"KB_AVATARS" help:"Directory for avatar images"`
-	HttpReqTimeout   time.Duration `name:"req-timeout" default:"6s" help:"Http request timeout for worker"`
-	DispPollInterval time.Duration `name:"disp-pollinterval" default:"300ms" help:"Polling interval for getting data from dispatcher queue"`
-	StorageURL       string        `name:"scrape-storage" env:"KB_STORAGE" help:"Storage connection string for scraped data. Example: postgres://localhost:5432/db, file:///home/user/dir"`
+	KbUrl          string        `name:"scrape-url" placeholder:"URL" help:"Base Url"`
+	UrlRazd        string        `name:"scrape-razd" env:"KB_URL_RAZD" help:"Url of section"`
+	UrlSotr        string        `name:"scrape-sotr" env:"KB_URL_SOTR" help:"Url of employer"`
+	UrlFio         string        `name:"scrape-fio" env:"KB_URL_FIO" help:"Url of employer full name"`
+	UrlMobile      string        `name:"scrape-mobil" env:"KB_URL_MOBIL" help:"Url of employer mobile"`
+	UrlNews        string        `name:"scrape-news" env:"KB_URL_NEWS" help:"Url of the page of news list, the number of the page is appended"`
+	UrlNewsItem    string        `name:"scrape-news-item" env:"KB_URL_NEWS_ITEM" help:"Url of the news, id of the news is appended"`
+	UrlComments    string        `name:"scrape-comments" env:"KB_URL_COMMENTS" help:"Url of comments of the news, id of the news is appended. If empty then comments are parsed from the page of the news."`
+	Avatars        string        `name:"scrape-avatars" env:"KB_AVATARS" help:"Directory for avatar images"`
+	HttpReqTimeout time.Duration `name:"req-timeout" default:"6s" help:"Http request timeout for worker"`
+	CacheDir       string        `name:"scrape-cache" env:"KB_CACHE" help:"Directory of cache of http responses and employees for skip not changed data. If empty then cache is disabled."`
+	CacheMaxAge    time.Duration `name:"scrape-cache-max-age" env:"KB_CACHE_MAX_AGE" default:"168h" help:"Max age of cached employees, older ones are requested again. If 0 then cached employees don't expire."`
+	StorageURL     string        `name:"scrape-storage" env:"KB_STORAGE" help:"Storage connection string for scraped data. Example: postgres://localhost:5432/db, file:///home/user/dir"`
 
-	Headers []string      `name:"scrape-headers" yaml:"headers" help:"Headers of http requsts as map[string]string in config file"`
-	Store   storage.Store `kong:"-"`
+	Limits  Limits                   `embed:"" prefix:"limit-"`
+	Fixture httpclient.FixtureConfig `embed:"" prefix:"fixture-"`
+	Parser  parser.Config            `embed:"" prefix:"parser-"`
+	Thumbs  avatar.ThumbConfig       `embed:"" prefix:"avatar-thumb-"`
+	// Fixtures are opened by the Fixture config and shared by workers
+	Fixtures *httpclient.Fixtures `kong:"-"`
+	Headers  []string             `name:"scrape-headers" yaml:"headers" help:"Headers of http requsts as map[string]string in config file"`
+	Store    storage.Store        `kong:"-"`
+
+	// Deprecated: workers take tasks from the shared frontier, the flag is kept for compatibility of command lines
+	DispPollInterval time.Duration `name:"disp-pollinterval" hidden:"" help:"Deprecated, not used"`
 }
@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"container/heap"
+	"slices"
+	"sync"
+)
+
+// Queue is a queue of tasks of razds or avatars shared by the pool of workers.
+// Every popped task should be finished by Finish or queued again by Retry.
+type Queue interface {
+	// SetPriority sets priority of the data, the priority is inherited by children tasks
+	SetPriority(data string, priority int)
+	// Push queues the task if its data isn't visited. It returns false for visited data.
+	Push(t Task) bool
+	// Retry queues the outstanding task again
+	Retry(t Task)
+	// Pop waits for a task. It returns false when the queue is closed.
+	Pop() (t Task, ok bool)
+	// Finish marks the popped task as finished
+	Finish(t Task)
+	// Close wakes up all waiting workers
+	Close()
+	// Complete marks the data of the task as successfully handled
+	Complete(data string)
+	// Completed returns data of successfully handled tasks
+	Completed() []string
+	// Len returns number of queued tasks
+	Len() int
+}
+
+// Outstanding reports outstanding tasks of the crawl
+type Outstanding interface {
+	// Pending returns number of outstanding tasks
+	Pending() int64
+	// Done returns a channel closed when all tasks are finished
+	Done() <-chan struct{}
+}
+
+// Frontier is a queue of tasks shared by the pool of workers.
+// Tasks are popped by priority and in BFS order within the same priority.
+// Already visited data (idr or avatar url) is not queued again.
+type Frontier struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   taskHeap
+	seq     uint64
+	visited map[string]struct{}
+	closed  bool
+	// data of successfully handled tasks
+	completed []string
+
+	// priorities of data, the priority is inherited by children tasks
+	priorities map[string]int
+	tasks      *Tracker
+}
+
+// NewFrontier creates the queue. Queued tasks are added to the tracker of outstanding tasks.
+func NewFrontier(tasks *Tracker) *Frontier {
+	f := &Frontier{
+		visited:    make(map[string]struct{}, 1000),
+		priorities: make(map[string]int),
+		tasks:      tasks,
+	}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// SetPriority sets priority of the data, e.g. a razd refreshed first with its subtree
+func (f *Frontier) SetPriority(data string, priority int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.priorities[data] = priority
+}
+
+// Push queues the task if its data isn't visited. It returns false for visited data.
+func (f *Frontier) Push(t Task) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.visited[t.Data]; ok || f.closed {
+		return false
+	}
+	f.visited[t.Data] = struct{}{}
+
+	f.tasks.Add(1)
+	f.push(t)
+	return true
+}
+
+// Retry queues the outstanding task again
+func (f *Frontier) Retry(t Task) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.push(t)
+}
+
+func (f *Frontier) push(t Task) {
+	if p, ok := f.priorities[t.Data]; ok && p > t.Priority {
+		t.Priority = p
+	}
+
+	f.seq++
+	heap.Push(&f.items, queued{Task: t, seq: f.seq})
+	f.cond.Signal()
+}
+
+// Pop waits for a task. It returns false when the frontier is closed.
+func (f *Frontier) Pop() (t Task, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.items) == 0 && !f.closed {
+		f.cond.Wait()
+	}
+
+	if f.closed {
+		return
+	}
+	return heap.Pop(&f.items).(queued).Task, true
+}
+
+// Finish marks the popped task as finished in the tracker
+func (f *Frontier) Finish(_ Task) {
+	f.tasks.Finish()
+}
+
+// Close wakes up all waiting workers, queued tasks are dropped
+func (f *Frontier) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	f.cond.Broadcast()
+}
+
+// Complete marks the data of the task as successfully handled
+func (f *Frontier) Complete(data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.completed = append(f.completed, data)
+}
+
+// Completed returns data of successfully handled tasks
+func (f *Frontier) Completed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.completed)
+}
+
+// Len returns number of queued tasks
+func (f *Frontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.items)
+}
+
+type queued struct {
+	Task
+	seq uint64
+}
+
+// taskHeap implements heap.Interface, the higher priority and the earlier queued task is the first
+type taskHeap []queued
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap) Push(x any) { *h = append(*h, x.(queued)) }
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	*h = old[:n-1]
+	return it
+}
@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrontierOrder(t *testing.T) {
+	tasks := NewTracker()
+	f := NewFrontier(tasks)
+	f.SetPriority("razd3", 1)
+
+	for _, d := range []string{"razd1", "razd2", "razd3", "razd1"} {
+		f.Push(*NewTask(d))
+	}
+	f.Push(Task{Data: "razd3-child", Priority: 1})
+
+	assert.Equal(t, 4, f.Len(), "visited razd should not be queued again")
+	assert.EqualValues(t, 4, tasks.Pending())
+
+	got := make([]string, 0, 4)
+	for range 4 {
+		task, ok := f.Pop()
+		require.True(t, ok)
+		got = append(got, task.Data)
+	}
+	assert.Equal(t, []string{"razd3", "razd3-child", "razd1", "razd2"}, got)
+}
+
+func TestFrontierRetry(t *testing.T) {
+	tasks := NewTracker()
+	f := NewFrontier(tasks)
+
+	require.True(t, f.Push(*NewTask("razd1")))
+	task, _ := f.Pop()
+
+	task.Num++
+	f.Retry(task)
+	assert.EqualValues(t, 1, tasks.Pending(), "retried task is still the same outstanding task")
+
+	task, ok := f.Pop()
+	require.True(t, ok)
+	assert.Equal(t, Task{Data: "razd1", Num: 1}, task)
+}
+
+func TestFrontierWait(t *testing.T) {
+	f := NewFrontier(NewTracker())
+
+	got := make(chan Task)
+	go func() {
+		task, _ := f.Pop()
+		got <- task
+	}()
+
+	select {
+	case <-got:
+		t.Fatal("Pop should wait for a task")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	f.Push(*NewTask("razd1"))
+	assert.Equal(t, "razd1", (<-got).Data)
+
+	closed := make(chan bool)
+	go func() {
+		_, ok := f.Pop()
+		closed <- ok
+	}()
+
+	f.Close()
+	assert.False(t, <-closed)
+	assert.False(t, f.Push(*NewTask("razd2")), "closed frontier should not queue tasks")
+}
+
+func TestTrackerFinish(t *testing.T) {
+	tasks := NewTracker()
+	tasks.Add(1)
+	tasks.Finish()
+	<-tasks.Done()
+
+	// the unbalanced finish is logged, the counter stays zero
+	tasks.Finish()
+	assert.EqualValues(t, 0, tasks.Pending())
+	tasks.Add(1)
+	assert.EqualValues(t, 1, tasks.Pending())
+}
@@ -0,0 +1,229 @@
+package worker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/imroc/req/v3"
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// HTTPCache is an on-disk cache of http responses keyed by URL and of prepared employees keyed by idr.
+// Cached responses are revalidated by If-None-Match / If-Modified-Since if the server supports it,
+// otherwise the new body is compared with the cached one by hash.
+// Prepared employees are expired by maxAge, so data of not changed razd entries is refetched eventually.
+// One HTTPCache can be shared by the pool of workers.
+type HTTPCache struct {
+	dir string
+	// max age of cached employees, they never expire if it's 0
+	maxAge time.Duration
+	lg     *slog.Logger
+}
+
+// cacheEntry is a file of the cache
+type cacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	// hash of the body of response or of the text of employee
+	Hash string `json:"hash"`
+	Body []byte `json:"body"`
+	// time of storing the entry
+	Time time.Time `json:"time"`
+}
+
+// NewHTTPCache creates the cache in the directory, cached employees are expired by maxAge
+func NewHTTPCache(dir string, maxAge time.Duration, lg *slog.Logger) (c *HTTPCache, err error) {
+	if err = os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("create http cache: %w", err)
+	}
+
+	return &HTTPCache{
+		dir:    dir,
+		maxAge: maxAge,
+		lg:     lg.With("cache", dir),
+	}, nil
+}
+
+// RoundTrip is middleware of the transport of req.Client.
+// Not modified responses are replaced by the cached ones, images are not cached.
+func (c *HTTPCache) RoundTrip(rt http.RoundTripper, stats *Stats) req.HttpRoundTripFunc {
+	return func(r *http.Request) (resp *http.Response, err error) {
+		if r.Method != http.MethodGet {
+			return rt.RoundTrip(r)
+		}
+
+		key := "url:" + r.URL.String()
+		e, cached := c.load(key)
+		if cached && (e.ETag != "" || e.LastModified != "") {
+			r = r.Clone(r.Context())
+			if e.ETag != "" {
+				r.Header.Set("If-None-Match", e.ETag)
+			}
+			if e.LastModified != "" {
+				r.Header.Set("If-Modified-Since", e.LastModified)
+			}
+		}
+
+		resp, err = rt.RoundTrip(r)
+		if err != nil {
+			return
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached:
+			resp.Body.Close()
+			stats.Cached.Add(1)
+			c.lg.Debug("Cache: not modified", "url", r.URL)
+			return e.response(resp), nil
+
+		case resp.StatusCode == http.StatusOK && !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/"):
+			body, e1 := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if e1 != nil {
+				return nil, e1
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := hashBytes(body)
+			if cached && e.Hash == hash {
+				stats.Cached.Add(1)
+				c.lg.Debug("Cache: body is not changed", "url", r.URL)
+				break
+			}
+
+			c.store(key, &cacheEntry{
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				ContentType:  resp.Header.Get("Content-Type"),
+				Hash:         hash,
+				Body:         body,
+			})
+		}
+		return
+	}
+}
+
+// Sotr returns the prepared employee if the text of the razd entry is not changed.
+// The employee parsed by other profile of the parser or older than maxAge isn't returned.
+func (c *HTTPCache) Sotr(profile, idr, text string) (sotr *kbv1.Sotr, ok bool) {
+	e, ok := c.load("sotr:" + idr)
+	if !ok || e.Hash != sotrHash(profile, text) {
+		return nil, false
+	}
+	if c.maxAge > 0 && time.Since(e.Time) > c.maxAge {
+		c.lg.Debug("Cache: sotr is expired", "idr", idr, "time", e.Time)
+		return nil, false
+	}
+
+	sotr = &kbv1.Sotr{}
+	if err := protojson.Unmarshal(e.Body, sotr); err != nil {
+		c.lg.Warn("Cache: invalid sotr", "idr", idr, "err", err)
+		return nil, false
+	}
+	return sotr, true
+}
+
+// SetSotr caches the employee prepared by the profile of the parser from the text of the razd entry
+func (c *HTTPCache) SetSotr(profile, idr, text string, sotr *kbv1.Sotr) {
+	b, err := protojson.Marshal(sotr)
+	if err != nil {
+		c.lg.Warn("Cache: marshal sotr", "idr", idr, "err", err)
+		return
+	}
+
+	c.store("sotr:"+idr, &cacheEntry{Hash: sotrHash(profile, text), Body: b})
+}
+
+// sotrHash is the hash of the razd entry with the profile of its parser
+func sotrHash(profile, text string) string {
+	return hashBytes([]byte(profile + "\n" + text))
+}
+
+// path returns the file of the key, files are spread over subdirectories by the first byte of hash
+func (c *HTTPCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name+".json")
+}
+
+func (c *HTTPCache) load(key string) (e *cacheEntry, ok bool) {
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return
+	}
+
+	e = &cacheEntry{}
+	if err = json.Unmarshal(b, e); err != nil {
+		c.lg.Warn("Cache: invalid entry", "key", key, "err", err)
+		return nil, false
+	}
+	return e, true
+}
+
+// store writes the entry to a temporary file and renames one, so readers never see a partial entry
+func (c *HTTPCache) store(key string, e *cacheEntry) {
+	path := c.path(key)
+	e.Time = time.Now()
+
+	err := func() error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return err
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(path), "entry-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+
+		if _, err = tmp.Write(b); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err = tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), path)
+	}()
+
+	if err != nil {
+		c.lg.Warn("Cache: store entry", "key", key, "err", err)
+	}
+}
+
+// response returns the cached response instead of not modified one
+func (e *cacheEntry) response(notModified *http.Response) *http.Response {
+	resp := *notModified
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 OK"
+	resp.Header = notModified.Header.Clone()
+	if e.ContentType != "" {
+		resp.Header.Set("Content-Type", e.ContentType)
+	}
+	resp.Header.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	resp.ContentLength = int64(len(e.Body))
+	resp.Body = io.NopCloser(bytes.NewReader(e.Body))
+	return &resp
+}
+
+func hashBytes(b []byte) string {
+	return strconv.FormatUint(xxhash.Sum64(b), 16)
+}
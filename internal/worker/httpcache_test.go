@@ -0,0 +1,152 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachedWorker(t *testing.T, srv *httptest.Server) *Worker {
+	t.Helper()
+
+	cache, err := NewHTTPCache(t.TempDir(), time.Hour, slog.Default())
+	require.NoError(t, err)
+
+	w := NewWorker(&Config{KbUrl: srv.URL, UrlRazd: "/razd/", UrlSotr: "/sotr/", UrlFio: "/fio/", UrlMobile: "/mobile/"},
+		"get", 0, slog.Default())
+	w.Cache = cache
+	return w
+}
+
+func TestHTTPCacheConditional(t *testing.T) {
+	var full atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "Иванов Иван Иванович")
+	}))
+	defer srv.Close()
+
+	w := newCachedWorker(t, srv)
+
+	for range 2 {
+		text, err := w.getData(context.Background(), w.Conf.UrlFio, "Иванов И.")
+		require.NoError(t, err)
+		assert.Equal(t, "Иванов Иван Иванович", text)
+	}
+
+	assert.EqualValues(t, 1, full.Load(), "the second response should be not modified")
+	assert.EqualValues(t, 1, w.Stats.Cached.Load())
+}
+
+func TestHTTPCacheHash(t *testing.T) {
+	body := "v1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("If-None-Match"))
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	w := newCachedWorker(t, srv)
+
+	for _, b := range []string{"v1", "v1", "v2"} {
+		body = b
+		text, err := w.getData(context.Background(), w.Conf.UrlFio, "q")
+		require.NoError(t, err)
+		assert.Equal(t, b, text)
+	}
+
+	// the second body is the same as cached one
+	assert.EqualValues(t, 1, w.Stats.Cached.Load())
+}
+
+func TestPrepareItemCached(t *testing.T) {
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	w := newCachedWorker(t, srv)
+	w.Coverage = parser.NewCoverage(w.Parser.Profile())
+
+	dep := func(text string) *kbv1.Dep {
+		return &kbv1.Dep{Idr: "s1", Parent: "razd1",
+			Text: fmt.Sprintf(`<tr data-tabnum="1"><img src="/avatar/1.jpg"><td width="300" class="s_1">%s<span`, text)}
+	}
+
+	first := w.PrepareItem(context.Background(), dep("Иванов И."))
+	n := requests.Load()
+	require.Positive(t, n)
+
+	second := w.PrepareItem(context.Background(), dep("Иванов И."))
+	assert.Equal(t, n, requests.Load(), "not changed employee should be got from the cache")
+	assert.Equal(t, first.(*kbv1.Sotr).Name, second.(*kbv1.Sotr).Name)
+	assert.EqualValues(t, 1, w.Stats.Cached.Load())
+	assert.Equal(t, 2, w.Coverage.Sotrs, "cached employee should be counted by the coverage")
+
+	// the employee parsed by other profile is parsed again
+	w.Parser = &parser.Index{Name: parser.EngineIndex, Version: 2}
+	w.PrepareItem(context.Background(), dep("Иванов И."))
+	assert.Greater(t, requests.Load(), n, "employee of other profile should be requested")
+
+	n = requests.Load()
+	w.PrepareItem(context.Background(), dep("Петров П."))
+	assert.Greater(t, requests.Load(), n, "changed employee should be requested")
+}
+
+func TestPrepareItemExpired(t *testing.T) {
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	w := newCachedWorker(t, srv)
+	dep := &kbv1.Dep{Idr: "s1", Parent: "razd1",
+		Text: `<tr data-tabnum="1"><img src="/avatar/1.jpg"><td width="300" class="s_1">Иванов И.<span`}
+
+	w.PrepareItem(context.Background(), dep)
+	n := requests.Load()
+	w.PrepareItem(context.Background(), dep)
+	require.Equal(t, n, requests.Load(), "fresh employee should be got from the cache")
+
+	// the employee cached before the max age is stale
+	key := "sotr:" + dep.Idr
+	e, ok := w.Cache.load(key)
+	require.True(t, ok)
+	e.Time = time.Now().Add(-2 * time.Hour)
+	b, err := json.Marshal(e)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(w.Cache.path(key), b, 0o644))
+
+	_, ok = w.Cache.Sotr(w.Parser.Profile(), dep.Idr, dep.Text)
+	assert.False(t, ok)
+	w.PrepareItem(context.Background(), dep)
+	assert.Greater(t, requests.Load(), n, "stale employee should be requested")
+
+	// the refetched employee is cached again
+	_, ok = w.Cache.Sotr(w.Parser.Profile(), dep.Idr, dep.Text)
+	assert.True(t, ok)
+}
@@ -0,0 +1,231 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imroc/req/v3"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// minimal rate of throttled endpoint, requests per second
+	minRate rate.Limit = 0.1
+	// pause of endpoint on throttle without Retry-After header
+	defaultPause = 2 * time.Second
+	// max pause of endpoint by Retry-After header
+	maxPause = time.Minute
+	// rate is increased on every success response by this factor until the configured one
+	recoverFactor = 1.1
+)
+
+// EndpointLimit configures requests to an endpoint
+type EndpointLimit struct {
+	Rate        float64 `name:"rate" default:"10" help:"Max requests per second, 0 is unlimited"`
+	Burst       int     `name:"burst" default:"5" help:"Burst of requests over the rate"`
+	MaxInFlight int     `name:"inflight" default:"5" help:"Max number of requests in flight, 0 is unlimited"`
+}
+
+// Limits of requests per endpoint. Limits are shared by all workers.
+type Limits struct {
+	Razd   EndpointLimit `embed:"" prefix:"razd-"`
+	Sotr   EndpointLimit `embed:"" prefix:"sotr-"`
+	Fio    EndpointLimit `embed:"" prefix:"fio-"`
+	Mobile EndpointLimit `embed:"" prefix:"mobile-"`
+	Avatar EndpointLimit `embed:"" prefix:"avatar-"`
+}
+
+// Limiter is token bucket rate limiter with a cap of requests in flight.
+// It slows down on 429 and 5xx responses and pauses requests for Retry-After.
+type Limiter struct {
+	Name string
+
+	rl   *rate.Limiter
+	base rate.Limit
+	sem  chan struct{}
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+	// number of throttles in a row for backoff of pause
+	throttles int
+}
+
+func NewLimiter(name string, cfg EndpointLimit) *Limiter {
+	l := &Limiter{
+		Name: name,
+		base: rate.Inf,
+	}
+
+	if cfg.Rate > 0 {
+		l.base = rate.Limit(cfg.Rate)
+	}
+	l.rl = rate.NewLimiter(l.base, max(cfg.Burst, 1))
+
+	if cfg.MaxInFlight > 0 {
+		l.sem = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+// Acquire waits for a pause, a token and a slot for request.
+// The returned release func should be called after the request.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	release = func() {
+		if l.sem != nil {
+			<-l.sem
+		}
+	}
+
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if pause > 0 {
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	if err = l.rl.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return
+}
+
+// Observe adapts the rate by the response: slows down and pauses on 429 and 5xx,
+// recovers the rate on success.
+func (l *Limiter) Observe(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		l.throttles++
+
+		pause, ok := retryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			pause = min(defaultPause*time.Duration(1<<min(l.throttles-1, 5)), maxPause)
+		}
+		l.pausedUntil = time.Now().Add(pause)
+
+		if l.base != rate.Inf {
+			l.rl.SetLimit(max(l.rl.Limit()/2, minRate))
+		}
+		return
+	}
+
+	l.throttles = 0
+	if cur := l.rl.Limit(); cur < l.base {
+		l.rl.SetLimit(min(cur*recoverFactor, l.base))
+	}
+}
+
+// Limit returns the current rate of the limiter
+func (l *Limiter) Limit() rate.Limit {
+	return l.rl.Limit()
+}
+
+// retryAfter parses Retry-After header as seconds or HTTP date
+func retryAfter(h string) (d time.Duration, ok bool) {
+	if h == "" {
+		return
+	}
+
+	if sec, err := strconv.Atoi(h); err == nil {
+		return min(time.Duration(sec)*time.Second, maxPause), sec >= 0
+	}
+
+	if t, err := http.ParseTime(h); err == nil {
+		return min(max(time.Until(t), 0), maxPause), true
+	}
+	return
+}
+
+// Limiters of the endpoints. Requests are matched to the endpoint by the url path,
+// requests of other paths (avatars) use Avatar limiter.
+type Limiters struct {
+	endpoints []endpoint
+	Avatar    *Limiter
+}
+
+type endpoint struct {
+	path    string
+	limiter *Limiter
+}
+
+// NewLimiters creates limiters of the endpoints configured by conf
+func NewLimiters(conf *Config) *Limiters {
+	ls := &Limiters{Avatar: NewLimiter("avatar", conf.Limits.Avatar)}
+
+	for _, e := range []struct {
+		name, url string
+		limit     EndpointLimit
+	}{
+		{"razd", conf.UrlRazd, conf.Limits.Razd},
+		{"sotr", conf.UrlSotr, conf.Limits.Sotr},
+		{"fio", conf.UrlFio, conf.Limits.Fio},
+		{"mobile", conf.UrlMobile, conf.Limits.Mobile},
+	} {
+		if e.url == "" {
+			continue
+		}
+
+		path := e.url
+		if u, err := url.Parse(e.url); err == nil {
+			path = u.Path
+		}
+		ls.endpoints = append(ls.endpoints, endpoint{path: path, limiter: NewLimiter(e.name, e.limit)})
+	}
+	return ls
+}
+
+// For returns limiter of the endpoint by the longest matched path
+func (ls *Limiters) For(u *url.URL) *Limiter {
+	l, matched := ls.Avatar, 0
+
+	for _, e := range ls.endpoints {
+		if e.path != "" && len(e.path) > matched && strings.HasPrefix(u.Path, e.path) {
+			l, matched = e.limiter, len(e.path)
+		}
+	}
+	return l
+}
+
+// RoundTrip is middleware of req.Client limiting requests
+func (ls *Limiters) RoundTrip(rt req.RoundTripper) req.RoundTripFunc {
+	return func(r *req.Request) (resp *req.Response, err error) {
+		l := ls.For(r.URL)
+
+		release, err := l.Acquire(r.Context())
+		if err != nil {
+			return
+		}
+		defer release()
+
+		resp, err = rt.RoundTrip(r)
+		if resp != nil {
+			l.Observe(resp.Response)
+		}
+		return
+	}
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestLimitersFor(t *testing.T) {
+	ls := NewLimiters(&Config{
+		UrlRazd:   "/api/razd/",
+		UrlSotr:   "/api/razd/sotr/",
+		UrlFio:    "http://kb.local/api/fio?q=",
+		UrlMobile: "",
+	})
+
+	for path, name := range map[string]string{
+		"/api/razd/razd1":      "razd",
+		"/api/razd/sotr/12345": "sotr",
+		"/api/fio":             "fio",
+		"/avatar/12345.jpg":    "avatar",
+	} {
+		assert.Equal(t, name, ls.For(&url.URL{Path: path}).Name, path)
+	}
+}
+
+func TestLimiterInFlight(t *testing.T) {
+	l := NewLimiter("test", EndpointLimit{MaxInFlight: 1})
+
+	release, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = l.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release, err = l.Acquire(context.Background())
+	require.NoError(t, err)
+	release()
+}
+
+func TestLimiterThrottle(t *testing.T) {
+	l := NewLimiter("test", EndpointLimit{Rate: 100, Burst: 1})
+
+	l.Observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}})
+	assert.Equal(t, rate.Limit(50), l.Limit())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	// paused by Retry-After
+	_, err := l.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	l.Observe(&http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}})
+	assert.Equal(t, rate.Limit(25), l.Limit())
+
+	// rate is recovered by success responses up to the configured one
+	for range 20 {
+		l.Observe(&http.Response{StatusCode: http.StatusOK})
+	}
+	assert.Equal(t, rate.Limit(100), l.Limit())
+}
+
+func TestRetryAfter(t *testing.T) {
+	d, ok := retryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	d, ok = retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, maxPause, d)
+
+	_, ok = retryAfter("soon")
+	assert.False(t, ok)
+}
+
+func TestWorkerLimited(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	conf := &Config{
+		KbUrl:  srv.URL,
+		UrlFio: "/fio/",
+		Limits: Limits{Fio: EndpointLimit{Rate: 1000, Burst: 1, MaxInFlight: 2}},
+	}
+	limiters := NewLimiters(conf)
+
+	done := make(chan struct{})
+	for i := range 3 {
+		w := NewWorker(conf, "get", 0, slog.Default())
+		w.Limiters = limiters
+
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for range 3 {
+				_, err := w.getData(context.Background(), conf.UrlFio, string(rune('a'+i)))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	for range 3 {
+		<-done
+	}
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(2))
+}
+
+func TestGetRazdThrottled(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message":"too many requests"}`))
+			return
+		}
+		w.Write([]byte(`[{"id":"razd1","parent":"root","text":"Отдел 1","children":true}]`))
+	}))
+	defer srv.Close()
+
+	w := NewWorker(&Config{KbUrl: srv.URL, UrlRazd: "/razd/"}, "get", 0, slog.Default())
+	w.MaxDepth = 1
+	w.Razds.Push(*NewTask("root"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out := make(chan models.Item, 1)
+	errCh := make(chan error, 1)
+	var deps, sotrs atomic.Int32
+	go func() { errCh <- w.GetRazd(ctx, out, 0, &deps, &sotrs) }()
+
+	// 429 is retried, the crawl isn't stopped
+	select {
+	case item := <-out:
+		assert.Equal(t, "razd1", item.(*kbv1.Dep).Idr)
+	case err := <-errCh:
+		t.Fatal("razd should be retried after 429:", err)
+	case <-ctx.Done():
+		t.Fatal("razd is not received")
+	}
+	assert.EqualValues(t, 2, calls.Load())
+
+	w.Razds.Close()
+	assert.NoError(t, <-errCh)
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+
+	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+)
+
+// GetNewsList gets news of the page of the news list. Pages are numbered from 1.
+func (w *Worker) GetNewsList(ctx context.Context, page int) (news []*kbv1.News, err error) {
+	if w.NewsParser == nil {
+		return nil, fmt.Errorf("news parser is not set")
+	}
+
+	text, err := w.getPage(ctx, w.Conf.UrlNews+strconv.Itoa(page))
+	if err != nil {
+		return nil, fmt.Errorf("get news list page %d: %w", page, err)
+	}
+
+	news = w.NewsParser.ParseList(html.UnescapeString(text))
+	w.Lg.Debug("Worker news: list", "page", page, "news", len(news))
+	return
+}
+
+// GetNews fills the news by its page and comments
+func (w *Worker) GetNews(ctx context.Context, n *kbv1.News) (err error) {
+	if w.NewsParser == nil {
+		return fmt.Errorf("news parser is not set")
+	}
+
+	n.Url = w.Conf.UrlNewsItem + url.PathEscape(n.Idn)
+	text, err := w.getPage(ctx, n.Url)
+	if err != nil {
+		return fmt.Errorf("get news %s: %w", n.Idn, err)
+	}
+	w.NewsParser.ParseNews(n, html.UnescapeString(text))
+
+	if w.Conf.UrlComments != "" {
+		text, err = w.getPage(ctx, w.Conf.UrlComments+url.PathEscape(n.Idn))
+		if err != nil {
+			return fmt.Errorf("get comments of news %s: %w", n.Idn, err)
+		}
+		n.Comments = w.NewsParser.ParseComments(html.UnescapeString(text))
+	}
+
+	w.Lg.Debug("Worker news:", "idn", n.Idn, "title", n.Title, "comments", len(n.Comments))
+	return
+}
+
+// getPage gets the page, statuses out of 2xx are errors
+func (w *Worker) getPage(ctx context.Context, pageURL string) (body string, err error) {
+	w.Stats.Requests.Add(1)
+	resp, err := w.httpClient.R().SetContext(ctx).Get(pageURL)
+	if err != nil {
+		w.Stats.Errors.Add(1)
+		return "", err
+	}
+
+	if !resp.IsSuccessState() {
+		w.Stats.Errors.Add(1)
+		return "", fmt.Errorf("status %s", resp.Status)
+	}
+	return resp.String(), nil
+}
@@ -0,0 +1,18 @@
+package worker
+
+import "sync/atomic"
+
+// Stats are counters of worker's requests. One Stats can be shared by the pool of workers.
+type Stats struct {
+	// http requests of razd, employee data and avatars
+	Requests atomic.Int64
+	// failed requests and razds out of retry limit
+	Errors atomic.Int64
+	// retries of empty razd and unsuccess mobile
+	Retries atomic.Int64
+	// responses not changed since the cached ones and employees with not changed razd entry
+	Cached atomic.Int64
+	// downloaded avatars and their size
+	Avatars     atomic.Int64
+	AvatarBytes atomic.Int64
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Tracker counts outstanding tasks of the crawl: queued, in flight and waiting for retry.
+// The crawl is completed when the last task is finished.
+type Tracker struct {
+	// Lg logs unbalanced finishes of tasks
+	Lg *slog.Logger
+
+	n    atomic.Int64
+	once sync.Once
+	done chan struct{}
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{Lg: slog.Default(), done: make(chan struct{})}
+}
+
+// Add adds n new tasks. It should be called before the parent task is finished.
+func (t *Tracker) Add(n int) {
+	t.n.Add(int64(n))
+}
+
+// Finish marks the task as finished. The finish without outstanding tasks is logged,
+// the counter isn't less than zero.
+func (t *Tracker) Finish() {
+	for {
+		n := t.n.Load()
+		if n <= 0 {
+			t.Lg.Error("Finish of the task without outstanding tasks", "pending", n)
+			return
+		}
+		if t.n.CompareAndSwap(n, n-1) {
+			if n == 1 {
+				t.once.Do(func() { close(t.done) })
+			}
+			return
+		}
+	}
+}
+
+// Pending returns number of outstanding tasks
+func (t *Tracker) Pending() int64 {
+	return t.n.Load()
+}
+
+// Done returns a channel closed when all tasks are finished
+func (t *Tracker) Done() <-chan struct{} {
+	return t.done
+}
@@ -1,24 +1,25 @@
 package worker
 
 import (
-	"container/list"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"html"
 	"log/slog"
+	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/imroc/req/v3"
 	kbv1 "github.com/mioxin/kbempgo/api/kbemp/v1"
+	"github.com/mioxin/kbempgo/internal/avatar"
 	httpclient "github.com/mioxin/kbempgo/internal/http_client"
 	"github.com/mioxin/kbempgo/internal/models"
+	"github.com/mioxin/kbempgo/internal/parser"
 	"github.com/mioxin/kbempgo/internal/utils"
 	"google.golang.org/protobuf/proto"
 )
@@ -28,51 +29,16 @@ type Task struct {
 	Data string
 	// Number of try to get response
 	Num int
+	// Tasks with higher priority are got first, children of razd inherit its priority
+	Priority int
+	// Level of razd below the start one
+	Depth int
+	// Tabnum of the employee of the avatar
+	Tabnum string
 }
 
 func NewTask(data string) *Task {
-	return &Task{data, 0}
-}
-
-type Queue struct {
-	list *list.List
-	mu   sync.Mutex
-}
-
-func NewQueue() *Queue {
-	return &Queue{
-		list: list.New(),
-	}
-}
-func (q *Queue) Push(data string) {
-	q.withLock(func() {
-		q.list.PushBack(data)
-	})
-}
-
-func (q *Queue) Pop() string {
-	var val string
-	q.withLock(func() {
-		if q.list.Len() == 0 {
-			return
-		}
-		val = q.list.Remove(q.list.Front()).(string)
-	})
-	return val
-}
-
-func (q *Queue) Len() int {
-	var length int
-	q.withLock(func() {
-		length = q.list.Len()
-	})
-	return length
-}
-
-func (q *Queue) withLock(fn func()) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
-	fn()
+	return &Task{Data: data}
 }
 
 type ReqMessageError struct {
@@ -110,35 +76,77 @@ type Item interface {
 }
 
 type Worker struct {
-	Name         string
-	QueueDep     *Queue
-	QueueAvatar  *Queue
-	Conf         *Config
-	Lg           *slog.Logger
-	httpClient   *req.Client
-	PollInterval *time.Duration
+	Name       string
+	Conf       *Config
+	Lg         *slog.Logger
+	httpClient *req.Client
+	Stats      *Stats
+	// limiters of requests, they should be shared by the pool of workers
+	Limiters *Limiters
+	// queues of razds and avatars, they should be shared by the pool of workers.
+	// Queues count outstanding tasks of the crawl.
+	Razds   Queue
+	Avatars Queue
+	// MaxDepth limits levels of razds got below the start ones, 0 is unlimited
+	MaxDepth int
+	// Cache of responses and employees, it should be shared by the pool of workers. Nil disables the cache.
+	Cache *HTTPCache
+	// Parser of the source html, the legacy index parser by default
+	Parser parser.Parser
+	// NewsParser parses news pages, news are not got if it's nil
+	NewsParser *parser.News
+	// AvatarStore keeps downloaded avatars, it should be shared by the pool of workers.
+	// Avatars are not downloaded if it's nil.
+	AvatarStore *avatar.Store
+	// Coverage counts filled fields of parsed employees, it should be shared by the pool of workers.
+	// Nil disables counting.
+	Coverage *parser.Coverage
 }
 
 func NewWorker(conf *Config, name string, debugLevel int, logger *slog.Logger) *Worker {
 	lg := logger.With("worker", name)
-	cli := httpclient.NewHTTPClient(debugLevel, conf.Headers)
+	cli := httpclient.NewHTTPClient(debugLevel, conf.Headers, conf.Fixtures)
+
+	tasks := NewTracker()
+	tasks.Lg = lg
+	w := &Worker{
+		Name:       name,
+		Conf:       conf,
+		Lg:         lg,
+		httpClient: cli,
+		Stats:      &Stats{},
+		Limiters:   NewLimiters(conf),
+		Razds:      NewFrontier(tasks),
+		Avatars:    NewFrontier(tasks),
+		Parser:     &parser.Index{Name: parser.EngineIndex, Version: 1},
+	}
+
 	cli.SetBaseURL(conf.KbUrl).
 		SetTimeout(conf.HttpReqTimeout).
-		SetLogger(&ReqLogger{Logger: *lg})
-
-	return &Worker{
-		Name:         name,
-		QueueDep:     NewQueue(),
-		QueueAvatar:  NewQueue(),
-		Conf:         conf,
-		Lg:           lg,
-		httpClient:   cli,
-		PollInterval: &conf.DispPollInterval,
-	}
+		SetLogger(&ReqLogger{Logger: *lg}).
+		// limiters can be replaced by shared ones after creating of the worker
+		WrapRoundTripFunc(func(rt req.RoundTripper) req.RoundTripFunc {
+			return func(r *req.Request) (*req.Response, error) {
+				return w.Limiters.RoundTrip(rt)(r)
+			}
+		})
+
+	cli.GetTransport().
+		WrapRoundTripFunc(func(rt http.RoundTripper) req.HttpRoundTripFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				if w.Cache == nil {
+					return rt.RoundTrip(r)
+				}
+				return w.Cache.RoundTrip(rt, w.Stats)(r)
+			}
+		})
+
+	return w
 }
 
-// GetRazd ...
-func (w *Worker) GetRazd(ctx context.Context, in chan Task, out chan models.Item, limit int32, depsCount *atomic.Int32, sotrsCount *atomic.Int32) (err error) {
+// GetRazd gets children of razd tasks from w.Razds until it is closed.
+// The task is finished after its children are queued or it is out of retry limit.
+func (w *Worker) GetRazd(ctx context.Context, out chan models.Item, limit int32, depsCount *atomic.Int32, sotrsCount *atomic.Int32) (err error) {
 	var (
 		errMsg *ReqMessageError
 		raw    []json.RawMessage
@@ -148,178 +156,136 @@ func (w *Worker) GetRazd(ctx context.Context, in chan Task, out chan models.Item
 	cli := w.httpClient
 
 	for {
-		select {
+		task, ok := w.Razds.Pop()
+		if !ok {
+			return ctx.Err()
+		}
 
-		case task, ok := <-in:
-			if !ok {
-				return
-			}
-			cnt := depsCount.Load() + sotrsCount.Load()
+		cnt := depsCount.Load() + sotrsCount.Load()
 
-			if limit > 0 && cnt > limit {
-				w.Lg.Info("Worker: Count limited", "count", cnt)
-				return &TaskLimitExceededError{val: int(cnt)}
-			}
+		if limit > 0 && cnt > limit {
+			w.Lg.Info("Worker: Count limited", "count", cnt)
+			return &TaskLimitExceededError{val: int(cnt)}
+		}
 
-			if task.Num > TryLimit {
-				w.Lg.Warn("Worker: Out of retry limit", "try", task.Num, "req_dep", task.Data)
-				break
-			}
+		if task.Num > TryLimit {
+			w.Lg.Warn("Worker: Out of retry limit", "try", task.Num, "req_dep", task.Data)
+			w.Stats.Errors.Add(1)
+			w.Razds.Finish(task)
+			continue
+		}
 
-			w.Lg.Debug("Worker:", "dep", task.Data, "try", task.Num)
-
-			// DepsResponse := make([]*kbv1.Dep, 0)
-
-			// retry if successful req but empty DepsResponse
-			resp, e := cli.R().
-				SetErrorResult(&errMsg). // Unmarshal response body into errMsg automatically if status code >= 400.
-				EnableDump().            // Enable dump at request level, only print dump content if there is an error or some unknown situation occurs to help troubleshoot.
-				// SetSuccessResult(&DepsResponse). // Unmarshal response body into userInfo automatically if status code is between 200 and 299.
-				SetContext(ctx).
-				Get(w.Conf.UrlRazd + task.Data)
-
-			select {
-			case <-ctx.Done():
-				w.Lg.Info("Worker: cancel done", "err", ctx.Err().Error())
-				return ctx.Err()
-			default:
-				if e != nil {
-					w.Lg.Debug("Worker: error handling", "resp dump", resp.Dump()) // Record raw content when error occurs.
-					err = e
-					return
-				}
-			}
+		w.Lg.Debug("Worker:", "dep", task.Data, "try", task.Num)
+
+		// DepsResponse := make([]*kbv1.Dep, 0)
 
-			if resp.IsErrorState() { // Status code >= 400.
-				w.Lg.Error("Worker:", "err", errMsg.Message) // Record error message returned.
-				err = errMsg
+		// retry if successful req but empty DepsResponse
+		w.Stats.Requests.Add(1)
+		resp, e := cli.R().
+			SetErrorResult(&errMsg). // Unmarshal response body into errMsg automatically if status code >= 400.
+			EnableDump().            // Enable dump at request level, only print dump content if there is an error or some unknown situation occurs to help troubleshoot.
+			// SetSuccessResult(&DepsResponse). // Unmarshal response body into userInfo automatically if status code is between 200 and 299.
+			SetContext(ctx).
+			Get(w.Conf.UrlRazd + task.Data)
+
+		select {
+		case <-ctx.Done():
+			w.Lg.Info("Worker: cancel done", "err", ctx.Err().Error())
+			return ctx.Err()
+		default:
+			if e != nil {
+				w.Lg.Debug("Worker: error handling", "resp dump", resp.Dump()) // Record raw content when error occurs.
+				w.Stats.Errors.Add(1)
+				err = e
 				return
 			}
+		}
 
-			if resp.IsSuccessState() { // Status code is between 200 and 299.
-
-				body, e := resp.ToBytes()
-				if e != nil {
-					w.Lg.Error("Worker: get body:", "err", e, "delay", resp.TotalTime())
-				}
+		if resp.IsErrorState() { // Status code >= 400.
+			w.Lg.Error("Worker:", "err", errMsg.Message) // Record error message returned.
+			w.Stats.Errors.Add(1)
+			err = errMsg
+			return
+		}
 
-				if e := json.Unmarshal(body, &raw); e != nil {
-					w.Lg.Error("Worker: unmurshal body to []Raw:", "err", e, "delay", resp.TotalTime())
-					break
-				}
+		if resp.IsSuccessState() { // Status code is between 200 and 299.
 
-				DepsResponse := make([]*kbv1.Dep, len(raw))
-				// opts := &protojson.UnmarshalOptions{DiscardUnknown: true}
+			body, e := resp.ToBytes()
+			if e != nil {
+				w.Lg.Error("Worker: get body:", "err", e, "delay", resp.TotalTime())
+			}
 
-				for i, rm := range raw {
-					dep := &models.Dep{} // Новое сообщение
-					if e := json.Unmarshal([]byte(rm), dep); e != nil {
-						w.Lg.Error("Worker: unmurshal []Raw :", "err", e, "delay", resp.TotalTime())
-						continue
-					}
-					DepsResponse[i] = dep.Conv2Kbv().GetDep()
-				}
+			if e := json.Unmarshal(body, &raw); e != nil {
+				w.Lg.Error("Worker: unmurshal body to []Raw:", "err", e, "delay", resp.TotalTime())
+				w.Razds.Finish(task)
+				continue
+			}
 
-				// construct string for debug output
-				rBytes := []byte{}
-				for _, dep := range DepsResponse {
-					rBytes = append(rBytes, []byte(dep.Idr)...)
-					rBytes = append(rBytes, []byte("; ")...)
-				}
+			DepsResponse := make([]*kbv1.Dep, len(raw))
+			// opts := &protojson.UnmarshalOptions{DiscardUnknown: true}
 
-				w.Lg.Debug("Worker: responce:", "razd", string(rBytes), "DepsResponse_length", len(DepsResponse), "delay", resp.TotalTime())
-
-				// retry get razd
-				if len(DepsResponse) == 0 { // && resp.TotalTime() > 4*time.Second {
-					w.Lg.Warn("Worker: Empty response ", "try", task.Num, "req_dep", task.Data, "resp", resp.Dump(), "delay", resp.TotalTime())
-
-					// requeue with backoff in a goroutine to avoid blocking the caller
-					go func(data string, num int) {
-						backoff := min(time.Duration(1<<num)*time.Second, 10*time.Second)
-						select {
-						case <-time.After(backoff):
-						case <-ctx.Done():
-							return
-						}
-						select {
-						case in <- Task{data, num}:
-						case <-ctx.Done():
-						}
-					}(task.Data, task.Num+1)
+			for i, rm := range raw {
+				dep := &models.Dep{} // Новое сообщение
+				if e := json.Unmarshal([]byte(rm), dep); e != nil {
+					w.Lg.Error("Worker: unmurshal []Raw :", "err", e, "delay", resp.TotalTime())
 					continue
 				}
+				DepsResponse[i] = dep.Conv2Kbv().GetDep()
+			}
 
-				for _, d := range DepsResponse {
-					if d.GetChildren() {
-						w.QueueDep.Push(d.Idr)
-						depsCount.Add(1)
-					} else {
-						sotrsCount.Add(1)
-					}
+			// construct string for debug output
+			rBytes := []byte{}
+			for _, dep := range DepsResponse {
+				rBytes = append(rBytes, []byte(dep.Idr)...)
+				rBytes = append(rBytes, []byte("; ")...)
+			}
+
+			w.Lg.Debug("Worker: responce:", "razd", string(rBytes), "DepsResponse_length", len(DepsResponse), "delay", resp.TotalTime())
 
+			// retry get razd
+			if len(DepsResponse) == 0 { // && resp.TotalTime() > 4*time.Second {
+				w.Lg.Warn("Worker: Empty response ", "try", task.Num, "req_dep", task.Data, "resp", resp.Dump(), "delay", resp.TotalTime())
+				w.Stats.Retries.Add(1)
+
+				// requeue with backoff in a goroutine to avoid blocking the caller,
+				// the task is still outstanding
+				go func(t Task) {
+					backoff := min(time.Duration(1<<t.Num)*time.Second, 10*time.Second)
 					select {
-					case out <- w.PrepareItem(ctx, d):
+					case <-time.After(backoff):
+						w.Razds.Retry(t)
 					case <-ctx.Done():
 					}
-				}
+				}(Task{Data: task.Data, Num: task.Num + 1, Priority: task.Priority, Depth: task.Depth})
+				continue
 			}
 
-			lenQ := w.QueueDep.Len()
-			w.Lg.Debug("Worker Len of QueueDep:", "len", lenQ)
-
-		case <-ctx.Done():
-			return ctx.Err()
-		}
-	}
-	return
-}
-
-// Dispatcher wolking accross worker queues for forwarding idr/avatar to worker input chanal
-func (w *Worker) Dispatcher(ctx context.Context, dispName string, queue *Queue, out chan<- Task) { // , isData <-chan struct{}
-	var (
-		count, lenQ int
-		s, message  string
-		timeStart   time.Time
-	)
-
-	w.Lg.Debug(fmt.Sprintf("Dispatcher %s: Getting data from Queue...", dispName))
-	defer func() {
-		w.Lg.Debug(fmt.Sprintf("Dispatcher %s: END %s", dispName, message))
-	}()
-
-	ticker := time.NewTicker(*w.PollInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			for queue.Len() > 0 {
-				timeStart = time.Now()
-				// get data from queue
-				s = queue.Pop()
+			for _, d := range DepsResponse {
+				if d.GetChildren() {
+					if w.MaxDepth > 0 && task.Depth+1 >= w.MaxDepth {
+						w.Lg.Debug("Worker: skip razd deeper than limit", "razd", d.Idr, "depth", task.Depth+1)
+					} else if !w.Razds.Push(Task{Data: d.Idr, Priority: task.Priority, Depth: task.Depth + 1}) {
+						w.Lg.Debug("Worker: skip visited razd", "razd", d.Idr)
+					}
+					depsCount.Add(1)
+				} else {
+					sotrsCount.Add(1)
+				}
 
-				// send data to out chanal (razdCh)
 				select {
-				case out <- *NewTask(s):
-					count++
-
+				case out <- w.PrepareItem(ctx, d):
 				case <-ctx.Done():
-					// return task to queue
-					queue.Push(s)
-					// TODO save queue for continue in the next run
-					lenQ = queue.Len()
-					w.Lg.Info(fmt.Sprintf("Dispatcher %s: general cancel:", dispName), "err", ctx.Err().Error(), "QueueLen", lenQ) // , "DepsResponseQueueLen", lenQD)
-					return
 				}
-				lenQ = queue.Len()
-				w.Lg.Info(fmt.Sprintf("Dispatcher %s: Get from queue", dispName), "count", count, "data", s, "QueueLen", lenQ, "delay", time.Since(timeStart))
 			}
 
-		case <-ctx.Done():
-			message = "with general cancel in loop"
-
-			return
+			// all children of razd are sent
+			if ctx.Err() == nil {
+				w.Razds.Complete(task.Data)
+			}
 		}
+
+		w.Lg.Debug("Worker Len of razd frontier:", "len", w.Razds.Len())
+		w.Razds.Finish(task)
 	}
 }
 
@@ -330,10 +296,26 @@ func (w *Worker) PrepareItem(ctx context.Context, item models.Item) models.Item
 	dep.Text = html.UnescapeString(dep.Text)
 
 	if !item.GetChildren() {
-		sotr := utils.ParseSotr(dep.Text)
+		// requests of employee data are skipped if the razd entry is not changed
+		if w.Cache != nil {
+			if sotr, ok := w.Cache.Sotr(w.Parser.Profile(), dep.Idr, dep.Text); ok {
+				w.Lg.Debug("Sotr is not changed", "idr", dep.Idr, "tabnum", sotr.Tabnum)
+				w.Stats.Cached.Add(1)
+				w.Avatars.Push(Task{Data: sotr.Avatar, Tabnum: sotr.Tabnum})
+				if w.Coverage != nil {
+					w.Coverage.Add(sotr)
+				}
+				return sotr
+			}
+		}
+
+		// employee is cached if all data are got
+		complete := true
+
+		sotr := w.Parser.ParseSotr(dep.Text)
 
 		// send url Avatar image to queue for download
-		w.QueueAvatar.Push(sotr.Avatar)
+		w.Avatars.Push(Task{Data: sotr.Avatar, Tabnum: sotr.Tabnum})
 
 		sotr.Children = dep.Children
 		sotr.Idr = dep.Idr
@@ -343,8 +325,9 @@ func (w *Worker) PrepareItem(ctx context.Context, item models.Item) models.Item
 		text, err := w.getData(ctx, w.Conf.UrlFio, sotr.Name)
 		if err != nil {
 			w.Lg.Error("Get middle name:", "err", err.Error())
+			complete = false
 		} else {
-			sotr.MidName = utils.ParseMidName(sotr, html.UnescapeString(text))
+			sotr.MidName = w.Parser.ParseMidName(sotr, html.UnescapeString(text))
 		}
 
 		if sotr.MidName == "" {
@@ -357,6 +340,10 @@ func (w *Worker) PrepareItem(ctx context.Context, item models.Item) models.Item
 		text = ""
 		text, err = w.getData(ctx, w.Conf.UrlSotr, sotr.Tabnum)
 
+		if err != nil {
+			complete = false
+		}
+
 		if err != nil || !utils.HasValidMobile(text) {
 			w.Lg.Error("Mobile data not found", "sotr_name", sotrFullName, "tabnum", sotr.Tabnum, "err", err, "text", text)
 		} else {
@@ -368,6 +355,7 @@ func (w *Worker) PrepareItem(ctx context.Context, item models.Item) models.Item
 
 				if err != nil {
 					w.Lg.Error("Mobile not found", "sotr_name", sotrFullName, "tabnum", sotr.Tabnum, "err", err)
+					complete = false
 					break
 				}
 
@@ -375,11 +363,16 @@ func (w *Worker) PrepareItem(ctx context.Context, item models.Item) models.Item
 
 				if err != nil {
 					w.Lg.Error("Mobile parsing", "sotr_name", sotrFullName, "text", text, "err", err)
+					complete = false
 					break
 				}
 
 				if !mob.Success {
+					if i+1 == ATTEMPT {
+						complete = false
+					}
 					w.Lg.Warn(fmt.Sprintf("#%d: Mobile get unsuccess", i+1), "sotr_name", sotrFullName, "tabnum", sotr.Tabnum, "responce", slog.String("message", text)) //html.UnescapeString(text))
+					w.Stats.Retries.Add(1)
 					time.Sleep(time.Duration(1<<(7+i)) * time.Millisecond)
 					continue
 				}
@@ -392,6 +385,13 @@ func (w *Worker) PrepareItem(ctx context.Context, item models.Item) models.Item
 
 			}
 		}
+
+		if w.Cache != nil && complete {
+			w.Cache.SetSotr(w.Parser.Profile(), dep.Idr, dep.Text, sotr)
+		}
+		if w.Coverage != nil {
+			w.Coverage.Add(sotr)
+		}
 		item = sotr
 	}
 	// _, err := w.Conf.Store.Save(ctx, item)
@@ -408,6 +408,7 @@ func (w *Worker) getData(_ context.Context, ajaxUrl, query string) (string, erro
 	qURL := fmt.Sprintf("%s%s", ajaxUrl, url.PathEscape(query))
 
 	cli := w.httpClient
+	w.Stats.Requests.Add(1)
 	resp, err := cli.R().
 		SetErrorResult(&errMsg). // Unmarshal response body into errMsg automatically if status code >= 400.
 		//	SetContext(ctx).
@@ -416,10 +417,12 @@ func (w *Worker) getData(_ context.Context, ajaxUrl, query string) (string, erro
 	if err != nil { // Error handling.
 		w.Lg.Debug("Get Data: raw content", "url", qURL, "resp_dump", resp.Dump()) // Record raw content when error occurs.
 		err = fmt.Errorf("get url %s: error handling %w", qURL, err)
+		w.Stats.Errors.Add(1)
 	}
 
 	if resp.IsErrorState() { // Status code >= 400.
 		w.Lg.Error(errMsg.Message) // Record error message returned.
+		w.Stats.Errors.Add(1)
 	}
 
 	if resp.IsSuccessState() { // Status code is between 200 and 299.
@@ -430,96 +433,109 @@ func (w *Worker) getData(_ context.Context, ajaxUrl, query string) (string, erro
 	return (body), err
 }
 
-func (w *Worker) GetAvatar(ctx context.Context, in <-chan Task, limit int32,
-	depsCount *atomic.Int32, sotrsCount *atomic.Int32, fileCollection map[string]AvatarInfo) error {
-	var errMsg ReqMessageError
-
+// GetAvatar downloads avatars from w.Avatars until it is closed
+func (w *Worker) GetAvatar(ctx context.Context, limit int32, depsCount *atomic.Int32, sotrsCount *atomic.Int32) error {
 	w.Lg.Debug("Worker avatar: Start Getting...")
 
 	defer func() {
 		w.Lg.Debug("Worker avatar: END...")
 	}()
 
-	cli := w.httpClient
-
-	callback := func(info req.DownloadInfo) {
-		if info.Response.Response != nil {
-			w.Lg.Debug(fmt.Sprintf("downloaded %.2f%% (%s)\n", float64(info.DownloadedSize)/float64(info.Response.ContentLength)*100.0, info.Response.Request.URL.String()))
-		}
-	}
-
 	for {
-		select {
-		case task := <-in:
-			ava := task.Data
-
-			cnt := depsCount.Load() + sotrsCount.Load()
-			if limit > 0 && cnt > limit {
-				w.Lg.Info("Worker avatar: Count limited", "count", cnt)
-				return &TaskLimitExceededError{val: int(cnt)}
-			}
-
-			w.Lg.Debug("Worker avatar:", "avatar", ava)
+		task, ok := w.Avatars.Pop()
+		if !ok {
+			return ctx.Err()
+		}
 
-			// get head for compare file size
-			r, e := cli.R().
-				// SetContext(ctx).
-				Head(ava)
+		cnt := depsCount.Load() + sotrsCount.Load()
+		if limit > 0 && cnt > limit {
+			w.Lg.Info("Worker avatar: Count limited", "count", cnt)
+			return &TaskLimitExceededError{val: int(cnt)}
+		}
 
-			if e != nil {
-				w.Lg.Error("Worker avatar: get head", "error", e.Error(), "avatar", ava)
+		if w.AvatarStore != nil {
+			if err := w.getAvatar(task); err != nil {
+				w.Lg.Error("Worker avatar:", "avatar", task.Data, "err", err)
 			}
+		}
+		w.Avatars.Finish(task)
+	}
+}
 
-			filename := filepath.Join(w.Conf.Avatars, ava)
-
-			key := strings.Split(filepath.Base(ava), ".")[0]
-			avaInfo, ok := fileCollection[key]
-
-			if ok {
-				if r.ContentLength == avaInfo.Size {
-					w.Lg.Debug("Worker avatar: Skip existing >>>", "file", ava, "avaInfo", avaInfo)
-					continue
-				}
-				// new name like "dir/8768768 (2).jpg"
-				filename = filepath.Join(filepath.Dir(filename),
-					fmt.Sprintf("%s (%d)%s",
-						key,
-						avaInfo.Num+1,
-						filepath.Ext(avaInfo.ActualName)))
-			}
+// getAvatar downloads the avatar to the store. The avatar downloaded before is revalidated
+// by ETag or Last-Modified, the image is stored by hash of the content, so the same image is stored once.
+func (w *Worker) getAvatar(task Task) (err error) {
+	var errMsg ReqMessageError
 
-			tFilename := filename + ".tmp"
+	ava, tabnum := task.Data, task.Tabnum
+	if tabnum == "" {
+		// avatars are named by tabnum
+		tabnum = strings.Split(filepath.Base(ava), ".")[0]
+	}
 
-			resp, err := cli.R().
-				SetErrorResult(&errMsg). // Unmarshal response body into errMsg automatically if status code >= 400.
-				SetOutputFile(tFilename).
-				SetDownloadCallback(callback).
-				Get(ava)
-			if err != nil { // Error handling.
-				w.Lg.Error("Worker avatar: request handling", "error", err)
+	w.Lg.Debug("Worker avatar:", "avatar", ava, "tabnum", tabnum)
 
-				err = os.Remove(tFilename)
-				if err != nil {
-					w.Lg.Error("Worker avatar: delete temp file", "error", err)
-				}
-			}
+	r := w.httpClient.R().SetErrorResult(&errMsg) // Unmarshal response body into errMsg automatically if status code >= 400.
 
-			if resp.IsErrorState() { // Status code >= 400.
-				w.Lg.Error("Worker avatar:", "err", errMsg.Message) // Record error message returned.
-			}
+	meta, cached := w.AvatarStore.Meta(tabnum)
+	if cached {
+		if meta.ETag != "" {
+			r.SetHeader("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			r.SetHeader("If-Modified-Since", meta.LastModified)
+		}
+	}
 
-			if resp.IsSuccessState() { // Status code is between 200 and 299.
-				w.Lg.Info("Worker avatar: downloaded", "avatar", ava, "syze", resp.ContentLength, "file_name", filename, "delay", resp.TotalTime())
+	w.Stats.Requests.Add(1)
+	resp, err := r.Get(ava)
+	if err != nil {
+		w.Stats.Errors.Add(1)
+		return fmt.Errorf("request handling: %w", err)
+	}
 
-				err = os.Rename(tFilename, filename)
-				if err != nil {
-					w.Lg.Error("Worker avatar: rename temp file", "error", err)
-				}
-			}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		w.Lg.Debug("Worker avatar: not modified", "avatar", ava, "hash", meta.Hash)
+		w.Stats.Cached.Add(1)
+		w.thumbnails(meta.Hash)
+		return w.AvatarStore.Seen(meta, time.Now())
+
+	case resp.IsSuccessState():
+		hash, size, e := w.AvatarStore.Put(bytes.NewReader(resp.Bytes()))
+		if e != nil {
+			return e
+		}
 
-		case <-ctx.Done():
-			return ctx.Err()
+		if cached && meta.Hash == hash {
+			w.Lg.Debug("Worker avatar: not changed", "avatar", ava, "hash", hash)
+			w.Stats.Cached.Add(1)
+		} else {
+			w.Lg.Info("Worker avatar: downloaded", "avatar", ava, "size", size, "hash", hash, "delay", resp.TotalTime())
+			w.Stats.Avatars.Add(1)
+			w.Stats.AvatarBytes.Add(size)
 		}
+		w.thumbnails(hash)
+
+		return w.AvatarStore.Seen(&avatar.Meta{
+			Tabnum:       tabnum,
+			Hash:         hash,
+			Size:         size,
+			ContentType:  resp.Header.Get("Content-Type"),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}, time.Now())
+
+	default:
+		w.Stats.Errors.Add(1)
+		return fmt.Errorf("status %s: %s", resp.Status, errMsg.Message)
+	}
+}
+
+// thumbnails makes missing thumbnails of the avatar. The avatar is kept without thumbnails
+// if the image can't be decoded.
+func (w *Worker) thumbnails(hash string) {
+	if err := w.AvatarStore.Thumbnails(hash); err != nil {
+		w.Lg.Warn("Worker avatar: thumbnails", "hash", hash, "err", err)
 	}
-	return nil
 }
@@ -56,3 +56,19 @@ func (c *ClientConfig) UniversalOptions() *redis.UniversalOptions {
 
 	return ret
 }
+
+// ParseURL makes the config of simple redis by URL: redis://[user:password@]host:port[/db]
+func ParseURL(url string) (*ClientConfig, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ClientConfig{}
+	c.SetDefaults()
+	c.Addrs = []string{opts.Addr}
+	c.DB = opts.DB
+	c.Username = opts.Username
+	c.Password = opts.Password
+	return c, nil
+}